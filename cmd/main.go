@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"github.com/spf13/viper"
+	"github.com/vkuzmich/gin-project/config"
 	"github.com/vkuzmich/gin-project/internal/app"
 	"github.com/vkuzmich/gin-project/internal/http"
 	"github.com/vkuzmich/gin-project/pkg/db"
+	"github.com/vkuzmich/gin-project/pkg/service"
 	"log"
 )
 
@@ -20,12 +23,29 @@ func main() {
 	port := viper.GetString("PORT")
 	dbUrl := viper.GetString("DB_URL")
 
+	var cfg config.Config
+	if err := viper.Unmarshal(&cfg); err != nil {
+		log.Fatalf("Error parsing config: %v", err)
+	}
+
 	dbConnection, err := db.Init(dbUrl)
 	if err != nil {
 		log.Fatalf("Error initializing: %v", err)
 	}
 
-	appInstance := app.Build(dbConnection)
+	appInstance := app.Build(dbConnection, cfg)
+
+	// The rows older than accounts stay without an owner until the
+	// operator names one
+	if cfg.LegacyOwnerEmail != "" {
+		if err := appInstance.UserService().AssignLegacyOwner(context.Background(), cfg.LegacyOwnerEmail); err != nil {
+			log.Fatalf("Error assigning legacy rows: %v", err)
+		}
+	}
+
+	sweeper := service.NewTrashSweeper(appInstance.TodoTaskRepository(), appInstance.AttachmentService(), appInstance.IdempotencyService(), cfg.TrashRetention, cfg.TrashSweepInterval)
+	go sweeper.Run(context.Background())
+
 	router := http.NewRouter(appInstance)
 
 	err = router.Run(port)
@@ -1,10 +1,79 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+    "time"
+
+    "github.com/spf13/viper"
+)
 
 type Config struct {
     Port  string `mapstructure:"PORT"`
     DBUrl string `mapstructure:"DB_URL"`
+
+    // RequireIfMatch rejects mutations without an If-Match header with
+    // 428 Precondition Required instead of applying them unconditionally.
+    RequireIfMatch bool `mapstructure:"REQUIRE_IF_MATCH"`
+
+    // TrashRetention is how long a deleted todo_task stays restorable
+    // before the sweeper purges it, checked every TrashSweepInterval.
+    // Zero keeps trashed todo_tasks forever.
+    TrashRetention     time.Duration `mapstructure:"TRASH_RETENTION"`
+    TrashSweepInterval time.Duration `mapstructure:"TRASH_SWEEP_INTERVAL"`
+
+    // IdempotencyTTL is how long the response to a request sent with an
+    // Idempotency-Key is replayed to its retries.
+    IdempotencyTTL time.Duration `mapstructure:"IDEMPOTENCY_TTL"`
+
+    // TodoTaskWorkflow lists the allowed status transitions as
+    // "from:to|to;from:to", empty uses the default workflow.
+    TodoTaskWorkflow string `mapstructure:"TODO_TASK_WORKFLOW"`
+
+    // SubtaskDeletePolicy is what deleting a todo_task does to its
+    // subtasks: "orphan" (the default) keeps them as top-level todo_tasks,
+    // "cascade" deletes them too.
+    SubtaskDeletePolicy string `mapstructure:"SUBTASK_DELETE_POLICY"`
+
+    // AttachmentMaxSize is the largest accepted attachment in bytes, zero
+    // means 10 MiB.
+    AttachmentMaxSize int64 `mapstructure:"ATTACHMENT_MAX_SIZE"`
+
+    // AttachmentStorage is where the content of attachments is kept:
+    // "local" (the default) writes below AttachmentDir, "s3" to the S3
+    // compatible bucket described by the S3 settings.
+    AttachmentStorage string `mapstructure:"ATTACHMENT_STORAGE"`
+    AttachmentDir     string `mapstructure:"ATTACHMENT_DIR"`
+
+    S3Endpoint  string `mapstructure:"S3_ENDPOINT"`
+    S3Region    string `mapstructure:"S3_REGION"`
+    S3Bucket    string `mapstructure:"S3_BUCKET"`
+    S3AccessKey string `mapstructure:"S3_ACCESS_KEY"`
+    S3SecretKey string `mapstructure:"S3_SECRET_KEY"`
+
+    // SessionTTL is how long the access token returned by a login stays
+    // valid, zero means 24 hours.
+    SessionTTL time.Duration `mapstructure:"SESSION_TTL"`
+
+    // LoginMaxFailures failed logins in a row lock an account for
+    // LoginLockout. Zero values mean 5 failures and 15 minutes.
+    LoginMaxFailures int           `mapstructure:"LOGIN_MAX_FAILURES"`
+    LoginLockout     time.Duration `mapstructure:"LOGIN_LOCKOUT"`
+
+    // JWTJWKS is the file path or http(s) URL of the JWK set JWTs are
+    // verified with, loaded again every JWTJWKSRefresh (5 minutes when
+    // zero). Empty only accepts the session tokens of /auth/login.
+    JWTJWKS        string        `mapstructure:"JWT_JWKS"`
+    JWTJWKSRefresh time.Duration `mapstructure:"JWT_JWKS_REFRESH"`
+
+    // JWTIssuer and JWTAudience are the required iss and aud of JWTs, not
+    // checked when empty. JWTClockSkew is the tolerance of exp and nbf.
+    JWTIssuer    string        `mapstructure:"JWT_ISSUER"`
+    JWTAudience  string        `mapstructure:"JWT_AUDIENCE"`
+    JWTClockSkew time.Duration `mapstructure:"JWT_CLOCK_SKEW"`
+
+    // LegacyOwnerEmail is the registered user given the default workspace
+    // and its todo_tasks and projects older than accounts at startup.
+    // Empty leaves them without an owner.
+    LegacyOwnerEmail string `mapstructure:"LEGACY_OWNER_EMAIL"`
 }
 
 func LoadConfig() (c Config, err error) {
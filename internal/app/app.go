@@ -1,22 +1,64 @@
 package app
 
 import (
+	"fmt"
+
+	"github.com/vkuzmich/gin-project/config"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
 	"github.com/vkuzmich/gin-project/pkg/repository"
 	"github.com/vkuzmich/gin-project/pkg/service"
+	"github.com/vkuzmich/gin-project/pkg/storage"
 	"gorm.io/gorm"
 )
 
 var _ Interface = (*App)(nil)
 
 type Interface interface {
+	Config() config.Config
 	TodoTaskRepository() repository.TodoTaskRepository
 	TodoTaskService() service.TodoTaskService
+	IdempotencyService() service.IdempotencyService
+	TagService() service.TagService
+	CommentService() service.CommentService
+	AttachmentService() service.AttachmentService
+	ProjectRepository() repository.ProjectRepository
+	ProjectService() service.ProjectService
+	UserRepository() repository.UserRepository
+	UserService() service.UserService
+	ShareService() service.ShareService
+	WorkspaceService() service.WorkspaceService
+	JWTVerifier() *auth.JWTVerifier
 }
 
 type App struct {
+	config config.Config
+
 	todoTaskService service.TodoTaskService
 
 	todoTaskRepository repository.TodoTaskRepository
+
+	idempotencyService service.IdempotencyService
+
+	tagService service.TagService
+
+	commentService service.CommentService
+
+	attachmentService service.AttachmentService
+
+	projectService service.ProjectService
+
+	projectRepository repository.ProjectRepository
+
+	userService service.UserService
+
+	userRepository repository.UserRepository
+
+	shareService service.ShareService
+
+	workspaceService service.WorkspaceService
+
+	jwtVerifier *auth.JWTVerifier
 }
 
 //func (a *App) TodoTaskRepository() repository.TodoTaskRepository {
@@ -29,6 +71,10 @@ type App struct {
 //	panic("implement me")
 //}
 
+func (a *App) Config() config.Config {
+	return a.config
+}
+
 func (a *App) TodoTaskRepository() repository.TodoTaskRepository {
 	return a.todoTaskRepository
 }
@@ -37,17 +83,147 @@ func (a *App) TodoTaskService() service.TodoTaskService {
 	return a.todoTaskService
 }
 
-func Build(db *gorm.DB) *App {
+func (a *App) IdempotencyService() service.IdempotencyService {
+	return a.idempotencyService
+}
+
+func (a *App) TagService() service.TagService {
+	return a.tagService
+}
+
+func (a *App) CommentService() service.CommentService {
+	return a.commentService
+}
+
+func (a *App) AttachmentService() service.AttachmentService {
+	return a.attachmentService
+}
+
+func (a *App) ProjectRepository() repository.ProjectRepository {
+	return a.projectRepository
+}
+
+func (a *App) ProjectService() service.ProjectService {
+	return a.projectService
+}
+
+func (a *App) UserRepository() repository.UserRepository {
+	return a.userRepository
+}
+
+func (a *App) UserService() service.UserService {
+	return a.userService
+}
+
+func (a *App) ShareService() service.ShareService {
+	return a.shareService
+}
+
+func (a *App) WorkspaceService() service.WorkspaceService {
+	return a.workspaceService
+}
+
+// JWTVerifier is nil when no JWK set is configured.
+func (a *App) JWTVerifier() *auth.JWTVerifier {
+	return a.jwtVerifier
+}
+
+func Build(db *gorm.DB, cfg config.Config) *App {
+	workflow, err := model.ParseTodoTaskWorkflow(cfg.TodoTaskWorkflow)
+	if err != nil {
+		panic(fmt.Sprintf("invalid TODO_TASK_WORKFLOW: %v", err))
+	}
+	deletePolicy, err := model.ParseSubtaskDeletePolicy(cfg.SubtaskDeletePolicy)
+	if err != nil {
+		panic(fmt.Sprintf("invalid SUBTASK_DELETE_POLICY: %v", err))
+	}
+	blobStore, err := newBlobStore(cfg)
+	if err != nil {
+		panic(fmt.Sprintf("invalid ATTACHMENT_STORAGE: %v", err))
+	}
+	// Before any repository queries the database
+	if err := repository.ScopeByWorkspace(db); err != nil {
+		panic(fmt.Sprintf("unable to scope the database by workspace: %v", err))
+	}
 
 	var (
 		todoTaskRepository = repository.NewTodoTaskRepository(db)
-		todoTaskService    = service.NewTodoTaskService(todoTaskRepository)
+		storage            = repository.NewStorage(db)
+		todoTaskService    = service.NewTodoTaskService(todoTaskRepository, storage, workflow, deletePolicy)
+
+		idempotencyKeyRepository = repository.NewIdempotencyKeyRepository(db)
+		idempotencyService       = service.NewIdempotencyService(idempotencyKeyRepository, cfg.IdempotencyTTL)
+
+		tagRepository = repository.NewTagRepository(db)
+		tagService    = service.NewTagService(tagRepository)
+
+		commentRepository = repository.NewCommentRepository(db)
+		commentService    = service.NewCommentService(commentRepository)
+
+		attachmentRepository = repository.NewAttachmentRepository(db)
+		attachmentService    = service.NewAttachmentService(attachmentRepository, blobStore, cfg.AttachmentMaxSize)
+
+		projectRepository = repository.NewProjectRepository(db)
+		projectService    = service.NewProjectService(projectRepository, todoTaskRepository)
+
+		userRepository = repository.NewUserRepository(db)
+		userService    = service.NewUserService(userRepository, auth.DefaultPasswordParams, service.LoginPolicy{
+			SessionTTL:  cfg.SessionTTL,
+			MaxFailures: cfg.LoginMaxFailures,
+			Lockout:     cfg.LoginLockout,
+		})
+
+		workspaceRepository = repository.NewWorkspaceRepository(db)
+		workspaceService    = service.NewWorkspaceService(workspaceRepository, userRepository)
+
+		shareRepository = repository.NewShareRepository(db)
+		shareService    = service.NewShareService(shareRepository, userRepository, workspaceRepository)
 	)
 
 	app := &App{
+		config:             cfg,
 		todoTaskRepository: todoTaskRepository,
 		todoTaskService:    todoTaskService,
+		idempotencyService: idempotencyService,
+		tagService:         tagService,
+		commentService:     commentService,
+		attachmentService:  attachmentService,
+		projectRepository:  projectRepository,
+		projectService:     projectService,
+		userRepository:     userRepository,
+		userService:        userService,
+		shareService:       shareService,
+		workspaceService:   workspaceService,
+	}
+	if cfg.JWTJWKS != "" {
+		app.jwtVerifier = auth.NewJWTVerifier(auth.NewJWKSSource(cfg.JWTJWKS, cfg.JWTJWKSRefresh, nil), auth.JWTConfig{
+			Issuer:   cfg.JWTIssuer,
+			Audience: cfg.JWTAudience,
+			Skew:     cfg.JWTClockSkew,
+		})
 	}
 
 	return app
 }
+
+// newBlobStore returns the store of attachment content selected by
+// cfg.AttachmentStorage.
+func newBlobStore(cfg config.Config) (storage.BlobStore, error) {
+	switch cfg.AttachmentStorage {
+	case "", "local":
+		dir := cfg.AttachmentDir
+		if dir == "" {
+			dir = "attachments"
+		}
+		return storage.NewLocalBlobStore(dir), nil
+	case "s3":
+		return storage.NewS3BlobStore(storage.S3Config{
+			Endpoint:  cfg.S3Endpoint,
+			Region:    cfg.S3Region,
+			Bucket:    cfg.S3Bucket,
+			AccessKey: cfg.S3AccessKey,
+			SecretKey: cfg.S3SecretKey,
+		}, nil)
+	}
+	return nil, fmt.Errorf("unknown storage %q, allowed: local, s3", cfg.AttachmentStorage)
+}
@@ -2,23 +2,62 @@ package http
 
 import (
 	"fmt"
+	"maps"
+
 	"github.com/gin-gonic/gin"
 	"github.com/vkuzmich/gin-project/internal/app"
 	"github.com/vkuzmich/gin-project/internal/middleware"
+	"github.com/vkuzmich/gin-project/internal/openapi"
 	"github.com/vkuzmich/gin-project/internal/routes"
 )
 
+// APIInfo is the info object of the served OpenAPI document.
+var APIInfo = openapi.Info{Title: "gin-project todo_tasks API", Version: "1.0.0"}
+
 func NewRouter(a app.Interface) *gin.Engine {
 
 	var (
-		todoTaskService = a.TodoTaskService()
+		todoTaskService    = a.TodoTaskService()
+		idempotencyService = a.IdempotencyService()
+		tagService         = a.TagService()
+		commentService     = a.CommentService()
+		attachmentService  = a.AttachmentService()
+		projectService     = a.ProjectService()
+		userService        = a.UserService()
+		shareService       = a.ShareService()
+		workspaceService   = a.WorkspaceService()
 	)
 	router := gin.Default()
-	router.Use(middleware.RouteMiddleware())
+	router.Use(middleware.RequestID(), middleware.ErrorHandler())
 
-	v := router.Group("")
+	routes.RegisterAuthHandlers(router.Group(""), userService)
+
+	// Every other route acts on behalf of the user of the access token, in
+	// one of their workspaces.
+	v := router.Group("", middleware.Authenticate(userService, a.JWTVerifier()), middleware.Workspace(workspaceService))
 	fmt.Println("Starting application...v", v)
 
-	routes.RegisterTodoTaskHandlers(v, todoTaskService)
+	routes.RegisterTodoTaskHandlers(v, todoTaskService, idempotencyService, a.Config())
+	routes.RegisterTagHandlers(v, tagService)
+	routes.RegisterCommentHandlers(v, commentService)
+	routes.RegisterAttachmentHandlers(v, attachmentService, a.Config())
+	routes.RegisterProjectHandlers(v, projectService)
+	routes.RegisterShareHandlers(v, shareService)
+	routes.RegisterWorkspaceHandlers(v, workspaceService)
+	routes.RegisterAccessTokenHandlers(v, userService)
+
+	// The document describes the routes above, so it is served last.
+	operations := routes.TodoTaskOperations()
+	maps.Copy(operations, routes.TagOperations())
+	maps.Copy(operations, routes.CommentOperations())
+	maps.Copy(operations, routes.AttachmentOperations())
+	maps.Copy(operations, routes.ProjectOperations())
+	maps.Copy(operations, routes.ShareOperations())
+	maps.Copy(operations, routes.WorkspaceOperations())
+	maps.Copy(operations, routes.AuthOperations())
+	maps.Copy(operations, routes.AccessTokenOperations())
+	if err := openapi.Serve(router, APIInfo, operations); err != nil {
+		panic(err)
+	}
 	return router
 }
@@ -0,0 +1,129 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/config"
+	"github.com/vkuzmich/gin-project/internal/app"
+	"github.com/vkuzmich/gin-project/internal/openapi"
+	"github.com/vkuzmich/gin-project/pkg/db"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// TestOpenAPIMatchesRoutes fails when the document does not describe
+// exactly the routes of the router, by method and path.
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{TranslateError: true})
+	require.NoError(t, err)
+
+	router := NewRouter(app.Build(db, config.Config{}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, openapi.SpecPath, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc openapi.Document
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, openapi.Version, doc.OpenAPI)
+
+	var documented []string
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+	// Every route but the two serving the documentation itself
+	var registered []string
+	pathParam := regexp.MustCompile(`[:*](\w+)`)
+	for _, route := range router.Routes() {
+		if route.Path == openapi.SpecPath || route.Path == openapi.DocsPath {
+			continue
+		}
+		registered = append(registered, route.Method+" "+pathParam.ReplaceAllString(route.Path, "{$1}"))
+	}
+	assert.NotEmpty(t, registered)
+	assert.ElementsMatch(t, registered, documented)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, openapi.DocsPath, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "openapi.json")
+}
+
+// TestScopeEnforcement checks that a personal access token only reaches
+// the routes of its scopes, whichever way the router dispatches them.
+func TestScopeEnforcement(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	sqliteDB, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{TranslateError: true})
+	require.NoError(t, err)
+	conn, err := sqliteDB.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	require.NoError(t, db.AutoMigration(sqliteDB))
+	router := NewRouter(app.Build(sqliteDB, config.Config{}))
+
+	send := func(token, method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(w, r)
+		return w
+	}
+	decode := func(w *httptest.ResponseRecorder, v interface{}) {
+		t.Helper()
+		require.Less(t, w.Code, 300, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), v))
+	}
+
+	credentials := `{"email":"ada@example.com","password":"violet tulip harbor"}`
+	require.Equal(t, http.StatusOK, send("", http.MethodPost, "/auth/register", credentials).Code)
+	var login struct {
+		AccessToken string `json:"access_token"`
+	}
+	decode(send("", http.MethodPost, "/auth/login", credentials), &login)
+	pat := func(scopes string) string {
+		var created struct {
+			Token string `json:"token"`
+		}
+		decode(send(login.AccessToken, http.MethodPost, "/auth/tokens", `{"name":"ci","scopes":`+scopes+`}`), &created)
+		return created.Token
+	}
+	read, write := pat(`["tasks:read"]`), pat(`["tasks:read","tasks:write"]`)
+	var todoTask struct {
+		ID uint `json:"id"`
+	}
+	decode(send(login.AccessToken, http.MethodPost, "/todo_tasks/", `{"title":"t","description":"d","status":"todo"}`), &todoTask)
+	item := "/todo_tasks/" + strconv.Itoa(int(todoTask.ID))
+	bulkDelete := `{"operations":[{"op":"delete","id":` + strconv.Itoa(int(todoTask.ID)) + `}]}`
+
+	assert.Equal(t, http.StatusOK, send(read, http.MethodGet, item, "").Code)
+	for _, tc := range []struct {
+		name, token, method, target, body, scope string
+	}{
+		{"put", read, http.MethodPut, item, `{"title":"t","description":"d","status":"todo"}`, "tasks:write"},
+		{"patch", read, http.MethodPatch, item, `{"title":"t"}`, "tasks:write"},
+		{"delete", read, http.MethodDelete, item, "", "tasks:delete"},
+		{"bulk delete", read, http.MethodPost, "/todo_tasks/bulk", bulkDelete, "tasks:write"},
+		{"bulk delete with write", write, http.MethodPost, "/todo_tasks/bulk", bulkDelete, "tasks:delete"},
+	} {
+		w := send(tc.token, tc.method, tc.target, tc.body)
+		assert.Equal(t, http.StatusForbidden, w.Code, tc.name)
+		assert.Equal(t, `Bearer realm="todo_tasks", error="insufficient_scope", scope="`+tc.scope+`"`, w.Header().Get("WWW-Authenticate"), tc.name)
+	}
+	// The todo_task survived every refused request
+	assert.Equal(t, http.StatusOK, send(read, http.MethodGet, item, "").Code)
+}
@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/service"
+)
+
+// AuthenticateHeader asks the client for a bearer token in 401 responses.
+const AuthenticateHeader = `Bearer realm="todo_tasks"`
+
+// Authenticate requires a valid "Authorization: Bearer <token>" header
+// and stores the principal of the token in the request context, where the
+// services and repositories pick it up to act on behalf of the caller.
+//
+// The token is either a session token returned by /auth/login or, when
+// jwtVerifier is not nil, a JWT whose sub is the id of the user.
+func Authenticate(userService service.UserService, jwtVerifier *auth.JWTVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := BearerToken(c)
+		if !ok {
+			c.Header("WWW-Authenticate", AuthenticateHeader)
+			_ = c.Error(service.NewError(service.ErrUnauthorized, "an access token is required", nil))
+			c.Abort()
+			return
+		}
+
+		var principal auth.Principal
+		var err error
+		if jwtVerifier != nil && auth.IsJWT(token) {
+			principal, err = verifyJWT(c, jwtVerifier, token)
+		} else {
+			principal, err = userService.Authenticate(c, token)
+		}
+		if err != nil {
+			contextLogger.ContextLog(c).Info().Err(err).Msg("request with invalid access token")
+			var domainErr *service.Error
+			if errors.As(err, &domainErr) && domainErr.Kind == service.ErrUnauthorized {
+				// RFC 6750 section 3.1
+				c.Header("WWW-Authenticate", fmt.Sprintf(`%s, error="invalid_token", error_description="%s"`,
+					AuthenticateHeader, strings.ReplaceAll(domainErr.Detail, `"`, "'")))
+			}
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
+
+func verifyJWT(c *gin.Context, jwtVerifier *auth.JWTVerifier, token string) (auth.Principal, error) {
+	claims, err := jwtVerifier.Verify(c, token)
+	if err == nil {
+		var principal auth.Principal
+		if principal, err = claims.Principal(); err == nil {
+			return principal, nil
+		}
+	}
+	if errors.Is(err, auth.ErrInvalidToken) {
+		return auth.Principal{}, service.NewError(service.ErrUnauthorized, err.Error(), err)
+	}
+	return auth.Principal{}, service.NewError(service.ErrUnavailable, "the signing keys could not be loaded", err)
+}
+
+// BearerToken returns the token of the Authorization header, if it holds
+// one.
+func BearerToken(c *gin.Context) (string, bool) {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	token = strings.TrimSpace(token)
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", false
+	}
+	return token, true
+}
+
+// RequireScope declares the scope a route belongs to. Principals
+// restricted to scopes, such as personal access tokens, are refused with
+// 403 unless they hold it. It must run after Authenticate.
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if principal, ok := auth.PrincipalFromContext(c); ok && !principal.HasScope(scope) {
+			InsufficientScope(c, scope)
+			return
+		}
+		c.Next()
+	}
+}
+
+// InsufficientScope refuses the request for lacking scope, for handlers
+// that find out which scope they need from the request body.
+func InsufficientScope(c *gin.Context, scope string) {
+	// RFC 6750 section 3.1
+	c.Header("WWW-Authenticate", fmt.Sprintf(`%s, error="insufficient_scope", scope="%s"`, AuthenticateHeader, scope))
+	_ = c.Error(service.NewError(service.ErrForbidden, "the access token lacks the scope "+scope, nil))
+	c.Abort()
+}
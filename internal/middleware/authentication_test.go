@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+	"github.com/vkuzmich/gin-project/pkg/service"
+)
+
+// testKeys serves the keys of a parsed JWK set.
+type testKeys struct{ *auth.KeySet }
+
+func (k testKeys) Key(_ context.Context, kid string) (auth.Key, error) {
+	return k.KeySet.Key(kid)
+}
+
+func TestAuthenticate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	sqliteDB := openTestDB(t)
+
+	// Tokens are stored as their SHA-256
+	hash := func(token string) string {
+		sum := sha256.Sum256([]byte(token))
+		return hex.EncodeToString(sum[:])
+	}
+	user := model.User{Email: "ada@example.com", PasswordHash: "-"}
+	require.NoError(t, sqliteDB.Create(&user).Error)
+	require.NoError(t, sqliteDB.Create(&model.Session{UserID: user.ID, TokenHash: hash("session"), ExpiresAt: time.Now().Add(time.Hour)}).Error)
+	require.NoError(t, sqliteDB.Create(&model.AccessToken{UserID: user.ID, Name: "ci", Prefix: "pat_read", TokenHash: hash("pat_read"),
+		Scopes: model.Scopes{auth.ScopeTasksRead}}).Error)
+
+	// A JWT signed with the secret of the key set, for user 7
+	secret := []byte("a secret of at least thirty-two bytes")
+	b64 := base64.RawURLEncoding
+	keys, err := auth.ParseJWKS([]byte(`{"keys": [{"kty": "oct", "kid": "k", "k": "` + b64.EncodeToString(secret) + `"}]}`))
+	require.NoError(t, err)
+	signingInput := b64.EncodeToString([]byte(`{"alg":"HS256","kid":"k","typ":"JWT"}`)) + "." +
+		b64.EncodeToString([]byte(`{"sub":"7","exp":`+strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)+`}`))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	jwt := signingInput + "." + b64.EncodeToString(mac.Sum(nil))
+
+	userService := service.NewUserService(repository.NewUserRepository(sqliteDB),
+		auth.PasswordParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}, service.LoginPolicy{})
+	router := gin.New()
+	router.Use(ErrorHandler(), Authenticate(userService, auth.NewJWTVerifier(testKeys{keys}, auth.JWTConfig{})))
+	var principal auth.Principal
+	whoAmI := func(c *gin.Context) {
+		principal, _ = auth.PrincipalFromContext(c)
+		c.Status(http.StatusOK)
+	}
+	router.GET("/read", RequireScope(auth.ScopeTasksRead), whoAmI)
+	router.GET("/write", RequireScope(auth.ScopeTasksWrite), whoAmI)
+
+	invalidToken := AuthenticateHeader + `, error="invalid_token"`
+	for _, tc := range []struct {
+		name, authorization, target string
+		code                        int
+		authenticate                string // prefix of the WWW-Authenticate header
+		principal                   auth.Principal
+	}{
+		{"no token", "", "/read", http.StatusUnauthorized, AuthenticateHeader, auth.Principal{}},
+		{"other scheme", "Basic YWRhOnNlY3JldA==", "/read", http.StatusUnauthorized, AuthenticateHeader, auth.Principal{}},
+		{"empty token", "Bearer ", "/read", http.StatusUnauthorized, AuthenticateHeader, auth.Principal{}},
+		{"unknown token", "Bearer nope", "/read", http.StatusUnauthorized, invalidToken, auth.Principal{}},
+		{"bad signature", "Bearer " + jwt + "x", "/read", http.StatusUnauthorized, invalidToken, auth.Principal{}},
+		{"session", "Bearer session", "/write", http.StatusOK, "", auth.Principal{UserID: user.ID}},
+		{"scheme in any case", "bearer session", "/write", http.StatusOK, "", auth.Principal{UserID: user.ID}},
+		{"personal access token", "Bearer pat_read", "/read", http.StatusOK, "", auth.Principal{UserID: user.ID, Scopes: []string{auth.ScopeTasksRead}}},
+		{"jwt", "Bearer " + jwt, "/write", http.StatusOK, "", auth.Principal{UserID: 7}},
+		{"missing scope", "Bearer pat_read", "/write", http.StatusForbidden, AuthenticateHeader + `, error="insufficient_scope", scope="tasks:write"`, auth.Principal{}},
+	} {
+		principal = auth.Principal{}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, tc.target, nil)
+		if tc.authorization != "" {
+			r.Header.Set("Authorization", tc.authorization)
+		}
+		router.ServeHTTP(w, r)
+
+		assert.Equal(t, tc.code, w.Code, tc.name)
+		if tc.authenticate == "" {
+			assert.Empty(t, w.Header().Get("WWW-Authenticate"), tc.name)
+		} else {
+			assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"), tc.name)
+			assert.Contains(t, w.Header().Get("WWW-Authenticate"), tc.authenticate, tc.name)
+		}
+		assert.Equal(t, tc.principal, principal, "the principal reaching the handler of %s", tc.name)
+	}
+}
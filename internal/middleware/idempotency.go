@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/service"
+)
+
+const (
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks a response replayed from an earlier request.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// replayedHeaders are the response headers stored with a response and
+// sent again when it is replayed.
+var replayedHeaders = []string{"Content-Type", "ETag", "Last-Modified", "Location"}
+
+// Idempotency makes a route safe to retry when the client sends an
+// Idempotency-Key header: the first successful response is stored and
+// returned again to every retry with the same key and request. Requests
+// that fail or panic are not stored, so the client may retry them with the
+// key.
+func Idempotency(idempotencyService service.IdempotencyService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+
+		// The body is hashed here and read again by the handler.
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			_ = c.Error(&StatusError{Status: http.StatusBadRequest, Err: err})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		stored, err := idempotencyService.Begin(c, key, requestFingerprint(c.Request, body))
+		if err != nil {
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+		if stored != nil {
+			for name, value := range stored.Header {
+				c.Header(name, value)
+			}
+			c.Header(IdempotentReplayedHeader, "true")
+			c.Status(stored.StatusCode)
+			_, _ = c.Writer.Write(stored.Body)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		logger := contextLogger.ContextLog(c)
+		completed := false
+		// Deferred so that the key is also released when the handler panics
+		defer func() {
+			if completed {
+				return
+			}
+			if err := idempotencyService.Abandon(c, key); err != nil {
+				logger.Error().Err(err).Msg("idempotency_key could not be released")
+			}
+		}()
+		c.Next()
+
+		if len(c.Errors) > 0 || recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+
+		header := map[string]string{}
+		for _, name := range replayedHeaders {
+			if value := recorder.Header().Get(name); value != "" {
+				header[name] = value
+			}
+		}
+		if err := idempotencyService.Complete(c, key, recorder.Status(), header, recorder.body.Bytes()); err != nil {
+			logger.Error().Err(err).Msg("idempotent response could not be stored")
+			return
+		}
+		completed = true
+	}
+}
+
+// requestFingerprint identifies a request by its caller, workspace,
+// method, target and body, so that a key never replays the response of
+// another user or workspace.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		h.Write([]byte("user " + strconv.FormatUint(uint64(principal.UserID), 10) + "\n"))
+		h.Write([]byte("workspace " + strconv.FormatUint(uint64(principal.WorkspaceID), 10) + "\n"))
+	}
+	h.Write([]byte(r.Method + " " + r.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body while writing it.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
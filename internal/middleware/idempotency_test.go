@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/db"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+	"github.com/vkuzmich/gin-project/pkg/service"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// openTestDB opens an in-memory SQLite database of its own for the test,
+// dropped when the test ends.
+func openTestDB(t *testing.T) *gorm.DB {
+	sqliteDB, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{TranslateError: true})
+	require.NoError(t, err)
+	conn, err := sqliteDB.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	require.NoError(t, db.AutoMigration(sqliteDB))
+	return sqliteDB
+}
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	sqliteDB := openTestDB(t)
+
+	router := gin.New()
+	router.Use(gin.Recovery(), ErrorHandler())
+	calls := 0
+	var retried *httptest.ResponseRecorder
+	router.POST("/todo_tasks/", Idempotency(service.NewIdempotencyService(repository.NewIdempotencyKeyRepository(sqliteDB), time.Hour)), func(ctx *gin.Context) {
+		calls++
+		switch ctx.Query("do") {
+		case "panic":
+			panic("handler failed")
+		case "fail":
+			_ = ctx.Error(service.NewValidationError("invalid body", model.FieldError{Field: "title", Message: "is required"}))
+			return
+		case "retry":
+			// The client retries while the first request is still running
+			retried = httptest.NewRecorder()
+			router.ServeHTTP(retried, ctx.Request.Clone(ctx.Request.Context()))
+		}
+		ctx.Header("Location", "/todo_tasks/"+strconv.Itoa(calls))
+		ctx.JSON(http.StatusCreated, gin.H{"calls": calls})
+	})
+	post := func(key, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		r.Header.Set(IdempotencyKeyHeader, key)
+		router.ServeHTTP(w, r)
+		return w
+	}
+
+	// A retry gets the first response again, without running the handler
+	first := post("created", "/todo_tasks/", `{"title":"a"}`)
+	require.Equal(t, http.StatusCreated, first.Code)
+	replayed := post("created", "/todo_tasks/", `{"title":"a"}`)
+	assert.Equal(t, http.StatusCreated, replayed.Code)
+	assert.Equal(t, "true", replayed.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, "/todo_tasks/1", replayed.Header().Get("Location"))
+	assert.JSONEq(t, first.Body.String(), replayed.Body.String())
+	assert.Equal(t, 1, calls)
+
+	// The key of another request is refused
+	w := post("created", "/todo_tasks/", `{"title":"b"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, ProblemContentType, w.Header().Get("Content-Type"))
+
+	// A retry while the first request runs conflicts
+	w = post("running", "/todo_tasks/?do=retry", `{}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	require.NotNil(t, retried)
+	assert.Equal(t, http.StatusConflict, retried.Code)
+
+	// Failed and panicking requests release their key, the retry runs again
+	for _, do := range []string{"fail", "panic"} {
+		calls = 0
+		w = post(do, "/todo_tasks/?do="+do, `{}`)
+		assert.NotEqual(t, http.StatusCreated, w.Code, do)
+		w = post(do, "/todo_tasks/", `{}`)
+		assert.Equal(t, http.StatusCreated, w.Code, do)
+		assert.Empty(t, w.Header().Get(IdempotentReplayedHeader), do)
+		assert.Equal(t, 2, calls, do)
+	}
+
+	// Without a key nothing is stored
+	calls = 0
+	for i := 0; i < 2; i++ {
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/todo_tasks/", strings.NewReader(`{}`)))
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+	assert.Equal(t, 2, calls)
+}
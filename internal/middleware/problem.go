@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/service"
+)
+
+// ProblemContentType is the media type of RFC 7807 error responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type      string             `json:"type"`
+	Title     string             `json:"title"`
+	Status    int                `json:"status"`
+	Detail    string             `json:"detail,omitempty"`
+	Instance  string             `json:"instance,omitempty"`
+	RequestID string             `json:"request_id,omitempty"`
+	Errors    []model.FieldError `json:"errors,omitempty"`
+}
+
+// StatusError lets a handler answer with a specific HTTP status for
+// errors that are about the HTTP exchange rather than the domain, such as
+// an unsupported media type.
+type StatusError struct {
+	Status int
+	Err    error
+}
+
+func (e *StatusError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+// problemKinds maps the service error kinds to their problem type.
+var problemKinds = []struct {
+	kind   error
+	status int
+	slug   string
+	title  string
+}{
+	{service.ErrNotFound, http.StatusNotFound, "not-found", "Resource not found"},
+	{service.ErrValidation, http.StatusBadRequest, "validation", "Invalid request"},
+	{service.ErrConflict, http.StatusConflict, "conflict", "Conflict"},
+	{service.ErrPreconditionFailed, http.StatusPreconditionFailed, "precondition-failed", "Precondition failed"},
+	{service.ErrUnprocessable, http.StatusUnprocessableEntity, "unprocessable", "Unprocessable request"},
+	{service.ErrFailedDependency, http.StatusFailedDependency, "failed-dependency", "Failed dependency"},
+	{service.ErrTooLarge, http.StatusRequestEntityTooLarge, "too-large", "Content too large"},
+	{service.ErrUnauthorized, http.StatusUnauthorized, "unauthorized", "Unauthorized"},
+	{service.ErrForbidden, http.StatusForbidden, "forbidden", "Forbidden"},
+	{service.ErrTooManyRequests, http.StatusTooManyRequests, "too-many-requests", "Too many requests"},
+	{service.ErrUnavailable, http.StatusServiceUnavailable, "unavailable", "Service unavailable"},
+}
+
+// ErrorHandler turns the last error a handler attached with ctx.Error
+// into an application/problem+json response. Handlers must not write a
+// response themselves when they report an error.
+func ErrorHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := c.Errors.Last().Err
+		problem := NewProblem(err)
+		problem.Instance = c.Request.URL.Path
+		problem.RequestID = GetRequestID(c)
+
+		logger := contextLogger.ContextLog(c)
+		if problem.Status >= http.StatusInternalServerError {
+			logger.Error().Err(err).Int("status", problem.Status).Msg("request failed")
+		} else {
+			logger.Info().Err(err).Int("status", problem.Status).Msg("request rejected")
+		}
+
+		c.Header("Content-Type", ProblemContentType)
+		c.AbortWithStatusJSON(problem.Status, problem)
+	}
+}
+
+// NewProblem describes err as a problem. Errors that are neither domain
+// errors nor StatusErrors become a 500 without leaking their message.
+func NewProblem(err error) Problem {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return Problem{
+			Type:   "about:blank",
+			Title:  http.StatusText(statusErr.Status),
+			Status: statusErr.Status,
+			Detail: statusErr.Err.Error(),
+		}
+	}
+
+	var domainErr *service.Error
+	if errors.As(err, &domainErr) {
+		for _, k := range problemKinds {
+			if errors.Is(domainErr, k.kind) {
+				return Problem{
+					Type:   "/problems/" + k.slug,
+					Title:  k.title,
+					Status: k.status,
+					Detail: domainErr.Detail,
+					Errors: domainErr.Fields,
+				}
+			}
+		}
+	}
+
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusInternalServerError),
+		Status: http.StatusInternalServerError,
+	}
+}
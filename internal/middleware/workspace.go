@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/service"
+)
+
+// WorkspaceHeader picks the workspace a request is made in, by its id.
+const WorkspaceHeader = "X-Workspace-ID"
+
+// Workspace puts the principal in the workspace the request is made in,
+// as resolved by workspaceService from the WorkspaceHeader and the
+// workspace the access token is bound to. The repositories only see the
+// rows of that workspace. It must run after Authenticate.
+func Workspace(workspaceService service.WorkspaceService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.PrincipalFromContext(c)
+		if !ok {
+			c.Next()
+			return
+		}
+
+		workspaceID, err := workspaceService.ResolveWorkspace(c, c.GetHeader(WorkspaceHeader))
+		if err != nil {
+			contextLogger.ContextLog(c).Info().Err(err).Msg("request in a refused workspace")
+			_ = c.Error(err)
+			c.Abort()
+			return
+		}
+
+		principal.WorkspaceID = workspaceID
+		c.Request = c.Request.WithContext(auth.WithPrincipal(c.Request.Context(), principal))
+		c.Next()
+	}
+}
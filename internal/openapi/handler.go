@@ -0,0 +1,36 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	SpecPath = "/openapi.json"
+	DocsPath = "/docs"
+)
+
+//go:embed docs.html
+var docsPage []byte
+
+// Serve documents the routes registered on router so far and serves the
+// document at SpecPath and a reference page at DocsPath. The page is a
+// small renderer of the document without external assets, not Swagger UI
+// or Redoc, which can be pointed at SpecPath instead. It must be called
+// after every documented route has been registered.
+func Serve(router *gin.Engine, info Info, operations map[string]Operation) error {
+	doc, err := Generate(info, router.Routes(), operations)
+	if err != nil {
+		return err
+	}
+
+	router.GET(SpecPath, func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, doc)
+	})
+	router.GET(DocsPath, func(ctx *gin.Context) {
+		ctx.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+	})
+	return nil
+}
@@ -0,0 +1,229 @@
+// Package openapi builds an OpenAPI 3.1 document of the routes registered
+// on a gin engine. Paths, methods, path parameters and operation ids are
+// read from the router and the schemas from the Go types of the bodies.
+// Summaries, query and header parameters and responses are written by hand,
+// one Operation per route, and Generate refuses a table that does not list
+// exactly the routes of the router.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const Version = "3.1.0"
+
+// Operation describes one registered route. RequestBody and the response
+// bodies are sample Go values, only their types are used.
+type Operation struct {
+	Summary     string
+	Tags        []string
+	Parameters  []Parameter // query and header parameters, path parameters are derived from the route
+	RequestBody interface{}
+	// RequestContentTypes defaults to application/json when RequestBody is set.
+	RequestContentTypes []string
+	Responses           map[int]Response
+}
+
+// Parameter is a query or header parameter of an operation.
+type Parameter struct {
+	Name        string
+	In          string // "query" or "header"
+	Description string
+	Required    bool
+	Schema      *Schema // defaults to a string
+}
+
+// Response is one possible response of an operation.
+type Response struct {
+	Description string
+	Body        interface{}
+	ContentType string // defaults to application/json when Body is set
+	Headers     []string
+}
+
+// Document is the subset of an OpenAPI 3.1 document this package emits.
+type Document struct {
+	OpenAPI    string                        `json:"openapi"`
+	Info       Info                          `json:"info"`
+	Paths      map[string]map[string]*pathOp `json:"paths"`
+	Components map[string]map[string]*Schema `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type pathOp struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []pathParameter      `json:"parameters,omitempty"`
+	RequestBody *requestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*response `json:"responses"`
+}
+
+type pathParameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required"`
+	Schema      *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Headers     map[string]header    `json:"headers,omitempty"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type header struct {
+	Schema *Schema `json:"schema"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Key identifies an operation by method and gin route path, e.g. "GET /todo_tasks/:id".
+func Key(method, path string) string {
+	return method + " " + path
+}
+
+// Generate documents every route of routes with its entry in operations.
+// It fails when a route has no description or a description has no
+// route, so that the document can not drift from the router.
+func Generate(info Info, routes gin.RoutesInfo, operations map[string]Operation) (*Document, error) {
+	doc := &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      map[string]map[string]*pathOp{},
+		Components: map[string]map[string]*Schema{"schemas": {}},
+	}
+	schemas := newSchemaRegistry(doc.Components["schemas"])
+
+	var problems []string
+	documented := map[string]bool{}
+	for _, route := range routes {
+		key := Key(route.Method, route.Path)
+		op, ok := operations[key]
+		if !ok {
+			problems = append(problems, "route "+key+" is not documented")
+			continue
+		}
+		documented[key] = true
+
+		path, pathParams := openAPIPath(route.Path)
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]*pathOp{}
+		}
+		doc.Paths[path][strings.ToLower(route.Method)] = buildOperation(route, op, pathParams, schemas)
+	}
+	for key := range operations {
+		if !documented[key] {
+			problems = append(problems, "operation "+key+" has no route")
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, fmt.Errorf("openapi: %s", strings.Join(problems, "; "))
+	}
+	return doc, nil
+}
+
+func buildOperation(route gin.RouteInfo, op Operation, pathParams []string, schemas *schemaRegistry) *pathOp {
+	result := &pathOp{
+		OperationID: operationID(route.Handler),
+		Summary:     op.Summary,
+		Tags:        op.Tags,
+		Responses:   map[string]*response{},
+	}
+
+	for _, name := range pathParams {
+		result.Parameters = append(result.Parameters, pathParameter{
+			Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"},
+		})
+	}
+	for _, p := range op.Parameters {
+		schema := p.Schema
+		if schema == nil {
+			schema = &Schema{Type: "string"}
+		}
+		result.Parameters = append(result.Parameters, pathParameter{
+			Name: p.Name, In: p.In, Description: p.Description, Required: p.Required, Schema: schema,
+		})
+	}
+
+	if op.RequestBody != nil {
+		contentTypes := op.RequestContentTypes
+		if len(contentTypes) == 0 {
+			contentTypes = []string{"application/json"}
+		}
+		body := &requestBody{Required: true, Content: map[string]mediaType{}}
+		for _, ct := range contentTypes {
+			body.Content[ct] = mediaType{Schema: schemas.schemaFor(reflect.TypeOf(op.RequestBody))}
+		}
+		result.RequestBody = body
+	}
+
+	for status, r := range op.Responses {
+		resp := &response{Description: r.Description}
+		if resp.Description == "" {
+			resp.Description = http.StatusText(status)
+		}
+		if r.Body != nil {
+			ct := r.ContentType
+			if ct == "" {
+				ct = "application/json"
+			}
+			resp.Content = map[string]mediaType{ct: {Schema: schemas.schemaFor(reflect.TypeOf(r.Body))}}
+		}
+		for _, h := range r.Headers {
+			if resp.Headers == nil {
+				resp.Headers = map[string]header{}
+			}
+			resp.Headers[h] = header{Schema: &Schema{Type: "string"}}
+		}
+		key := strconv.Itoa(status)
+		if status == 0 {
+			key = "default"
+		}
+		result.Responses[key] = resp
+	}
+	return result
+}
+
+// openAPIPath turns "/todo_tasks/:id" into "/todo_tasks/{id}" and returns
+// the names of the path parameters.
+func openAPIPath(ginPath string) (string, []string) {
+	var params []string
+	segments := strings.Split(ginPath, "/")
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			params = append(params, s[1:])
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// operationID derives a stable id from the handler name, e.g.
+// "github.com/.../routes.TodoTaskResource.GetTodoTaskRoute-fm" gives "GetTodoTask".
+func operationID(handler string) string {
+	name := handler[strings.LastIndex(handler, ".")+1:]
+	name = strings.TrimSuffix(name, "-fm")
+	return strings.TrimSuffix(name, "Route")
+}
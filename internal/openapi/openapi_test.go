@@ -0,0 +1,53 @@
+package openapi
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	ID    uint    `json:"id"`
+	Name  string  `json:"name"`
+	Notes *string `json:"notes,omitempty"`
+}
+
+func noop(*gin.Context) {}
+
+func TestGenerate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/items/:id", noop)
+
+	doc, err := Generate(Info{Title: "test", Version: "1"}, router.Routes(), map[string]Operation{
+		Key(http.MethodGet, "/items/:id"): {Responses: map[int]Response{http.StatusOK: {Body: item{}}}},
+	})
+	require.NoError(t, err)
+
+	op := doc.Paths["/items/{id}"]["get"]
+	require.NotNil(t, op)
+	assert.Equal(t, "id", op.Parameters[0].Name)
+	assert.Equal(t, "path", op.Parameters[0].In)
+	assert.Equal(t, "#/components/schemas/item", op.Responses["200"].Content["application/json"].Schema.Ref)
+
+	schema := doc.Components["schemas"]["item"]
+	require.NotNil(t, schema)
+	assert.Equal(t, []string{"id", "name"}, schema.Required)
+	assert.Equal(t, []string{"string", "null"}, schema.Properties["notes"].Type)
+}
+
+func TestGenerateDetectsDrift(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/items", noop)
+
+	_, err := Generate(Info{}, router.Routes(), map[string]Operation{
+		Key(http.MethodPost, "/items"): {},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "route GET /items is not documented")
+	assert.Contains(t, err.Error(), "operation POST /items has no route")
+}
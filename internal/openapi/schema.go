@@ -0,0 +1,133 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Schema is a JSON Schema (draft 2020-12) object as used by OpenAPI 3.1.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 interface{}        `json:"type,omitempty"` // a type name or a list of them
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// knownSchemas covers the types whose JSON form differs from their Go
+// structure because they implement json.Marshaler.
+var knownSchemas = map[reflect.Type]Schema{
+	reflect.TypeOf(json.Number("")):  {Type: []string{"integer", "string"}},
+	reflect.TypeOf(time.Time{}):      {Type: "string", Format: "date-time"},
+	reflect.TypeOf(gorm.DeletedAt{}): {Type: []string{"string", "null"}, Format: "date-time"},
+}
+
+// RegisterSchema documents the type of sample with schema wherever it is
+// used, e.g. to list the values of an enumeration. Call it from an init
+// function, before any document is generated.
+func RegisterSchema(sample interface{}, schema Schema) {
+	knownSchemas[reflect.TypeOf(sample)] = schema
+}
+
+type schemaRegistry struct {
+	components map[string]*Schema
+}
+
+func newSchemaRegistry(components map[string]*Schema) *schemaRegistry {
+	return &schemaRegistry{components: components}
+}
+
+// schemaFor returns the schema of t. Named struct types are stored once
+// in the components and referenced.
+func (r *schemaRegistry) schemaFor(t reflect.Type) *Schema {
+	if known, ok := knownSchemas[t]; ok {
+		return &known
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := r.schemaFor(t.Elem())
+		if s.Ref != "" {
+			return s
+		}
+		nullable := *s
+		if name, ok := s.Type.(string); ok {
+			nullable.Type = []string{name, "null"}
+		}
+		return &nullable
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: r.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: r.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return r.structSchema(t)
+		}
+		if _, ok := r.components[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate
+			r.components[t.Name()] = &Schema{}
+			*r.components[t.Name()] = *r.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + t.Name()}
+	default:
+		return &Schema{}
+	}
+}
+
+// structSchema follows encoding/json: embedded structs without a JSON
+// name are flattened and "-" fields are skipped.
+func (r *schemaRegistry) structSchema(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" || (!f.IsExported() && !f.Anonymous) {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if _, known := knownSchemas[embedded]; embedded.Kind() == reflect.Struct && !known {
+				inner := r.structSchema(embedded)
+				for n, p := range inner.Properties {
+					s.Properties[n] = p
+				}
+				s.Required = append(s.Required, inner.Required...)
+				continue
+			}
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		s.Properties[name] = r.schemaFor(f.Type)
+		if !strings.Contains(opts, "omitempty") && f.Type.Kind() != reflect.Pointer {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
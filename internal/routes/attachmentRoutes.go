@@ -0,0 +1,218 @@
+package routes
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/config"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/service"
+)
+
+// multipartOverhead is the room left in an upload request for the part
+// headers and boundaries around the file.
+const multipartOverhead = 64 << 10
+
+func RegisterAttachmentHandlers(r *gin.RouterGroup, attachmentService service.AttachmentService, cfg config.Config) {
+
+	res := AttachmentResource{
+		attachmentService: attachmentService,
+		maxSize:           model.AttachmentMaxSize(cfg.AttachmentMaxSize),
+	}
+
+	attachment := r.Group("/todo_tasks/:id/attachments")
+	{
+		attachment.POST("", writeScope, res.AddAttachmentRoute)
+		attachment.GET("", readScope, res.GetAttachmentsRoute)
+		attachment.GET("/:attachment_id", readScope, res.GetAttachmentRoute)
+		attachment.GET("/:attachment_id/content", readScope, res.GetAttachmentContentRoute)
+		attachment.DELETE("/:attachment_id", deleteScope, res.DeleteAttachmentRoute)
+	}
+}
+
+type AttachmentResource struct {
+	attachmentService service.AttachmentService
+	maxSize           int64
+}
+
+// AttachmentUploadForm represents the multipart/form-data body of
+// uploading an attachment.
+type AttachmentUploadForm struct {
+	File attachmentContent `json:"file"` // The file, its name is kept and its content type sniffed
+}
+
+// attachmentContent is the raw content of an attachment.
+type attachmentContent []byte
+
+func (r AttachmentResource) AddAttachmentRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("AddAttachment endpoint hit")
+	todoTaskID, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	// Larger bodies are cut off before they are spooled to disk
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, r.maxSize+multipartOverhead)
+	header, err := ctx.FormFile("file")
+	if ctx.Request.MultipartForm != nil {
+		defer ctx.Request.MultipartForm.RemoveAll()
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Error in reading attachment from request")
+		abortWithError(ctx, r.invalidUpload(err))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		logger.Error().Err(err).Msg("Error in opening uploaded attachment")
+		abortWithError(ctx, err)
+		return
+	}
+	defer file.Close()
+
+	attachment, err := r.attachmentService.AddAttachment(ctx, todoTaskID, model.AttachmentUpload{
+		Filename: header.Filename,
+		Size:     header.Size,
+		Content:  file,
+	})
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", todoTaskID).Msg("Error in processing attachment")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("AddAttachment endpoint successfully created attachment")
+	ctx.JSON(http.StatusOK, &attachment)
+}
+
+// invalidUpload reports an upload request that could not be read.
+func (r AttachmentResource) invalidUpload(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &service.Error{Kind: service.ErrTooLarge, Detail: "the file is too large", Err: err,
+			Fields: []model.FieldError{{Field: "file", Message: "must be at most " + strconv.FormatInt(r.maxSize, 10) + " bytes"}}}
+	case errors.Is(err, http.ErrMissingFile):
+		return service.NewValidationError("one or more fields are invalid", model.FieldError{Field: "file", Message: "is required"})
+	}
+	return service.NewError(service.ErrValidation, "the request body must be multipart/form-data with a file part", err)
+}
+
+func (r AttachmentResource) GetAttachmentsRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetAttachments endpoint hit")
+	todoTaskID, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	params := model.AttachmentListParams{Cursor: ctx.Query("cursor")}
+	if raw := ctx.Query("limit"); raw != "" {
+		var err error
+		if params.Limit, err = strconv.Atoi(raw); err != nil || params.Limit < 1 {
+			abortWithError(ctx, invalidQuery("limit", "must be a positive integer"))
+			return
+		}
+	}
+
+	page, err := r.attachmentService.GetAttachments(ctx, todoTaskID, params)
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", todoTaskID).Msg("Error in getting attachments")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &page)
+}
+
+func (r AttachmentResource) GetAttachmentRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetAttachment endpoint hit")
+	todoTaskID, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	id, err := parseID(ctx, "attachment_id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	attachment, err := r.attachmentService.GetAttachment(ctx, todoTaskID, id)
+	if err != nil {
+		logger.Error().Err(err).Str("attachment_id", id).Msg("Error in getting attachment")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &attachment)
+}
+
+// GetAttachmentContentRoute streams the content of an attachment as a
+// download. The content never changes, so its checksum is the ETag.
+func (r AttachmentResource) GetAttachmentContentRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetAttachmentContent endpoint hit")
+	todoTaskID, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	id, err := parseID(ctx, "attachment_id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	attachment, content, err := r.attachmentService.OpenAttachment(ctx, todoTaskID, id)
+	if err != nil {
+		logger.Error().Err(err).Str("attachment_id", id).Msg("Error in opening attachment")
+		abortWithError(ctx, err)
+		return
+	}
+	defer content.Close()
+	if setValidators(ctx, `"`+attachment.SHA256+`"`, attachment.CreatedAt) {
+		return
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename})
+	if disposition == "" {
+		disposition = "attachment"
+	}
+	checksum, _ := hex.DecodeString(attachment.SHA256)
+	ctx.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, map[string]string{
+		"Content-Disposition":    disposition,
+		"Repr-Digest":            "sha-256=:" + base64.StdEncoding.EncodeToString(checksum) + ":",
+		"X-Content-Type-Options": "nosniff",
+	})
+}
+
+func (r AttachmentResource) DeleteAttachmentRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("DeleteAttachment endpoint hit")
+	todoTaskID, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	id, err := parseID(ctx, "attachment_id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	if err := r.attachmentService.DeleteAttachment(ctx, todoTaskID, id); err != nil {
+		logger.Error().Err(err).Str("attachment_id", id).Msg("Error in deleting attachment")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("DeleteAttachment endpoint successfully deleted attachment")
+	ctx.Status(http.StatusOK)
+}
@@ -0,0 +1,178 @@
+package routes
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/internal/middleware"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/service"
+)
+
+// RegisterAuthHandlers registers the account routes. They are reachable
+// without an access token, so r must not require one.
+func RegisterAuthHandlers(r *gin.RouterGroup, userService service.UserService) {
+
+	res := AuthResource{
+		userService: userService,
+	}
+
+	authentication := r.Group("/auth")
+	{
+		authentication.POST("/register", res.RegisterRoute)
+		authentication.POST("/login", res.LoginRoute)
+		authentication.POST("/logout", res.LogoutRoute)
+	}
+}
+
+type AuthResource struct {
+	userService service.UserService
+}
+
+// CredentialsRequestBody represents the request body of registering and
+// logging in.
+type CredentialsRequestBody struct {
+	Email    string `json:"email"`    // Email address, compared case-insensitively
+	Password string `json:"password"` // 12 to 128 characters, not a common password
+}
+
+func (r AuthResource) RegisterRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("Register endpoint hit")
+
+	body := CredentialsRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		logger.Error().Err(err).Msg("Error in Binding credentials from request")
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	user, err := r.userService.Register(ctx, &model.Credentials{Email: body.Email, Password: body.Password})
+	if err != nil {
+		logger.Error().Err(err).Msg("Error in registering user")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("Register endpoint successfully registered user")
+	ctx.JSON(http.StatusOK, &user)
+}
+
+func (r AuthResource) LoginRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("Login endpoint hit")
+
+	body := CredentialsRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		logger.Error().Err(err).Msg("Error in Binding credentials from request")
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	result, err := r.userService.Login(ctx, &model.Credentials{Email: body.Email, Password: body.Password})
+	if err != nil {
+		logger.Info().Err(err).Msg("Error in logging in user")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &result)
+}
+
+// LogoutRoute ends the session of the bearer token. Logging out with a
+// token that is already invalid succeeds.
+func (r AuthResource) LogoutRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("Logout endpoint hit")
+
+	token, ok := middleware.BearerToken(ctx)
+	if !ok {
+		ctx.Header("WWW-Authenticate", middleware.AuthenticateHeader)
+		abortWithError(ctx, service.NewError(service.ErrUnauthorized, "an access token is required", nil))
+		return
+	}
+	if err := r.userService.Logout(ctx, token); err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusOK)
+}
+
+// RegisterAccessTokenHandlers registers the routes managing the personal
+// access tokens of the caller, r must require an access token.
+func RegisterAccessTokenHandlers(r *gin.RouterGroup, userService service.UserService) {
+
+	res := AuthResource{
+		userService: userService,
+	}
+
+	accessToken := r.Group("/auth/tokens")
+	{
+		accessToken.POST("", res.CreateAccessTokenRoute)
+		accessToken.GET("", res.GetAccessTokensRoute)
+		accessToken.DELETE("/:id", res.RevokeAccessTokenRoute)
+	}
+}
+
+// AccessTokenRequestBody represents the request body of creating a
+// personal access token.
+type AccessTokenRequestBody struct {
+	Name      string     `json:"name"`       // What the token is for
+	Scopes    []string   `json:"scopes"`     // Any of tasks:read, tasks:write and tasks:delete
+	ExpiresAt *time.Time `json:"expires_at"` // When the token stops working, never when omitted
+}
+
+// CreateAccessTokenRoute answers with the token, it is not shown again.
+func (r AuthResource) CreateAccessTokenRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("CreateAccessToken endpoint hit")
+
+	body := AccessTokenRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		logger.Error().Err(err).Msg("Error in Binding access_token payload from request")
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	accessToken, err := r.userService.CreateAccessToken(ctx, &model.AccessTokenPayload{
+		Name: body.Name, Scopes: body.Scopes, ExpiresAt: body.ExpiresAt})
+	if err != nil {
+		logger.Error().Err(err).Msg("Error in creating access_token")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("CreateAccessToken endpoint successfully created access_token")
+	ctx.JSON(http.StatusOK, &accessToken)
+}
+
+func (r AuthResource) GetAccessTokensRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetAccessTokens endpoint hit")
+
+	accessTokens, err := r.userService.GetAccessTokens(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error in getting access_tokens")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &accessTokens)
+}
+
+func (r AuthResource) RevokeAccessTokenRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("RevokeAccessToken endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	accessToken, err := r.userService.RevokeAccessToken(ctx, id)
+	if err != nil {
+		logger.Error().Err(err).Str("access_token_id", id).Msg("Error in revoking access_token")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("RevokeAccessToken endpoint successfully revoked access_token")
+	ctx.JSON(http.StatusOK, &accessToken)
+}
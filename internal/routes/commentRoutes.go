@@ -0,0 +1,172 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/service"
+)
+
+func RegisterCommentHandlers(r *gin.RouterGroup, commentService service.CommentService) {
+
+	res := CommentResource{
+		commentService: commentService,
+	}
+
+	comment := r.Group("/todo_tasks/:id/comments")
+	{
+		comment.POST("", writeScope, res.AddCommentRoute)
+		comment.GET("", readScope, res.GetCommentsRoute)
+		comment.GET("/:comment_id", readScope, res.GetCommentRoute)
+		comment.PATCH("/:comment_id", writeScope, res.UpdateCommentRoute)
+		comment.DELETE("/:comment_id", deleteScope, res.DeleteCommentRoute)
+	}
+}
+
+type CommentResource struct {
+	commentService service.CommentService
+}
+
+// CommentRequestBody represents the request body of posting a comment.
+// The author is the user the request is made on behalf of.
+type CommentRequestBody struct {
+	Body string `json:"body"` // The text of the comment
+}
+
+// CommentEditRequestBody represents the request body of editing a comment.
+type CommentEditRequestBody struct {
+	Body string `json:"body"` // The new text of the comment
+}
+
+func (r CommentResource) AddCommentRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("AddComment endpoint hit")
+	todoTaskID, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	body := CommentRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		logger.Error().Err(err).Msg("Error in Binding comment payload from request")
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	comment, err := r.commentService.AddComment(ctx, todoTaskID, &model.CommentPayload{Body: body.Body})
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", todoTaskID).Msg("Error in processing comment")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("AddComment endpoint successfully created comment")
+	ctx.JSON(http.StatusOK, &comment)
+}
+
+func (r CommentResource) GetCommentsRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetComments endpoint hit")
+	todoTaskID, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	params := model.CommentListParams{Cursor: ctx.Query("cursor")}
+	if raw := ctx.Query("limit"); raw != "" {
+		var err error
+		if params.Limit, err = strconv.Atoi(raw); err != nil || params.Limit < 1 {
+			abortWithError(ctx, invalidQuery("limit", "must be a positive integer"))
+			return
+		}
+	}
+
+	page, err := r.commentService.GetComments(ctx, todoTaskID, params)
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", todoTaskID).Msg("Error in getting comments")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &page)
+}
+
+func (r CommentResource) GetCommentRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetComment endpoint hit")
+	todoTaskID, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	id, err := parseID(ctx, "comment_id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	comment, err := r.commentService.GetComment(ctx, todoTaskID, id)
+	if err != nil {
+		logger.Error().Err(err).Str("comment_id", id).Msg("Error in getting comment")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &comment)
+}
+
+func (r CommentResource) UpdateCommentRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("UpdateComment endpoint hit")
+	todoTaskID, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	id, err := parseID(ctx, "comment_id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	body := CommentEditRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		logger.Error().Err(err).Msg("Error in Binding comment payload from request")
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	comment, err := r.commentService.UpdateComment(ctx, todoTaskID, id, &model.CommentEditPayload{Body: body.Body})
+	if err != nil {
+		logger.Error().Err(err).Str("comment_id", id).Msg("Error in updating comment")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("UpdateComment endpoint successfully updated comment")
+	ctx.JSON(http.StatusOK, &comment)
+}
+
+func (r CommentResource) DeleteCommentRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("DeleteComment endpoint hit")
+	todoTaskID, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	id, err := parseID(ctx, "comment_id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	if err := r.commentService.DeleteComment(ctx, todoTaskID, id); err != nil {
+		logger.Error().Err(err).Str("comment_id", id).Msg("Error in deleting comment")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("DeleteComment endpoint successfully deleted comment")
+	ctx.Status(http.StatusOK)
+}
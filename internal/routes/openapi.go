@@ -0,0 +1,550 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/vkuzmich/gin-project/internal/middleware"
+	"github.com/vkuzmich/gin-project/internal/openapi"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/patch"
+)
+
+func init() {
+	statuses := make([]interface{}, len(model.TodoTaskStatuses))
+	for i, s := range model.TodoTaskStatuses {
+		statuses[i] = s
+	}
+	openapi.RegisterSchema(model.TodoTaskStatus(""), openapi.Schema{Type: "string", Enum: statuses})
+	openapi.RegisterSchema(model.BulkMode(""), openapi.Schema{
+		Type: "string", Enum: []interface{}{model.BulkAllOrNothing, model.BulkBestEffort}})
+	openapi.RegisterSchema(attachmentContent(nil), openapi.Schema{Type: "string", Format: "binary"})
+}
+
+// problemResponse documents the problem+json body every endpoint answers
+// with when it fails.
+var problemResponse = openapi.Response{
+	Description: "Error",
+	Body:        middleware.Problem{},
+	ContentType: middleware.ProblemContentType,
+}
+
+var (
+	ifMatchHeader = openapi.Parameter{
+		Name: "If-Match", In: "header",
+		Description: "ETags the todo_task must match, required when the server enforces preconditions",
+	}
+	ifNoneMatchHeader = openapi.Parameter{
+		Name: "If-None-Match", In: "header",
+		Description: "Answer 304 when the current ETag is listed",
+	}
+	ifModifiedSinceHeader = openapi.Parameter{
+		Name: "If-Modified-Since", In: "header",
+		Description: "Answer 304 when unchanged since this HTTP date",
+	}
+	idempotencyKeyHeader = openapi.Parameter{
+		Name: "Idempotency-Key", In: "header",
+		Description: "Retries with the same key and body get the first response again",
+	}
+	authorizationHeader = openapi.Parameter{
+		Name: "Authorization", In: "header", Required: true,
+		Description: "Bearer access token returned by /auth/login",
+	}
+	integerSchema = &openapi.Schema{Type: "integer"}
+)
+
+// todoTaskListParameters documents todoTaskListQuery.
+var todoTaskListParameters = []openapi.Parameter{
+	{Name: "limit", In: "query", Description: "Page size, at most 100", Schema: integerSchema},
+	{Name: "cursor", In: "query", Description: "next_cursor of the previous page"},
+	{Name: "status", In: "query", Description: "Comma separated statuses, any of them matches"},
+	{Name: "title", In: "query", Description: "Case-insensitive substring of the title"},
+	{Name: "created_after", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+	{Name: "created_before", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+	{Name: "updated_after", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+	{Name: "updated_before", In: "query", Schema: &openapi.Schema{Type: "string", Format: "date-time"}},
+	{Name: "due_after", In: "query", Description: "RFC 3339 timestamp or YYYY-MM-DD date in tz"},
+	{Name: "due_before", In: "query", Description: "RFC 3339 timestamp or YYYY-MM-DD date in tz"},
+	{Name: "due_today", In: "query", Description: "Due today in tz", Schema: &openapi.Schema{Type: "boolean"}},
+	{Name: "overdue", In: "query", Description: "Past due and neither done nor cancelled", Schema: &openapi.Schema{Type: "boolean"}},
+	{Name: "project_id", In: "query", Description: "In this project, archived or not; otherwise archived projects are left out", Schema: integerSchema},
+	{Name: "tags_any", In: "query", Description: "Comma separated tag names, any of them matches"},
+	{Name: "tags_all", In: "query", Description: "Comma separated tag names, all of them must match"},
+	{Name: "tz", In: "query", Description: "IANA time zone of dates and due_today, UTC by default"},
+	{Name: "sort", In: "query", Description: "Comma separated fields, a leading '-' sorts descending"},
+}
+
+// TodoTaskOperations documents every route registered by
+// RegisterTodoTaskHandlers.
+func TodoTaskOperations() map[string]openapi.Operation {
+	tags := []string{"todo_tasks"}
+	todoTaskResponse := openapi.Response{Body: model.TodoTask{}, Headers: []string{"ETag"}}
+
+	return map[string]openapi.Operation{
+		openapi.Key(http.MethodPost, "/todo_tasks/"): {
+			Summary:     "Create a todo_task",
+			Tags:        tags,
+			Parameters:  []openapi.Parameter{idempotencyKeyHeader},
+			RequestBody: TodoTaskRequestBody{},
+			Responses:   map[int]openapi.Response{http.StatusOK: {Body: model.TodoTask{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodPost, "/todo_tasks/bulk"): {
+			Summary:     "Create, update and delete todo_tasks in one transaction",
+			Tags:        tags,
+			Parameters:  []openapi.Parameter{idempotencyKeyHeader},
+			RequestBody: TodoTaskBulkRequestBody{},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:          {Description: "Every operation succeeded", Body: TodoTaskBulkResponse{}},
+				http.StatusMultiStatus: {Description: "Some operations failed", Body: TodoTaskBulkResponse{}},
+				0:                      problemResponse,
+			},
+		},
+		openapi.Key(http.MethodGet, "/todo_tasks/"): {
+			Summary:    "List todo_tasks",
+			Tags:       tags,
+			Parameters: append(todoTaskListParameters, ifNoneMatchHeader),
+			Responses: map[int]openapi.Response{
+				http.StatusOK:          {Body: model.TodoTaskPage{}, Headers: []string{"ETag"}},
+				http.StatusNotModified: {},
+				0:                      problemResponse,
+			},
+		},
+		openapi.Key(http.MethodGet, "/todo_tasks/search"): {
+			Summary: "Full-text search todo_tasks",
+			Tags:    tags,
+			Parameters: []openapi.Parameter{
+				{Name: "q", In: "query", Required: true, Description: "Search terms"},
+				{Name: "limit", In: "query", Schema: integerSchema},
+			},
+			Responses: map[int]openapi.Response{http.StatusOK: {Body: model.TodoTaskSearchResults{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodGet, "/todo_tasks/trash"): {
+			Summary:    "List trashed todo_tasks",
+			Tags:       tags,
+			Parameters: todoTaskListParameters,
+			Responses:  map[int]openapi.Response{http.StatusOK: {Body: model.TodoTaskPage{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodGet, "/todo_tasks/:id"): {
+			Summary:    "Get a todo_task",
+			Tags:       tags,
+			Parameters: []openapi.Parameter{ifNoneMatchHeader, ifModifiedSinceHeader},
+			Responses: map[int]openapi.Response{
+				http.StatusOK:          {Body: model.TodoTask{}, Headers: []string{"ETag", "Last-Modified"}},
+				http.StatusNotModified: {},
+				0:                      problemResponse,
+			},
+		},
+		openapi.Key(http.MethodPut, "/todo_tasks/:id"): {
+			Summary:     "Replace a todo_task",
+			Tags:        tags,
+			Parameters:  []openapi.Parameter{ifMatchHeader},
+			RequestBody: TodoTaskRequestBody{},
+			Responses:   map[int]openapi.Response{http.StatusOK: todoTaskResponse, 0: problemResponse},
+		},
+		openapi.Key(http.MethodPatch, "/todo_tasks/:id"): {
+			Summary:             "Patch a todo_task",
+			Tags:                tags,
+			Parameters:          []openapi.Parameter{ifMatchHeader, idempotencyKeyHeader},
+			RequestBody:         map[string]interface{}{},
+			RequestContentTypes: []string{patch.MergePatchContentType, patch.JSONPatchContentType},
+			Responses:           map[int]openapi.Response{http.StatusOK: todoTaskResponse, 0: problemResponse},
+		},
+		openapi.Key(http.MethodDelete, "/todo_tasks/:id"): {
+			Summary: "Move a todo_task to the trash",
+			Tags:    tags,
+			Parameters: []openapi.Parameter{
+				ifMatchHeader,
+				{Name: "permanent", In: "query", Description: "Delete for good instead", Schema: &openapi.Schema{Type: "boolean"}},
+			},
+			Responses: map[int]openapi.Response{http.StatusOK: {}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodGet, "/todo_tasks/:id/tree"): {
+			Summary:   "Get a todo_task with all of its subtasks and their completion",
+			Tags:      tags,
+			Responses: map[int]openapi.Response{http.StatusOK: {Body: model.TodoTaskNode{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodPost, "/todo_tasks/:id/move"): {
+			Summary:     "Move a todo_task under another parent",
+			Tags:        tags,
+			Parameters:  []openapi.Parameter{ifMatchHeader},
+			RequestBody: TodoTaskMoveRequestBody{},
+			Responses:   map[int]openapi.Response{http.StatusOK: todoTaskResponse, 0: problemResponse},
+		},
+		openapi.Key(http.MethodPost, "/todo_tasks/:id/move_to_project"): {
+			Summary:     "Move a top-level todo_task and its subtasks to another project",
+			Tags:        tags,
+			Parameters:  []openapi.Parameter{ifMatchHeader},
+			RequestBody: TodoTaskMoveToProjectRequestBody{},
+			Responses:   map[int]openapi.Response{http.StatusOK: todoTaskResponse, 0: problemResponse},
+		},
+		openapi.Key(http.MethodGet, "/todo_tasks/:id/dependencies"): {
+			Summary: "Get the dependency graph of a todo_task",
+			Tags:    tags,
+			Parameters: []openapi.Parameter{
+				{Name: "format", In: "query", Description: "json (default) or dot for a Graphviz " + DOTContentType + " document"},
+			},
+			Responses: map[int]openapi.Response{http.StatusOK: {Body: model.TodoTaskGraph{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodPost, "/todo_tasks/:id/blockers"): {
+			Summary:     "Make another todo_task block this one",
+			Tags:        tags,
+			RequestBody: TodoTaskBlockerRequestBody{},
+			Responses:   map[int]openapi.Response{http.StatusOK: {Body: model.TodoTaskDependency{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodDelete, "/todo_tasks/:id/blockers/:blocker_id"): {
+			Summary:   "Stop a todo_task from blocking this one",
+			Tags:      tags,
+			Responses: map[int]openapi.Response{http.StatusOK: {}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodGet, "/todo_tasks/:id/occurrences"): {
+			Summary: "Preview the next occurrences of a recurring todo_task",
+			Tags:    tags,
+			Parameters: []openapi.Parameter{
+				{Name: "count", In: "query", Description: "Number of occurrences, 20 by default and at most 100", Schema: integerSchema},
+			},
+			Responses: map[int]openapi.Response{http.StatusOK: {Body: model.TodoTaskOccurrences{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodPost, "/todo_tasks/:id/transitions"): {
+			Summary:     "Move a todo_task to another status of the workflow",
+			Tags:        tags,
+			Parameters:  []openapi.Parameter{ifMatchHeader},
+			RequestBody: TodoTaskTransitionRequestBody{},
+			Responses:   map[int]openapi.Response{http.StatusOK: todoTaskResponse, 0: problemResponse},
+		},
+		openapi.Key(http.MethodPost, "/todo_tasks/:id/restore"): {
+			Summary:    "Restore a trashed todo_task",
+			Tags:       tags,
+			Parameters: []openapi.Parameter{ifMatchHeader},
+			Responses:  map[int]openapi.Response{http.StatusOK: todoTaskResponse, 0: problemResponse},
+		},
+	}
+}
+
+// TagOperations documents every route registered by RegisterTagHandlers.
+func TagOperations() map[string]openapi.Operation {
+	tags := []string{"tags"}
+	tagResponses := map[int]openapi.Response{http.StatusOK: {Body: model.Tag{}}, 0: problemResponse}
+
+	return map[string]openapi.Operation{
+		openapi.Key(http.MethodPost, "/tags/"): {
+			Summary:     "Create a tag",
+			Tags:        tags,
+			RequestBody: TagRequestBody{},
+			Responses:   tagResponses,
+		},
+		openapi.Key(http.MethodGet, "/tags/"): {
+			Summary:   "List the tags by name",
+			Tags:      tags,
+			Responses: map[int]openapi.Response{http.StatusOK: {Body: model.TagList{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodGet, "/tags/:id"): {
+			Summary:   "Get a tag",
+			Tags:      tags,
+			Responses: tagResponses,
+		},
+		openapi.Key(http.MethodPut, "/tags/:id"): {
+			Summary:     "Rename a tag",
+			Tags:        tags,
+			RequestBody: TagRequestBody{},
+			Responses:   tagResponses,
+		},
+		openapi.Key(http.MethodDelete, "/tags/:id"): {
+			Summary:   "Delete a tag and take it off every todo_task",
+			Tags:      tags,
+			Responses: map[int]openapi.Response{http.StatusOK: {}, 0: problemResponse},
+		},
+	}
+}
+
+// CommentOperations documents every route registered by
+// RegisterCommentHandlers.
+func CommentOperations() map[string]openapi.Operation {
+	tags := []string{"comments"}
+	commentResponses := map[int]openapi.Response{http.StatusOK: {Body: model.Comment{}}, 0: problemResponse}
+
+	return map[string]openapi.Operation{
+		openapi.Key(http.MethodPost, "/todo_tasks/:id/comments"): {
+			Summary:     "Comment on a todo_task",
+			Tags:        tags,
+			RequestBody: CommentRequestBody{},
+			Responses:   commentResponses,
+		},
+		openapi.Key(http.MethodGet, "/todo_tasks/:id/comments"): {
+			Summary: "List the comments of a todo_task, oldest first",
+			Tags:    tags,
+			Parameters: []openapi.Parameter{
+				{Name: "limit", In: "query", Description: "Page size, at most 100", Schema: integerSchema},
+				{Name: "cursor", In: "query", Description: "next_cursor of the previous page"},
+			},
+			Responses: map[int]openapi.Response{http.StatusOK: {Body: model.CommentPage{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodGet, "/todo_tasks/:id/comments/:comment_id"): {
+			Summary:   "Get a comment",
+			Tags:      tags,
+			Responses: commentResponses,
+		},
+		openapi.Key(http.MethodPatch, "/todo_tasks/:id/comments/:comment_id"): {
+			Summary:     "Edit the body of a comment",
+			Tags:        tags,
+			RequestBody: CommentEditRequestBody{},
+			Responses:   commentResponses,
+		},
+		openapi.Key(http.MethodDelete, "/todo_tasks/:id/comments/:comment_id"): {
+			Summary:   "Delete a comment",
+			Tags:      tags,
+			Responses: map[int]openapi.Response{http.StatusOK: {}, 0: problemResponse},
+		},
+	}
+}
+
+// AttachmentOperations documents every route registered by
+// RegisterAttachmentHandlers.
+func AttachmentOperations() map[string]openapi.Operation {
+	tags := []string{"attachments"}
+	attachmentResponses := map[int]openapi.Response{http.StatusOK: {Body: model.Attachment{}}, 0: problemResponse}
+
+	return map[string]openapi.Operation{
+		openapi.Key(http.MethodPost, "/todo_tasks/:id/attachments"): {
+			Summary:             "Attach a file to a todo_task",
+			Tags:                tags,
+			RequestBody:         AttachmentUploadForm{},
+			RequestContentTypes: []string{"multipart/form-data"},
+			Responses:           attachmentResponses,
+		},
+		openapi.Key(http.MethodGet, "/todo_tasks/:id/attachments"): {
+			Summary: "List the attachments of a todo_task, oldest first",
+			Tags:    tags,
+			Parameters: []openapi.Parameter{
+				{Name: "limit", In: "query", Description: "Page size, at most 100", Schema: integerSchema},
+				{Name: "cursor", In: "query", Description: "next_cursor of the previous page"},
+			},
+			Responses: map[int]openapi.Response{http.StatusOK: {Body: model.AttachmentPage{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodGet, "/todo_tasks/:id/attachments/:attachment_id"): {
+			Summary:   "Get the metadata of an attachment",
+			Tags:      tags,
+			Responses: attachmentResponses,
+		},
+		openapi.Key(http.MethodGet, "/todo_tasks/:id/attachments/:attachment_id/content"): {
+			Summary:    "Download the content of an attachment",
+			Tags:       tags,
+			Parameters: []openapi.Parameter{ifNoneMatchHeader, ifModifiedSinceHeader},
+			Responses: map[int]openapi.Response{
+				http.StatusOK: {
+					Body:        attachmentContent(nil),
+					ContentType: "application/octet-stream",
+					Headers:     []string{"Content-Disposition", "ETag", "Last-Modified", "Repr-Digest"},
+				},
+				http.StatusNotModified: {},
+				0:                      problemResponse,
+			},
+		},
+		openapi.Key(http.MethodDelete, "/todo_tasks/:id/attachments/:attachment_id"): {
+			Summary:   "Delete an attachment and its content",
+			Tags:      tags,
+			Responses: map[int]openapi.Response{http.StatusOK: {}, 0: problemResponse},
+		},
+	}
+}
+
+// ProjectOperations documents every route registered by
+// RegisterProjectHandlers.
+func ProjectOperations() map[string]openapi.Operation {
+	tags := []string{"projects"}
+	projectResponses := map[int]openapi.Response{http.StatusOK: {Body: model.Project{}}, 0: problemResponse}
+
+	return map[string]openapi.Operation{
+		openapi.Key(http.MethodPost, "/projects"): {
+			Summary:     "Create a project",
+			Tags:        tags,
+			RequestBody: ProjectRequestBody{},
+			Responses:   projectResponses,
+		},
+		openapi.Key(http.MethodGet, "/projects"): {
+			Summary: "List the projects with their counts, oldest first",
+			Tags:    tags,
+			Parameters: []openapi.Parameter{
+				{Name: "limit", In: "query", Description: "Page size, at most 100", Schema: integerSchema},
+				{Name: "cursor", In: "query", Description: "next_cursor of the previous page"},
+				{Name: "archived", In: "query", Description: "List the archived projects instead", Schema: &openapi.Schema{Type: "boolean"}},
+			},
+			Responses: map[int]openapi.Response{http.StatusOK: {Body: model.ProjectPage{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodGet, "/projects/:id"): {
+			Summary:   "Get a project with its counts",
+			Tags:      tags,
+			Responses: projectResponses,
+		},
+		openapi.Key(http.MethodPut, "/projects/:id"): {
+			Summary:     "Replace the name and description of a project",
+			Tags:        tags,
+			RequestBody: ProjectRequestBody{},
+			Responses:   projectResponses,
+		},
+		openapi.Key(http.MethodDelete, "/projects/:id"): {
+			Summary:   "Delete a project and keep its todo_tasks outside of any project",
+			Tags:      tags,
+			Responses: map[int]openapi.Response{http.StatusOK: {}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodPost, "/projects/:id/archive"): {
+			Summary:   "Archive a project and everything in it",
+			Tags:      tags,
+			Responses: projectResponses,
+		},
+		openapi.Key(http.MethodPost, "/projects/:id/unarchive"): {
+			Summary:   "Bring an archived project back",
+			Tags:      tags,
+			Responses: projectResponses,
+		},
+		openapi.Key(http.MethodGet, "/projects/:id/todo_tasks"): {
+			Summary:    "List the todo_tasks of a project",
+			Tags:       tags,
+			Parameters: todoTaskListParameters,
+			Responses:  map[int]openapi.Response{http.StatusOK: {Body: model.TodoTaskPage{}}, 0: problemResponse},
+		},
+	}
+}
+
+// AuthOperations documents every route registered by
+// RegisterAuthHandlers.
+func AuthOperations() map[string]openapi.Operation {
+	tags := []string{"auth"}
+
+	return map[string]openapi.Operation{
+		openapi.Key(http.MethodPost, "/auth/register"): {
+			Summary:     "Register a user",
+			Tags:        tags,
+			RequestBody: CredentialsRequestBody{},
+			Responses:   map[int]openapi.Response{http.StatusOK: {Body: model.User{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodPost, "/auth/login"): {
+			Summary:     "Log in and get an access token, the account is locked after repeated failures",
+			Tags:        tags,
+			RequestBody: CredentialsRequestBody{},
+			Responses:   map[int]openapi.Response{http.StatusOK: {Body: model.LoginResult{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodPost, "/auth/logout"): {
+			Summary:    "End the session of the access token",
+			Tags:       tags,
+			Parameters: []openapi.Parameter{authorizationHeader},
+			Responses:  map[int]openapi.Response{http.StatusOK: {}, 0: problemResponse},
+		},
+	}
+}
+
+// AccessTokenOperations documents every route registered by
+// RegisterAccessTokenHandlers.
+func AccessTokenOperations() map[string]openapi.Operation {
+	tags := []string{"auth"}
+
+	return map[string]openapi.Operation{
+		openapi.Key(http.MethodPost, "/auth/tokens"): {
+			Summary:     "Create a personal access token, the token is only shown in this response",
+			Tags:        tags,
+			RequestBody: AccessTokenRequestBody{},
+			Responses:   map[int]openapi.Response{http.StatusOK: {Body: model.CreatedAccessToken{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodGet, "/auth/tokens"): {
+			Summary:   "List the personal access tokens, revoked and expired ones included",
+			Tags:      tags,
+			Responses: map[int]openapi.Response{http.StatusOK: {Body: []model.AccessToken{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodDelete, "/auth/tokens/:id"): {
+			Summary:   "Revoke a personal access token",
+			Tags:      tags,
+			Responses: map[int]openapi.Response{http.StatusOK: {Body: model.AccessToken{}}, 0: problemResponse},
+		},
+	}
+}
+
+// ShareOperations documents every route registered by
+// RegisterShareHandlers.
+func ShareOperations() map[string]openapi.Operation {
+	tags := []string{"shares"}
+	permissionsResponses := map[int]openapi.Response{http.StatusOK: {Body: model.Permissions{}}, 0: problemResponse}
+	sharesResponses := map[int]openapi.Response{http.StatusOK: {Body: model.ShareList{}}, 0: problemResponse}
+	shareResponses := map[int]openapi.Response{http.StatusOK: {Body: model.Share{}}, 0: problemResponse}
+	unshareResponses := map[int]openapi.Response{http.StatusOK: {}, 0: problemResponse}
+
+	return map[string]openapi.Operation{
+		openapi.Key(http.MethodGet, "/todo_tasks/:id/permissions"): {
+			Summary:   "Get the role of the caller on a todo_task and the actions it allows",
+			Tags:      tags,
+			Responses: permissionsResponses,
+		},
+		openapi.Key(http.MethodGet, "/todo_tasks/:id/shares"): {
+			Summary:   "List the users a todo_task is shared with",
+			Tags:      tags,
+			Responses: sharesResponses,
+		},
+		openapi.Key(http.MethodPost, "/todo_tasks/:id/shares"): {
+			Summary:     "Share a todo_task with a user or change their role",
+			Tags:        tags,
+			RequestBody: ShareRequestBody{},
+			Responses:   shareResponses,
+		},
+		openapi.Key(http.MethodDelete, "/todo_tasks/:id/shares/:user_id"): {
+			Summary:   "Stop sharing a todo_task with a user",
+			Tags:      tags,
+			Responses: unshareResponses,
+		},
+		openapi.Key(http.MethodGet, "/projects/:id/permissions"): {
+			Summary:   "Get the role of the caller on a project and the actions it allows",
+			Tags:      tags,
+			Responses: permissionsResponses,
+		},
+		openapi.Key(http.MethodGet, "/projects/:id/shares"): {
+			Summary:   "List the users a project is shared with",
+			Tags:      tags,
+			Responses: sharesResponses,
+		},
+		openapi.Key(http.MethodPost, "/projects/:id/shares"): {
+			Summary:     "Share a project and all of its todo_tasks with a user or change their role",
+			Tags:        tags,
+			RequestBody: ShareRequestBody{},
+			Responses:   shareResponses,
+		},
+		openapi.Key(http.MethodDelete, "/projects/:id/shares/:user_id"): {
+			Summary:   "Stop sharing a project with a user",
+			Tags:      tags,
+			Responses: unshareResponses,
+		},
+	}
+}
+
+// WorkspaceOperations documents the routes of RegisterWorkspaceHandlers.
+// Every authenticated route is made in a workspace, picked with the
+// X-Workspace-ID header.
+func WorkspaceOperations() map[string]openapi.Operation {
+	tags := []string{"workspaces"}
+	membersResponses := map[int]openapi.Response{http.StatusOK: {Body: model.WorkspaceMemberList{}}, 0: problemResponse}
+
+	return map[string]openapi.Operation{
+		openapi.Key(http.MethodPost, "/workspaces"): {
+			Summary:     "Create a workspace owned by the caller",
+			Tags:        tags,
+			RequestBody: WorkspaceRequestBody{},
+			Responses:   map[int]openapi.Response{http.StatusOK: {Body: model.Workspace{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodGet, "/workspaces"): {
+			Summary:   "List the workspaces of the caller, their ids go in the X-Workspace-ID header",
+			Tags:      tags,
+			Responses: map[int]openapi.Response{http.StatusOK: {Body: model.WorkspaceList{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodGet, "/workspaces/:id/members"): {
+			Summary:   "List the members of a workspace",
+			Tags:      tags,
+			Responses: membersResponses,
+		},
+		openapi.Key(http.MethodPost, "/workspaces/:id/members"): {
+			Summary:     "Add a user to a workspace, only its owner may",
+			Tags:        tags,
+			RequestBody: WorkspaceMemberRequestBody{},
+			Responses:   map[int]openapi.Response{http.StatusOK: {Body: model.WorkspaceMember{}}, 0: problemResponse},
+		},
+		openapi.Key(http.MethodDelete, "/workspaces/:id/members/:user_id"): {
+			Summary:   "Remove a member from a workspace, members may leave on their own",
+			Tags:      tags,
+			Responses: map[int]openapi.Response{http.StatusOK: {}, 0: problemResponse},
+		},
+	}
+}
@@ -0,0 +1,134 @@
+package routes
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/middleware"
+	"github.com/vkuzmich/gin-project/pkg/model"
+)
+
+// errPreconditionRequired is returned for a mutation without If-Match
+// while the server runs with config.Config.RequireIfMatch.
+var errPreconditionRequired = &middleware.StatusError{
+	Status: http.StatusPreconditionRequired,
+	Err:    errors.New("If-Match header is required"),
+}
+
+// todoTaskETag is the strong entity tag of a todo_task, derived from its
+// row version so that it changes with every update.
+func todoTaskETag(todoTask model.TodoTask) string {
+	return `"` + strconv.FormatUint(uint64(todoTask.Version), 10) + `"`
+}
+
+// todoTaskPageETag is the weak entity tag of a page of todo_tasks: a hash
+// of the ids, versions and updates on the page and of the tags rendered
+// with them. Any create, update or delete touching the page changes one or
+// the other, and so does renaming one of its tags. List pages have no
+// Last-Modified, a row leaving the page does not change the latest update
+// of the rows left on it.
+func todoTaskPageETag(page model.TodoTaskPage) string {
+	h := sha256.New()
+	buf := make([]byte, 8)
+	for _, todoTask := range page.Items {
+		binary.BigEndian.PutUint64(buf, uint64(todoTask.ID))
+		h.Write(buf)
+		binary.BigEndian.PutUint64(buf, uint64(todoTask.Version))
+		h.Write(buf)
+		binary.BigEndian.PutUint64(buf, uint64(todoTask.UpdatedAt.UnixNano()))
+		h.Write(buf)
+		for _, tag := range todoTask.Tags {
+			binary.BigEndian.PutUint64(buf, uint64(tag.ID))
+			h.Write(buf)
+			h.Write([]byte(tag.Name))
+			h.Write([]byte{0})
+		}
+		h.Write([]byte{0xff})
+	}
+	if page.HasMore {
+		h.Write([]byte{1})
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// setValidators sets the ETag and, unless lastModified is zero, the
+// Last-Modified response headers and reports whether the client's cached copy is still fresh, in which case
+// a 304 Not Modified has been written and the handler must return.
+func setValidators(ctx *gin.Context, etag string, lastModified time.Time) bool {
+	ctx.Header("ETag", etag)
+	if !lastModified.IsZero() {
+		ctx.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if !notModified(ctx, etag, lastModified) {
+		return false
+	}
+	ctx.AbortWithStatus(http.StatusNotModified)
+	return true
+}
+
+// notModified evaluates If-None-Match and, only when it is absent,
+// If-Modified-Since as described in RFC 9110 section 13.2.2.
+func notModified(ctx *gin.Context, etag string, lastModified time.Time) bool {
+	if header := strings.TrimSpace(ctx.GetHeader("If-None-Match")); header != "" {
+		if header == "*" {
+			return true
+		}
+		// If-None-Match uses weak comparison
+		for _, tag := range strings.Split(header, ",") {
+			if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	header := ctx.GetHeader("If-Modified-Since")
+	if header == "" || lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(header)
+	if err != nil {
+		return false
+	}
+	// HTTP dates have a one second resolution
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// ifMatchVersions parses the If-Match header into the row versions the
+// client accepts. It returns nil when the mutation is unconditional, that
+// is for "*" or, unless the server is strict, for a missing header. Weak
+// and malformed tags never match, as If-Match uses strong comparison.
+func (r TodoTaskResource) ifMatchVersions(ctx *gin.Context) ([]uint, error) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" {
+		if r.requireIfMatch {
+			return nil, errPreconditionRequired
+		}
+		return nil, nil
+	}
+	if header == "*" {
+		return nil, nil
+	}
+
+	versions := []uint{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		version, err := strconv.ParseUint(tag[1:len(tag)-1], 10, 32)
+		if err != nil {
+			continue
+		}
+		versions = append(versions, uint(version))
+	}
+	return versions, nil
+}
@@ -0,0 +1,70 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/vkuzmich/gin-project/pkg/model"
+)
+
+func TestTodoTaskPageETag(t *testing.T) {
+	updatedAt := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	page := func(version uint, tags ...model.Tag) model.TodoTaskPage {
+		todoTask := model.TodoTask{Title: "t", Version: version, Tags: tags}
+		todoTask.ID, todoTask.UpdatedAt = 1, updatedAt
+		return model.TodoTaskPage{Items: []model.TodoTask{todoTask}}
+	}
+
+	etag := todoTaskPageETag(page(1, model.Tag{ID: 1, Name: "home"}))
+	assert.Equal(t, etag, todoTaskPageETag(page(1, model.Tag{ID: 1, Name: "home"})))
+	assert.NotEqual(t, etag, todoTaskPageETag(page(2, model.Tag{ID: 1, Name: "home"})), "an update changes the version")
+	assert.NotEqual(t, etag, todoTaskPageETag(page(1, model.Tag{ID: 1, Name: "house"})), "a renamed tag is rendered in the page")
+	assert.NotEqual(t, etag, todoTaskPageETag(page(1)))
+	assert.NotEqual(t,
+		todoTaskPageETag(page(1, model.Tag{ID: 1, Name: "ab"}, model.Tag{ID: 2, Name: "c"})),
+		todoTaskPageETag(page(1, model.Tag{ID: 1, Name: "a"}, model.Tag{ID: 2, Name: "bc"})))
+}
+
+func TestSetValidators(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lastModified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name         string
+		header       map[string]string
+		lastModified time.Time
+		notModified  bool
+	}{
+		{"no validators", nil, lastModified, false},
+		{"matching If-None-Match", map[string]string{"If-None-Match": `"2", W/"1"`}, lastModified, true},
+		{"other If-None-Match wins over If-Modified-Since", map[string]string{"If-None-Match": `"2"`, "If-Modified-Since": lastModified.Format(http.TimeFormat)}, lastModified, false},
+		{"unchanged since", map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, lastModified, true},
+		{"changed since", map[string]string{"If-Modified-Since": lastModified.Add(-time.Second).Format(http.TimeFormat)}, lastModified, false},
+		// List pages have no Last-Modified
+		{"without Last-Modified", map[string]string{"If-Modified-Since": lastModified.Format(http.TimeFormat)}, time.Time{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			ctx, _ := gin.CreateTestContext(w)
+			ctx.Request = httptest.NewRequest(http.MethodGet, "/todo_tasks/", nil)
+			for name, value := range tt.header {
+				ctx.Request.Header.Set(name, value)
+			}
+
+			assert.Equal(t, tt.notModified, setValidators(ctx, `W/"1"`, tt.lastModified))
+			assert.Equal(t, `W/"1"`, w.Header().Get("ETag"))
+			if tt.lastModified.IsZero() {
+				assert.Empty(t, w.Header().Get("Last-Modified"))
+			} else {
+				assert.Equal(t, tt.lastModified.Format(http.TimeFormat), w.Header().Get("Last-Modified"))
+			}
+			if tt.notModified {
+				assert.Equal(t, http.StatusNotModified, ctx.Writer.Status())
+			}
+		})
+	}
+}
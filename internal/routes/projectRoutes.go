@@ -0,0 +1,206 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/service"
+)
+
+func RegisterProjectHandlers(r *gin.RouterGroup, projectService service.ProjectService) {
+
+	res := ProjectResource{
+		projectService: projectService,
+	}
+
+	project := r.Group("/projects")
+	{
+		project.POST("", writeScope, res.AddProjectRoute)
+		project.GET("", readScope, res.GetProjectsRoute)
+		project.GET("/:id", readScope, res.GetProjectRoute)
+		project.PUT("/:id", writeScope, res.UpdateProjectRoute)
+		project.DELETE("/:id", deleteScope, res.DeleteProjectRoute)
+		project.POST("/:id/archive", writeScope, res.ArchiveProjectRoute)
+		project.POST("/:id/unarchive", writeScope, res.UnarchiveProjectRoute)
+		project.GET("/:id/todo_tasks", readScope, res.GetProjectTodoTasksRoute)
+	}
+}
+
+type ProjectResource struct {
+	projectService service.ProjectService
+}
+
+// ProjectRequestBody represents the request body of creating or replacing
+// a project.
+type ProjectRequestBody struct {
+	Name        string `json:"name"`        // Name of the project
+	Description string `json:"description"` // Description of the project, optional
+}
+
+func (r ProjectResource) AddProjectRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("AddProject endpoint hit")
+
+	body := ProjectRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		logger.Error().Err(err).Msg("Error in Binding project payload from request")
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	project, err := r.projectService.AddProject(ctx, &model.ProjectPayload{Name: body.Name, Description: body.Description})
+	if err != nil {
+		logger.Error().Err(err).Msg("Error in processing project")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("AddProject endpoint successfully created project")
+	ctx.JSON(http.StatusOK, &project)
+}
+
+func (r ProjectResource) GetProjectsRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetProjects endpoint hit")
+
+	params := model.ProjectListParams{Cursor: ctx.Query("cursor")}
+	if raw := ctx.Query("limit"); raw != "" {
+		var err error
+		if params.Limit, err = strconv.Atoi(raw); err != nil || params.Limit < 1 {
+			abortWithError(ctx, invalidQuery("limit", "must be a positive integer"))
+			return
+		}
+	}
+	if raw := ctx.Query("archived"); raw != "" {
+		var err error
+		if params.Archived, err = strconv.ParseBool(raw); err != nil {
+			abortWithError(ctx, invalidQuery("archived", "must be true or false"))
+			return
+		}
+	}
+
+	page, err := r.projectService.GetProjects(ctx, params)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error in getting projects")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &page)
+}
+
+func (r ProjectResource) GetProjectRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetProject endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	project, err := r.projectService.GetProject(ctx, id)
+	if err != nil {
+		logger.Error().Err(err).Str("project_id", id).Msg("Error in getting project")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &project)
+}
+
+func (r ProjectResource) UpdateProjectRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("UpdateProject endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	body := ProjectRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		logger.Error().Err(err).Msg("Error in Binding project payload from request")
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	project, err := r.projectService.UpdateProject(ctx, id, &model.ProjectPayload{Name: body.Name, Description: body.Description})
+	if err != nil {
+		logger.Error().Err(err).Str("project_id", id).Msg("Error in updating project")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("UpdateProject endpoint successfully updated project")
+	ctx.JSON(http.StatusOK, &project)
+}
+
+func (r ProjectResource) DeleteProjectRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("DeleteProject endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	if err := r.projectService.DeleteProject(ctx, id); err != nil {
+		logger.Error().Err(err).Str("project_id", id).Msg("Error in deleting project")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("DeleteProject endpoint successfully deleted project")
+	ctx.Status(http.StatusOK)
+}
+
+func (r ProjectResource) ArchiveProjectRoute(ctx *gin.Context) {
+	r.archiveProject(ctx, true)
+}
+
+func (r ProjectResource) UnarchiveProjectRoute(ctx *gin.Context) {
+	r.archiveProject(ctx, false)
+}
+
+func (r ProjectResource) archiveProject(ctx *gin.Context, archived bool) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Bool("archived", archived).Msg("ArchiveProject endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	project, err := r.projectService.ArchiveProject(ctx, id, archived)
+	if err != nil {
+		logger.Error().Err(err).Str("project_id", id).Msg("Error in archiving project")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &project)
+}
+
+// GetProjectTodoTasksRoute lists the todo_tasks of a project with the
+// query parameters of the todo_tasks list.
+func (r ProjectResource) GetProjectTodoTasksRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetProjectTodoTasks endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	params, err := parseTodoTaskListParams(ctx)
+	if err != nil {
+		logger.Info().Err(err).Msg("invalid todo_tasks list parameters")
+		abortWithError(ctx, err)
+		return
+	}
+
+	page, err := r.projectService.GetProjectTodoTasks(ctx, id, params)
+	if err != nil {
+		logger.Error().Err(err).Str("project_id", id).Msg("Error in getting todo_tasks of project")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &page)
+}
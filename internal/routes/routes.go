@@ -1 +1,236 @@
 package routes
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/middleware"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/service"
+)
+
+// The scope of every route is declared where it is registered. Personal
+// access tokens only reach the routes of their scopes.
+var (
+	readScope   = middleware.RequireScope(auth.ScopeTasksRead)
+	writeScope  = middleware.RequireScope(auth.ScopeTasksWrite)
+	deleteScope = middleware.RequireScope(auth.ScopeTasksDelete)
+)
+
+// abortWithError hands err to middleware.ErrorHandler, which writes the
+// problem+json response, and stops the remaining handlers.
+func abortWithError(ctx *gin.Context, err error) {
+	_ = ctx.Error(err)
+	ctx.Abort()
+}
+
+// invalidQuery reports a malformed query parameter. When allowed is given
+// the message lists the values the client may use instead.
+func invalidQuery(param, message string, allowed ...string) error {
+	if len(allowed) > 0 {
+		message = fmt.Sprintf("%s, allowed: %s", message, strings.Join(allowed, ", "))
+	}
+	return service.NewValidationError("invalid query parameter "+param, model.FieldError{Field: param, Message: message})
+}
+
+// invalidBody reports a request body that could not be decoded.
+func invalidBody(err error) error {
+	return service.NewError(service.ErrValidation, "the request body is not valid JSON for this endpoint", err)
+}
+
+// todoTaskListQuery maps every query parameter accepted by the todo_tasks
+// list endpoint to the function storing it in the list params.
+var todoTaskListQuery = map[string]func(p *model.TodoTaskListParams, v string, loc *time.Location) error{
+	"limit": func(p *model.TodoTaskListParams, v string, _ *time.Location) error {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 {
+			return invalidQuery("limit", "must be a positive integer")
+		}
+		p.Limit = limit
+		return nil
+	},
+	"cursor": func(p *model.TodoTaskListParams, v string, _ *time.Location) error {
+		p.Cursor = v
+		return nil
+	},
+	"status": func(p *model.TodoTaskListParams, v string, _ *time.Location) error {
+		for _, s := range strings.Split(v, ",") {
+			status := model.TodoTaskStatus(strings.TrimSpace(s))
+			if !status.Valid() {
+				return invalidQuery("status", fmt.Sprintf("%q is not a status", status), statusNames()...)
+			}
+			p.Filter.Status = append(p.Filter.Status, status)
+		}
+		return nil
+	},
+	"title": func(p *model.TodoTaskListParams, v string, _ *time.Location) error {
+		p.Filter.Title = v
+		return nil
+	},
+	"tz": func(p *model.TodoTaskListParams, _ string, loc *time.Location) error {
+		p.Filter.Location = loc
+		return nil
+	},
+	"created_after":  timeQuery("created_after", func(p *model.TodoTaskListParams) **time.Time { return &p.Filter.CreatedAfter }),
+	"created_before": timeQuery("created_before", func(p *model.TodoTaskListParams) **time.Time { return &p.Filter.CreatedBefore }),
+	"updated_after":  timeQuery("updated_after", func(p *model.TodoTaskListParams) **time.Time { return &p.Filter.UpdatedAfter }),
+	"updated_before": timeQuery("updated_before", func(p *model.TodoTaskListParams) **time.Time { return &p.Filter.UpdatedBefore }),
+	"due_after":      timeQuery("due_after", func(p *model.TodoTaskListParams) **time.Time { return &p.Filter.DueAfter }),
+	"due_before":     timeQuery("due_before", func(p *model.TodoTaskListParams) **time.Time { return &p.Filter.DueBefore }),
+	"due_today": func(p *model.TodoTaskListParams, v string, _ *time.Location) error {
+		dueToday, err := strconv.ParseBool(v)
+		if err != nil {
+			return invalidQuery("due_today", "must be true or false")
+		}
+		p.Filter.DueToday = dueToday
+		return nil
+	},
+	"overdue": func(p *model.TodoTaskListParams, v string, _ *time.Location) error {
+		overdue, err := strconv.ParseBool(v)
+		if err != nil {
+			return invalidQuery("overdue", "must be true or false")
+		}
+		p.Filter.Overdue = &overdue
+		return nil
+	},
+	"project_id": func(p *model.TodoTaskListParams, v string, _ *time.Location) error {
+		projectID, err := strconv.ParseUint(v, 10, 0)
+		if err != nil || projectID == 0 {
+			return invalidQuery("project_id", "must be a project id")
+		}
+		id := uint(projectID)
+		p.Filter.ProjectID = &id
+		return nil
+	},
+	"tags_any": tagsQuery("tags_any", func(p *model.TodoTaskListParams) *[]string { return &p.Filter.TagsAny }),
+	"tags_all": tagsQuery("tags_all", func(p *model.TodoTaskListParams) *[]string { return &p.Filter.TagsAll }),
+	"sort": func(p *model.TodoTaskListParams, v string, _ *time.Location) error {
+		sortFields, err := parseSort(v, model.TodoTaskSortFields)
+		if err != nil {
+			return err
+		}
+		p.Sort = sortFields
+		return nil
+	},
+}
+
+// timeQuery parses an RFC 3339 timestamp, or a date meaning the start of
+// that day in the time zone of the request.
+func timeQuery(name string, field func(p *model.TodoTaskListParams) **time.Time) func(p *model.TodoTaskListParams, v string, loc *time.Location) error {
+	return func(p *model.TodoTaskListParams, v string, loc *time.Location) error {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			if t, err = time.ParseInLocation(time.DateOnly, v, loc); err != nil {
+				return invalidQuery(name, "must be an RFC 3339 timestamp or a YYYY-MM-DD date")
+			}
+		}
+		*field(p) = &t
+		return nil
+	}
+}
+
+// tagsQuery parses a comma separated list of tag names.
+func tagsQuery(name string, field func(p *model.TodoTaskListParams) *[]string) func(p *model.TodoTaskListParams, v string, _ *time.Location) error {
+	return func(p *model.TodoTaskListParams, v string, _ *time.Location) error {
+		var names []string
+		for _, tag := range strings.Split(v, ",") {
+			if tag = model.NormalizeTagName(tag); tag == "" {
+				return invalidQuery(name, "must be a comma separated list of tag names")
+			}
+			names = append(names, tag)
+		}
+		*field(p) = names
+		return nil
+	}
+}
+
+// queryLocation reads the IANA time zone of the tz query parameter, the
+// dates and "today" of a request are evaluated in it. UTC is the default.
+func queryLocation(ctx *gin.Context) (*time.Location, error) {
+	name := ctx.Query("tz")
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil || name == "Local" {
+		return nil, invalidQuery("tz", "must be an IANA time zone such as Europe/Kyiv")
+	}
+	return loc, nil
+}
+
+// parseTodoTaskListParams reads the pagination, filter and sort query
+// parameters of a list request. Unknown parameters are rejected so that a
+// typo does not silently return an unfiltered list.
+func parseTodoTaskListParams(ctx *gin.Context) (model.TodoTaskListParams, error) {
+	params := model.TodoTaskListParams{}
+
+	loc, err := queryLocation(ctx)
+	if err != nil {
+		return model.TodoTaskListParams{}, err
+	}
+
+	for name, values := range ctx.Request.URL.Query() {
+		set, ok := todoTaskListQuery[name]
+		if !ok {
+			return model.TodoTaskListParams{}, invalidQuery(name, "is not a known parameter", queryNames(todoTaskListQuery)...)
+		}
+		if err := set(&params, values[len(values)-1], loc); err != nil {
+			return model.TodoTaskListParams{}, err
+		}
+	}
+	return params, nil
+}
+
+// parseSort parses a comma separated sort such as "-updated_at,title",
+// where a leading '-' means descending.
+func parseSort(v string, allowed []string) ([]model.SortField, error) {
+	var sortFields []model.SortField
+	seen := map[string]bool{}
+	for _, key := range strings.Split(v, ",") {
+		key = strings.TrimSpace(key)
+		f := model.SortField{Field: strings.TrimPrefix(key, "-"), Desc: strings.HasPrefix(key, "-")}
+		if !slices.Contains(allowed, f.Field) {
+			return nil, invalidQuery("sort", fmt.Sprintf("%q is not a sortable field", f.Field), allowed...)
+		}
+		if seen[f.Field] {
+			return nil, invalidQuery("sort", fmt.Sprintf("%q is given twice", f.Field))
+		}
+		seen[f.Field] = true
+		sortFields = append(sortFields, f)
+	}
+	return sortFields, nil
+}
+
+func queryNames[T any](query map[string]T) []string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func statusNames() []string {
+	names := make([]string, len(model.TodoTaskStatuses))
+	for i, s := range model.TodoTaskStatuses {
+		names[i] = string(s)
+	}
+	return names
+}
+
+// parseID reads the path parameter name holding the id of a resource. Ids
+// are positive integers, anything else is refused before it reaches a
+// query.
+func parseID(ctx *gin.Context, name string) (string, error) {
+	id, err := strconv.ParseUint(ctx.Param(name), 10, 0)
+	if err != nil || id == 0 {
+		return "", service.NewValidationError("invalid path parameter "+name, model.FieldError{Field: name, Message: "must be a positive integer id"})
+	}
+	return strconv.FormatUint(id, 10), nil
+}
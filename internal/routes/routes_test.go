@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/vkuzmich/gin-project/pkg/service"
+)
+
+func TestParseID(t *testing.T) {
+	tests := []struct {
+		param string
+		id    string
+		valid bool
+	}{
+		{"42", "42", true},
+		{"007", "7", true},
+		{"0", "", false},
+		{"-1", "", false},
+		{"abc", "", false},
+		{"1 OR 1=1", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.param, func(t *testing.T) {
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Params = gin.Params{{Key: "id", Value: tt.param}}
+			id, err := parseID(ctx, "id")
+			assert.Equal(t, tt.id, id)
+			assert.Equal(t, !tt.valid, errors.Is(err, service.ErrValidation))
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/service"
+)
+
+func RegisterShareHandlers(r *gin.RouterGroup, shareService service.ShareService) {
+
+	res := ShareResource{
+		shareService: shareService,
+	}
+
+	todoTask := r.Group("/todo_tasks/:id")
+	{
+		todoTask.GET("/permissions", readScope, res.GetTodoTaskPermissionsRoute)
+		todoTask.GET("/shares", readScope, res.GetTodoTaskSharesRoute)
+		todoTask.POST("/shares", writeScope, res.ShareTodoTaskRoute)
+		todoTask.DELETE("/shares/:user_id", deleteScope, res.UnshareTodoTaskRoute)
+	}
+	project := r.Group("/projects/:id")
+	{
+		project.GET("/permissions", readScope, res.GetProjectPermissionsRoute)
+		project.GET("/shares", readScope, res.GetProjectSharesRoute)
+		project.POST("/shares", writeScope, res.ShareProjectRoute)
+		project.DELETE("/shares/:user_id", deleteScope, res.UnshareProjectRoute)
+	}
+}
+
+type ShareResource struct {
+	shareService service.ShareService
+}
+
+// ShareRequestBody represents the request body of sharing a project or a
+// todo_task.
+type ShareRequestBody struct {
+	Email string    `json:"email"` // Email the user registered with
+	Role  auth.Role `json:"role"`  // viewer, commenter, editor or owner
+}
+
+func (r ShareResource) GetTodoTaskPermissionsRoute(ctx *gin.Context) {
+	r.getPermissions(ctx, "GetTodoTaskPermissions", r.shareService.GetTodoTaskPermissions)
+}
+
+func (r ShareResource) GetTodoTaskSharesRoute(ctx *gin.Context) {
+	r.getShares(ctx, "GetTodoTaskShares", r.shareService.GetTodoTaskShares)
+}
+
+func (r ShareResource) ShareTodoTaskRoute(ctx *gin.Context) {
+	r.share(ctx, "ShareTodoTask", r.shareService.ShareTodoTask)
+}
+
+func (r ShareResource) UnshareTodoTaskRoute(ctx *gin.Context) {
+	r.unshare(ctx, "UnshareTodoTask", r.shareService.UnshareTodoTask)
+}
+
+func (r ShareResource) GetProjectPermissionsRoute(ctx *gin.Context) {
+	r.getPermissions(ctx, "GetProjectPermissions", r.shareService.GetProjectPermissions)
+}
+
+func (r ShareResource) GetProjectSharesRoute(ctx *gin.Context) {
+	r.getShares(ctx, "GetProjectShares", r.shareService.GetProjectShares)
+}
+
+func (r ShareResource) ShareProjectRoute(ctx *gin.Context) {
+	r.share(ctx, "ShareProject", r.shareService.ShareProject)
+}
+
+func (r ShareResource) UnshareProjectRoute(ctx *gin.Context) {
+	r.unshare(ctx, "UnshareProject", r.shareService.UnshareProject)
+}
+
+// The routes of the todo_tasks and of the projects only differ in the
+// service method they call.
+
+func (r ShareResource) getPermissions(ctx *gin.Context, endpoint string, get func(*gin.Context, string) (model.Permissions, error)) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg(endpoint + " endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	permissions, err := get(ctx, id)
+	if err != nil {
+		logger.Error().Err(err).Str("id", id).Msg("Error in getting permissions")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &permissions)
+}
+
+func (r ShareResource) getShares(ctx *gin.Context, endpoint string, get func(*gin.Context, string) (model.ShareList, error)) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg(endpoint + " endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	shares, err := get(ctx, id)
+	if err != nil {
+		logger.Error().Err(err).Str("id", id).Msg("Error in getting shares")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &shares)
+}
+
+func (r ShareResource) share(ctx *gin.Context, endpoint string, share func(*gin.Context, string, *model.SharePayload) (model.Share, error)) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg(endpoint + " endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	body := ShareRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		logger.Error().Err(err).Msg("Error in Binding share payload from request")
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	created, err := share(ctx, id, &model.SharePayload{Email: body.Email, Role: body.Role})
+	if err != nil {
+		logger.Error().Err(err).Str("id", id).Msg("Error in sharing")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg(endpoint + " endpoint successfully shared")
+	ctx.JSON(http.StatusOK, &created)
+}
+
+func (r ShareResource) unshare(ctx *gin.Context, endpoint string, unshare func(*gin.Context, string, string) error) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg(endpoint + " endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	userID, err := parseID(ctx, "user_id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	if err := unshare(ctx, id, userID); err != nil {
+		logger.Error().Err(err).Str("id", id).Msg("Error in unsharing")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg(endpoint + " endpoint successfully unshared")
+	ctx.Status(http.StatusOK)
+}
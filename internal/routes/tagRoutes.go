@@ -0,0 +1,131 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/service"
+)
+
+func RegisterTagHandlers(r *gin.RouterGroup, tagService service.TagService) {
+
+	res := TagResource{
+		tagService: tagService,
+	}
+
+	tag := r.Group("/tags")
+	{
+		tag.POST("/", writeScope, res.AddTagRoute)
+		tag.GET("/", readScope, res.GetTagsRoute)
+		tag.GET("/:id", readScope, res.GetTagRoute)
+		tag.PUT("/:id", writeScope, res.UpdateTagRoute)
+		tag.DELETE("/:id", deleteScope, res.DeleteTagRoute)
+	}
+}
+
+type TagResource struct {
+	tagService service.TagService
+}
+
+// TagRequestBody represents the request body of creating or renaming a tag.
+type TagRequestBody struct {
+	Name string `json:"name"` // Stored trimmed and lowercased
+}
+
+func (r TagResource) AddTagRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("AddTag endpoint hit")
+
+	body := TagRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		logger.Error().Err(err).Msg("Error in Binding tag payload from request")
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	tag, err := r.tagService.AddTag(ctx, &model.TagPayload{Name: body.Name})
+	if err != nil {
+		logger.Error().Err(err).Msg("Error in processing tag")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("AddTag endpoint successfully created tag")
+	ctx.JSON(http.StatusOK, &tag)
+}
+
+func (r TagResource) GetTagsRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetTags endpoint hit")
+
+	tags, err := r.tagService.GetTags(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error in getting tags")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &tags)
+}
+
+func (r TagResource) GetTagRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetTag endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	tag, err := r.tagService.GetTag(ctx, id)
+	if err != nil {
+		logger.Error().Err(err).Str("tag_id", id).Msg("Error in getting tag")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &tag)
+}
+
+func (r TagResource) UpdateTagRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("UpdateTag endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	body := TagRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		logger.Error().Err(err).Msg("Error in Binding tag payload from request")
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	tag, err := r.tagService.UpdateTag(ctx, id, &model.TagPayload{Name: body.Name})
+	if err != nil {
+		logger.Error().Err(err).Str("tag_id", id).Msg("Error in updating tag")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("UpdateTag endpoint successfully updated tag")
+	ctx.JSON(http.StatusOK, &tag)
+}
+
+func (r TagResource) DeleteTagRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("DeleteTag endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	if err := r.tagService.DeleteTag(ctx, id); err != nil {
+		logger.Error().Err(err).Str("tag_id", id).Msg("Error in deleting tag")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("DeleteTag endpoint successfully deleted tag")
+	ctx.Status(http.StatusOK)
+}
@@ -1,42 +1,113 @@
 package routes
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/config"
 	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/internal/middleware"
+	"github.com/vkuzmich/gin-project/pkg/auth"
 	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/patch"
 	"github.com/vkuzmich/gin-project/pkg/service"
-	"gorm.io/gorm"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func RegisterTodoTaskHandlers(
 	r *gin.RouterGroup,
 	todoTaskService service.TodoTaskService,
+	idempotencyService service.IdempotencyService,
+	cfg config.Config,
 ) {
 
-	res := TodoTaskResource{todoTaskService}
+	res := TodoTaskResource{
+		todoTaskService: todoTaskService,
+		requireIfMatch:  cfg.RequireIfMatch,
+	}
+
+	// Retries of these carry an Idempotency-Key and get the first response.
+	idempotent := middleware.Idempotency(idempotencyService)
 
 	todoTask := r.Group("/todo_tasks")
 	{
-		todoTask.POST("/", res.AddTodoTaskRoute)
-		todoTask.GET("/", res.GetTodoTasksRoute)
-		todoTask.GET("/:id", res.GetTodoTaskRoute)
-		todoTask.PUT("/:id", res.UpdateTodoTaskRoute)
-		todoTask.DELETE("/:id", res.DeleteTodoTaskRoute)
+		todoTask.POST("/", writeScope, idempotent, res.AddTodoTaskRoute)
+		// Bulk deletes also need tasks:delete, checked by the handler
+		todoTask.POST("/bulk", writeScope, idempotent, res.BulkTodoTasksRoute)
+		todoTask.GET("/", readScope, res.GetTodoTasksRoute)
+		todoTask.GET("/search", readScope, res.SearchTodoTasksRoute)
+		todoTask.GET("/trash", readScope, res.GetTrashedTodoTasksRoute)
+		todoTask.GET("/:id", readScope, res.GetTodoTaskRoute)
+		todoTask.PUT("/:id", writeScope, res.UpdateTodoTaskRoute)
+		todoTask.PATCH("/:id", writeScope, idempotent, res.PatchTodoTaskRoute)
+		todoTask.DELETE("/:id", deleteScope, res.DeleteTodoTaskRoute)
+		todoTask.POST("/:id/restore", writeScope, res.RestoreTodoTaskRoute)
+		todoTask.POST("/:id/transitions", writeScope, res.TransitionTodoTaskRoute)
+		todoTask.GET("/:id/tree", readScope, res.GetTodoTaskTreeRoute)
+		todoTask.POST("/:id/move", writeScope, res.MoveTodoTaskRoute)
+		todoTask.POST("/:id/move_to_project", writeScope, res.MoveTodoTaskToProjectRoute)
+		todoTask.GET("/:id/occurrences", readScope, res.GetTodoTaskOccurrencesRoute)
+		todoTask.GET("/:id/dependencies", readScope, res.GetTodoTaskDependenciesRoute)
+		todoTask.POST("/:id/blockers", writeScope, res.AddTodoTaskBlockerRoute)
+		// Removing a blocker edits the todo_task, it deletes no todo_task
+		todoTask.DELETE("/:id/blockers/:blocker_id", writeScope, res.DeleteTodoTaskBlockerRoute)
 	}
 }
 
 type TodoTaskResource struct {
 	todoTaskService service.TodoTaskService
+	requireIfMatch  bool
 }
 
 // AddTodoTaskRequestBody represents the structure of the request body
 // expected when adding a new todo task.
 type TodoTaskRequestBody struct {
-	Title       string `json:"title"`       // Title of the todo task
-	Description string `json:"description"` // Description of the todo task
-	State       bool   `json:"state"`       // State of the todo task (completed or not)
+	Title       string               `json:"title"`       // Title of the todo task
+	Description string               `json:"description"` // Description of the todo task
+	Status      model.TodoTaskStatus `json:"status"`      // Workflow status, todo when omitted on create
+	StartAt     *time.Time           `json:"start_at"`    // When work may start, optional
+	DueAt       *time.Time           `json:"due_at"`      // When the todo task is due, optional
+	Tags        []string             `json:"tags"`        // Tag names, missing tags are created; omit to keep the tags on update
+	ParentID    *uint                `json:"parent_id"`   // Parent todo task on create, use move to change it
+	ProjectID   *uint                `json:"project_id"`  // Project on create, the parent's for a subtask; use move_to_project to change it
+	Recurrence  string               `json:"recurrence"`  // RFC 5545 RRULE, the next occurrence is created when this one is done
+	Timezone    string               `json:"timezone"`    // IANA time zone the recurrence follows, UTC when omitted
+}
+
+// payload returns the todo task described by the request body.
+func (b TodoTaskRequestBody) payload() model.TodoTaskPayload {
+	return model.TodoTaskPayload{
+		Title:       b.Title,
+		Description: b.Description,
+		Status:      b.Status,
+		StartAt:     b.StartAt,
+		DueAt:       b.DueAt,
+		Tags:        b.Tags,
+		ParentID:    b.ParentID,
+		ProjectID:   b.ProjectID,
+		Recurrence:  b.Recurrence,
+		Timezone:    b.Timezone,
+	}
+}
+
+// TodoTaskTransitionRequestBody names the status to move a todo task to.
+type TodoTaskTransitionRequestBody struct {
+	Status model.TodoTaskStatus `json:"status"`
+}
+
+// TodoTaskMoveRequestBody names the new parent of a todo task, null or
+// omitted makes it a top-level todo task.
+type TodoTaskMoveRequestBody struct {
+	ParentID *uint `json:"parent_id"`
+}
+
+// TodoTaskMoveToProjectRequestBody names the new project of a top-level
+// todo task and its subtasks, null or omitted takes them out of any project.
+type TodoTaskMoveToProjectRequestBody struct {
+	ProjectID *uint `json:"project_id"`
 }
 
 func (r TodoTaskResource) AddTodoTaskRoute(ctx *gin.Context) {
@@ -45,24 +116,20 @@ func (r TodoTaskResource) AddTodoTaskRoute(ctx *gin.Context) {
 
 	body := TodoTaskRequestBody{}
 	// Receive request body
-	if err := ctx.BindJSON(&body); err != nil {
+	if err := ctx.ShouldBindJSON(&body); err != nil {
 		logger.Error().Err(err).Msg("Error in Binding todo_task payload from request")
-		ctx.AbortWithError(http.StatusBadRequest, err)
+		abortWithError(ctx, invalidBody(err))
 		return
 	}
 
 	// Assign values from request body to todoTask
-	todoTask := model.TodoTaskPayload{
-		Title:       body.Title,
-		Description: body.Description,
-		State:       body.State,
-	}
+	todoTask := body.payload()
 
 	// Create todoTask in the database
 	result, err := r.todoTaskService.AddTodoTask(ctx, &todoTask)
 	if err != nil {
 		logger.Error().Err(err).Msg("Error in processing todo_task")
-		ctx.AbortWithError(http.StatusInternalServerError, err)
+		abortWithError(ctx, err)
 		return
 	}
 	logger.Info().Msg("AddTodoTask endpoint successfully created todo_task")
@@ -73,36 +140,84 @@ func (r TodoTaskResource) AddTodoTaskRoute(ctx *gin.Context) {
 func (r TodoTaskResource) GetTodoTasksRoute(ctx *gin.Context) {
 	logger := contextLogger.ContextLog(ctx)
 	logger.Info().Msg("GetTodoTasks endpoint hit")
-	// Retrieve todo_tasks from the database.
-	todoTasks, err := r.todoTaskService.GetTodoTasks(ctx)
+
+	params, err := parseTodoTaskListParams(ctx)
+	if err != nil {
+		logger.Info().Err(err).Msg("invalid todo_tasks list parameters")
+		abortWithError(ctx, err)
+		return
+	}
+
+	// Retrieve a page of todo_tasks from the database.
+	page, err := r.todoTaskService.GetTodoTasks(ctx, params)
 	if err != nil {
-		if errors.Is(gorm.ErrRecordNotFound, err) {
-			logger.Info().Msg("no todo_tasks")
-			ctx.AbortWithError(http.StatusNotFound, err)
-			return
-		}
 		logger.Error().Err(err).Msg("Error in getting todo_tasks")
 		// Abort the request with an error if retrieval fails.
-		ctx.AbortWithError(http.StatusBadRequest, err)
+		abortWithError(ctx, err)
+		return
+	}
+
+	if setValidators(ctx, todoTaskPageETag(page), time.Time{}) {
+		logger.Info().Msg("todo_tasks page not modified")
+		return
+	}
+
+	// Respond with the retrieved page of todo tasks.
+	ctx.JSON(http.StatusOK, &page)
+}
+
+func (r TodoTaskResource) SearchTodoTasksRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("SearchTodoTasks endpoint hit")
+
+	text := ctx.Query("q")
+	if strings.TrimSpace(text) == "" {
+		abortWithError(ctx, invalidQuery("q", "must not be empty"))
+		return
+	}
+
+	limit := 0
+	if raw := ctx.Query("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 {
+			abortWithError(ctx, invalidQuery("limit", "must be a positive integer"))
+			return
+		}
+	}
+
+	// Retrieve the best matching todo_tasks from the database.
+	results, err := r.todoTaskService.SearchTodoTasks(ctx, text, limit)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error in searching todo_tasks")
+		abortWithError(ctx, err)
 		return
 	}
 
-	// Respond with the retrieved todo tasks.
-	ctx.JSON(http.StatusOK, &todoTasks)
+	// Respond with the ranked search results.
+	ctx.JSON(http.StatusOK, &results)
 }
 
 func (r TodoTaskResource) GetTodoTaskRoute(ctx *gin.Context) {
 	logger := contextLogger.ContextLog(ctx)
 	logger.Info().Msg("GetTodoTask endpoint hit")
 	// Extract the ID parameter from the request URL.
-	id := ctx.Param("id")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
 
 	// Retrieve the todo_task from the database by its ID.
 	todoTask, err := r.todoTaskService.GetTodoTask(ctx, id)
 	if err != nil {
 		// Abort the request with an error if retrieval fails.
 		logger.Error().Err(err).Str("todo_task_id", id).Msg("Error in getting todo_task")
-		ctx.AbortWithError(http.StatusNotFound, err)
+		abortWithError(ctx, err)
+		return
+	}
+
+	if setValidators(ctx, todoTaskETag(todoTask), todoTask.UpdatedAt) {
+		logger.Info().Str("todo_task_id", id).Msg("todo_task not modified")
 		return
 	}
 
@@ -113,32 +228,78 @@ func (r TodoTaskResource) GetTodoTaskRoute(ctx *gin.Context) {
 
 func (r TodoTaskResource) UpdateTodoTaskRoute(ctx *gin.Context) {
 	// Extract the ID parameter from the request URL.
-	id := ctx.Param("id")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	versions, err := r.ifMatchVersions(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
 
 	// Declare a variable to store the request body.
 	body := TodoTaskRequestBody{}
 
 	// Receive request body.
-	if err := ctx.BindJSON(&body); err != nil {
-		ctx.AbortWithError(http.StatusBadRequest, err)
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		abortWithError(ctx, invalidBody(err))
 		return
 	}
 
 	// Assign values from request body to todoTask
-	todoTaskPayload := model.TodoTaskPayload{
-		Title:       body.Title,
-		Description: body.Description,
-		State:       body.State,
-	}
+	todoTaskPayload := body.payload()
 
 	// Retrieve the todo_task from the database by its ID.
-	todoTask, err := r.todoTaskService.UpdateTodoTask(ctx, id, &todoTaskPayload)
+	todoTask, err := r.todoTaskService.UpdateTodoTask(ctx, id, versions, &todoTaskPayload)
 	if err != nil {
-		ctx.AbortWithError(http.StatusNotFound, err)
+		abortWithError(ctx, err)
 		return
 	}
 
 	// Respond with the updated todo_task.
+	ctx.Header("ETag", todoTaskETag(todoTask))
+	ctx.JSON(http.StatusOK, &todoTask)
+}
+
+func (r TodoTaskResource) PatchTodoTaskRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("PatchTodoTask endpoint hit")
+	// Extract the ID parameter from the request URL.
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	versions, err := r.ifMatchVersions(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	// Receive the raw patch document, it is interpreted by its Content-Type.
+	patchDocument, err := ctx.GetRawData()
+	if err != nil {
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	todoTask, err := r.todoTaskService.PatchTodoTask(ctx, id, versions, ctx.GetHeader("Content-Type"), patchDocument)
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("Error in patching todo_task")
+		if errors.Is(err, patch.ErrUnsupportedContentType) {
+			ctx.Header("Accept-Patch", patch.MergePatchContentType+", "+patch.JSONPatchContentType)
+			err = &middleware.StatusError{Status: http.StatusUnsupportedMediaType, Err: err}
+		}
+		abortWithError(ctx, err)
+		return
+	}
+
+	// Respond with the patched todo_task.
+	ctx.Header("ETag", todoTaskETag(todoTask))
 	ctx.JSON(http.StatusOK, &todoTask)
 }
 
@@ -146,17 +307,412 @@ func (r TodoTaskResource) DeleteTodoTaskRoute(ctx *gin.Context) {
 	logger := contextLogger.ContextLog(ctx)
 	logger.Info().Msg("DeleteTodoTask endpoint hit")
 	// Extract the ID parameter from the request URL.
-	id := ctx.Param("id")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
 
-	// Retrieve the todo_task from the database by its ID.
-	err := r.todoTaskService.DeleteTodoTask(ctx, id)
+	versions, err := r.ifMatchVersions(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	permanent := false
+	if raw := ctx.Query("permanent"); raw != "" {
+		if permanent, err = strconv.ParseBool(raw); err != nil {
+			abortWithError(ctx, invalidQuery("permanent", "must be true or false"))
+			return
+		}
+	}
+
+	// Move the todo_task to the trash, or delete it for good if asked to.
+	if permanent {
+		err = r.todoTaskService.PurgeTodoTask(ctx, id, versions)
+	} else {
+		err = r.todoTaskService.DeleteTodoTask(ctx, id, versions)
+	}
 	if err != nil {
 		// Abort the request with an error if retrieval fails.
 		logger.Error().Err(err).Str("todo_task_id", id).Msg("Error in deleting todo_task")
-		ctx.AbortWithError(http.StatusNotFound, err)
+		abortWithError(ctx, err)
 		return
 	}
 
 	// Respond with a success status.
 	ctx.Status(http.StatusOK)
 }
+
+func (r TodoTaskResource) GetTrashedTodoTasksRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetTrashedTodoTasks endpoint hit")
+
+	params, err := parseTodoTaskListParams(ctx)
+	if err != nil {
+		logger.Info().Err(err).Msg("invalid todo_tasks list parameters")
+		abortWithError(ctx, err)
+		return
+	}
+
+	// Retrieve a page of soft-deleted todo_tasks from the database.
+	page, err := r.todoTaskService.GetTrashedTodoTasks(ctx, params)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error in getting trashed todo_tasks")
+		abortWithError(ctx, err)
+		return
+	}
+
+	// Respond with the retrieved page of trashed todo tasks.
+	ctx.JSON(http.StatusOK, &page)
+}
+
+func (r TodoTaskResource) RestoreTodoTaskRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("RestoreTodoTask endpoint hit")
+	// Extract the ID parameter from the request URL.
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	versions, err := r.ifMatchVersions(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	todoTask, err := r.todoTaskService.RestoreTodoTask(ctx, id, versions)
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("Error in restoring todo_task")
+		abortWithError(ctx, err)
+		return
+	}
+
+	// Respond with the restored todo_task.
+	ctx.Header("ETag", todoTaskETag(todoTask))
+	ctx.JSON(http.StatusOK, &todoTask)
+}
+
+func (r TodoTaskResource) TransitionTodoTaskRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("TransitionTodoTask endpoint hit")
+	// Extract the ID parameter from the request URL.
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	versions, err := r.ifMatchVersions(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	body := TodoTaskTransitionRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	todoTask, err := r.todoTaskService.TransitionTodoTask(ctx, id, versions, body.Status)
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("Error in transitioning todo_task")
+		abortWithError(ctx, err)
+		return
+	}
+
+	// Respond with the todo_task at its new status.
+	ctx.Header("ETag", todoTaskETag(todoTask))
+	ctx.JSON(http.StatusOK, &todoTask)
+}
+
+func (r TodoTaskResource) GetTodoTaskTreeRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetTodoTaskTree endpoint hit")
+	// Extract the ID parameter from the request URL.
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	tree, err := r.todoTaskService.GetTodoTaskTree(ctx, id)
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("Error in getting todo_task tree")
+		abortWithError(ctx, err)
+		return
+	}
+
+	// Respond with the todo_task and its subtasks.
+	ctx.JSON(http.StatusOK, &tree)
+}
+
+func (r TodoTaskResource) MoveTodoTaskRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("MoveTodoTask endpoint hit")
+	// Extract the ID parameter from the request URL.
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	versions, err := r.ifMatchVersions(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	body := TodoTaskMoveRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	todoTask, err := r.todoTaskService.MoveTodoTask(ctx, id, versions, body.ParentID)
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("Error in moving todo_task")
+		abortWithError(ctx, err)
+		return
+	}
+
+	// Respond with the todo_task under its new parent.
+	ctx.Header("ETag", todoTaskETag(todoTask))
+	ctx.JSON(http.StatusOK, &todoTask)
+}
+
+func (r TodoTaskResource) MoveTodoTaskToProjectRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("MoveTodoTaskToProject endpoint hit")
+	// Extract the ID parameter from the request URL.
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	versions, err := r.ifMatchVersions(ctx)
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	body := TodoTaskMoveToProjectRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	todoTask, err := r.todoTaskService.MoveTodoTaskToProject(ctx, id, versions, body.ProjectID)
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("Error in moving todo_task to project")
+		abortWithError(ctx, err)
+		return
+	}
+
+	// Respond with the todo_task in its new project.
+	ctx.Header("ETag", todoTaskETag(todoTask))
+	ctx.JSON(http.StatusOK, &todoTask)
+}
+
+func (r TodoTaskResource) GetTodoTaskOccurrencesRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetTodoTaskOccurrences endpoint hit")
+	// Extract the ID parameter from the request URL.
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	count := 0
+	if raw := ctx.Query("count"); raw != "" {
+		var err error
+		if count, err = strconv.Atoi(raw); err != nil || count < 1 {
+			abortWithError(ctx, invalidQuery("count", "must be a positive integer"))
+			return
+		}
+	}
+
+	occurrences, err := r.todoTaskService.GetTodoTaskOccurrences(ctx, id, count)
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("Error in getting todo_task occurrences")
+		abortWithError(ctx, err)
+		return
+	}
+
+	// Respond with the upcoming occurrences.
+	ctx.JSON(http.StatusOK, &occurrences)
+}
+
+// DOTContentType is the media type of Graphviz DOT documents.
+const DOTContentType = "text/vnd.graphviz; charset=utf-8"
+
+// TodoTaskBlockerRequestBody names a todo task blocking the one in the path.
+type TodoTaskBlockerRequestBody struct {
+	BlockerID *uint `json:"blocker_id"`
+}
+
+func (r TodoTaskResource) GetTodoTaskDependenciesRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetTodoTaskDependencies endpoint hit")
+	// Extract the ID parameter from the request URL.
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	format := ctx.DefaultQuery("format", "json")
+	if format != "json" && format != "dot" {
+		abortWithError(ctx, invalidQuery("format", "is not a known format", "dot", "json"))
+		return
+	}
+
+	graph, err := r.todoTaskService.GetTodoTaskDependencyGraph(ctx, id)
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("Error in getting todo_task dependencies")
+		abortWithError(ctx, err)
+		return
+	}
+
+	// Respond with the graph in the requested format.
+	if format == "dot" {
+		ctx.Data(http.StatusOK, DOTContentType, []byte(graph.DOT()))
+		return
+	}
+	ctx.JSON(http.StatusOK, &graph)
+}
+
+func (r TodoTaskResource) AddTodoTaskBlockerRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("AddTodoTaskBlocker endpoint hit")
+	// Extract the ID parameter from the request URL.
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	body := TodoTaskBlockerRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	dependency, err := r.todoTaskService.AddTodoTaskDependency(ctx, id, body.BlockerID)
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("Error in adding todo_task blocker")
+		abortWithError(ctx, err)
+		return
+	}
+
+	// Respond with the stored edge.
+	ctx.JSON(http.StatusOK, &dependency)
+}
+
+func (r TodoTaskResource) DeleteTodoTaskBlockerRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("DeleteTodoTaskBlocker endpoint hit")
+	// Extract the ID parameters from the request URL.
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	blockerID, err := parseID(ctx, "blocker_id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	if err := r.todoTaskService.DeleteTodoTaskDependency(ctx, id, blockerID); err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("Error in deleting todo_task blocker")
+		abortWithError(ctx, err)
+		return
+	}
+
+	// Respond with a success status.
+	ctx.Status(http.StatusOK)
+}
+
+// TodoTaskBulkRequestBody is the body of a bulk request. Mode defaults to
+// all_or_nothing.
+type TodoTaskBulkRequestBody struct {
+	Mode       model.BulkMode              `json:"mode"`
+	Operations []TodoTaskBulkOperationBody `json:"operations"`
+}
+
+// TodoTaskBulkOperationBody is one operation of a bulk request. ID is
+// required by update and delete, TodoTask by create and update.
+type TodoTaskBulkOperationBody struct {
+	Op       string               `json:"op"`
+	ID       json.Number          `json:"id,omitempty"`
+	Version  *uint                `json:"version,omitempty"` // like If-Match, the operation fails on another version
+	TodoTask *TodoTaskRequestBody `json:"todo_task,omitempty"`
+}
+
+// TodoTaskBulkResponse reports the outcome of every operation, in order.
+type TodoTaskBulkResponse struct {
+	Committed bool                     `json:"committed"`
+	Results   []TodoTaskBulkItemResult `json:"results"`
+}
+
+type TodoTaskBulkItemResult struct {
+	Op       string              `json:"op"`
+	Status   int                 `json:"status"`
+	TodoTask *model.TodoTask     `json:"todo_task,omitempty"`
+	Error    *middleware.Problem `json:"error,omitempty"`
+}
+
+func (r TodoTaskResource) BulkTodoTasksRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("BulkTodoTasks endpoint hit")
+
+	body := TodoTaskBulkRequestBody{Mode: model.BulkAllOrNothing}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		logger.Error().Err(err).Msg("Error in Binding bulk payload from request")
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	principal, _ := auth.PrincipalFromContext(ctx)
+	operations := make([]model.TodoTaskBulkOperation, len(body.Operations))
+	for i, op := range body.Operations {
+		if op.Op == model.BulkDelete && !principal.HasScope(auth.ScopeTasksDelete) {
+			logger.Info().Msg("bulk delete without the delete scope")
+			middleware.InsufficientScope(ctx, auth.ScopeTasksDelete)
+			return
+		}
+		operations[i] = model.TodoTaskBulkOperation{Op: op.Op, ID: op.ID.String(), Version: op.Version}
+		if op.TodoTask != nil {
+			payload := op.TodoTask.payload()
+			operations[i].Payload = &payload
+		}
+	}
+
+	results, committed, err := r.todoTaskService.BulkTodoTasks(ctx, body.Mode, operations)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error in bulk processing todo_tasks")
+		abortWithError(ctx, err)
+		return
+	}
+
+	// 207 tells the client to look at the results, some operations failed.
+	status := http.StatusOK
+	response := TodoTaskBulkResponse{Committed: committed, Results: make([]TodoTaskBulkItemResult, len(results))}
+	for i, result := range results {
+		item := TodoTaskBulkItemResult{Op: body.Operations[i].Op, Status: http.StatusOK, TodoTask: result.TodoTask}
+		if result.Err != nil {
+			problem := middleware.NewProblem(result.Err)
+			item.Status = problem.Status
+			item.Error = &problem
+			status = http.StatusMultiStatus
+		}
+		response.Results[i] = item
+	}
+	ctx.JSON(status, &response)
+}
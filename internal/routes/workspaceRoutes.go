@@ -0,0 +1,143 @@
+package routes
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/service"
+)
+
+func RegisterWorkspaceHandlers(r *gin.RouterGroup, workspaceService service.WorkspaceService) {
+
+	res := WorkspaceResource{
+		workspaceService: workspaceService,
+	}
+
+	workspace := r.Group("/workspaces")
+	{
+		workspace.POST("", writeScope, res.AddWorkspaceRoute)
+		workspace.GET("", readScope, res.GetWorkspacesRoute)
+		workspace.GET("/:id/members", readScope, res.GetWorkspaceMembersRoute)
+		workspace.POST("/:id/members", writeScope, res.AddWorkspaceMemberRoute)
+		workspace.DELETE("/:id/members/:user_id", deleteScope, res.RemoveWorkspaceMemberRoute)
+	}
+}
+
+type WorkspaceResource struct {
+	workspaceService service.WorkspaceService
+}
+
+// WorkspaceRequestBody represents the request body of creating a
+// workspace.
+type WorkspaceRequestBody struct {
+	Name string `json:"name"` // Name of the workspace
+}
+
+// WorkspaceMemberRequestBody represents the request body of adding a
+// member to a workspace.
+type WorkspaceMemberRequestBody struct {
+	Email string `json:"email"` // Email the user registered with
+}
+
+func (r WorkspaceResource) AddWorkspaceRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("AddWorkspace endpoint hit")
+
+	body := WorkspaceRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		logger.Error().Err(err).Msg("Error in Binding workspace payload from request")
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	workspace, err := r.workspaceService.AddWorkspace(ctx, &model.WorkspacePayload{Name: body.Name})
+	if err != nil {
+		logger.Error().Err(err).Msg("Error in processing workspace")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("AddWorkspace endpoint successfully created workspace")
+	ctx.JSON(http.StatusOK, &workspace)
+}
+
+func (r WorkspaceResource) GetWorkspacesRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetWorkspaces endpoint hit")
+
+	workspaces, err := r.workspaceService.GetWorkspaces(ctx)
+	if err != nil {
+		logger.Error().Err(err).Msg("Error in getting workspaces")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &workspaces)
+}
+
+func (r WorkspaceResource) GetWorkspaceMembersRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("GetWorkspaceMembers endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	members, err := r.workspaceService.GetWorkspaceMembers(ctx, id)
+	if err != nil {
+		logger.Error().Err(err).Str("id", id).Msg("Error in getting workspace members")
+		abortWithError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, &members)
+}
+
+func (r WorkspaceResource) AddWorkspaceMemberRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("AddWorkspaceMember endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	body := WorkspaceMemberRequestBody{}
+	if err := ctx.ShouldBindJSON(&body); err != nil {
+		logger.Error().Err(err).Msg("Error in Binding workspace member payload from request")
+		abortWithError(ctx, invalidBody(err))
+		return
+	}
+
+	member, err := r.workspaceService.AddWorkspaceMember(ctx, id, &model.WorkspaceMemberPayload{Email: body.Email})
+	if err != nil {
+		logger.Error().Err(err).Str("id", id).Msg("Error in adding workspace member")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("AddWorkspaceMember endpoint successfully added member")
+	ctx.JSON(http.StatusOK, &member)
+}
+
+func (r WorkspaceResource) RemoveWorkspaceMemberRoute(ctx *gin.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	logger.Info().Msg("RemoveWorkspaceMember endpoint hit")
+	id, err := parseID(ctx, "id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+	userID, err := parseID(ctx, "user_id")
+	if err != nil {
+		abortWithError(ctx, err)
+		return
+	}
+
+	if err := r.workspaceService.RemoveWorkspaceMember(ctx, id, userID); err != nil {
+		logger.Error().Err(err).Str("id", id).Msg("Error in removing workspace member")
+		abortWithError(ctx, err)
+		return
+	}
+	logger.Info().Msg("RemoveWorkspaceMember endpoint successfully removed member")
+	ctx.Status(http.StatusOK)
+}
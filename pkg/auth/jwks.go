@@ -0,0 +1,265 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// The signing algorithms accepted in JWTs.
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// ErrUnknownKey is returned when no key of the key set has the kid of a
+// token.
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is a verification key of a JWK set.
+type Key struct {
+	ID        string // kid
+	Algorithm string // HS256, RS256 or EdDSA
+	secret    []byte
+	rsa       *rsa.PublicKey
+	ed25519   ed25519.PublicKey
+}
+
+// KeySet is a parsed JWK set, keyed by kid.
+type KeySet struct {
+	keys map[string]Key
+}
+
+// jwk holds the members of RFC 7517 and RFC 8037 keys this package reads.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	K   string `json:"k"`   // oct
+	N   string `json:"n"`   // RSA
+	E   string `json:"e"`   // RSA
+	Crv string `json:"crv"` // OKP
+	X   string `json:"x"`   // OKP
+}
+
+// ParseJWKS parses a JWK set such as {"keys": [...]}. Keys meant for
+// encryption and keys of other types are skipped, so that an identity
+// provider may publish them side by side.
+func ParseJWKS(data []byte) (*KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("auth: invalid JWK set: %w", err)
+	}
+
+	set := &KeySet{keys: map[string]Key{}}
+	for i, raw := range doc.Keys {
+		if raw.Use != "" && raw.Use != "sig" {
+			continue
+		}
+		key, ok, err := parseJWK(raw)
+		if err != nil {
+			return nil, fmt.Errorf("auth: key %d of the JWK set: %w", i, err)
+		}
+		if !ok {
+			continue
+		}
+		if _, dup := set.keys[key.ID]; dup {
+			return nil, fmt.Errorf("auth: the JWK set has two keys with kid %q", key.ID)
+		}
+		set.keys[key.ID] = key
+	}
+	return set, nil
+}
+
+// parseJWK returns ok false for keys of a type or algorithm that is not
+// supported.
+func parseJWK(raw jwk) (key Key, ok bool, err error) {
+	key = Key{ID: raw.Kid, Algorithm: raw.Alg}
+	switch raw.Kty {
+	case "oct":
+		if key.Algorithm == "" {
+			key.Algorithm = HS256
+		}
+		if key.secret, err = base64.RawURLEncoding.DecodeString(raw.K); err != nil || len(key.secret) == 0 {
+			return Key{}, false, errors.New("invalid k")
+		}
+	case "RSA":
+		if key.Algorithm == "" {
+			key.Algorithm = RS256
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(raw.N)
+		e, errE := base64.RawURLEncoding.DecodeString(raw.E)
+		if errN != nil || errE != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return Key{}, false, errors.New("invalid n or e")
+		}
+		key.rsa = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		if key.rsa.N.BitLen() < 2048 {
+			return Key{}, false, errors.New("RSA keys must have at least 2048 bits")
+		}
+	case "OKP":
+		if raw.Crv != "Ed25519" {
+			return Key{}, false, nil
+		}
+		if key.Algorithm == "" {
+			key.Algorithm = EdDSA
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(raw.X)
+		if errX != nil || len(x) != ed25519.PublicKeySize {
+			return Key{}, false, errors.New("invalid x")
+		}
+		key.ed25519 = x
+	default:
+		return Key{}, false, nil
+	}
+
+	// A key is only used with the one algorithm it is published for
+	want := map[string]string{"oct": HS256, "RSA": RS256, "OKP": EdDSA}[raw.Kty]
+	return key, key.Algorithm == want, nil
+}
+
+// Key returns the key with the id kid. A token without a kid can only be
+// verified by a set holding a single key.
+func (s *KeySet) Key(kid string) (Key, error) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, nil
+		}
+	}
+	key, ok := s.keys[kid]
+	if !ok {
+		return Key{}, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// KeySource provides the keys tokens are verified with.
+type KeySource interface {
+	Key(ctx context.Context, kid string) (Key, error)
+}
+
+// minJWKSRefresh limits how often tokens with an unknown kid make the
+// JWKSSource load the keys again.
+const minJWKSRefresh = 10 * time.Second
+
+// defaultJWKSTimeout bounds a fetch of the JWK set by the default client.
+const defaultJWKSTimeout = 10 * time.Second
+
+// JWKSSource is a KeySource reading a JWK set from a file or an http(s)
+// URL. The set is loaded again every refresh interval and when a token is
+// signed with a kid it does not know yet, so that keys can be rotated by
+// publishing the new key before signing with it.
+type JWKSSource struct {
+	location string
+	refresh  time.Duration
+	client   *http.Client
+	now      func() time.Time
+
+	// loads runs one load at a time, the requests arriving meanwhile
+	// share its result.
+	loads singleflight.Group
+
+	mu       sync.Mutex // guards keys and loadedAt, never held while loading
+	keys     *KeySet
+	loadedAt time.Time
+}
+
+// NewJWKSSource returns a source reading location, a file path or an
+// http(s) URL. refresh defaults to 5 minutes and client to an
+// http.Client giving up after 10 seconds.
+func NewJWKSSource(location string, refresh time.Duration, client *http.Client) *JWKSSource {
+	if refresh <= 0 {
+		refresh = 5 * time.Minute
+	}
+	if client == nil {
+		client = &http.Client{Timeout: defaultJWKSTimeout}
+	}
+	return &JWKSSource{location: location, refresh: refresh, client: client, now: time.Now}
+}
+
+func (s *JWKSSource) Key(ctx context.Context, kid string) (Key, error) {
+	s.mu.Lock()
+	keys, loadedAt := s.keys, s.loadedAt
+	s.mu.Unlock()
+
+	if keys == nil || s.now().Sub(loadedAt) >= s.refresh {
+		var err error
+		if keys, loadedAt, err = s.load(ctx); err != nil && keys == nil {
+			return Key{}, err
+		}
+	}
+	key, err := keys.Key(kid)
+	if errors.Is(err, ErrUnknownKey) && s.now().Sub(loadedAt) >= minJWKSRefresh {
+		if keys, _, err = s.load(ctx); err != nil {
+			return Key{}, err
+		}
+		return keys.Key(kid)
+	}
+	return key, err
+}
+
+// load replaces the keys and returns them with the time of the load, on
+// failure the previous keys are kept. The set is read without holding
+// s.mu and by one caller at a time, whose request being canceled does not
+// fail the others.
+func (s *JWKSSource) load(ctx context.Context) (*KeySet, time.Time, error) {
+	type loaded struct {
+		keys *KeySet
+		at   time.Time
+	}
+	v, err, _ := s.loads.Do("", func() (interface{}, error) {
+		data, err := s.read(context.WithoutCancel(ctx))
+		var keys *KeySet
+		if err == nil {
+			keys, err = ParseJWKS(data)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if err == nil {
+			s.keys = keys
+		}
+		// A failed load is not retried before the next refresh either
+		s.loadedAt = s.now()
+		return loaded{s.keys, s.loadedAt}, err
+	})
+	result := v.(loaded)
+	return result.keys, result.at, err
+}
+
+func (s *JWKSSource) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(s.location, "http://") && !strings.HasPrefix(s.location, "https://") {
+		return os.ReadFile(s.location)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.location, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("auth: fetching the JWK set answered %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
@@ -0,0 +1,228 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is wrapped by every error of JWTVerifier.Verify, the
+// wrapping error tells why the token was refused.
+var ErrInvalidToken = errors.New("invalid token")
+
+func invalidToken(reason string) error {
+	return fmt.Errorf("%w: %s", ErrInvalidToken, reason)
+}
+
+// JWTConfig is what the claims of a token must satisfy besides being
+// unexpired.
+type JWTConfig struct {
+	Issuer   string        // Required iss, not checked when empty
+	Audience string        // Required in aud, not checked when empty
+	Skew     time.Duration // Tolerated clock difference with the issuer for exp, nbf and iat
+}
+
+// Claims are the registered claims of a verified token.
+type Claims struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	ExpiresAt time.Time
+	NotBefore *time.Time
+	IssuedAt  *time.Time
+	// WorkspaceID binds the token to a workspace, 0 when the token has no
+	// workspace_id claim.
+	WorkspaceID uint
+}
+
+// Principal returns the user the token was issued to, in the workspace it
+// is bound to. The subject must be the id of a user of this service.
+func (c Claims) Principal() (Principal, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil || id == 0 {
+		return Principal{}, invalidToken("sub is not a user id")
+	}
+	return Principal{UserID: uint(id), WorkspaceID: c.WorkspaceID}, nil
+}
+
+// JWTVerifier verifies compact JWS tokens signed with HS256, RS256 or
+// EdDSA by a key of its KeySource.
+type JWTVerifier struct {
+	keys   KeySource
+	config JWTConfig
+	now    func() time.Time
+}
+
+func NewJWTVerifier(keys KeySource, config JWTConfig) *JWTVerifier {
+	return &JWTVerifier{keys: keys, config: config, now: time.Now}
+}
+
+// IsJWT tells whether token has the shape of a compact JWS, as opposed to
+// the opaque session tokens.
+func IsJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Verify checks the signature and the claims of token and returns them.
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, invalidToken("malformed")
+	}
+
+	var header struct {
+		Alg  string   `json:"alg"`
+		Kid  string   `json:"kid"`
+		Crit []string `json:"crit"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return Claims{}, invalidToken("malformed header")
+	}
+	if len(header.Crit) > 0 {
+		return Claims{}, invalidToken("unsupported crit header")
+	}
+	key, err := v.keys.Key(ctx, header.Kid)
+	if errors.Is(err, ErrUnknownKey) {
+		return Claims{}, invalidToken("unknown kid")
+	}
+	if err != nil {
+		return Claims{}, err
+	}
+	// The algorithm comes from the key, the header only has to agree, so
+	// that a token can not pick "none" or HS256 with a public key.
+	if header.Alg != key.Algorithm {
+		return Claims{}, invalidToken("alg does not match the key")
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return Claims{}, invalidToken("bad signature")
+	}
+
+	var payload struct {
+		Sub string          `json:"sub"`
+		Iss string          `json:"iss"`
+		Aud json.RawMessage `json:"aud"`
+		Exp *json.Number    `json:"exp"`
+		Nbf *json.Number    `json:"nbf"`
+		Iat *json.Number    `json:"iat"`
+		Wid *json.Number    `json:"workspace_id"`
+	}
+	if err := decodeSegment(parts[1], &payload); err != nil {
+		return Claims{}, invalidToken("malformed claims")
+	}
+	claims := Claims{Subject: payload.Sub, Issuer: payload.Iss}
+	if claims.Audience, err = audience(payload.Aud); err != nil {
+		return Claims{}, err
+	}
+	exp, err := numericDate(payload.Exp, "exp")
+	if err != nil {
+		return Claims{}, err
+	}
+	if exp == nil {
+		return Claims{}, invalidToken("exp is required")
+	}
+	claims.ExpiresAt = *exp
+	if claims.NotBefore, err = numericDate(payload.Nbf, "nbf"); err != nil {
+		return Claims{}, err
+	}
+	if claims.IssuedAt, err = numericDate(payload.Iat, "iat"); err != nil {
+		return Claims{}, err
+	}
+	if payload.Wid != nil {
+		workspaceID, err := strconv.ParseUint(payload.Wid.String(), 10, 64)
+		if err != nil || workspaceID == 0 {
+			return Claims{}, invalidToken("workspace_id is not a workspace id")
+		}
+		claims.WorkspaceID = uint(workspaceID)
+	}
+	return claims, v.checkClaims(claims)
+}
+
+func (v *JWTVerifier) checkClaims(claims Claims) error {
+	now := v.now()
+	if !now.Before(claims.ExpiresAt.Add(v.config.Skew)) {
+		return invalidToken("expired")
+	}
+	if claims.NotBefore != nil && now.Add(v.config.Skew).Before(*claims.NotBefore) {
+		return invalidToken("not valid yet")
+	}
+	if claims.IssuedAt != nil && now.Add(v.config.Skew).Before(*claims.IssuedAt) {
+		return invalidToken("issued in the future")
+	}
+	if v.config.Issuer != "" && claims.Issuer != v.config.Issuer {
+		return invalidToken("wrong iss")
+	}
+	if v.config.Audience != "" && !slices.Contains(claims.Audience, v.config.Audience) {
+		return invalidToken("wrong aud")
+	}
+	return nil
+}
+
+func (k Key) verify(signed, signature []byte) bool {
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), signature)
+	case RS256:
+		digest := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(k.rsa, crypto.SHA256, digest[:], signature) == nil
+	case EdDSA:
+		return ed25519.Verify(k.ed25519, signed, signature)
+	}
+	return false
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// audience reads aud, which RFC 7519 allows to be a string or an array of
+// strings.
+func audience(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var one string
+	if err := json.Unmarshal(raw, &one); err == nil {
+		return []string{one}, nil
+	}
+	var many []string
+	if err := json.Unmarshal(raw, &many); err != nil {
+		return nil, invalidToken("malformed aud")
+	}
+	return many, nil
+}
+
+// numericDate reads a NumericDate claim, seconds since the epoch.
+func numericDate(n *json.Number, name string) (*time.Time, error) {
+	if n == nil {
+		return nil, nil
+	}
+	seconds, err := n.Float64()
+	if err != nil || math.Abs(seconds) > 1e12 {
+		return nil, invalidToken("malformed " + name)
+	}
+	whole, frac := math.Modf(seconds)
+	t := time.Unix(int64(whole), int64(frac*float64(time.Second)))
+	return &t, nil
+}
@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var b64 = base64.RawURLEncoding
+
+// signToken builds a compact JWS of claims signed by sign.
+func signToken(t *testing.T, alg, kid string, claims map[string]interface{}, sign func([]byte) []byte) string {
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	require.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+	signed := b64.EncodeToString(header) + "." + b64.EncodeToString(payload)
+	return signed + "." + b64.EncodeToString(sign([]byte(signed)))
+}
+
+func TestJWTVerifier(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	edPublic, edPrivate, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	keys, err := ParseJWKS([]byte(fmt.Sprintf(`{"keys": [
+		{"kty": "oct", "kid": "hs", "k": %q},
+		{"kty": "RSA", "kid": "rs", "alg": "RS256", "use": "sig", "n": %q, "e": %q},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": %q},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": "", "y": ""}
+	]}`, b64.EncodeToString(secret), b64.EncodeToString(rsaKey.N.Bytes()),
+		b64.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()), b64.EncodeToString(edPublic))))
+	require.NoError(t, err)
+
+	hs := func(signed []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		return mac.Sum(nil)
+	}
+	rs := func(signed []byte) []byte {
+		digest := sha256.Sum256(signed)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+		return signature
+	}
+	ed := func(signed []byte) []byte { return ed25519.Sign(edPrivate, signed) }
+
+	now := time.Unix(1_700_000_000, 0)
+	verifier := NewJWTVerifier(staticKeys{keys}, JWTConfig{Issuer: "https://id.example.com", Audience: "todo_tasks", Skew: time.Minute})
+	verifier.now = func() time.Time { return now }
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"sub": "42", "iss": "https://id.example.com", "aud": []string{"other", "todo_tasks"},
+			"exp": now.Add(time.Hour).Unix(), "nbf": now.Unix(), "iat": now.Unix(),
+		}
+		for k, v := range changes {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+
+	for _, token := range []string{
+		signToken(t, HS256, "hs", claims(nil), hs),
+		signToken(t, RS256, "rs", claims(nil), rs),
+		signToken(t, EdDSA, "ed", claims(map[string]interface{}{"aud": "todo_tasks"}), ed),
+		// Within the clock skew
+		signToken(t, EdDSA, "ed", claims(map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()}), ed),
+		signToken(t, EdDSA, "ed", claims(map[string]interface{}{"nbf": now.Add(30 * time.Second).Unix()}), ed),
+	} {
+		got, err := verifier.Verify(context.Background(), token)
+		require.NoError(t, err, token)
+		principal, err := got.Principal()
+		require.NoError(t, err)
+		assert.Equal(t, uint(42), principal.UserID)
+	}
+
+	for name, token := range map[string]string{
+		"expired":            signToken(t, EdDSA, "ed", claims(map[string]interface{}{"exp": now.Add(-2 * time.Minute).Unix()}), ed),
+		"no exp":             signToken(t, EdDSA, "ed", claims(map[string]interface{}{"exp": nil}), ed),
+		"not yet valid":      signToken(t, EdDSA, "ed", claims(map[string]interface{}{"nbf": now.Add(2 * time.Minute).Unix()}), ed),
+		"issued later":       signToken(t, EdDSA, "ed", claims(map[string]interface{}{"iat": now.Add(2 * time.Minute).Unix()}), ed),
+		"wrong issuer":       signToken(t, EdDSA, "ed", claims(map[string]interface{}{"iss": "https://evil.example.com"}), ed),
+		"wrong audience":     signToken(t, EdDSA, "ed", claims(map[string]interface{}{"aud": "other"}), ed),
+		"no audience":        signToken(t, EdDSA, "ed", claims(map[string]interface{}{"aud": nil}), ed),
+		"unknown kid":        signToken(t, EdDSA, "nope", claims(nil), ed),
+		"encryption key":     signToken(t, RS256, "enc", claims(nil), rs),
+		"other key":          signToken(t, EdDSA, "rs", claims(nil), ed),
+		"alg of another key": signToken(t, HS256, "rs", claims(nil), hs),
+		"alg none":           signToken(t, "none", "hs", claims(nil), func([]byte) []byte { return nil }),
+		"bad signature":      signToken(t, HS256, "hs", claims(nil), ed),
+		"malformed":          "a.b",
+		"malformed header":   "e30x.e30.e30",
+		"bad workspace":      signToken(t, EdDSA, "ed", claims(map[string]interface{}{"workspace_id": "home"}), ed),
+		"no workspace":       signToken(t, EdDSA, "ed", claims(map[string]interface{}{"workspace_id": 0}), ed),
+	} {
+		_, err := verifier.Verify(context.Background(), token)
+		assert.ErrorIs(t, err, ErrInvalidToken, name)
+	}
+
+	got, err := verifier.Verify(context.Background(), signToken(t, HS256, "hs", claims(map[string]interface{}{"sub": "ada"}), hs))
+	require.NoError(t, err)
+	_, err = got.Principal()
+	assert.ErrorIs(t, err, ErrInvalidToken)
+
+	// A workspace_id claim binds the token to a workspace
+	for _, workspaceID := range []interface{}{7, "7"} {
+		got, err = verifier.Verify(context.Background(), signToken(t, HS256, "hs", claims(map[string]interface{}{"workspace_id": workspaceID}), hs))
+		require.NoError(t, err)
+		principal, err := got.Principal()
+		require.NoError(t, err)
+		assert.Equal(t, uint(7), principal.WorkspaceID)
+	}
+}
+
+type staticKeys struct{ *KeySet }
+
+func (s staticKeys) Key(_ context.Context, kid string) (Key, error) {
+	return s.KeySet.Key(kid)
+}
+
+func TestJWKSSourceRotation(t *testing.T) {
+	first, second := []byte("first secret of thirty-two bytes"), []byte("second secret of thirty-two byte")
+	jwks := func(keys map[string][]byte) []byte {
+		doc := map[string][]map[string]string{"keys": {}}
+		for kid, secret := range keys {
+			doc["keys"] = append(doc["keys"], map[string]string{"kty": "oct", "kid": kid, "k": b64.EncodeToString(secret)})
+		}
+		data, err := json.Marshal(doc)
+		require.NoError(t, err)
+		return data
+	}
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwks(map[string][]byte{"1": first}), 0o600))
+	now := time.Unix(1_700_000_000, 0)
+	source := NewJWKSSource(path, time.Hour, nil)
+	source.now = func() time.Time { return now }
+
+	key, err := source.Key(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, first, key.secret)
+
+	// The new key is published, tokens signed with it load the set again,
+	// but not more than once every minJWKSRefresh
+	require.NoError(t, os.WriteFile(path, jwks(map[string][]byte{"1": first, "2": second}), 0o600))
+	_, err = source.Key(context.Background(), "2")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	now = now.Add(minJWKSRefresh)
+	key, err = source.Key(context.Background(), "2")
+	require.NoError(t, err)
+	assert.Equal(t, second, key.secret)
+
+	// The old key is retired at the next refresh, a broken file keeps the
+	// keys loaded last
+	require.NoError(t, os.WriteFile(path, jwks(map[string][]byte{"2": second}), 0o600))
+	_, err = source.Key(context.Background(), "1")
+	require.NoError(t, err)
+	now = now.Add(time.Hour)
+	_, err = source.Key(context.Background(), "1")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+	require.NoError(t, os.WriteFile(path, []byte("{"), 0o600))
+	now = now.Add(time.Hour)
+	_, err = source.Key(context.Background(), "2")
+	require.NoError(t, err)
+
+	// Over HTTP
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(jwks(map[string][]byte{"3": first}))
+	}))
+	defer server.Close()
+	key, err = NewJWKSSource(server.URL, 0, server.Client()).Key(context.Background(), "3")
+	require.NoError(t, err)
+	assert.Equal(t, first, key.secret)
+
+	_, err = NewJWKSSource(filepath.Join(t.TempDir(), "missing.json"), 0, nil).Key(context.Background(), "1")
+	assert.Error(t, err)
+}
+
+func TestJWKSSourceConcurrentLoads(t *testing.T) {
+	assert.Equal(t, defaultJWKSTimeout, NewJWKSSource("jwks.json", 0, nil).client.Timeout)
+
+	var fetches atomic.Int32
+	fetched, release := make(chan struct{}, 1), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fetches.Add(1)
+		select {
+		case fetched <- struct{}{}:
+		default:
+		}
+		<-release
+		_, _ = w.Write([]byte(`{"keys": [{"kty": "oct", "kid": "1", "k": "` + b64.EncodeToString([]byte("first secret of thirty-two bytes")) + `"}]}`))
+	}))
+	defer server.Close()
+	source := NewJWKSSource(server.URL, time.Hour, server.Client())
+
+	// The requests arriving while the set is fetched wait for that fetch
+	const callers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := source.Key(context.Background(), "1")
+			errs <- err
+		}()
+	}
+	<-fetched
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Less(t, fetches.Load(), int32(callers))
+
+	// The fetch outlives the request that started it
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	source = NewJWKSSource(server.URL, time.Hour, server.Client())
+	_, err := source.Key(ctx, "1")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testParams keep the tests fast, the hashes carry their own parameters.
+var testParams = PasswordParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestPassword(t *testing.T) {
+	hash, err := HashPassword("correct horse battery staple", testParams)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=64,t=1,p=1$"))
+
+	again, err := HashPassword("correct horse battery staple", testParams)
+	require.NoError(t, err)
+	assert.NotEqual(t, hash, again, "every hash has its own salt")
+
+	ok, err := VerifyPassword(hash, "correct horse battery staple")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = VerifyPassword(hash, "correct horse battery stapler")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	for _, invalid := range []string{
+		"",
+		"plain",
+		"$argon2i$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=16$m=64,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$!!$a2V5",
+		"$argon2id$v=19$m=64,t=1,p=1$c2FsdHNhbHQ$",
+	} {
+		_, err := VerifyPassword(invalid, "password")
+		assert.ErrorIs(t, err, ErrInvalidHash, invalid)
+	}
+}
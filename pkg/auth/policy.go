@@ -0,0 +1,84 @@
+package auth
+
+import "slices"
+
+// Role is what a user may do with a project, and with every todo_task in
+// it, or with a single todo_task. The owner of a project or a todo_task
+// holds RoleOwner, other users get a role by being shared with.
+type Role string
+
+const (
+	RoleViewer    Role = "viewer"
+	RoleCommenter Role = "commenter"
+	RoleEditor    Role = "editor"
+	RoleOwner     Role = "owner"
+)
+
+// Roles lists every role, from the one allowing the least to the one
+// allowing the most.
+var Roles = []Role{RoleViewer, RoleCommenter, RoleEditor, RoleOwner}
+
+// Action is something done to a project or a todo_task that the policy
+// allows or not.
+type Action string
+
+const (
+	ActionRead     Action = "read"     // See it, its comments and attachments
+	ActionComment  Action = "comment"  // Add comments, edit and delete your own
+	ActionEdit     Action = "edit"     // Change it and its attachments, add todo_tasks to it
+	ActionModerate Action = "moderate" // Edit and delete the comments of other users
+	ActionDelete   Action = "delete"   // Move it to the trash, restore or purge it
+	ActionShare    Action = "share"    // Give other users a role on it
+	ActionManage   Action = "manage"   // Rename, archive or delete a project
+)
+
+// Actions lists every action, in the order they are documented.
+var Actions = []Action{ActionRead, ActionComment, ActionEdit, ActionModerate, ActionDelete, ActionShare, ActionManage}
+
+// policy is the list of actions each role allows. It is the only place
+// granting access, a role missing from it allows nothing.
+var policy = map[Role][]Action{
+	RoleViewer:    {ActionRead},
+	RoleCommenter: {ActionRead, ActionComment},
+	RoleEditor:    {ActionRead, ActionComment, ActionEdit, ActionModerate, ActionDelete},
+	RoleOwner:     {ActionRead, ActionComment, ActionEdit, ActionModerate, ActionDelete, ActionShare, ActionManage},
+}
+
+// Valid tells whether r is one of the Roles.
+func (r Role) Valid() bool {
+	return slices.Contains(Roles, r)
+}
+
+// Can tells whether the role allows action. The empty role, of a user
+// without access, allows nothing.
+func (r Role) Can(action Action) bool {
+	return slices.Contains(policy[r], action)
+}
+
+// CanChangeComment tells whether the role allows editing or deleting a
+// comment. Authors change their own comments while they may comment,
+// moderators change every comment.
+func (r Role) CanChangeComment(author bool) bool {
+	return r.Can(ActionModerate) || author && r.Can(ActionComment)
+}
+
+// Actions lists the actions the role allows, in the order of Actions.
+func (r Role) Actions() []Action {
+	actions := []Action{}
+	for _, action := range Actions {
+		if r.Can(action) {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
+
+// MaxRole returns the role allowing the most of a and b. A user holding
+// several roles on a todo_task, such as one on the todo_task and one on
+// its project, acts with the highest.
+func MaxRole(a, b Role) Role {
+	if slices.Index(Roles, b) > slices.Index(Roles, a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestPolicy spells out every role × action. A change to the policy must
+// change this table too, so no role gets more access by accident.
+func TestPolicy(t *testing.T) {
+	const (
+		y = true
+		n = false
+	)
+	table := map[Role]map[Action]bool{
+		RoleViewer: {
+			ActionRead: y, ActionComment: n, ActionEdit: n, ActionModerate: n, ActionDelete: n, ActionShare: n, ActionManage: n,
+		},
+		RoleCommenter: {
+			ActionRead: y, ActionComment: y, ActionEdit: n, ActionModerate: n, ActionDelete: n, ActionShare: n, ActionManage: n,
+		},
+		RoleEditor: {
+			ActionRead: y, ActionComment: y, ActionEdit: y, ActionModerate: y, ActionDelete: y, ActionShare: n, ActionManage: n,
+		},
+		RoleOwner: {
+			ActionRead: y, ActionComment: y, ActionEdit: y, ActionModerate: y, ActionDelete: y, ActionShare: y, ActionManage: y,
+		},
+		// Users without a role and roles that do not exist
+		"": {
+			ActionRead: n, ActionComment: n, ActionEdit: n, ActionModerate: n, ActionDelete: n, ActionShare: n, ActionManage: n,
+		},
+		"admin": {
+			ActionRead: n, ActionComment: n, ActionEdit: n, ActionModerate: n, ActionDelete: n, ActionShare: n, ActionManage: n,
+		},
+	}
+
+	for _, role := range Roles {
+		assert.Contains(t, table, role, "role %s is missing from the table", role)
+	}
+	for role, actions := range table {
+		assert.Len(t, actions, len(Actions), "role %q does not list every action", role)
+		for _, action := range Actions {
+			assert.Equal(t, actions[action], role.Can(action), "%q %s", role, action)
+		}
+		assert.Equal(t, role.Valid(), policy[role] != nil, "%q", role)
+	}
+	assert.False(t, RoleOwner.Can("launch"), "unknown actions are never allowed")
+
+	assert.Equal(t, []Action{ActionRead, ActionComment}, RoleCommenter.Actions())
+	assert.Equal(t, []Action{}, Role("").Actions())
+}
+
+// TestCommentPolicy spells out who may edit or delete a comment, their
+// own or the one of another user.
+func TestCommentPolicy(t *testing.T) {
+	table := []struct {
+		role        Role
+		own, others bool
+	}{
+		{RoleViewer, false, false},
+		{RoleCommenter, true, false},
+		{RoleEditor, true, true},
+		{RoleOwner, true, true},
+		{"", false, false},
+	}
+
+	for _, row := range table {
+		assert.Equal(t, row.own, row.role.CanChangeComment(true), "%q own comment", row.role)
+		assert.Equal(t, row.others, row.role.CanChangeComment(false), "%q comment of another user", row.role)
+	}
+}
+
+func TestMaxRole(t *testing.T) {
+	assert.Equal(t, RoleEditor, MaxRole(RoleViewer, RoleEditor))
+	assert.Equal(t, RoleEditor, MaxRole(RoleEditor, RoleCommenter))
+	assert.Equal(t, RoleOwner, MaxRole(RoleOwner, RoleOwner))
+	assert.Equal(t, RoleViewer, MaxRole("", RoleViewer))
+	assert.Equal(t, RoleViewer, MaxRole(RoleViewer, ""))
+	assert.Equal(t, Role(""), MaxRole("", ""))
+}
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"context"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The scopes a personal access token can be restricted to.
+const (
+	ScopeTasksRead   = "tasks:read"
+	ScopeTasksWrite  = "tasks:write"
+	ScopeTasksDelete = "tasks:delete"
+)
+
+// Scopes lists every scope, in the order they are documented.
+var Scopes = []string{ScopeTasksRead, ScopeTasksWrite, ScopeTasksDelete}
+
+// Principal is the user a request is made on behalf of.
+type Principal struct {
+	UserID uint
+	// Scopes restrict a personal access token to part of the API. nil, as
+	// for sessions and JWTs, allows everything the user may do.
+	Scopes []string
+	// WorkspaceID is the workspace the request is made in. The rows of
+	// the other workspaces do not exist for it.
+	WorkspaceID uint
+}
+
+// Restricted tells whether the principal is limited to its Scopes.
+func (p Principal) Restricted() bool {
+	return p.Scopes != nil
+}
+
+// HasScope tells whether the principal may use the routes of scope.
+func (p Principal) HasScope(scope string) bool {
+	return !p.Restricted() || slices.Contains(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying the principal.
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFromContext returns the principal stored by WithPrincipal in ctx,
+// or in the request context of a *gin.Context. ok is false outside of an
+// authenticated request, such as in background jobs.
+func PrincipalFromContext(ctx context.Context) (principal Principal, ok bool) {
+	if gc, isGin := ctx.(*gin.Context); isGin {
+		if gc.Request == nil {
+			return Principal{}, false
+		}
+		ctx = gc.Request.Context()
+	}
+	principal, ok = ctx.Value(principalKey{}).(Principal)
+	return principal, ok
+}
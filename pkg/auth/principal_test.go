@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrincipalFromContext(t *testing.T) {
+	_, ok := PrincipalFromContext(context.Background())
+	assert.False(t, ok)
+
+	principal, ok := PrincipalFromContext(WithPrincipal(context.Background(), Principal{UserID: 7}))
+	assert.True(t, ok)
+	assert.Equal(t, uint(7), principal.UserID)
+}
+
+func TestPrincipalScopes(t *testing.T) {
+	unrestricted := Principal{UserID: 1}
+	assert.False(t, unrestricted.Restricted())
+	assert.True(t, unrestricted.HasScope(ScopeTasksDelete))
+
+	readOnly := Principal{UserID: 1, Scopes: []string{ScopeTasksRead}}
+	assert.True(t, readOnly.Restricted())
+	assert.True(t, readOnly.HasScope(ScopeTasksRead))
+	assert.False(t, readOnly.HasScope(ScopeTasksWrite))
+	assert.True(t, Principal{Scopes: []string{}}.Restricted(), "a token without scopes reaches nothing")
+}
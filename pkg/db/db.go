@@ -1,11 +1,16 @@
 package db
 
 import (
+	"context"
+	"embed"
 	"errors"
 	"fmt"
 	"github.com/vkuzmich/gin-project/pkg/model"
 	"strings"
 
+	"github.com/golang-migrate/migrate/v4"
+	scripts "github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
@@ -30,16 +35,67 @@ func Init(url string) (*gorm.DB, error) {
 	return db, nil
 }
 
+// models are the tables of the application, in the order they can be
+// created in.
+var models = []interface{}{
+	&model.User{}, &model.Session{}, &model.AccessToken{}, &model.Workspace{}, &model.WorkspaceMember{}, &model.Tag{}, &model.Project{},
+	&model.TodoTask{}, &model.Share{}, &model.TodoTaskDependency{}, &model.Comment{}, &model.Attachment{}, &model.IdempotencyKey{},
+}
+
+// AutoMigration brings the schema up to date. On Postgres the versioned
+// scripts in pkg/db/migration are the only source of the schema, they are
+// embedded in the binary and run with golang-migrate. Other dialects, such
+// as the SQLite databases of the tests, are created from the models.
 func AutoMigration(db *gorm.DB) error {
 	if db == nil {
 		return errors.New("nil database connection")
 	}
-	return db.AutoMigrate(&model.TodoTask{})
+	if db.Dialector.Name() != "postgres" {
+		return db.AutoMigrate(models...)
+	}
+	return migrateUp(db)
+}
+
+//go:embed migration/*.sql
+var migrations embed.FS
+
+// migrateUp runs the embedded migration scripts not applied yet.
+func migrateUp(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	// A connection of its own, closing the driver must not close the pool
+	conn, err := sqlDB.Conn(context.Background())
+	if err != nil {
+		return err
+	}
+	driver, err := scripts.WithConnection(context.Background(), conn, &scripts.Config{})
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	source, err := iofs.New(migrations, "migration")
+	if err != nil {
+		driver.Close()
+		return err
+	}
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		driver.Close()
+		return err
+	}
+	defer m.Close()
+
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("failed to migrate database: %w", err)
+	}
+	return nil
 }
 
 func ConnectionToDB(url string) (*gorm.DB, error) {
 	// Attempt to open the database connection
-	db, err := gorm.Open(postgres.Open(url), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(url), &gorm.Config{TranslateError: true})
 	if err != nil {
 		// Check if the error is related to database does not exist
 		if strings.Contains(err.Error(), `database "gin_pron" does not exist`) {
@@ -2,9 +2,11 @@ package db
 
 import (
 	"errors"
+	"fmt"
 	"github.com/stretchr/testify/mock"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -130,3 +132,26 @@ func createSQLiteDB(t *testing.T) *gorm.DB {
 	}
 	return db
 }
+
+// TestMigrationScripts checks the embedded scripts golang-migrate runs on
+// Postgres: numbered without gaps, each with its down script.
+func TestMigrationScripts(t *testing.T) {
+	entries, err := migrations.ReadDir("migration")
+	assert.NoError(t, err)
+
+	names := map[string]bool{}
+	for _, entry := range entries {
+		names[entry.Name()] = true
+	}
+	version := 0
+	for _, entry := range entries {
+		name, found := strings.CutSuffix(entry.Name(), ".up.sql")
+		if !found {
+			continue
+		}
+		version++
+		assert.True(t, strings.HasPrefix(name, fmt.Sprintf("%06d_", version)), "%s follows version %d", entry.Name(), version-1)
+		assert.True(t, names[name+".down.sql"], "%s has a down script", entry.Name())
+	}
+	assert.Equal(t, len(entries), 2*version)
+}
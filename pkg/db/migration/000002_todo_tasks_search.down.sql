@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_todo_tasks_search_vector;
+ALTER TABLE todo_tasks DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,8 @@
+-- Full-text search over todo_tasks titles and descriptions
+ALTER TABLE todo_tasks ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_todo_tasks_search_vector ON todo_tasks USING GIN (search_vector);
@@ -0,0 +1 @@
+ALTER TABLE todo_tasks DROP COLUMN IF EXISTS version;
@@ -0,0 +1,2 @@
+-- Row version used for optimistic concurrency control and ETags
+ALTER TABLE todo_tasks ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,5 @@
+ALTER TABLE todo_tasks ADD COLUMN IF NOT EXISTS state BOOLEAN DEFAULT false;
+UPDATE todo_tasks SET state = (status = 'done');
+DROP INDEX IF EXISTS idx_todo_tasks_status;
+ALTER TABLE todo_tasks DROP CONSTRAINT IF EXISTS chk_todo_tasks_status;
+ALTER TABLE todo_tasks DROP COLUMN IF EXISTS status;
@@ -0,0 +1,7 @@
+-- Replace the boolean state with a workflow status, completed todo_tasks become done
+ALTER TABLE todo_tasks ADD COLUMN IF NOT EXISTS status VARCHAR(16) NOT NULL DEFAULT 'todo';
+UPDATE todo_tasks SET status = CASE WHEN state THEN 'done' ELSE 'todo' END;
+ALTER TABLE todo_tasks DROP COLUMN IF EXISTS state;
+ALTER TABLE todo_tasks ADD CONSTRAINT chk_todo_tasks_status
+    CHECK (status IN ('backlog', 'todo', 'in_progress', 'blocked', 'done', 'cancelled'));
+CREATE INDEX IF NOT EXISTS idx_todo_tasks_status ON todo_tasks (status);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_todo_tasks_open_due_at;
+DROP INDEX IF EXISTS idx_todo_tasks_due_at;
+DROP INDEX IF EXISTS idx_todo_tasks_start_at;
+ALTER TABLE todo_tasks DROP COLUMN IF EXISTS due_at;
+ALTER TABLE todo_tasks DROP COLUMN IF EXISTS start_at;
@@ -0,0 +1,8 @@
+-- When work on a todo_task may start and when it is due
+ALTER TABLE todo_tasks ADD COLUMN IF NOT EXISTS start_at TIMESTAMPTZ;
+ALTER TABLE todo_tasks ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ;
+CREATE INDEX IF NOT EXISTS idx_todo_tasks_start_at ON todo_tasks (start_at);
+CREATE INDEX IF NOT EXISTS idx_todo_tasks_due_at ON todo_tasks (due_at);
+-- Overdue lists only look at the open todo_tasks with a due date
+CREATE INDEX IF NOT EXISTS idx_todo_tasks_open_due_at ON todo_tasks (due_at)
+    WHERE due_at IS NOT NULL AND deleted_at IS NULL AND status NOT IN ('done', 'cancelled');
@@ -0,0 +1,16 @@
+-- Labels put on todo_tasks, a tag name is stored trimmed and lowercased
+CREATE TABLE IF NOT EXISTS tags (
+    id         BIGSERIAL PRIMARY KEY,
+    name       VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name ON tags (name);
+
+CREATE TABLE IF NOT EXISTS todo_task_tags (
+    todo_task_id BIGINT NOT NULL REFERENCES todo_tasks (id) ON DELETE CASCADE,
+    tag_id       BIGINT NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+    PRIMARY KEY (todo_task_id, tag_id)
+);
+-- Filtering by tag looks the todo_tasks up from the tag side
+CREATE INDEX IF NOT EXISTS idx_todo_task_tags_tag_id ON todo_task_tags (tag_id);
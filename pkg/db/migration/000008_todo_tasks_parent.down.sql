@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_todo_tasks_parent_id;
+ALTER TABLE todo_tasks DROP CONSTRAINT IF EXISTS chk_todo_tasks_parent;
+ALTER TABLE todo_tasks DROP CONSTRAINT IF EXISTS fk_todo_tasks_parent;
+ALTER TABLE todo_tasks DROP COLUMN IF EXISTS parent_id;
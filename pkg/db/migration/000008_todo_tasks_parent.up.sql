@@ -0,0 +1,6 @@
+-- Subtasks point at their parent todo_task, top-level todo_tasks have none
+ALTER TABLE todo_tasks ADD COLUMN IF NOT EXISTS parent_id BIGINT;
+ALTER TABLE todo_tasks ADD CONSTRAINT fk_todo_tasks_parent
+    FOREIGN KEY (parent_id) REFERENCES todo_tasks (id) ON DELETE SET NULL;
+ALTER TABLE todo_tasks ADD CONSTRAINT chk_todo_tasks_parent CHECK (parent_id <> id);
+CREATE INDEX IF NOT EXISTS idx_todo_tasks_parent_id ON todo_tasks (parent_id);
@@ -0,0 +1,10 @@
+-- "blocker_id blocks blocked_id": the blocked todo_task can not be done while the blocker is open
+CREATE TABLE IF NOT EXISTS todo_task_dependencies (
+    blocker_id BIGINT NOT NULL REFERENCES todo_tasks (id) ON DELETE CASCADE,
+    blocked_id BIGINT NOT NULL REFERENCES todo_tasks (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ,
+    PRIMARY KEY (blocker_id, blocked_id),
+    CONSTRAINT chk_todo_task_dependencies_self CHECK (blocker_id <> blocked_id)
+);
+-- The primary key serves the lookups by blocker, this one those by blocked todo_task
+CREATE INDEX IF NOT EXISTS idx_todo_task_dependencies_blocked_id ON todo_task_dependencies (blocked_id);
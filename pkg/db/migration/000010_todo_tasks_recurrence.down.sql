@@ -0,0 +1,2 @@
+ALTER TABLE todo_tasks DROP COLUMN IF EXISTS timezone;
+ALTER TABLE todo_tasks DROP COLUMN IF EXISTS recurrence;
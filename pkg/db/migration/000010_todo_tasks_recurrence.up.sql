@@ -0,0 +1,3 @@
+-- RFC 5545 RRULE of a recurring todo_task and the IANA time zone it follows
+ALTER TABLE todo_tasks ADD COLUMN IF NOT EXISTS recurrence VARCHAR(512) NOT NULL DEFAULT '';
+ALTER TABLE todo_tasks ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT '';
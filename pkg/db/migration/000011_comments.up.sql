@@ -0,0 +1,13 @@
+-- The discussion thread of a todo_task, soft deleted with it
+CREATE TABLE IF NOT EXISTS comments (
+    id BIGSERIAL PRIMARY KEY,
+    todo_task_id BIGINT NOT NULL REFERENCES todo_tasks (id) ON DELETE CASCADE,
+    author VARCHAR(64) NOT NULL,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    edited_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_comments_todo_task_id ON comments (todo_task_id);
+CREATE INDEX IF NOT EXISTS idx_comments_deleted_at ON comments (deleted_at);
@@ -0,0 +1,15 @@
+-- Metadata of the files attached to a todo_task, the content lives in the
+-- blob store. Purging the todo_task detaches its attachments until their
+-- content is deleted.
+CREATE TABLE IF NOT EXISTS attachments (
+    id BIGSERIAL PRIMARY KEY,
+    todo_task_id BIGINT REFERENCES todo_tasks (id) ON DELETE SET NULL,
+    filename VARCHAR(255) NOT NULL,
+    content_type VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    sha256 VARCHAR(64) NOT NULL,
+    storage_key VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_attachments_todo_task_id ON attachments (todo_task_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_attachments_storage_key ON attachments (storage_key);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_todo_tasks_project_id;
+ALTER TABLE todo_tasks DROP CONSTRAINT IF EXISTS fk_todo_tasks_project;
+ALTER TABLE todo_tasks DROP COLUMN IF EXISTS project_id;
+DROP TABLE IF EXISTS projects;
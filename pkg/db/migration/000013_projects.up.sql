@@ -0,0 +1,16 @@
+-- Projects group todo_tasks. Deleting a project keeps its todo_tasks
+-- outside of any project.
+CREATE TABLE IF NOT EXISTS projects (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(128) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    archived_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_projects_archived_at ON projects (archived_at);
+
+ALTER TABLE todo_tasks ADD COLUMN IF NOT EXISTS project_id BIGINT;
+ALTER TABLE todo_tasks ADD CONSTRAINT fk_todo_tasks_project
+    FOREIGN KEY (project_id) REFERENCES projects (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_todo_tasks_project_id ON todo_tasks (project_id);
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_projects_owner_id;
+ALTER TABLE projects DROP CONSTRAINT IF EXISTS fk_projects_owner;
+ALTER TABLE projects DROP COLUMN IF EXISTS owner_id;
+DROP INDEX IF EXISTS idx_todo_tasks_owner_id;
+ALTER TABLE todo_tasks DROP CONSTRAINT IF EXISTS fk_todo_tasks_owner;
+ALTER TABLE todo_tasks DROP COLUMN IF EXISTS owner_id;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,34 @@
+-- Users own the todo_tasks and projects they create. Only the argon2id
+-- hash of a password and the SHA-256 of a session token are stored.
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(254) NOT NULL,
+    password_hash TEXT NOT NULL,
+    failed_logins BIGINT NOT NULL DEFAULT 0,
+    locked_until TIMESTAMPTZ,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_sessions_user_id ON sessions (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_sessions_token_hash ON sessions (token_hash);
+CREATE INDEX IF NOT EXISTS idx_sessions_expires_at ON sessions (expires_at);
+
+-- Rows older than accounts keep a NULL owner.
+ALTER TABLE todo_tasks ADD COLUMN IF NOT EXISTS owner_id BIGINT;
+ALTER TABLE todo_tasks ADD CONSTRAINT fk_todo_tasks_owner
+    FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_todo_tasks_owner_id ON todo_tasks (owner_id);
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS owner_id BIGINT;
+ALTER TABLE projects ADD CONSTRAINT fk_projects_owner
+    FOREIGN KEY (owner_id) REFERENCES users (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_projects_owner_id ON projects (owner_id);
@@ -0,0 +1,17 @@
+-- Roles of users on the projects and todo_tasks shared with them. A share
+-- is on a project, and with it on all of its todo_tasks, or on one todo_task.
+CREATE TABLE IF NOT EXISTS shares (
+    id BIGSERIAL PRIMARY KEY,
+    project_id BIGINT REFERENCES projects (id) ON DELETE CASCADE,
+    todo_task_id BIGINT REFERENCES todo_tasks (id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role VARCHAR(16) NOT NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ,
+    CONSTRAINT chk_shares_target CHECK ((project_id IS NULL) <> (todo_task_id IS NULL)),
+    CONSTRAINT chk_shares_role CHECK (role IN ('viewer', 'commenter', 'editor', 'owner'))
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shares_project_user ON shares (project_id, user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shares_todo_task_user ON shares (todo_task_id, user_id);
+-- The visibility of the todo_tasks and projects is looked up by user
+CREATE INDEX IF NOT EXISTS idx_shares_user_id ON shares (user_id);
@@ -0,0 +1,8 @@
+-- Fails when two workspaces have a tag with the same name
+DROP INDEX IF EXISTS idx_tags_workspace_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_name ON tags (name);
+ALTER TABLE tags DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE projects DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE todo_tasks DROP COLUMN IF EXISTS workspace_id;
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
@@ -0,0 +1,44 @@
+-- Workspaces are tenants: todo_tasks, projects and tags belong to exactly
+-- one and are only seen by requests made in it by its members.
+CREATE TABLE IF NOT EXISTS workspaces (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(128) NOT NULL,
+    owner_id BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_workspaces_owner_id ON workspaces (owner_id);
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+    workspace_id BIGINT NOT NULL REFERENCES workspaces (id) ON DELETE CASCADE,
+    user_id BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ,
+    PRIMARY KEY (workspace_id, user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON workspace_members (user_id);
+
+-- The data older than workspaces, and the users who could see it, move to
+-- the default workspace.
+INSERT INTO workspaces (id, name, created_at, updated_at) VALUES (1, 'Default', now(), now())
+    ON CONFLICT (id) DO NOTHING;
+SELECT setval(pg_get_serial_sequence('workspaces', 'id'), GREATEST((SELECT max(id) FROM workspaces), 1));
+INSERT INTO workspace_members (workspace_id, user_id, created_at)
+    SELECT 1, id, now() FROM users
+    ON CONFLICT DO NOTHING;
+
+ALTER TABLE todo_tasks ADD COLUMN IF NOT EXISTS workspace_id BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE todo_tasks ADD CONSTRAINT fk_todo_tasks_workspace
+    FOREIGN KEY (workspace_id) REFERENCES workspaces (id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS idx_todo_tasks_workspace_id ON todo_tasks (workspace_id);
+
+ALTER TABLE projects ADD COLUMN IF NOT EXISTS workspace_id BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE projects ADD CONSTRAINT fk_projects_workspace
+    FOREIGN KEY (workspace_id) REFERENCES workspaces (id) ON DELETE CASCADE;
+CREATE INDEX IF NOT EXISTS idx_projects_workspace_id ON projects (workspace_id);
+
+-- Tag names are unique within a workspace
+ALTER TABLE tags ADD COLUMN IF NOT EXISTS workspace_id BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE tags ADD CONSTRAINT fk_tags_workspace
+    FOREIGN KEY (workspace_id) REFERENCES workspaces (id) ON DELETE CASCADE;
+DROP INDEX IF EXISTS idx_tags_name;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_workspace_name ON tags (workspace_id, name);
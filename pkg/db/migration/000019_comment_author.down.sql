@@ -0,0 +1,3 @@
+-- The names of the authors are gone, the comments get an empty one.
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS author VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE comments DROP COLUMN IF EXISTS author_id;
@@ -0,0 +1,8 @@
+-- Comments record the user who wrote them instead of a name given by the
+-- client. The names of the older comments can not be matched to users,
+-- those comments keep a NULL author.
+ALTER TABLE comments ADD COLUMN IF NOT EXISTS author_id BIGINT;
+ALTER TABLE comments ADD CONSTRAINT fk_comments_author
+    FOREIGN KEY (author_id) REFERENCES users (id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_comments_author_id ON comments (author_id);
+ALTER TABLE comments DROP COLUMN IF EXISTS author;
@@ -0,0 +1,78 @@
+package model
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// AccessToken is a personal access token, for scripts that can not log
+// in. Like a session only the SHA-256 of the token is stored, the token
+// itself is shown once when it is created.
+type AccessToken struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"-" gorm:"not null;index"`
+	Name       string     `json:"name" gorm:"size:64;not null"`
+	Prefix     string     `json:"prefix" gorm:"size:16;not null"` // Start of the token, to tell tokens apart
+	TokenHash  string     `json:"-" gorm:"size:64;not null;uniqueIndex"`
+	Scopes     Scopes     `json:"scopes" gorm:"size:255;not null"`
+	ExpiresAt  *time.Time `json:"expires_at"`   // nil for a token that does not expire
+	LastUsedAt *time.Time `json:"last_used_at"` // Updated at most once a minute
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreatedAccessToken is an AccessToken with the token, as returned once by
+// its creation.
+type CreatedAccessToken struct {
+	AccessToken
+	Token string `json:"token"`
+}
+
+// Scopes are stored space separated, like the scope of OAuth 2.0.
+type Scopes []string
+
+func (s Scopes) Value() (driver.Value, error) {
+	return strings.Join(s, " "), nil
+}
+
+func (s *Scopes) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case string:
+		*s = strings.Fields(v)
+	case []byte:
+		*s = strings.Fields(string(v))
+	default:
+		return fmt.Errorf("model: can not scan %T into Scopes", value)
+	}
+	return nil
+}
+
+type AccessTokenPayload struct {
+	Name      string     `json:"name" validate:"required,max=64"`
+	Scopes    []string   `json:"scopes" validate:"required,dive,oneof=tasks:read tasks:write tasks:delete"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// ValidateAccessTokenPayload validates the AccessTokenPayload fields. The
+// scopes are sorted and duplicates dropped.
+func (p *AccessTokenPayload) ValidateAccessTokenPayload(now time.Time) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if len(p.Scopes) == 0 {
+		p.Scopes = nil
+	}
+	if err := validate.Struct(p); err != nil {
+		return newValidationError(err, p)
+	}
+	if p.ExpiresAt != nil && !p.ExpiresAt.After(now) {
+		return &ValidationError{
+			Fields: []FieldError{{Field: "expires_at", Message: "must be in the future"}},
+			msg:    "validation fails: expires_at is not in the future",
+		}
+	}
+	slices.Sort(p.Scopes)
+	p.Scopes = slices.Compact(p.Scopes)
+	return nil
+}
@@ -0,0 +1,65 @@
+package model
+
+import (
+	"io"
+	"time"
+)
+
+// DefaultAttachmentMaxSize is the largest attachment accepted when no
+// limit is configured.
+const DefaultAttachmentMaxSize int64 = 10 << 20
+
+// Attachment is the metadata of a file attached to a todo_task, its
+// content lives in a storage.BlobStore under StorageKey. Attachments are
+// hidden while their todo_task is in the trash. Purging the todo_task
+// detaches them, TodoTaskID becomes nil, until the trash sweeper deletes
+// their content.
+type Attachment struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	TodoTaskID  *uint     `json:"todo_task_id" gorm:"index"`
+	Filename    string    `json:"filename" gorm:"size:255;not null"`
+	ContentType string    `json:"content_type" gorm:"size:255;not null"` // sniffed from the content, the client's claim is ignored
+	Size        int64     `json:"size" gorm:"not null"`
+	SHA256      string    `json:"sha256" gorm:"column:sha256;size:64;not null"` // hex encoded checksum of the content
+	StorageKey  string    `json:"-" gorm:"size:255;not null;uniqueIndex"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AttachmentUpload is a file uploaded to a todo_task. Content is read
+// twice, once to checksum it and once to store it.
+type AttachmentUpload struct {
+	Filename string        `json:"filename" validate:"required,max=255"`
+	Size     int64         `json:"size"`
+	Content  io.ReadSeeker `json:"-" validate:"-"`
+}
+
+// AttachmentListParams describes which page of attachments the client
+// wants. Attachments are listed oldest first.
+type AttachmentListParams struct {
+	Limit  int    // Requested page size, clamped to MaxPageSize
+	Cursor string // Opaque cursor returned as next_cursor by the previous page
+}
+
+// AttachmentPage is the response envelope of the attachments list endpoint.
+type AttachmentPage struct {
+	Items      []Attachment `json:"items"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+	HasMore    bool         `json:"has_more"`
+}
+
+// AttachmentMaxSize returns the configured size limit of attachments,
+// DefaultAttachmentMaxSize when it is not positive.
+func AttachmentMaxSize(configured int64) int64 {
+	if configured <= 0 {
+		return DefaultAttachmentMaxSize
+	}
+	return configured
+}
+
+// ValidateAttachmentUpload validates the AttachmentUpload fields
+func (a *AttachmentUpload) ValidateAttachmentUpload() error {
+	if err := validate.Struct(a); err != nil {
+		return newValidationError(err, a)
+	}
+	return nil
+}
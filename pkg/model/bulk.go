@@ -0,0 +1,38 @@
+package model
+
+// Operations of a todo_tasks bulk request.
+const (
+	BulkCreate = "create"
+	BulkUpdate = "update"
+	BulkDelete = "delete"
+)
+
+// BulkMode decides what happens to the other operations of a bulk
+// request when one of them fails.
+type BulkMode string
+
+const (
+	// BulkAllOrNothing rolls every operation back when one fails.
+	BulkAllOrNothing BulkMode = "all_or_nothing"
+	// BulkBestEffort keeps the operations that succeeded.
+	BulkBestEffort BulkMode = "best_effort"
+)
+
+// MaxBulkOperations bounds the size of a bulk request.
+const MaxBulkOperations = 1000
+
+// TodoTaskBulkOperation is one create, update or delete of a bulk request.
+// Version, when set, makes an update or delete conditional like If-Match.
+type TodoTaskBulkOperation struct {
+	Op      string
+	ID      string
+	Version *uint
+	Payload *TodoTaskPayload
+}
+
+// TodoTaskBulkResult is the outcome of the operation at the same index.
+// TodoTask is the created or updated todo_task, Err is nil on success.
+type TodoTaskBulkResult struct {
+	TodoTask *TodoTask
+	Err      error
+}
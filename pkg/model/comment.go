@@ -0,0 +1,62 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Comment is a message in the discussion thread of a todo_task. Deleted
+// comments are soft deleted, like the todo_tasks they belong to.
+type Comment struct {
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	TodoTaskID uint           `json:"todo_task_id" gorm:"not null;index"`
+	AuthorID   *uint          `json:"author_id" gorm:"index"` // the user who wrote it, nil for comments older than accounts
+	Body       string         `json:"body" gorm:"not null"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	EditedAt   *time.Time     `json:"edited_at"` // when the body last changed, nil if it never did
+	DeletedAt  gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// CommentPayload holds the body of a new comment, its author is the user
+// the request is made on behalf of.
+type CommentPayload struct {
+	Body string `json:"body" validate:"required,max=10000"`
+}
+
+// CommentEditPayload holds the new body of a comment, the author of a
+// comment never changes.
+type CommentEditPayload struct {
+	Body string `json:"body" validate:"required,max=10000"`
+}
+
+// CommentListParams describes which page of a thread the client wants.
+// Comments are listed oldest first.
+type CommentListParams struct {
+	Limit  int    // Requested page size, clamped to MaxPageSize
+	Cursor string // Opaque cursor returned as next_cursor by the previous page
+}
+
+// CommentPage is the response envelope of the comments list endpoint.
+type CommentPage struct {
+	Items      []Comment `json:"items"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	HasMore    bool      `json:"has_more"`
+}
+
+// ValidateCommentPayload validates the CommentPayload fields
+func (c *CommentPayload) ValidateCommentPayload() error {
+	if err := validate.Struct(c); err != nil {
+		return newValidationError(err, c)
+	}
+	return nil
+}
+
+// ValidateCommentEditPayload validates the CommentEditPayload fields
+func (c *CommentEditPayload) ValidateCommentEditPayload() error {
+	if err := validate.Struct(c); err != nil {
+		return newValidationError(err, c)
+	}
+	return nil
+}
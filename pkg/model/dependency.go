@@ -0,0 +1,67 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TodoTaskDependency is the edge "BlockerID blocks BlockedID": the blocked
+// todo_task can not be done while the blocker is open.
+type TodoTaskDependency struct {
+	BlockerID uint      `json:"blocker_id" gorm:"primaryKey;autoIncrement:false"`
+	BlockedID uint      `json:"blocked_id" gorm:"primaryKey;autoIncrement:false;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TodoTaskGraphNode is a todo_task of a dependency graph.
+type TodoTaskGraphNode struct {
+	ID     uint           `json:"id"`
+	Title  string         `json:"title"`
+	Status TodoTaskStatus `json:"status"`
+}
+
+// TodoTaskGraph holds a todo_task, every todo_task blocking it directly or
+// indirectly, every todo_task it blocks and the edges between them.
+type TodoTaskGraph struct {
+	Root  uint                 `json:"root"`
+	Nodes []TodoTaskGraphNode  `json:"nodes"`
+	Edges []TodoTaskDependency `json:"edges"`
+}
+
+// Open reports whether a todo_task at status s still blocks the
+// todo_tasks depending on it.
+func (s TodoTaskStatus) Open() bool {
+	return s != StatusDone && s != StatusCancelled
+}
+
+// DOT renders the graph in the Graphviz DOT language, with an arrow from
+// every blocker to the todo_task it blocks. The root is drawn bold and
+// closed todo_tasks are grayed out.
+func (g TodoTaskGraph) DOT() string {
+	var b strings.Builder
+	b.WriteString("digraph todo_tasks {\n")
+	b.WriteString("  rankdir=LR;\n")
+	b.WriteString("  node [shape=box];\n")
+	for _, n := range g.Nodes {
+		var attrs []string
+		attrs = append(attrs, fmt.Sprintf("label=%s", dotQuote(fmt.Sprintf("#%d %s\n%s", n.ID, n.Title, n.Status))))
+		if n.ID == g.Root {
+			attrs = append(attrs, "style=bold")
+		}
+		if !n.Status.Open() {
+			attrs = append(attrs, "color=gray", "fontcolor=gray")
+		}
+		fmt.Fprintf(&b, "  %d [%s];\n", n.ID, strings.Join(attrs, ", "))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %d -> %d;\n", e.BlockerID, e.BlockedID)
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// dotQuote quotes s as a DOT string.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// IdempotencyKey remembers a request sent with an Idempotency-Key header
+// and, once it completed, the response to replay to retries of it. Keys
+// are chosen by the clients, so they are only unique for the user and the
+// workspace that sent them; both are zero for anonymous requests.
+type IdempotencyKey struct {
+	UserID      uint              `gorm:"primaryKey;autoIncrement:false"`
+	WorkspaceID uint              `gorm:"primaryKey;autoIncrement:false"`
+	Key         string            `gorm:"primaryKey;size:255"`
+	Fingerprint string            `gorm:"not null;size:64"` // hash of the request the key was first used with
+	StatusCode  int               `gorm:"not null;default:0"`
+	Header      map[string]string `gorm:"serializer:json;type:text"`
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
+
+// Completed reports whether the response of the first request is stored.
+// A key without a response belongs to a request still in flight.
+func (k IdempotencyKey) Completed() bool {
+	return k.StatusCode != 0
+}
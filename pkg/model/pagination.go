@@ -0,0 +1,70 @@
+package model
+
+import "time"
+
+const (
+	// DefaultPageSize is used when the client does not ask for a page size.
+	DefaultPageSize = 20
+	// MaxPageSize is the largest page the server will return, whatever the client asks for.
+	MaxPageSize = 100
+)
+
+// TodoTaskSortFields lists the fields a todo_tasks list can be sorted by.
+var TodoTaskSortFields = []string{"id", "title", "status", "created_at", "updated_at"}
+
+// SortField is one key of a multi-field sort, e.g. "-updated_at".
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// TodoTaskFilter narrows down the todo_tasks returned by a list request.
+// Nil and empty fields do not filter.
+type TodoTaskFilter struct {
+	Status        []TodoTaskStatus // Any of these statuses
+	Title         string           // Case-insensitive substring of the title
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedAfter  *time.Time
+	UpdatedBefore *time.Time
+	DueAfter      *time.Time
+	DueBefore     *time.Time
+	DueToday      bool           // Due between the start and the end of today in Location
+	Overdue       *bool          // Past due and neither done nor cancelled, or the opposite
+	Location      *time.Location // Time zone of DueToday, UTC when nil
+	TagsAny       []string       // Tagged with at least one of these tag names
+	TagsAll       []string       // Tagged with every one of these tag names
+	ProjectID     *uint          // In this project, archived or not; otherwise archived projects are left out
+}
+
+// TodoTaskListParams describes which page of todo_tasks the client wants.
+type TodoTaskListParams struct {
+	Limit  int    // Requested page size, clamped to MaxPageSize
+	Cursor string // Opaque cursor returned as next_cursor by the previous page
+	Filter TodoTaskFilter
+	Sort   []SortField // Sort keys in priority order, id is always the last tiebreaker
+}
+
+// PageSize returns the effective page size for the request.
+func (p TodoTaskListParams) PageSize() int {
+	return PageSize(p.Limit)
+}
+
+// PageSize clamps a client supplied limit to (0, MaxPageSize], falling back
+// to DefaultPageSize when no limit was given.
+func PageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return limit
+}
+
+// TodoTaskPage is the response envelope of the todo_tasks list endpoint.
+type TodoTaskPage struct {
+	Items      []TodoTask `json:"items"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	HasMore    bool       `json:"has_more"`
+}
@@ -0,0 +1,52 @@
+package model
+
+import "time"
+
+// Project groups todo_tasks. Archiving a project archives everything in
+// it: its todo_tasks leave the todo_tasks list and search, and no
+// todo_task can be added to it until it is unarchived.
+type Project struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	Name        string         `json:"name" gorm:"size:128;not null"`
+	Description string         `json:"description" gorm:"not null;default:''"`
+	ArchivedAt  *time.Time     `json:"archived_at" gorm:"index"` // nil while the project is active
+	OwnerID     *uint          `json:"owner_id" gorm:"index"`    // the user who created it
+	WorkspaceID uint           `json:"workspace_id" gorm:"not null;default:1;index"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	Counts      *ProjectCounts `json:"counts,omitempty" gorm:"-"`
+}
+
+// ProjectCounts tells how many live todo_tasks a project holds.
+type ProjectCounts struct {
+	Total    int64                    `json:"total"`
+	ByStatus map[TodoTaskStatus]int64 `json:"by_status"` // Statuses without todo_tasks are left out
+}
+
+type ProjectPayload struct {
+	Name        string `json:"name" validate:"required,max=128"`
+	Description string `json:"description" validate:"max=10000"`
+}
+
+// ProjectListParams describes which page of projects the client wants.
+// Projects are listed oldest first.
+type ProjectListParams struct {
+	Limit    int    // Requested page size, clamped to MaxPageSize
+	Cursor   string // Opaque cursor returned as next_cursor by the previous page
+	Archived bool   // List the archived projects instead of the active ones
+}
+
+// ProjectPage is the response envelope of the projects list endpoint.
+type ProjectPage struct {
+	Items      []Project `json:"items"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	HasMore    bool      `json:"has_more"`
+}
+
+// ValidateProjectPayload validates the ProjectPayload fields
+func (p *ProjectPayload) ValidateProjectPayload() error {
+	if err := validate.Struct(p); err != nil {
+		return newValidationError(err, p)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package model
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/teambition/rrule-go"
+)
+
+// TodoTaskOccurrence is when an occurrence of a recurring todo_task starts
+// and is due, in the time zone of the todo_task.
+type TodoTaskOccurrence struct {
+	StartAt *time.Time `json:"start_at"`
+	DueAt   *time.Time `json:"due_at"`
+}
+
+// TodoTaskOccurrences represents the upcoming occurrences of a todo_task.
+type TodoTaskOccurrences struct {
+	Items []TodoTaskOccurrence `json:"items"`
+}
+
+// NormalizeRecurrence trims an RRULE and drops its optional "RRULE:" name,
+// so "rrule:FREQ=WEEKLY;BYDAY=MO" is stored as "FREQ=WEEKLY;BYDAY=MO".
+func NormalizeRecurrence(s string) string {
+	return strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(s)), "RRULE:")
+}
+
+// parseRecurrence parses the RRULE rule, a floating UNTIL is read in loc.
+// The series starts at the todo_task itself, so the rule may not carry a
+// DTSTART, and todo_tasks recur at most hourly.
+func parseRecurrence(rule string, loc *time.Location) (*rrule.ROption, error) {
+	rule = NormalizeRecurrence(rule)
+	if strings.ContainsAny(rule, "\r\n") {
+		return nil, errors.New("only a single RRULE is supported")
+	}
+	option, err := rrule.StrToROptionInLocation(rule, loc)
+	if err != nil {
+		return nil, err
+	}
+	if !option.Dtstart.IsZero() {
+		return nil, errors.New("DTSTART is taken from the todo_task")
+	}
+	if option.Freq > rrule.HOURLY {
+		return nil, errors.New("FREQ must be at most HOURLY")
+	}
+	if option.Count < 0 || option.Interval < 0 {
+		return nil, errors.New("COUNT and INTERVAL must be positive")
+	}
+	// An UNTIL date includes the whole day
+	for _, part := range strings.Split(rule, ";") {
+		if until, ok := strings.CutPrefix(part, "UNTIL="); ok && len(until) == len(rrule.DateFormat) {
+			option.Until = option.Until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+	}
+	if _, err := rrule.NewRRule(*option); err != nil {
+		return nil, err
+	}
+	return option, nil
+}
+
+// validateRecurrence is the "rrule" validation of a recurrence field.
+func validateRecurrence(fl validator.FieldLevel) bool {
+	_, err := parseRecurrence(fl.Field().String(), time.UTC)
+	return err == nil
+}
+
+// Location is the time zone the recurrence of t is evaluated in, UTC
+// unless the todo_task has a Timezone.
+func (t TodoTask) Location() *time.Location {
+	if loc, err := time.LoadLocation(t.Timezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// NextOccurrences returns up to n occurrences following t. The todo_task is
+// the first occurrence of its series: its due date, or its start date when
+// it has none, is the DTSTART of the rule and a COUNT includes it. The rule
+// is evaluated on the wall clock of Location, so a todo_task due Mondays at
+// 09:00 stays due at 09:00 across daylight saving time changes.
+func (t TodoTask) NextOccurrences(n int) ([]TodoTaskOccurrence, error) {
+	occurrences := []TodoTaskOccurrence{}
+	anchor := t.DueAt
+	if anchor == nil {
+		anchor = t.StartAt
+	}
+	if t.Recurrence == "" || anchor == nil {
+		return occurrences, nil
+	}
+
+	loc := t.Location()
+	option, err := parseRecurrence(t.Recurrence, loc)
+	if err != nil {
+		return nil, err
+	}
+	if option.Count > 0 {
+		n = min(n, option.Count-1)
+		option.Count = 0
+	}
+	dtstart := anchor.In(loc)
+	option.Dtstart = dtstart
+	rule, err := rrule.NewRRule(*option)
+	if err != nil {
+		return nil, err
+	}
+
+	next := rule.Iterator()
+	for len(occurrences) < n {
+		at, ok := next()
+		if !ok {
+			break
+		}
+		if !at.After(dtstart) {
+			continue
+		}
+		occurrence := TodoTaskOccurrence{}
+		switch {
+		case t.DueAt == nil:
+			occurrence.StartAt = &at
+		case t.StartAt == nil:
+			occurrence.DueAt = &at
+		default:
+			startAt := shiftWallClock(*t.StartAt, dtstart, at)
+			occurrence.StartAt, occurrence.DueAt = &startAt, &at
+		}
+		occurrences = append(occurrences, occurrence)
+	}
+	return occurrences, nil
+}
+
+// NextOccurrence returns the payload of the todo_task following t in its
+// series, nil when t is the last one. The next todo_task is a copy of t at
+// the default status whose COUNT, if any, is one less.
+func (t TodoTask) NextOccurrence() (*TodoTaskPayload, error) {
+	occurrences, err := t.NextOccurrences(1)
+	if err != nil || len(occurrences) == 0 {
+		return nil, err
+	}
+	next := t.Payload()
+	next.Status = DefaultTodoTaskStatus
+	next.StartAt, next.DueAt = occurrences[0].StartAt, occurrences[0].DueAt
+	next.Recurrence = decrementCount(t.Recurrence)
+	return &next, nil
+}
+
+// decrementCount lowers the COUNT of rule by one.
+func decrementCount(rule string) string {
+	parts := strings.Split(NormalizeRecurrence(rule), ";")
+	for i, part := range parts {
+		if count, ok := strings.CutPrefix(part, "COUNT="); ok {
+			if n, err := strconv.Atoi(count); err == nil {
+				parts[i] = "COUNT=" + strconv.Itoa(n-1)
+			}
+		}
+	}
+	return strings.Join(parts, ";")
+}
+
+// shiftWallClock moves t, which is relative to from, to the same wall clock
+// distance from to in the location of to. A start the evening before the
+// due date stays the evening before, whatever the daylight saving time.
+func shiftWallClock(t, from, to time.Time) time.Time {
+	loc := to.Location()
+	wallClock := func(t time.Time) time.Time {
+		t = t.In(loc)
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	}
+	shifted := wallClock(to).Add(wallClock(t).Sub(wallClock(from)))
+	return time.Date(shifted.Year(), shifted.Month(), shifted.Day(), shifted.Hour(), shifted.Minute(), shifted.Second(), shifted.Nanosecond(), loc)
+}
@@ -0,0 +1,16 @@
+package model
+
+// TodoTaskSearchResult is a todo_task matched by a full-text search, with
+// its relevance and the matched parts of the text wrapped in <mark> tags.
+// The snippets are HTML escaped, the <mark> tags are their only markup.
+type TodoTaskSearchResult struct {
+	TodoTask
+	Rank               float64 `json:"rank"`
+	TitleSnippet       string  `json:"title_snippet"`
+	DescriptionSnippet string  `json:"description_snippet"`
+}
+
+// TodoTaskSearchResults is the response envelope of the search endpoint.
+type TodoTaskSearchResults struct {
+	Items []TodoTaskSearchResult `json:"items"`
+}
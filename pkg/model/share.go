@@ -0,0 +1,63 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/vkuzmich/gin-project/pkg/auth"
+)
+
+// Share gives a user a role on a project, and with it on every todo_task
+// of the project, or on a single todo_task. Exactly one of ProjectID and
+// TodoTaskID is set. A user has at most one share per project or todo_task.
+type Share struct {
+	ID         uint      `json:"-" gorm:"primaryKey"`
+	ProjectID  *uint     `json:"project_id,omitempty" gorm:"uniqueIndex:idx_shares_project_user"`
+	TodoTaskID *uint     `json:"todo_task_id,omitempty" gorm:"uniqueIndex:idx_shares_todo_task_user"`
+	UserID     uint      `json:"user_id" gorm:"not null;index;uniqueIndex:idx_shares_project_user;uniqueIndex:idx_shares_todo_task_user"`
+	Email      string    `json:"email" gorm:"->;-:migration"` // Of the user, read from users
+	Role       auth.Role `json:"role" gorm:"size:16;not null"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// ShareTarget is the project or the todo_task shares are on, exactly one
+// of ProjectID and TodoTaskID is set.
+type ShareTarget struct {
+	ProjectID  *uint
+	TodoTaskID *uint
+}
+
+// SharePayload shares a project or a todo_task with the user registered
+// with Email. Sharing again with the same user changes their role.
+type SharePayload struct {
+	Email string    `json:"email" validate:"required,max=254,email"`
+	Role  auth.Role `json:"role" validate:"required,oneof=viewer commenter editor owner"`
+}
+
+// ShareList is the response envelope of the shares list endpoints.
+type ShareList struct {
+	Items []Share `json:"items"`
+}
+
+// Permissions are what the caller may do with a project or a todo_task.
+type Permissions struct {
+	Role    auth.Role     `json:"role"`
+	Actions []auth.Action `json:"actions"`
+}
+
+// NewPermissions lists the actions role allows.
+func NewPermissions(role auth.Role) Permissions {
+	return Permissions{Role: role, Actions: role.Actions()}
+}
+
+// ValidateSharePayload validates the SharePayload fields, the email is
+// normalized like the one of a registration.
+func (p *SharePayload) ValidateSharePayload() error {
+	p.Email = NormalizeEmail(p.Email)
+	p.Role = auth.Role(strings.TrimSpace(string(p.Role)))
+	if err := validate.Struct(p); err != nil {
+		return newValidationError(err, p)
+	}
+	return nil
+}
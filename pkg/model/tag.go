@@ -0,0 +1,66 @@
+package model
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// MaxTagNameLength is the size of the tags.name column.
+const MaxTagNameLength = 64
+
+// Tag is a label that can be put on any number of todo_tasks. Tag names
+// are unique within a workspace.
+type Tag struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"size:64;not null;uniqueIndex:idx_tags_workspace_name,priority:2" validate:"required,max=64"`
+	WorkspaceID uint      `json:"workspace_id" gorm:"not null;default:1;uniqueIndex:idx_tags_workspace_name,priority:1"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type TagPayload struct {
+	Name string `json:"name" validate:"required,max=64"`
+}
+
+// TagList is the response envelope of the tags list endpoint.
+type TagList struct {
+	Items []Tag `json:"items"`
+}
+
+// ValidateTagPayload validates the TagPayload fields
+func (t *TagPayload) ValidateTagPayload() error {
+	if err := validate.Struct(t); err != nil {
+		return newValidationError(err, t)
+	}
+	return nil
+}
+
+// NormalizeTagName trims and lowercases a tag name, so that "Urgent" and
+// " urgent" are the same tag.
+func NormalizeTagName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// NormalizeTagNames normalizes names and returns them sorted without
+// duplicates. Nil stays nil, meaning the tags are not given.
+func NormalizeTagNames(names []string) []string {
+	if names == nil {
+		return nil
+	}
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		normalized = append(normalized, NormalizeTagName(name))
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
+
+// TagNames returns the names of tags.
+func TagNames(tags []Tag) []string {
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	return names
+}
@@ -1,30 +1,104 @@
 package model
 
 import (
-	"fmt"
+	"slices"
+	"time"
+
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
 )
 
 type TodoTask struct {
-	gorm.Model         // adds ID, created_at etc.
-	Title       string `json:"title" validate:"required"`
-	Description string `json:"description" validate:"required"`
-	State       bool   `json:"state" validate:"required"`
+	gorm.Model                 // adds ID, created_at etc.
+	Title       string         `json:"title" validate:"required"`
+	Description string         `json:"description" validate:"required"`
+	Status      TodoTaskStatus `json:"status" gorm:"size:16;not null;default:todo;index" validate:"required,oneof=backlog todo in_progress blocked done cancelled"`
+	StartAt     *time.Time     `json:"start_at" gorm:"index"`
+	DueAt       *time.Time     `json:"due_at" gorm:"index"`
+	Tags        []Tag          `json:"tags" gorm:"many2many:todo_task_tags"`
+	ParentID    *uint          `json:"parent_id" gorm:"index"`                                                    // nil for a top-level todo_task
+	ProjectID   *uint          `json:"project_id" gorm:"index"`                                                   // nil outside of any project, subtasks share the project of their parent
+	OwnerID     *uint          `json:"owner_id" gorm:"index"`                                                     // the user who created it, nil for todo_tasks older than accounts
+	WorkspaceID uint           `json:"workspace_id" gorm:"not null;default:1;index"`                              // set from the request it is created in
+	Recurrence  string         `json:"recurrence" gorm:"size:512;not null;default:''" validate:"omitempty,rrule"` // RFC 5545 RRULE, empty for a one-off todo_task
+	Timezone    string         `json:"timezone" gorm:"size:64;not null;default:''" validate:"omitempty,timezone"` // IANA zone of the recurrence, empty for UTC
+	Version     uint           `json:"version" gorm:"not null;default:1"`                                         // incremented by every update
 }
 
 type TodoTaskPayload struct {
-	Title       string `json:"title" validate:"required"`
-	Description string `json:"description" validate:"required"`
-	State       bool   `json:"state" validate:"required"`
+	Title       string         `json:"title" validate:"required"`
+	Description string         `json:"description" validate:"required"`
+	Status      TodoTaskStatus `json:"status" validate:"required,oneof=backlog todo in_progress blocked done cancelled"`
+	StartAt     *time.Time     `json:"start_at"`
+	DueAt       *time.Time     `json:"due_at"`
+	Tags        []string       `json:"tags" validate:"omitempty,dive,required,max=64"` // Tag names, nil leaves the tags unchanged
+	ParentID    *uint          `json:"parent_id"`                                      // Set on create, changed by moving the todo_task
+	ProjectID   *uint          `json:"project_id"`                                     // Set on create, changed by moving the todo_task to another project
+	Recurrence  string         `json:"recurrence" validate:"omitempty,max=512,rrule"`
+	Timezone    string         `json:"timezone" validate:"omitempty,timezone"`
+}
+
+// Payload returns the client editable fields of the todoTask.
+func (t TodoTask) Payload() TodoTaskPayload {
+	return TodoTaskPayload{
+		Title:       t.Title,
+		Description: t.Description,
+		Status:      t.Status,
+		StartAt:     t.StartAt,
+		DueAt:       t.DueAt,
+		Tags:        TagNames(t.Tags),
+		ParentID:    t.ParentID,
+		ProjectID:   t.ProjectID,
+		Recurrence:  t.Recurrence,
+		Timezone:    t.Timezone,
+	}
+}
+
+// Changes returns the columns whose value differs between from and t,
+// keyed by column name, so that only those columns are written. The
+// parent and the project are left out, they only change when the
+// todo_task is moved.
+func (t TodoTaskPayload) Changes(from TodoTaskPayload) map[string]interface{} {
+	changes := map[string]interface{}{}
+	if t.Title != from.Title {
+		changes["title"] = t.Title
+	}
+	if t.Description != from.Description {
+		changes["description"] = t.Description
+	}
+	if t.Status != from.Status {
+		changes["status"] = t.Status
+	}
+	if !sameTime(t.StartAt, from.StartAt) {
+		changes["start_at"] = t.StartAt
+	}
+	if !sameTime(t.DueAt, from.DueAt) {
+		changes["due_at"] = t.DueAt
+	}
+	if recurrence := NormalizeRecurrence(t.Recurrence); recurrence != from.Recurrence {
+		changes["recurrence"] = recurrence
+	}
+	if t.Timezone != from.Timezone {
+		changes["timezone"] = t.Timezone
+	}
+	if tags := NormalizeTagNames(t.Tags); tags != nil && !slices.Equal(tags, NormalizeTagNames(from.Tags)) {
+		changes["tags"] = tags
+	}
+	return changes
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
 }
 
 // ValidateTodoTaskPayload validates the TodoTaskPayload fields
 func (t *TodoTaskPayload) ValidateTodoTaskPayload() error {
-	v := validator.New()
-	err := v.Struct(t)
+	err := validate.Struct(t)
 	if err != nil {
-		return fmt.Errorf("validation fails: %v", err)
+		return newValidationError(err, t)
 	}
 	return nil
 }
@@ -34,13 +108,36 @@ var validate *validator.Validate
 
 func init() {
 	validate = validator.New()
+	validate.RegisterStructValidation(validateTodoTaskSchedule, TodoTask{}, TodoTaskPayload{})
+	_ = validate.RegisterValidation("rrule", validateRecurrence)
+	validate.RegisterStructValidation(validateCredentials, Credentials{})
+	_ = validate.RegisterValidation("password", validatePassword)
+}
+
+// validateTodoTaskSchedule checks that a todo_task does not start after it
+// is due, and that a recurring todo_task has a date to recur from.
+func validateTodoTaskSchedule(sl validator.StructLevel) {
+	var startAt, dueAt *time.Time
+	var recurrence string
+	switch t := sl.Current().Interface().(type) {
+	case TodoTask:
+		startAt, dueAt, recurrence = t.StartAt, t.DueAt, t.Recurrence
+	case TodoTaskPayload:
+		startAt, dueAt, recurrence = t.StartAt, t.DueAt, t.Recurrence
+	}
+	if startAt != nil && dueAt != nil && startAt.After(*dueAt) {
+		sl.ReportError(startAt, "StartAt", "StartAt", "ltefield", "due_at")
+	}
+	if recurrence != "" && startAt == nil && dueAt == nil {
+		sl.ReportError(dueAt, "DueAt", "DueAt", "required_with", "recurrence")
+	}
 }
 
 // ValidateTodoTask validates the TodoTask struct
 func ValidateTodoTask(todoTask TodoTask) error {
 	err := validate.Struct(todoTask)
 	if err != nil {
-		return fmt.Errorf("validation fails: %v", err)
+		return newValidationError(err, todoTask)
 	}
 	return nil
 }
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SubtaskDeletePolicy decides what happens to the subtasks of a deleted
+// todo_task.
+type SubtaskDeletePolicy string
+
+const (
+	// SubtaskOrphan keeps the subtasks and makes them top-level todo_tasks.
+	SubtaskOrphan SubtaskDeletePolicy = "orphan"
+	// SubtaskCascade deletes the whole subtree with the todo_task.
+	SubtaskCascade SubtaskDeletePolicy = "cascade"
+)
+
+// DefaultSubtaskDeletePolicy is used when no policy is configured.
+const DefaultSubtaskDeletePolicy = SubtaskOrphan
+
+// ParseSubtaskDeletePolicy parses the SUBTASK_DELETE_POLICY setting, empty
+// means DefaultSubtaskDeletePolicy.
+func ParseSubtaskDeletePolicy(s string) (SubtaskDeletePolicy, error) {
+	switch policy := SubtaskDeletePolicy(strings.TrimSpace(s)); policy {
+	case "":
+		return DefaultSubtaskDeletePolicy, nil
+	case SubtaskOrphan, SubtaskCascade:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown subtask delete policy %q, use %s or %s", s, SubtaskOrphan, SubtaskCascade)
+	}
+}
+
+// TodoTaskNode is a todo_task of a tree with its subtasks. Descendants
+// counts the subtasks at any depth, DoneDescendants those of them that
+// are done.
+type TodoTaskNode struct {
+	TodoTask
+	Descendants     int            `json:"descendants"`
+	DoneDescendants int            `json:"done_descendants"`
+	Children        []TodoTaskNode `json:"children"`
+}
+
+// NewTodoTaskTree arranges the todo_tasks of a subtree, the root first,
+// into nodes and rolls up the completion of every node.
+func NewTodoTaskTree(todoTasks []TodoTask) TodoTaskNode {
+	children := map[uint][]TodoTask{}
+	for _, t := range todoTasks[1:] {
+		if t.ParentID != nil {
+			children[*t.ParentID] = append(children[*t.ParentID], t)
+		}
+	}
+
+	var build func(t TodoTask) TodoTaskNode
+	build = func(t TodoTask) TodoTaskNode {
+		node := TodoTaskNode{TodoTask: t, Children: []TodoTaskNode{}}
+		for _, child := range children[t.ID] {
+			c := build(child)
+			node.Children = append(node.Children, c)
+			node.Descendants += 1 + c.Descendants
+			node.DoneDescendants += c.DoneDescendants
+			if child.Status == StatusDone {
+				node.DoneDescendants++
+			}
+		}
+		return node
+	}
+	return build(todoTasks[0])
+}
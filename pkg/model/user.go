@@ -0,0 +1,88 @@
+package model
+
+import (
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// User is an account todo_tasks and projects belong to. Its password is
+// only stored as an argon2id hash.
+type User struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	Email        string     `json:"email" gorm:"size:254;not null;uniqueIndex"` // Lower case, unique
+	PasswordHash string     `json:"-" gorm:"not null"`
+	FailedLogins int        `json:"-" gorm:"not null;default:0"` // Failed logins since the last success or lockout
+	LockedUntil  *time.Time `json:"-"`                           // Logins are refused until then
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// Session is a bearer token issued by a login. Only the SHA-256 of the
+// token is stored, the token itself is shown once to the client.
+type Session struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null;index"`
+	CreatedAt time.Time
+}
+
+// commonPasswords are refused whatever their length, compared in lower case.
+var commonPasswords = map[string]bool{
+	"password1234": true, "123456789012": true, "qwertyuiop12": true, "passwordpassword": true,
+	"iloveyou1234": true, "letmein12345": true, "administrator": true, "changeme1234": true,
+	"welcome12345": true, "1q2w3e4r5t6y": true, "qwertyuiopasdfgh": true, "correcthorsebatterystaple": true,
+}
+
+// Credentials are what a user registers and logs in with. The password
+// policy asks for 12 to 128 characters, not one of the passwords attackers
+// try first and not built from the email address.
+type Credentials struct {
+	Email    string `json:"email" validate:"required,max=254,email"`
+	Password string `json:"password" validate:"required,min=12,max=128,password"`
+}
+
+// LoginResult is the response to a successful login.
+type LoginResult struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"` // Always "Bearer"
+	ExpiresAt   time.Time `json:"expires_at"`
+	User        User      `json:"user"`
+}
+
+// NormalizeEmail trims and lower cases an email address, the form it is
+// stored and looked up in.
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// ValidateCredentials checks the email and the password policy, it is
+// only used on registration so that older passwords keep working.
+func (c *Credentials) ValidateCredentials() error {
+	if err := validate.Struct(c); err != nil {
+		return newValidationError(err, c)
+	}
+	return nil
+}
+
+// validatePassword is the "password" validation of a new password.
+func validatePassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	distinct := map[rune]bool{}
+	for _, r := range password {
+		distinct[r] = true
+	}
+	return len(distinct) >= 5 && !commonPasswords[strings.ToLower(password)]
+}
+
+// validateCredentials rejects passwords containing the local part of the
+// email address.
+func validateCredentials(sl validator.StructLevel) {
+	c := sl.Current().Interface().(Credentials)
+	local, _, _ := strings.Cut(NormalizeEmail(c.Email), "@")
+	if len(local) >= 3 && strings.Contains(strings.ToLower(c.Password), local) {
+		sl.ReportError(c.Password, "Password", "Password", "excludes_email", "")
+	}
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError describes why a single field failed validation.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned by the model validators and lists every
+// field that failed, keyed by its JSON name.
+type ValidationError struct {
+	Fields []FieldError
+	msg    string
+}
+
+func (e *ValidationError) Error() string {
+	return e.msg
+}
+
+// newValidationError wraps the error of validating value.
+func newValidationError(err error, value interface{}) error {
+	validationErr := &ValidationError{msg: fmt.Sprintf("validation fails: %v", err)}
+
+	var fieldErrors validator.ValidationErrors
+	if errors.As(err, &fieldErrors) {
+		for _, fe := range fieldErrors {
+			validationErr.Fields = append(validationErr.Fields, FieldError{
+				Field:   jsonFieldName(reflect.Indirect(reflect.ValueOf(value)).Type(), fe),
+				Message: fieldErrorMessage(fe),
+			})
+		}
+	}
+	return validationErr
+}
+
+// jsonFieldName resolves the JSON name of the failed field of typ. An
+// element of a slice keeps its index, e.g. "tags[1]".
+func jsonFieldName(typ reflect.Type, fe validator.FieldError) string {
+	field, index, _ := strings.Cut(fe.StructField(), "[")
+	if index != "" {
+		index = "[" + index
+	}
+	if f, ok := typ.FieldByName(field); ok {
+		if name := strings.Split(f.Tag.Get("json"), ",")[0]; name != "" && name != "-" {
+			return name + index
+		}
+	}
+	return fe.Field()
+}
+
+func fieldErrorMessage(fe validator.FieldError) string {
+	switch fe.Tag() {
+	case "required":
+		return "is required"
+	case "max":
+		return "must be at most " + fe.Param() + " characters"
+	case "min":
+		return "must be at least " + fe.Param() + " characters"
+	case "email":
+		return "must be an email address"
+	case "password":
+		return "is too common or repetitive"
+	case "excludes_email":
+		return "must not contain the email address"
+	case "ltefield":
+		return "must not be after " + fe.Param()
+	case "required_with":
+		return "is required with " + fe.Param()
+	case "rrule":
+		return "must be an RFC 5545 RRULE such as FREQ=WEEKLY;BYDAY=MO"
+	case "timezone":
+		return "must be an IANA time zone such as Europe/Berlin"
+	case "oneof":
+		return "must be one of: " + strings.Join(strings.Fields(fe.Param()), ", ")
+	default:
+		if fe.Param() != "" {
+			return fmt.Sprintf("must satisfy %s=%s", fe.Tag(), fe.Param())
+		}
+		return "must satisfy " + fe.Tag()
+	}
+}
@@ -0,0 +1,85 @@
+package model
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TodoTaskStatus is the step of the workflow a todo_task is at.
+type TodoTaskStatus string
+
+const (
+	StatusBacklog    TodoTaskStatus = "backlog"
+	StatusTodo       TodoTaskStatus = "todo"
+	StatusInProgress TodoTaskStatus = "in_progress"
+	StatusBlocked    TodoTaskStatus = "blocked"
+	StatusDone       TodoTaskStatus = "done"
+	StatusCancelled  TodoTaskStatus = "cancelled"
+)
+
+// DefaultTodoTaskStatus is given to todo_tasks created without a status.
+const DefaultTodoTaskStatus = StatusTodo
+
+// TodoTaskStatuses lists every status in workflow order.
+var TodoTaskStatuses = []TodoTaskStatus{StatusBacklog, StatusTodo, StatusInProgress, StatusBlocked, StatusDone, StatusCancelled}
+
+// Valid reports whether s is one of TodoTaskStatuses.
+func (s TodoTaskStatus) Valid() bool {
+	return slices.Contains(TodoTaskStatuses, s)
+}
+
+// TodoTaskWorkflow maps every status to the statuses a todo_task may move
+// to from it. Staying at the same status is always allowed.
+type TodoTaskWorkflow map[TodoTaskStatus][]TodoTaskStatus
+
+// DefaultTodoTaskWorkflow is used when no workflow is configured.
+var DefaultTodoTaskWorkflow = TodoTaskWorkflow{
+	StatusBacklog:    {StatusTodo, StatusCancelled},
+	StatusTodo:       {StatusBacklog, StatusInProgress, StatusCancelled},
+	StatusInProgress: {StatusTodo, StatusBlocked, StatusDone, StatusCancelled},
+	StatusBlocked:    {StatusInProgress, StatusCancelled},
+	StatusDone:       {StatusTodo},
+	StatusCancelled:  {StatusBacklog},
+}
+
+// CanTransition reports whether a todo_task may move from one status to another.
+func (w TodoTaskWorkflow) CanTransition(from, to TodoTaskStatus) bool {
+	return from == to || slices.Contains(w[from], to)
+}
+
+// ParseTodoTaskWorkflow reads a workflow written as
+// "backlog:todo|cancelled;todo:in_progress;...", one rule per status the
+// todo_tasks may leave. An empty string gives DefaultTodoTaskWorkflow.
+func ParseTodoTaskWorkflow(s string) (TodoTaskWorkflow, error) {
+	if strings.TrimSpace(s) == "" {
+		return DefaultTodoTaskWorkflow, nil
+	}
+
+	workflow := TodoTaskWorkflow{}
+	for _, rule := range strings.Split(s, ";") {
+		if strings.TrimSpace(rule) == "" {
+			continue
+		}
+		from, targets, ok := strings.Cut(rule, ":")
+		if !ok {
+			return nil, fmt.Errorf("workflow rule %q is not of the form from:to|to", rule)
+		}
+		fromStatus := TodoTaskStatus(strings.TrimSpace(from))
+		if !fromStatus.Valid() {
+			return nil, fmt.Errorf("workflow rule %q: unknown status %q", rule, fromStatus)
+		}
+		if _, ok := workflow[fromStatus]; ok {
+			return nil, fmt.Errorf("workflow has two rules for %q", fromStatus)
+		}
+		workflow[fromStatus] = []TodoTaskStatus{}
+		for _, to := range strings.Split(targets, "|") {
+			toStatus := TodoTaskStatus(strings.TrimSpace(to))
+			if !toStatus.Valid() {
+				return nil, fmt.Errorf("workflow rule %q: unknown status %q", rule, toStatus)
+			}
+			workflow[fromStatus] = append(workflow[fromStatus], toStatus)
+		}
+	}
+	return workflow, nil
+}
@@ -0,0 +1,68 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultWorkspaceID is the workspace holding the data older than
+// workspaces. Every user registered before them is a member of it.
+const DefaultWorkspaceID uint = 1
+
+// Workspace is a tenant. Its todo_tasks, projects and tags are only ever
+// seen by requests made in it, and only members can make requests in it.
+type Workspace struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"size:128;not null"`
+	OwnerID   *uint     `json:"owner_id" gorm:"index"` // manages the members, nil for the default workspace
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WorkspaceMember lets a user make requests in a workspace.
+type WorkspaceMember struct {
+	WorkspaceID uint      `json:"workspace_id" gorm:"primaryKey;autoIncrement:false"`
+	UserID      uint      `json:"user_id" gorm:"primaryKey;autoIncrement:false;index"`
+	Email       string    `json:"email" gorm:"->;-:migration"` // Of the user, read from users
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type WorkspacePayload struct {
+	Name string `json:"name" validate:"required,max=128"`
+}
+
+// WorkspaceMemberPayload adds the user registered with Email to a
+// workspace.
+type WorkspaceMemberPayload struct {
+	Email string `json:"email" validate:"required,max=254,email"`
+}
+
+// WorkspaceList is the response envelope of the workspaces list endpoint.
+type WorkspaceList struct {
+	Items []Workspace `json:"items"`
+}
+
+// WorkspaceMemberList is the response envelope of the members list
+// endpoint.
+type WorkspaceMemberList struct {
+	Items []WorkspaceMember `json:"items"`
+}
+
+// ValidateWorkspacePayload validates the WorkspacePayload fields
+func (w *WorkspacePayload) ValidateWorkspacePayload() error {
+	w.Name = strings.TrimSpace(w.Name)
+	if err := validate.Struct(w); err != nil {
+		return newValidationError(err, w)
+	}
+	return nil
+}
+
+// ValidateWorkspaceMemberPayload validates the WorkspaceMemberPayload
+// fields, the email is normalized like the one of a registration.
+func (p *WorkspaceMemberPayload) ValidateWorkspaceMemberPayload() error {
+	p.Email = NormalizeEmail(p.Email)
+	if err := validate.Struct(p); err != nil {
+		return newValidationError(err, p)
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package patch
+
+import "encoding/json"
+
+// MergePatch applies an RFC 7396 JSON Merge Patch to doc. Object members
+// of the patch replace the members of doc, a null member removes it and
+// any non-object patch replaces doc as a whole.
+func MergePatch(doc, patch []byte) ([]byte, error) {
+	target, err := decode(doc, "document")
+	if err != nil {
+		return nil, err
+	}
+	p, err := decode(patch, "patch")
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergePatch(target, p))
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergePatch(targetObject[name], value)
+	}
+	return targetObject
+}
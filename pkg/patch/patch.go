@@ -0,0 +1,58 @@
+// Package patch applies JSON Merge Patch (RFC 7396) and JSON Patch
+// (RFC 6902) documents to JSON values.
+package patch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+)
+
+const (
+	// MergePatchContentType is the media type of an RFC 7396 JSON Merge Patch.
+	MergePatchContentType = "application/merge-patch+json"
+	// JSONPatchContentType is the media type of an RFC 6902 JSON Patch.
+	JSONPatchContentType = "application/json-patch+json"
+)
+
+var (
+	// ErrUnsupportedContentType is returned for a patch media type other
+	// than MergePatchContentType and JSONPatchContentType.
+	ErrUnsupportedContentType = errors.New("unsupported patch content type")
+	// ErrInvalidPatch is returned when the patch document is malformed or
+	// can not be applied to the target document.
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrTestFailed is returned when a JSON Patch "test" operation does not match.
+	ErrTestFailed = errors.New("patch test operation failed")
+)
+
+// Apply applies patch to the JSON document doc according to contentType
+// and returns the patched document.
+func Apply(contentType string, doc, patch []byte) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, ErrUnsupportedContentType
+	}
+
+	switch mediaType {
+	case MergePatchContentType:
+		return MergePatch(doc, patch)
+	case JSONPatchContentType:
+		return JSONPatch(doc, patch)
+	default:
+		return nil, ErrUnsupportedContentType
+	}
+}
+
+func invalidPatch(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidPatch, fmt.Sprintf(format, args...))
+}
+
+func decode(raw []byte, what string) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, invalidPatch("%s is not valid JSON: %v", what, err)
+	}
+	return v, nil
+}
@@ -0,0 +1,136 @@
+package patch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		patch    string
+		expected string
+	}{
+		{
+			name:     "replace member",
+			doc:      `{"title":"a","state":true}`,
+			patch:    `{"title":"b"}`,
+			expected: `{"title":"b","state":true}`,
+		},
+		{
+			name:     "null removes member",
+			doc:      `{"title":"a","state":true}`,
+			patch:    `{"state":null}`,
+			expected: `{"title":"a"}`,
+		},
+		{
+			name:     "nested objects are merged",
+			doc:      `{"a":{"b":1,"c":2}}`,
+			patch:    `{"a":{"c":null,"d":3}}`,
+			expected: `{"a":{"b":1,"d":3}}`,
+		},
+		{
+			name:     "non object replaces document",
+			doc:      `{"a":1}`,
+			patch:    `["x"]`,
+			expected: `["x"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(result))
+		})
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		doc         string
+		patch       string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "replace and add",
+			doc:      `{"title":"a","state":false}`,
+			patch:    `[{"op":"replace","path":"/title","value":"b"},{"op":"add","path":"/state","value":true}]`,
+			expected: `{"title":"b","state":true}`,
+		},
+		{
+			name:     "array insert, append and remove",
+			doc:      `{"tags":["a","c"]}`,
+			patch:    `[{"op":"add","path":"/tags/1","value":"b"},{"op":"add","path":"/tags/-","value":"d"},{"op":"remove","path":"/tags/0"}]`,
+			expected: `{"tags":["b","c","d"]}`,
+		},
+		{
+			name:     "move and copy",
+			doc:      `{"a":{"x":1},"b":{}}`,
+			patch:    `[{"op":"copy","from":"/a/x","path":"/b/y"},{"op":"move","from":"/a","path":"/c"}]`,
+			expected: `{"b":{"y":1},"c":{"x":1}}`,
+		},
+		{
+			name:     "escaped pointer",
+			doc:      `{"a/b":1,"m~n":2}`,
+			patch:    `[{"op":"remove","path":"/a~1b"},{"op":"replace","path":"/m~0n","value":3}]`,
+			expected: `{"m~n":3}`,
+		},
+		{
+			name:     "null value",
+			doc:      `{"title":"a","due_at":"2024-01-01T00:00:00Z","start_at":null}`,
+			patch:    `[{"op":"replace","path":"/due_at","value":null},{"op":"test","path":"/start_at","value":null},{"op":"add","path":"/note","value":null}]`,
+			expected: `{"title":"a","due_at":null,"start_at":null,"note":null}`,
+		},
+		{
+			name:        "missing value",
+			doc:         `{"title":"a"}`,
+			patch:       `[{"op":"replace","path":"/title"}]`,
+			expectedErr: ErrInvalidPatch,
+		},
+		{
+			name:        "failing test operation",
+			doc:         `{"title":"a"}`,
+			patch:       `[{"op":"test","path":"/title","value":"b"}]`,
+			expectedErr: ErrTestFailed,
+		},
+		{
+			name:        "replace of missing member",
+			doc:         `{"title":"a"}`,
+			patch:       `[{"op":"replace","path":"/state","value":true}]`,
+			expectedErr: ErrInvalidPatch,
+		},
+		{
+			name:        "unknown operation",
+			doc:         `{}`,
+			patch:       `[{"op":"merge","path":"/a","value":1}]`,
+			expectedErr: ErrInvalidPatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := JSONPatch([]byte(tt.doc), []byte(tt.patch))
+			if tt.expectedErr != nil {
+				assert.True(t, errors.Is(err, tt.expectedErr), "unexpected error %v", err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(result))
+		})
+	}
+}
+
+func TestApplyUnsupportedContentType(t *testing.T) {
+	_, err := Apply("application/json", []byte(`{}`), []byte(`{}`))
+	assert.Equal(t, ErrUnsupportedContentType, err)
+
+	result, err := Apply("application/merge-patch+json; charset=utf-8", []byte(`{"a":1}`), []byte(`{"a":2}`))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"a":2}`, string(result))
+}
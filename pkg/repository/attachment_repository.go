@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+// AttachmentRepository stores the metadata of the files attached to the
+// todo_tasks. The methods taking a todoTaskID return
+// gorm.ErrRecordNotFound when that todo_task is not live.
+//
+// Attachments are never deleted in one go: they are detached from their
+// todo_task first, and the row is purged once the content is gone from
+// the blob store, so that no content outlives its metadata.
+type AttachmentRepository interface {
+	RoleRepository
+	CreateAttachment(ctx context.Context, todoTaskID string, attachment *model.Attachment) error
+	GetAttachment(ctx context.Context, todoTaskID string, id string) (model.Attachment, error)
+	GetAttachments(ctx context.Context, todoTaskID string, params model.AttachmentListParams) (model.AttachmentPage, error)
+	DetachAttachment(ctx context.Context, todoTaskID string, id string) (model.Attachment, error)
+	GetDetachedAttachments(ctx context.Context, limit int) ([]model.Attachment, error)
+	PurgeAttachment(ctx context.Context, id uint) error
+}
+
+func NewAttachmentRepository(db *gorm.DB) AttachmentRepository {
+	return repository{db}
+}
+
+// CreateAttachment records the metadata of content already stored under
+// attachment.StorageKey and sets its id and todo_task.
+func (r repository) CreateAttachment(ctx context.Context, todoTaskID string, attachment *model.Attachment) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		id, err := (repository{tx}).liveTodoTask(ctx, todoTaskID)
+		if err != nil {
+			return err
+		}
+		attachment.TodoTaskID = &id
+		return tx.Create(attachment).Error
+	})
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", todoTaskID).Msg("error while creating attachment")
+		return err
+	}
+	logger.Info().Uint("attachment_id", attachment.ID).Msg("Attachment created")
+	return nil
+}
+
+func (r repository) GetAttachment(ctx context.Context, todoTaskID string, id string) (model.Attachment, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	if _, err := r.liveTodoTask(ctx, todoTaskID); err != nil {
+		logger.Error().Err(err).Str("todo_task_id", todoTaskID).Msg("error while getting todo_task of attachment")
+		return model.Attachment{}, err
+	}
+	var attachment model.Attachment
+	if err := r.db.WithContext(ctx).Where("todo_task_id = ? AND id = ?", todoTaskID, id).First(&attachment).Error; err != nil {
+		logger.Error().Err(err).Str("attachment_id", id).Msg("error while getting attachment")
+		return model.Attachment{}, err
+	}
+	logger.Info().Msg("Attachment was found")
+	return attachment, nil
+}
+
+// GetAttachments returns one page of the attachments of a todo_task,
+// oldest first, paged like the comments.
+func (r repository) GetAttachments(ctx context.Context, todoTaskID string, params model.AttachmentListParams) (model.AttachmentPage, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	if _, err := r.liveTodoTask(ctx, todoTaskID); err != nil {
+		logger.Error().Err(err).Str("todo_task_id", todoTaskID).Msg("error while getting todo_task of attachments")
+		return model.AttachmentPage{}, err
+	}
+
+	limit := model.PageSize(params.Limit)
+	query := r.db.WithContext(ctx).Where("todo_task_id = ?", todoTaskID)
+	if params.Cursor != "" {
+		after, err := decodeIDCursor(params.Cursor)
+		if err != nil {
+			logger.Info().Str("cursor", params.Cursor).Msg("invalid attachments cursor")
+			return model.AttachmentPage{}, err
+		}
+		query = query.Where("id > ?", after)
+	}
+
+	attachments := []model.Attachment{}
+	if err := query.Order("id ASC").Limit(limit + 1).Find(&attachments).Error; err != nil {
+		logger.Error().Err(err).Msg("error while fetching attachments")
+		return model.AttachmentPage{}, err
+	}
+
+	// One extra row was fetched to find out whether another page exists
+	page := model.AttachmentPage{Items: attachments}
+	if len(attachments) > limit {
+		page.Items = attachments[:limit]
+		page.HasMore = true
+		page.NextCursor = encodeIDCursor(page.Items[limit-1].ID)
+	}
+
+	logger.Info().Int("count", len(page.Items)).Msg("Get page of Attachments")
+	return page, nil
+}
+
+// DetachAttachment takes an attachment away from its todo_task and
+// returns it, the caller then deletes its content and purges it.
+func (r repository) DetachAttachment(ctx context.Context, todoTaskID string, id string) (model.Attachment, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var attachment model.Attachment
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if attachment, err = (repository{tx}).GetAttachment(ctx, todoTaskID, id); err != nil {
+			return err
+		}
+		return tx.Model(&attachment).Update("todo_task_id", nil).Error
+	})
+	if err != nil {
+		logger.Error().Err(err).Str("attachment_id", id).Msg("error while detaching attachment")
+		return model.Attachment{}, err
+	}
+	attachment.TodoTaskID = nil
+	logger.Info().Str("attachment_id", id).Msg("Attachment detached")
+	return attachment, nil
+}
+
+// GetDetachedAttachments returns up to limit attachments whose content
+// still has to be deleted, oldest first.
+func (r repository) GetDetachedAttachments(ctx context.Context, limit int) ([]model.Attachment, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	attachments := []model.Attachment{}
+	if err := r.db.WithContext(ctx).Where("todo_task_id IS NULL").Order("id ASC").Limit(limit).Find(&attachments).Error; err != nil {
+		logger.Error().Err(err).Msg("error while fetching detached attachments")
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// PurgeAttachment deletes the row of a detached attachment.
+func (r repository) PurgeAttachment(ctx context.Context, id uint) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	if err := r.db.WithContext(ctx).Where("id = ? AND todo_task_id IS NULL", id).Delete(&model.Attachment{}).Error; err != nil {
+		logger.Error().Err(err).Uint("attachment_id", id).Msg("error while purging attachment")
+		return err
+	}
+	logger.Info().Uint("attachment_id", id).Msg("Attachment purged")
+	return nil
+}
+
+// detachAttachments detaches the attachments of todoTasks, an id or a
+// query selecting ids, when those todo_tasks are purged.
+func detachAttachments(tx *gorm.DB, todoTasks interface{}) error {
+	return tx.Model(&model.Attachment{}).Where("todo_task_id IN (?)", todoTasks).Update("todo_task_id", nil).Error
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+// CommentRepository stores the discussion threads of the todo_tasks. Every
+// method takes the id of the todo_task owning the thread and returns
+// gorm.ErrRecordNotFound when that todo_task is not live.
+type CommentRepository interface {
+	RoleRepository
+	CreateComment(ctx context.Context, todoTaskID string, commentPayload *model.CommentPayload) (model.Comment, error)
+	GetComment(ctx context.Context, todoTaskID string, id string) (model.Comment, error)
+	GetComments(ctx context.Context, todoTaskID string, params model.CommentListParams) (model.CommentPage, error)
+	UpdateComment(ctx context.Context, todoTaskID string, id string, commentPayload *model.CommentEditPayload) (model.Comment, error)
+	DeleteComment(ctx context.Context, todoTaskID string, id string) error
+}
+
+func NewCommentRepository(db *gorm.DB) CommentRepository {
+	return repository{db}
+}
+
+// liveTodoTask returns the id of the live todo_task todoTaskID of the caller.
+func (r repository) liveTodoTask(ctx context.Context, todoTaskID string) (uint, error) {
+	var todoTask model.TodoTask
+	if err := r.db.WithContext(ctx).Scopes(todoTasksOfCaller(ctx)).Select("id").Where("id = ?", todoTaskID).First(&todoTask).Error; err != nil {
+		return 0, err
+	}
+	return todoTask.ID, nil
+}
+
+func (r repository) CreateComment(ctx context.Context, todoTaskID string, commentPayload *model.CommentPayload) (model.Comment, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	commentPayload.Body = strings.TrimSpace(commentPayload.Body)
+	if err := commentPayload.ValidateCommentPayload(); err != nil {
+		return model.Comment{}, err
+	}
+
+	comment := model.Comment{AuthorID: callerID(ctx), Body: commentPayload.Body}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if comment.TodoTaskID, err = (repository{tx}).liveTodoTask(ctx, todoTaskID); err != nil {
+			return err
+		}
+		return tx.Create(&comment).Error
+	})
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", todoTaskID).Msg("error while creating comment")
+		return model.Comment{}, err
+	}
+	logger.Info().Uint("comment_id", comment.ID).Msg("Comment created")
+	return comment, nil
+}
+
+func (r repository) GetComment(ctx context.Context, todoTaskID string, id string) (model.Comment, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	if _, err := r.liveTodoTask(ctx, todoTaskID); err != nil {
+		logger.Error().Err(err).Str("todo_task_id", todoTaskID).Msg("error while getting todo_task of comment")
+		return model.Comment{}, err
+	}
+	var comment model.Comment
+	if err := r.db.WithContext(ctx).Where("todo_task_id = ? AND id = ?", todoTaskID, id).First(&comment).Error; err != nil {
+		logger.Error().Err(err).Str("comment_id", id).Msg("error while getting comment")
+		return model.Comment{}, err
+	}
+	logger.Info().Msg("Comment was found")
+	return comment, nil
+}
+
+// GetComments returns one page of the live comments of a todo_task, oldest
+// first. Like the todo_tasks list, the next page starts after the id
+// encoded in params.Cursor.
+func (r repository) GetComments(ctx context.Context, todoTaskID string, params model.CommentListParams) (model.CommentPage, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	if _, err := r.liveTodoTask(ctx, todoTaskID); err != nil {
+		logger.Error().Err(err).Str("todo_task_id", todoTaskID).Msg("error while getting todo_task of comments")
+		return model.CommentPage{}, err
+	}
+
+	limit := model.PageSize(params.Limit)
+	query := r.db.WithContext(ctx).Where("todo_task_id = ?", todoTaskID)
+	if params.Cursor != "" {
+		after, err := decodeIDCursor(params.Cursor)
+		if err != nil {
+			logger.Info().Str("cursor", params.Cursor).Msg("invalid comments cursor")
+			return model.CommentPage{}, err
+		}
+		query = query.Where("id > ?", after)
+	}
+
+	comments := []model.Comment{}
+	if err := query.Order("id ASC").Limit(limit + 1).Find(&comments).Error; err != nil {
+		logger.Error().Err(err).Msg("error while fetching comments")
+		return model.CommentPage{}, err
+	}
+
+	// One extra row was fetched to find out whether another page exists
+	page := model.CommentPage{Items: comments}
+	if len(comments) > limit {
+		page.Items = comments[:limit]
+		page.HasMore = true
+		page.NextCursor = encodeIDCursor(page.Items[limit-1].ID)
+	}
+
+	logger.Info().Int("count", len(page.Items)).Msg("Get page of Comments")
+	return page, nil
+}
+
+// UpdateComment replaces the body of a comment. EditedAt is only set when
+// the body actually changes.
+func (r repository) UpdateComment(ctx context.Context, todoTaskID string, id string, commentPayload *model.CommentEditPayload) (model.Comment, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	commentPayload.Body = strings.TrimSpace(commentPayload.Body)
+	if err := commentPayload.ValidateCommentEditPayload(); err != nil {
+		return model.Comment{}, err
+	}
+
+	var comment model.Comment
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if comment, err = (repository{tx}).GetComment(ctx, todoTaskID, id); err != nil || comment.Body == commentPayload.Body {
+			return err
+		}
+		return tx.Model(&comment).Updates(map[string]interface{}{"body": commentPayload.Body, "edited_at": time.Now()}).Error
+	})
+	if err != nil {
+		logger.Error().Err(err).Str("comment_id", id).Msg("error while updating comment")
+		return model.Comment{}, err
+	}
+	logger.Info().Str("comment_id", id).Msg("Comment updated")
+	return comment, nil
+}
+
+func (r repository) DeleteComment(ctx context.Context, todoTaskID string, id string) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	if _, err := r.liveTodoTask(ctx, todoTaskID); err != nil {
+		logger.Error().Err(err).Str("todo_task_id", todoTaskID).Msg("error while getting todo_task of comment")
+		return err
+	}
+	result := r.db.WithContext(ctx).Where("todo_task_id = ? AND id = ?", todoTaskID, id).Delete(&model.Comment{})
+	if result.Error != nil {
+		logger.Error().Err(result.Error).Str("comment_id", id).Msg("error while deleting comment")
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	logger.Info().Str("comment_id", id).Msg("Comment deleted")
+	return nil
+}
+
+// trashComments soft deletes the live comments of todoTasks, an id or a
+// query selecting ids, when those todo_tasks are moved to the trash.
+func trashComments(tx *gorm.DB, todoTasks interface{}) error {
+	return tx.Where("todo_task_id IN (?)", todoTasks).Delete(&model.Comment{}).Error
+}
+
+// restoreComments brings back the comments trashed together with a
+// todo_task deleted at deletedAt. Comments deleted before it stay deleted.
+func restoreComments(tx *gorm.DB, todoTaskID string, deletedAt time.Time) error {
+	return tx.Unscoped().Model(&model.Comment{}).
+		Where("todo_task_id = ? AND deleted_at >= ?", todoTaskID, deletedAt).
+		Update("deleted_at", nil).Error
+}
+
+// purgeComments permanently deletes every comment of todoTasks, an id or
+// a query selecting ids.
+func purgeComments(tx *gorm.DB, todoTasks interface{}) error {
+	return tx.Unscoped().Where("todo_task_id IN (?)", todoTasks).Delete(&model.Comment{}).Error
+}
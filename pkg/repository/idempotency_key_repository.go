@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdempotencyKeyRepository interface {
+	ReserveIdempotencyKey(ctx context.Context, key model.IdempotencyKey, now time.Time) (model.IdempotencyKey, bool, error)
+	CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, header map[string]string, body []byte) error
+	DeleteIdempotencyKey(ctx context.Context, key string) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
+}
+
+func NewIdempotencyKeyRepository(db *gorm.DB) IdempotencyKeyRepository {
+	return repository{db}
+}
+
+// idempotencyKeyOwner returns the user and the workspace the idempotency
+// keys of the request belong to, zero for both outside of a request.
+func idempotencyKeyOwner(ctx context.Context) (userID uint, workspaceID uint) {
+	principal, _ := auth.PrincipalFromContext(ctx)
+	return principal.UserID, principal.WorkspaceID
+}
+
+// idempotencyKeysOfCaller restricts a query on idempotency_keys to the
+// keys of the user and the workspace of the request, so that the same key
+// sent by someone else is another key.
+func idempotencyKeysOfCaller(ctx context.Context) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		userID, workspaceID := idempotencyKeyOwner(ctx)
+		return db.Where("user_id = ? AND workspace_id = ?", userID, workspaceID)
+	}
+}
+
+// ReserveIdempotencyKey stores key for the caller unless they already used
+// the same key and it has not expired at now. It reports whether key was
+// stored and otherwise returns the existing row, so that two concurrent
+// requests can not both reserve the same key.
+func (r repository) ReserveIdempotencyKey(ctx context.Context, key model.IdempotencyKey, now time.Time) (model.IdempotencyKey, bool, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	// A key past its expiry starts over, the sweeper drops the other ones
+	err := r.db.WithContext(ctx).Scopes(idempotencyKeysOfCaller(ctx)).Where("key = ? AND expires_at < ?", key.Key, now).Delete(&model.IdempotencyKey{}).Error
+	if err != nil {
+		logger.Error().Err(err).Msg("error while deleting expired idempotency_key")
+		return model.IdempotencyKey{}, false, err
+	}
+
+	key.UserID, key.WorkspaceID = idempotencyKeyOwner(ctx)
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&key)
+	if result.Error != nil {
+		logger.Error().Err(result.Error).Msg("error while reserving idempotency_key")
+		return model.IdempotencyKey{}, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		logger.Info().Msg("IdempotencyKey reserved")
+		return key, true, nil
+	}
+
+	var existing model.IdempotencyKey
+	if err := r.db.WithContext(ctx).Scopes(idempotencyKeysOfCaller(ctx)).Where("key = ?", key.Key).First(&existing).Error; err != nil {
+		logger.Error().Err(err).Msg("error while getting idempotency_key")
+		return model.IdempotencyKey{}, false, err
+	}
+	return existing, false, nil
+}
+
+// CompleteIdempotencyKey stores the response of the request holding the
+// caller's key.
+func (r repository) CompleteIdempotencyKey(ctx context.Context, key string, statusCode int, header map[string]string, body []byte) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	result := r.db.WithContext(ctx).Model(&model.IdempotencyKey{}).Scopes(idempotencyKeysOfCaller(ctx)).Where("key = ?", key).Updates(model.IdempotencyKey{
+		StatusCode: statusCode,
+		Header:     header,
+		Body:       body,
+	})
+	if result.Error != nil {
+		logger.Error().Err(result.Error).Msg("error while completing idempotency_key")
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteIdempotencyKey releases the caller's key so that the request may
+// be retried.
+func (r repository) DeleteIdempotencyKey(ctx context.Context, key string) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	if err := r.db.WithContext(ctx).Scopes(idempotencyKeysOfCaller(ctx)).Where("key = ?", key).Delete(&model.IdempotencyKey{}).Error; err != nil {
+		logger.Error().Err(err).Msg("error while deleting idempotency_key")
+		return err
+	}
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys removes the keys of every caller that
+// expired before now.
+func (r repository) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	result := r.db.WithContext(ctx).Where("expires_at < ?", now).Delete(&model.IdempotencyKey{})
+	if result.Error != nil {
+		logger.Error().Err(result.Error).Msg("error while deleting expired idempotency_keys")
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
@@ -15,6 +15,9 @@ func TestMain(m *testing.M) {
 	ctx, container, db := InitiateContainerCreation()
 
 	testDB = db
+	if err := ScopeByWorkspace(testDB); err != nil {
+		log.Fatalf("unable to scope the test DB by workspace: %v", err)
+	}
 
 	todoTaskRepo = NewTodoTaskRepository(testDB)
 
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+// projectsOfUser selects the ids of the projects @user owns or is shared.
+const projectsOfUser = `SELECT id FROM projects WHERE owner_id = @user
+    UNION SELECT project_id FROM shares WHERE user_id = @user AND project_id IS NOT NULL`
+
+// todoTasksOfCaller restricts a query on todo_tasks to the rows the user
+// the request is made on behalf of can see: the todo_tasks they own, the
+// ones shared with them and the ones of their projects. What they may do
+// with them is up to their role. Calls made outside of a request, such as
+// by the trash sweeper, see every row.
+func todoTasksOfCaller(ctx context.Context) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if principal, ok := auth.PrincipalFromContext(ctx); ok {
+			return db.Where(`(todo_tasks.owner_id = @user
+    OR todo_tasks.id IN (SELECT todo_task_id FROM shares WHERE user_id = @user AND todo_task_id IS NOT NULL)
+    OR todo_tasks.project_id IN (`+projectsOfUser+`))`, map[string]interface{}{"user": principal.UserID})
+		}
+		return db
+	}
+}
+
+// projectsOfCaller restricts a query on projects to the ones the caller
+// owns or is shared, like todoTasksOfCaller.
+func projectsOfCaller(ctx context.Context) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if principal, ok := auth.PrincipalFromContext(ctx); ok {
+			return db.Where("projects.id IN ("+projectsOfUser+")", map[string]interface{}{"user": principal.UserID})
+		}
+		return db
+	}
+}
+
+// callerID is the owner of the rows created by the request, nil outside
+// of a request.
+func callerID(ctx context.Context) *uint {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		return &principal.UserID
+	}
+	return nil
+}
+
+// callerTodoTasks selects the todo_tasks the caller can see, for the raw
+// queries that can not take a scope.
+func (r repository) callerTodoTasks(ctx context.Context) *gorm.DB {
+	return r.db.WithContext(ctx).Session(&gorm.Session{NewDB: true}).Table("todo_tasks").Scopes(todoTasksOfCaller(ctx))
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+// or was issued for a different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor is the keyset position a page starts after: the sort key values
+// of the last row of the previous page. It is serialized as base64url
+// encoded JSON so clients treat it as an opaque token.
+type cursor struct {
+	Sort   string   `json:"s"`
+	Values []string `json:"v"`
+}
+
+func encodeCursor(c cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// sortKey renders a sort order the way the client wrote it, e.g. "-updated_at,title,id".
+func sortKey(order []model.SortField) string {
+	keys := make([]string, len(order))
+	for i, f := range order {
+		keys[i] = f.Field
+		if f.Desc {
+			keys[i] = "-" + f.Field
+		}
+	}
+	return strings.Join(keys, ",")
+}
+
+// cursorFor builds the cursor pointing after todoTask in the given order.
+func cursorFor(order []model.SortField, todoTask model.TodoTask) cursor {
+	c := cursor{Sort: sortKey(order), Values: make([]string, len(order))}
+	for i, f := range order {
+		c.Values[i] = todoTaskSortColumns[f.Field].format(todoTask)
+	}
+	return c
+}
+
+// applyCursor restricts query to the rows strictly after c in the given
+// order. For keys k1..kn it adds
+// (k1 > v1) OR (k1 = v1 AND k2 > v2) OR ... with < for descending keys.
+func applyCursor(query *gorm.DB, order []model.SortField, c cursor) (*gorm.DB, error) {
+	if c.Sort != sortKey(order) || len(c.Values) != len(order) {
+		return nil, ErrInvalidCursor
+	}
+
+	values := make([]interface{}, len(order))
+	for i, f := range order {
+		v, err := todoTaskSortColumns[f.Field].parse(c.Values[i])
+		if err != nil {
+			return nil, ErrInvalidCursor
+		}
+		values[i] = v
+	}
+
+	var (
+		branches []string
+		args     []interface{}
+	)
+	for i, f := range order {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, todoTaskSortColumns[order[j].Field].column+" = ?")
+			args = append(args, values[j])
+		}
+		op := " > ?"
+		if f.Desc {
+			op = " < ?"
+		}
+		parts = append(parts, todoTaskSortColumns[f.Field].column+op)
+		args = append(args, values[i])
+		branches = append(branches, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	return query.Where(strings.Join(branches, " OR "), args...), nil
+}
+
+// encodeIDCursor returns the cursor of a list ordered by id alone, such as
+// the comments of a todo_task.
+func encodeIDCursor(id uint) string {
+	return encodeCursor(cursor{Sort: "id", Values: []string{strconv.FormatUint(uint64(id), 10)}})
+}
+
+func decodeIDCursor(s string) (uint64, error) {
+	c, err := decodeCursor(s)
+	if err != nil || c.Sort != "id" || len(c.Values) != 1 {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseUint(c.Values[0], 10, 64)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
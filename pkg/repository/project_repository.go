@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrUnknownProject is returned when a todo_task is put in a project
+	// that does not exist.
+	ErrUnknownProject = errors.New("unknown project")
+	// ErrProjectArchived is returned when a todo_task is put in an
+	// archived project.
+	ErrProjectArchived = errors.New("project is archived")
+)
+
+// ProjectRepository stores the projects grouping the todo_tasks. The
+// projects it returns carry their counts.
+type ProjectRepository interface {
+	RoleRepository
+	CreateProject(ctx context.Context, projectPayload *model.ProjectPayload) (model.Project, error)
+	GetProject(ctx context.Context, id string) (model.Project, error)
+	GetProjects(ctx context.Context, params model.ProjectListParams) (model.ProjectPage, error)
+	UpdateProject(ctx context.Context, id string, projectPayload *model.ProjectPayload) (model.Project, error)
+	DeleteProject(ctx context.Context, id string) error
+	ArchiveProject(ctx context.Context, id string, archived bool) (model.Project, error)
+}
+
+func NewProjectRepository(db *gorm.DB) ProjectRepository {
+	return repository{db}
+}
+
+func (r repository) CreateProject(ctx context.Context, projectPayload *model.ProjectPayload) (model.Project, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	projectPayload.Name = strings.TrimSpace(projectPayload.Name)
+	if err := projectPayload.ValidateProjectPayload(); err != nil {
+		return model.Project{}, err
+	}
+
+	project := model.Project{Name: projectPayload.Name, Description: projectPayload.Description, OwnerID: callerID(ctx)}
+	if err := r.db.WithContext(ctx).Create(&project).Error; err != nil {
+		logger.Error().Err(err).Msg("error while creating project")
+		return model.Project{}, err
+	}
+	project.Counts = &model.ProjectCounts{ByStatus: map[model.TodoTaskStatus]int64{}}
+	logger.Info().Uint("project_id", project.ID).Msg("Project created")
+	return project, nil
+}
+
+func (r repository) GetProject(ctx context.Context, id string) (model.Project, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var project model.Project
+	if err := r.db.WithContext(ctx).Scopes(projectsOfCaller(ctx)).Where("id = ?", id).First(&project).Error; err != nil {
+		logger.Error().Err(err).Str("project_id", id).Msg("error while getting project")
+		return model.Project{}, err
+	}
+	projects := []model.Project{project}
+	if err := r.countProjects(ctx, projects); err != nil {
+		logger.Error().Err(err).Str("project_id", id).Msg("error while counting todo_tasks of project")
+		return model.Project{}, err
+	}
+	logger.Info().Msg("Project was found")
+	return projects[0], nil
+}
+
+// GetProjects returns one page of the active or of the archived projects,
+// oldest first, paged like the comments.
+func (r repository) GetProjects(ctx context.Context, params model.ProjectListParams) (model.ProjectPage, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	limit := model.PageSize(params.Limit)
+	query := r.db.WithContext(ctx).Scopes(projectsOfCaller(ctx)).Where("archived_at IS NULL")
+	if params.Archived {
+		query = r.db.WithContext(ctx).Scopes(projectsOfCaller(ctx)).Where("archived_at IS NOT NULL")
+	}
+	if params.Cursor != "" {
+		after, err := decodeIDCursor(params.Cursor)
+		if err != nil {
+			logger.Info().Str("cursor", params.Cursor).Msg("invalid projects cursor")
+			return model.ProjectPage{}, err
+		}
+		query = query.Where("id > ?", after)
+	}
+
+	projects := []model.Project{}
+	if err := query.Order("id ASC").Limit(limit + 1).Find(&projects).Error; err != nil {
+		logger.Error().Err(err).Msg("error while fetching projects")
+		return model.ProjectPage{}, err
+	}
+
+	// One extra row was fetched to find out whether another page exists
+	page := model.ProjectPage{Items: projects}
+	if len(projects) > limit {
+		page.Items = projects[:limit]
+		page.HasMore = true
+		page.NextCursor = encodeIDCursor(page.Items[limit-1].ID)
+	}
+	if err := r.countProjects(ctx, page.Items); err != nil {
+		logger.Error().Err(err).Msg("error while counting todo_tasks of projects")
+		return model.ProjectPage{}, err
+	}
+
+	logger.Info().Int("count", len(page.Items)).Msg("Get page of Projects")
+	return page, nil
+}
+
+// countProjects sets the counts of projects with a single grouped query.
+func (r repository) countProjects(ctx context.Context, projects []model.Project) error {
+	ids := make([]uint, len(projects))
+	counts := make(map[uint]*model.ProjectCounts, len(projects))
+	for i := range projects {
+		ids[i] = projects[i].ID
+		projects[i].Counts = &model.ProjectCounts{ByStatus: map[model.TodoTaskStatus]int64{}}
+		counts[projects[i].ID] = projects[i].Counts
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	var rows []struct {
+		ProjectID uint
+		Status    model.TodoTaskStatus
+		Count     int64
+	}
+	err := r.db.WithContext(ctx).Model(&model.TodoTask{}).Select("project_id, status, COUNT(*) AS count").
+		Where("project_id IN ?", ids).Group("project_id, status").Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		counts[row.ProjectID].Total += row.Count
+		counts[row.ProjectID].ByStatus[row.Status] = row.Count
+	}
+	return nil
+}
+
+func (r repository) UpdateProject(ctx context.Context, id string, projectPayload *model.ProjectPayload) (model.Project, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	projectPayload.Name = strings.TrimSpace(projectPayload.Name)
+	if err := projectPayload.ValidateProjectPayload(); err != nil {
+		return model.Project{}, err
+	}
+
+	result := r.db.WithContext(ctx).Model(&model.Project{}).Scopes(projectsOfCaller(ctx)).Where("id = ?", id).
+		Updates(map[string]interface{}{"name": projectPayload.Name, "description": projectPayload.Description})
+	if result.Error != nil {
+		logger.Error().Err(result.Error).Str("project_id", id).Msg("error while updating project")
+		return model.Project{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.Project{}, gorm.ErrRecordNotFound
+	}
+	logger.Info().Str("project_id", id).Msg("Project updated")
+	return r.GetProject(ctx, id)
+}
+
+// DeleteProject deletes a project and its shares. Its todo_tasks, trashed
+// ones included, are kept outside of any project.
+func (r repository) DeleteProject(ctx context.Context, id string) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(projectsOfCaller(ctx)).Select("id").Where("id = ?", id).First(&model.Project{}).Error; err != nil {
+			return err
+		}
+		err := tx.Unscoped().Model(&model.TodoTask{}).Where("project_id = ?", id).
+			Updates(map[string]interface{}{"project_id": nil, "version": gorm.Expr("version + 1")}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("project_id = ?", id).Delete(&model.Share{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id = ?", id).Delete(&model.Project{}).Error
+	})
+	if err != nil {
+		logger.Error().Err(err).Str("project_id", id).Msg("error while deleting project")
+		return err
+	}
+	logger.Info().Str("project_id", id).Msg("Project deleted")
+	return nil
+}
+
+// ArchiveProject archives or unarchives a project. Archiving an archived
+// project keeps the time it was first archived.
+func (r repository) ArchiveProject(ctx context.Context, id string, archived bool) (model.Project, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var archivedAt *time.Time
+	changing := "archived_at IS NOT NULL"
+	if archived {
+		now := time.Now().UTC()
+		archivedAt = &now
+		changing = "archived_at IS NULL"
+	}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Scopes(projectsOfCaller(ctx)).Select("id").Where("id = ?", id).First(&model.Project{}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&model.Project{}).Where("id = ?", id).Where(changing).
+			Update("archived_at", archivedAt).Error
+	})
+	if err != nil {
+		logger.Error().Err(err).Str("project_id", id).Msg("error while archiving project")
+		return model.Project{}, err
+	}
+	logger.Info().Str("project_id", id).Bool("archived", archived).Msg("Project archived")
+	return r.GetProject(ctx, id)
+}
+
+// activeProject fails with ErrUnknownProject or ErrProjectArchived unless
+// projectID, when given, is an active project of the caller.
+func (r repository) activeProject(ctx context.Context, projectID *uint) error {
+	if projectID == nil {
+		return nil
+	}
+	var project model.Project
+	result := r.db.WithContext(ctx).Scopes(projectsOfCaller(ctx)).Select("id", "archived_at").Where("id = ?", *projectID).Limit(1).Find(&project)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUnknownProject
+	}
+	if project.ArchivedAt != nil {
+		return ErrProjectArchived
+	}
+	return nil
+}
+
+// moveSubtasksToProject puts the live subtasks of the todo_task, at any
+// depth, in the same project as the todo_task.
+func moveSubtasksToProject(tx *gorm.DB, id string, projectID *uint) error {
+	var ids []uint
+	if err := tx.Raw(todoTaskSubtreeQuery, id).Scan(&ids).Error; err != nil {
+		return err
+	}
+	return tx.Model(&model.TodoTask{}).Where("id IN ? AND id <> ?", ids, id).
+		Updates(map[string]interface{}{"project_id": projectID, "version": gorm.Expr("version + 1")}).Error
+}
+
+// outsideArchivedProjects keeps the todo_tasks of archived projects out of
+// the todo_tasks list and search.
+const outsideArchivedProjects = `(todo_tasks.project_id IS NULL OR todo_tasks.project_id NOT IN
+    (SELECT id FROM projects WHERE archived_at IS NOT NULL))`
@@ -0,0 +1,186 @@
+package repository
+
+import (
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+// RoleRepository tells the role of the caller on a todo_task, trashed or
+// not, or on a project: the owner has auth.RoleOwner, a user it is shared
+// with the highest of the roles given to them on it and on its project.
+// The methods return gorm.ErrRecordNotFound when the caller has no role,
+// and auth.RoleOwner outside of a request, such as in background jobs.
+type RoleRepository interface {
+	GetTodoTaskRole(ctx context.Context, id string) (auth.Role, error)
+	GetProjectRole(ctx context.Context, id string) (auth.Role, error)
+}
+
+// ShareRepository stores the roles users are given on the projects and
+// todo_tasks shared with them.
+type ShareRepository interface {
+	RoleRepository
+	GetShares(ctx context.Context, target model.ShareTarget) ([]model.Share, error)
+	PutShare(ctx context.Context, target model.ShareTarget, userID uint, role auth.Role) (model.Share, error)
+	DeleteShare(ctx context.Context, target model.ShareTarget, userID uint) error
+}
+
+func NewShareRepository(db *gorm.DB) ShareRepository {
+	return repository{db}
+}
+
+func (r repository) GetTodoTaskRole(ctx context.Context, id string) (auth.Role, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var todoTask model.TodoTask
+	if err := r.db.WithContext(ctx).Unscoped().Select("id", "owner_id", "project_id").Where("id = ?", id).First(&todoTask).Error; err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("error while getting role on todo_task")
+		return "", err
+	}
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok || ownedBy(todoTask.OwnerID, principal.UserID) {
+		return auth.RoleOwner, nil
+	}
+
+	role, err := r.sharedRole(ctx, principal.UserID, "todo_task_id", todoTask.ID)
+	if err == nil && todoTask.ProjectID != nil {
+		var projectRole auth.Role
+		projectRole, err = r.projectRole(ctx, principal.UserID, *todoTask.ProjectID)
+		role = auth.MaxRole(role, projectRole)
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("error while getting role on todo_task")
+		return "", err
+	}
+	if role == "" {
+		return "", gorm.ErrRecordNotFound
+	}
+	return role, nil
+}
+
+func (r repository) GetProjectRole(ctx context.Context, id string) (auth.Role, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var project model.Project
+	if err := r.db.WithContext(ctx).Select("id", "owner_id").Where("id = ?", id).First(&project).Error; err != nil {
+		logger.Error().Err(err).Str("project_id", id).Msg("error while getting role on project")
+		return "", err
+	}
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok || ownedBy(project.OwnerID, principal.UserID) {
+		return auth.RoleOwner, nil
+	}
+
+	role, err := r.sharedRole(ctx, principal.UserID, "project_id", project.ID)
+	if err != nil {
+		logger.Error().Err(err).Str("project_id", id).Msg("error while getting role on project")
+		return "", err
+	}
+	if role == "" {
+		return "", gorm.ErrRecordNotFound
+	}
+	return role, nil
+}
+
+// projectRole is the role of the user on the project, empty when they
+// have none.
+func (r repository) projectRole(ctx context.Context, userID, projectID uint) (auth.Role, error) {
+	var project model.Project
+	result := r.db.WithContext(ctx).Select("id", "owner_id").Where("id = ?", projectID).Limit(1).Find(&project)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return "", result.Error
+	}
+	if ownedBy(project.OwnerID, userID) {
+		return auth.RoleOwner, nil
+	}
+	return r.sharedRole(ctx, userID, "project_id", projectID)
+}
+
+// sharedRole is the role the user is given by the share on column, either
+// project_id or todo_task_id, empty when there is none.
+func (r repository) sharedRole(ctx context.Context, userID uint, column string, id uint) (auth.Role, error) {
+	var shares []model.Share
+	err := r.db.WithContext(ctx).Select("role").Where(column+" = ? AND user_id = ?", id, userID).Limit(1).Find(&shares).Error
+	if err != nil || len(shares) == 0 {
+		return "", err
+	}
+	return shares[0].Role, nil
+}
+
+// ownedBy tells whether ownerID is the user userID.
+func ownedBy(ownerID *uint, userID uint) bool {
+	return ownerID != nil && *ownerID == userID
+}
+
+// GetShares returns the shares on target with the email of their users,
+// oldest first.
+func (r repository) GetShares(ctx context.Context, target model.ShareTarget) ([]model.Share, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	shares := []model.Share{}
+	if err := sharesWithEmail(r.db.WithContext(ctx)).Scopes(onTarget(target)).Order("shares.id ASC").Find(&shares).Error; err != nil {
+		logger.Error().Err(err).Msg("error while fetching shares")
+		return nil, err
+	}
+	logger.Info().Int("count", len(shares)).Msg("Get Shares")
+	return shares, nil
+}
+
+// PutShare gives the user role on target, replacing the role they were
+// given before.
+func (r repository) PutShare(ctx context.Context, target model.ShareTarget, userID uint, role auth.Role) (model.Share, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var share model.Share
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Scopes(onTarget(target)).Where("user_id = ?", userID).Limit(1).Find(&share)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			share = model.Share{ProjectID: target.ProjectID, TodoTaskID: target.TodoTaskID, UserID: userID, Role: role}
+			return tx.Create(&share).Error
+		}
+		return tx.Model(&share).Update("role", role).Error
+	})
+	if err != nil {
+		logger.Error().Err(err).Uint("user_id", userID).Msg("error while sharing")
+		return model.Share{}, err
+	}
+	if err := sharesWithEmail(r.db.WithContext(ctx)).Where("shares.id = ?", share.ID).First(&share).Error; err != nil {
+		logger.Error().Err(err).Uint("share_id", share.ID).Msg("error while getting share")
+		return model.Share{}, err
+	}
+	logger.Info().Uint("share_id", share.ID).Str("role", string(role)).Msg("Share saved")
+	return share, nil
+}
+
+// DeleteShare takes the role of the user on target away. Deleting a share
+// that does not exist succeeds.
+func (r repository) DeleteShare(ctx context.Context, target model.ShareTarget, userID uint) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	if err := r.db.WithContext(ctx).Scopes(onTarget(target)).Where("user_id = ?", userID).Delete(&model.Share{}).Error; err != nil {
+		logger.Error().Err(err).Uint("user_id", userID).Msg("error while deleting share")
+		return err
+	}
+	logger.Info().Uint("user_id", userID).Msg("Share deleted")
+	return nil
+}
+
+// sharesWithEmail queries the shares joined with the email of their user.
+func sharesWithEmail(db *gorm.DB) *gorm.DB {
+	return db.Model(&model.Share{}).Select("shares.*, users.email").Joins("JOIN users ON users.id = shares.user_id")
+}
+
+// onTarget restricts a query on shares to those on target.
+func onTarget(target model.ShareTarget) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if target.ProjectID != nil {
+			return db.Where("shares.project_id = ?", *target.ProjectID)
+		}
+		return db.Where("shares.todo_task_id = ?", target.TodoTaskID)
+	}
+}
@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TagRepository stores the tags. Tag names are normalized with
+// model.NormalizeTagName before they are validated and written.
+type TagRepository interface {
+	CreateTag(ctx context.Context, tagPayload *model.TagPayload) (model.Tag, error)
+	GetTag(ctx context.Context, id string) (model.Tag, error)
+	GetTags(ctx context.Context) ([]model.Tag, error)
+	UpdateTag(ctx context.Context, id string, tagPayload *model.TagPayload) (model.Tag, error)
+	DeleteTag(ctx context.Context, id string) error
+}
+
+func NewTagRepository(db *gorm.DB) TagRepository {
+	return repository{db}
+}
+
+func (r repository) CreateTag(ctx context.Context, tagPayload *model.TagPayload) (model.Tag, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	tagPayload.Name = model.NormalizeTagName(tagPayload.Name)
+	if err := tagPayload.ValidateTagPayload(); err != nil {
+		return model.Tag{}, err
+	}
+
+	tag := model.Tag{Name: tagPayload.Name}
+	if err := r.db.WithContext(ctx).Create(&tag).Error; err != nil {
+		logger.Error().Err(err).Msg("error while creating tag")
+		return model.Tag{}, err
+	}
+	logger.Info().Msg("Tag created")
+	return tag, nil
+}
+
+func (r repository) GetTag(ctx context.Context, id string) (model.Tag, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	if id == "" {
+		logger.Info().Str("tag_id", id).Msg("invalid tag_id")
+		return model.Tag{}, errors.New("Invalid id")
+	}
+
+	var tag model.Tag
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&tag).Error; err != nil {
+		logger.Error().Err(err).Msg("error while getting tag")
+		return model.Tag{}, err
+	}
+	logger.Info().Msg("Tag was found")
+	return tag, nil
+}
+
+// GetTags returns every tag ordered by name.
+func (r repository) GetTags(ctx context.Context) ([]model.Tag, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	tags := []model.Tag{}
+	if err := r.db.WithContext(ctx).Order("name ASC").Find(&tags).Error; err != nil {
+		logger.Error().Err(err).Msg("error while fetching tags")
+		return nil, err
+	}
+	logger.Info().Int("count", len(tags)).Msg("Get Tags")
+	return tags, nil
+}
+
+// UpdateTag renames a tag, the todo_tasks carrying it keep it.
+func (r repository) UpdateTag(ctx context.Context, id string, tagPayload *model.TagPayload) (model.Tag, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	tagPayload.Name = model.NormalizeTagName(tagPayload.Name)
+	if err := tagPayload.ValidateTagPayload(); err != nil {
+		return model.Tag{}, err
+	}
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Tag{}).Where("id = ?", id).Update("name", tagPayload.Name)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return touchTaggedTodoTasks(tx, id)
+	})
+	if err != nil {
+		logger.Error().Err(err).Str("tag_id", id).Msg("error while updating tag")
+		return model.Tag{}, err
+	}
+	logger.Info().Msg("Tag updated")
+	return r.GetTag(ctx, id)
+}
+
+// DeleteTag deletes a tag and takes it off every todo_task.
+func (r repository) DeleteTag(ctx context.Context, id string) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := touchTaggedTodoTasks(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM todo_task_tags WHERE tag_id = ?", id).Error; err != nil {
+			return err
+		}
+		result := tx.Where("id = ?", id).Delete(&model.Tag{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error().Err(err).Str("tag_id", id).Msg("error while deleting tag")
+		return err
+	}
+	logger.Info().Msg("Tag deleted")
+	return nil
+}
+
+// touchTaggedTodoTasks bumps the version of the todo_tasks carrying the
+// tag, so that their cached representations and ETags change with it.
+func touchTaggedTodoTasks(tx *gorm.DB, tagID string) error {
+	return tx.Unscoped().Model(&model.TodoTask{}).
+		Where("id IN (?)", tx.Table("todo_task_tags").Select("todo_task_id").Where("tag_id = ?", tagID)).
+		Updates(map[string]interface{}{"version": gorm.Expr("version + 1"), "updated_at": time.Now()}).Error
+}
+
+// ensureTags returns the tags with the given normalized names, ordered by
+// name, and creates the ones that do not exist yet. Concurrent callers
+// creating the same tag do not fail thanks to the name being unique in
+// the workspace.
+func ensureTags(tx *gorm.DB, names []string) ([]model.Tag, error) {
+	tags := []model.Tag{}
+	if len(names) == 0 {
+		return tags, nil
+	}
+	missing := make([]model.Tag, len(names))
+	for i, name := range names {
+		missing[i] = model.Tag{Name: name}
+	}
+	if err := tx.Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "workspace_id"}, {Name: "name"}}, DoNothing: true}).Create(&missing).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Where("name IN ?", names).Order("name ASC").Find(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// replaceTodoTaskTags sets the tags of the todo_task to the given names.
+func replaceTodoTaskTags(tx *gorm.DB, todoTask *model.TodoTask, names []string) error {
+	tags, err := ensureTags(tx, names)
+	if err != nil {
+		return err
+	}
+	return tx.Model(todoTask).Association("Tags").Replace(tags)
+}
+
+// preloadTags loads the tags of the todo_tasks, ordered by name.
+func preloadTags(db *gorm.DB) *gorm.DB {
+	return db.Preload("Tags", func(db *gorm.DB) *gorm.DB {
+		return db.Order("tags.name ASC")
+	})
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"slices"
+
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Both walks follow every edge, trashed todo_tasks included, so that a
+// restored todo_task can not close a cycle. UNION stops at visited rows.
+const (
+	// todoTaskBlockersQuery selects the todo_tasks blocking the given one
+	// directly or indirectly.
+	todoTaskBlockersQuery = `
+WITH RECURSIVE blockers (id) AS (
+    SELECT blocker_id FROM todo_task_dependencies WHERE blocked_id = ?
+    UNION
+    SELECT todo_task_dependencies.blocker_id FROM todo_task_dependencies
+    JOIN blockers ON todo_task_dependencies.blocked_id = blockers.id
+)
+SELECT id FROM blockers`
+
+	// todoTaskDependentsQuery selects the todo_tasks the given one blocks
+	// directly or indirectly.
+	todoTaskDependentsQuery = `
+WITH RECURSIVE dependents (id) AS (
+    SELECT blocked_id FROM todo_task_dependencies WHERE blocker_id = ?
+    UNION
+    SELECT todo_task_dependencies.blocked_id FROM todo_task_dependencies
+    JOIN dependents ON todo_task_dependencies.blocker_id = dependents.id
+)
+SELECT id FROM dependents`
+)
+
+// AddTodoTaskDependency stores the edge "blockerID blocks blockedID".
+// Adding an existing edge again does nothing.
+func (r repository) AddTodoTaskDependency(ctx context.Context, blockerID, blockedID uint) (model.TodoTaskDependency, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	dependency := model.TodoTaskDependency{BlockerID: blockerID, BlockedID: blockedID}
+	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&dependency).Error; err != nil {
+		logger.Error().Err(err).Msg("error while adding todo_task dependency")
+		return model.TodoTaskDependency{}, err
+	}
+	// The edge may have existed, its creation time is read back
+	if err := r.db.WithContext(ctx).Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).First(&dependency).Error; err != nil {
+		logger.Error().Err(err).Msg("error while getting todo_task dependency")
+		return model.TodoTaskDependency{}, err
+	}
+
+	logger.Info().Uint("blocker_id", blockerID).Uint("blocked_id", blockedID).Msg("TodoTask dependency added")
+	return dependency, nil
+}
+
+// DeleteTodoTaskDependency removes the edge "blockerID blocks blockedID",
+// it returns gorm.ErrRecordNotFound when there is no such edge.
+func (r repository) DeleteTodoTaskDependency(ctx context.Context, blockerID, blockedID uint) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	result := r.db.WithContext(ctx).Where("blocker_id = ? AND blocked_id = ?", blockerID, blockedID).
+		Where("blocked_id IN (?)", r.callerTodoTasks(ctx).Select("id")).Delete(&model.TodoTaskDependency{})
+	if result.Error != nil {
+		logger.Error().Err(result.Error).Msg("error while deleting todo_task dependency")
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		logger.Info().Uint("blocker_id", blockerID).Uint("blocked_id", blockedID).Msg("todo_task dependency not found")
+		return gorm.ErrRecordNotFound
+	}
+
+	logger.Info().Uint("blocker_id", blockerID).Uint("blocked_id", blockedID).Msg("TodoTask dependency deleted")
+	return nil
+}
+
+// GetTodoTaskBlockers returns the live todo_tasks directly blocking the
+// todo_task, ordered by id.
+func (r repository) GetTodoTaskBlockers(ctx context.Context, id string) ([]model.TodoTask, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var blockers []model.TodoTask
+	err := r.db.WithContext(ctx).Scopes(todoTasksOfCaller(ctx)).Where("id IN (?)", r.db.WithContext(ctx).Model(&model.TodoTaskDependency{}).Select("blocker_id").Where("blocked_id = ?", id)).
+		Order("id ASC").Find(&blockers).Error
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("error while getting todo_task blockers")
+		return nil, err
+	}
+	return blockers, nil
+}
+
+// IsTodoTaskBlockedBy reports whether blockerID blocks the todo_task id
+// directly or indirectly.
+func (r repository) IsTodoTaskBlockedBy(ctx context.Context, id, blockerID uint) (bool, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var blockers []uint
+	if err := r.db.WithContext(ctx).Raw(todoTaskBlockersQuery, id).Scan(&blockers).Error; err != nil {
+		logger.Error().Err(err).Uint("todo_task_id", id).Msg("error while walking todo_task blockers")
+		return false, err
+	}
+	return slices.Contains(blockers, blockerID), nil
+}
+
+// GetTodoTaskDependencyGraph returns the live todo_task with the given id,
+// the live todo_tasks it depends on or that depend on it, directly or
+// indirectly, and the edges between them.
+func (r repository) GetTodoTaskDependencyGraph(ctx context.Context, id string) (model.TodoTaskGraph, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var root model.TodoTask
+	if err := r.db.WithContext(ctx).Scopes(todoTasksOfCaller(ctx)).Where("id = ?", id).First(&root).Error; err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("error while getting todo_task")
+		return model.TodoTaskGraph{}, err
+	}
+
+	ids := []uint{root.ID}
+	for _, query := range []string{todoTaskBlockersQuery, todoTaskDependentsQuery} {
+		var related []uint
+		if err := r.db.WithContext(ctx).Raw(query, root.ID).Scan(&related).Error; err != nil {
+			logger.Error().Err(err).Str("todo_task_id", id).Msg("error while walking todo_task dependencies")
+			return model.TodoTaskGraph{}, err
+		}
+		ids = append(ids, related...)
+	}
+
+	graph := model.TodoTaskGraph{Root: root.ID, Nodes: []model.TodoTaskGraphNode{}, Edges: []model.TodoTaskDependency{}}
+	err := r.db.WithContext(ctx).Model(&model.TodoTask{}).Scopes(todoTasksOfCaller(ctx)).Select("id", "title", "status").Where("id IN ?", ids).
+		Order("id ASC").Find(&graph.Nodes).Error
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("error while getting todo_task dependency graph")
+		return model.TodoTaskGraph{}, err
+	}
+
+	live := make([]uint, len(graph.Nodes))
+	for i, n := range graph.Nodes {
+		live[i] = n.ID
+	}
+	err = r.db.WithContext(ctx).Where("blocker_id IN ? AND blocked_id IN ?", live, live).
+		Order("blocker_id ASC, blocked_id ASC").Find(&graph.Edges).Error
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("error while getting todo_task dependency graph")
+		return model.TodoTaskGraph{}, err
+	}
+
+	logger.Info().Int("nodes", len(graph.Nodes)).Int("edges", len(graph.Edges)).Msg("Get TodoTask dependency graph")
+	return graph, nil
+}
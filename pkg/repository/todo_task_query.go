@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"gorm.io/gorm"
+)
+
+// sortColumn maps a sortable field of model.TodoTask to its column and
+// knows how to carry its value through a pagination cursor.
+type sortColumn struct {
+	column string
+	format func(t model.TodoTask) string
+	parse  func(s string) (interface{}, error)
+}
+
+func parseTime(s string) (interface{}, error) {
+	return time.Parse(time.RFC3339Nano, s)
+}
+
+func parseString(s string) (interface{}, error) {
+	return s, nil
+}
+
+var todoTaskSortColumns = map[string]sortColumn{
+	"id": {
+		column: "id",
+		format: func(t model.TodoTask) string { return strconv.FormatUint(uint64(t.ID), 10) },
+		parse:  func(s string) (interface{}, error) { return strconv.ParseUint(s, 10, 64) },
+	},
+	"title": {
+		column: "title",
+		format: func(t model.TodoTask) string { return t.Title },
+		parse:  parseString,
+	},
+	"status": {
+		column: "status",
+		format: func(t model.TodoTask) string { return string(t.Status) },
+		parse:  parseString,
+	},
+	"created_at": {
+		column: "created_at",
+		format: func(t model.TodoTask) string { return t.CreatedAt.UTC().Format(time.RFC3339Nano) },
+		parse:  parseTime,
+	},
+	"updated_at": {
+		column: "updated_at",
+		format: func(t model.TodoTask) string { return t.UpdatedAt.UTC().Format(time.RFC3339Nano) },
+		parse:  parseTime,
+	},
+}
+
+// todoTaskOrder validates the requested sort and appends id as the final
+// tiebreaker so that every row has a unique position for keyset paging.
+func todoTaskOrder(sort []model.SortField) ([]model.SortField, error) {
+	order := make([]model.SortField, 0, len(sort)+1)
+	for _, f := range sort {
+		if _, ok := todoTaskSortColumns[f.Field]; !ok {
+			return nil, fmt.Errorf("unknown sort field %q", f.Field)
+		}
+		order = append(order, f)
+		if f.Field == "id" {
+			return order, nil
+		}
+	}
+	return append(order, model.SortField{Field: "id"}), nil
+}
+
+func applyTodoTaskOrder(query *gorm.DB, order []model.SortField) *gorm.DB {
+	for _, f := range order {
+		direction := " ASC"
+		if f.Desc {
+			direction = " DESC"
+		}
+		query = query.Order(todoTaskSortColumns[f.Field].column + direction)
+	}
+	return query
+}
+
+// applyTodoTaskFilter translates the list filter into WHERE clauses.
+func applyTodoTaskFilter(query *gorm.DB, filter model.TodoTaskFilter) *gorm.DB {
+	if len(filter.Status) > 0 {
+		query = query.Where("status IN ?", filter.Status)
+	}
+	if filter.Title != "" {
+		query = query.Where("LOWER(title) LIKE ? ESCAPE '\\'", "%"+escapeLike(strings.ToLower(filter.Title))+"%")
+	}
+	if filter.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *filter.CreatedBefore)
+	}
+	if filter.UpdatedAfter != nil {
+		query = query.Where("updated_at >= ?", *filter.UpdatedAfter)
+	}
+	if filter.UpdatedBefore != nil {
+		query = query.Where("updated_at < ?", *filter.UpdatedBefore)
+	}
+	if filter.DueAfter != nil {
+		query = query.Where("due_at >= ?", utc(filter.DueAfter))
+	}
+	if filter.DueBefore != nil {
+		query = query.Where("due_at < ?", utc(filter.DueBefore))
+	}
+	if filter.DueToday {
+		start, end := today(time.Now(), filter.Location)
+		query = query.Where("due_at >= ? AND due_at < ?", start, end)
+	}
+	if filter.Overdue != nil {
+		overdue := "due_at < ? AND status NOT IN ?"
+		if !*filter.Overdue {
+			overdue = "NOT (due_at IS NOT NULL AND " + overdue + ")"
+		}
+		query = query.Where(overdue, time.Now(), closedStatuses)
+	}
+	if filter.ProjectID != nil {
+		query = query.Where("project_id = ?", *filter.ProjectID)
+	}
+	if len(filter.TagsAny) > 0 {
+		query = query.Where("id IN (?)", taggedTodoTaskIDs(query, filter.TagsAny))
+	}
+	if len(filter.TagsAll) > 0 {
+		names := model.NormalizeTagNames(filter.TagsAll)
+		query = query.Where("id IN (?)", taggedTodoTaskIDs(query, names).
+			Group("todo_task_tags.todo_task_id").
+			Having("COUNT(DISTINCT tags.name) = ?", len(names)))
+	}
+	return query
+}
+
+// taggedTodoTaskIDs selects the ids of the todo_tasks carrying any of the
+// tags with the given names.
+func taggedTodoTaskIDs(query *gorm.DB, names []string) *gorm.DB {
+	return query.Session(&gorm.Session{NewDB: true}).Table("todo_task_tags").
+		Select("todo_task_tags.todo_task_id").
+		Joins("JOIN tags ON tags.id = todo_task_tags.tag_id").
+		Where("tags.name IN ?", model.NormalizeTagNames(names))
+}
+
+// utc stores times in UTC, so that they compare correctly also on
+// databases that keep timestamps as text, such as SQLite.
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// closedStatuses are the statuses of todo_tasks that can not be overdue.
+var closedStatuses = []model.TodoTaskStatus{model.StatusDone, model.StatusCancelled}
+
+// today returns the bounds of the calendar day of now in loc.
+func today(now time.Time, loc *time.Location) (time.Time, time.Time) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	y, m, d := now.In(loc).Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	return start.UTC(), start.AddDate(0, 0, 1).UTC()
+}
+
+// escapeLike escapes the LIKE wildcards so user input matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
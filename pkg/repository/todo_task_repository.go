@@ -6,14 +6,35 @@ import (
 	"github.com/vkuzmich/gin-project/pkg/model"
 	"golang.org/x/net/context"
 	"gorm.io/gorm"
+	"time"
 )
 
+// ErrVersionMismatch is returned when a conditional write finds the
+// todo_task at a different version than the caller expected.
+var ErrVersionMismatch = errors.New("todo_task version mismatch")
+
+// TodoTaskRepository writes are conditional on the row version: the
+// versions argument lists the versions the caller accepts, nil skips the check.
 type TodoTaskRepository interface {
+	RoleRepository
 	CreateTodoTask(ctx context.Context, todoTaskPayload *model.TodoTaskPayload) (model.TodoTask, error)
-	DeleteTodoTask(ctx context.Context, id string) error
+	DeleteTodoTask(ctx context.Context, id string, versions []uint) error
 	GetTodoTask(ctx context.Context, id string) (model.TodoTask, error)
-	GetTodoTasks(ctx context.Context) ([]model.TodoTask, error)
-	UpdateTodoTask(ctx context.Context, id string, todoTaskPayload *model.TodoTaskPayload) (model.TodoTask, error)
+	GetTodoTasks(ctx context.Context, params model.TodoTaskListParams) (model.TodoTaskPage, error)
+	SearchTodoTasks(ctx context.Context, text string, limit int) ([]model.TodoTaskSearchResult, error)
+	UpdateTodoTask(ctx context.Context, id string, versions []uint, todoTaskPayload *model.TodoTaskPayload) (model.TodoTask, error)
+	PatchTodoTask(ctx context.Context, id string, versions []uint, changes map[string]interface{}) (model.TodoTask, error)
+	GetTrashedTodoTasks(ctx context.Context, params model.TodoTaskListParams) (model.TodoTaskPage, error)
+	RestoreTodoTask(ctx context.Context, id string, versions []uint) (model.TodoTask, error)
+	PurgeTodoTask(ctx context.Context, id string, versions []uint) error
+	PurgeTrashedTodoTasks(ctx context.Context, deletedBefore time.Time) (int64, error)
+	GetTodoTaskTree(ctx context.Context, id string) ([]model.TodoTask, error)
+	OrphanSubtasks(ctx context.Context, id string) (int64, error)
+	AddTodoTaskDependency(ctx context.Context, blockerID, blockedID uint) (model.TodoTaskDependency, error)
+	DeleteTodoTaskDependency(ctx context.Context, blockerID, blockedID uint) error
+	GetTodoTaskBlockers(ctx context.Context, id string) ([]model.TodoTask, error)
+	IsTodoTaskBlockedBy(ctx context.Context, id, blockerID uint) (bool, error)
+	GetTodoTaskDependencyGraph(ctx context.Context, id string) (model.TodoTaskGraph, error)
 }
 
 func NewTodoTaskRepository(db *gorm.DB) TodoTaskRepository {
@@ -24,6 +45,13 @@ func NewTodoTaskRepository(db *gorm.DB) TodoTaskRepository {
 func (r repository) CreateTodoTask(ctx context.Context, todoTaskPayload *model.TodoTaskPayload) (model.TodoTask, error) {
 	logger := contextLogger.ContextLog(ctx)
 
+	// A todo_task created without a status starts at the default one
+	if todoTaskPayload.Status == "" {
+		todoTaskPayload.Status = model.DefaultTodoTaskStatus
+	}
+	todoTaskPayload.Tags = model.NormalizeTagNames(todoTaskPayload.Tags)
+	todoTaskPayload.Recurrence = model.NormalizeRecurrence(todoTaskPayload.Recurrence)
+
 	// Validate the todoTaskPayload
 	if err := todoTaskPayload.ValidateTodoTaskPayload(); err != nil {
 		return model.TodoTask{}, err
@@ -32,26 +60,62 @@ func (r repository) CreateTodoTask(ctx context.Context, todoTaskPayload *model.T
 	todoTask := model.TodoTask{
 		Title:       todoTaskPayload.Title,
 		Description: todoTaskPayload.Description,
-		State:       todoTaskPayload.State,
+		Status:      todoTaskPayload.Status,
+		StartAt:     utc(todoTaskPayload.StartAt),
+		DueAt:       utc(todoTaskPayload.DueAt),
+		ParentID:    todoTaskPayload.ParentID,
+		ProjectID:   todoTaskPayload.ProjectID,
+		OwnerID:     callerID(ctx),
+		Recurrence:  todoTaskPayload.Recurrence,
+		Timezone:    todoTaskPayload.Timezone,
+		Version:     1,
 	}
 
-	result := r.db.Create(&todoTask)
-	if result.Error != nil {
-		logger.Error().Err(result.Error).Msg("error while creating todo_task")
-		return model.TodoTask{}, result.Error
+	// Missing tags are created together with the todo_task
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := (repository{tx}).activeProject(ctx, todoTaskPayload.ProjectID); err != nil {
+			return err
+		}
+		tags, err := ensureTags(tx, todoTaskPayload.Tags)
+		if err != nil {
+			return err
+		}
+		todoTask.Tags = tags
+		return tx.Create(&todoTask).Error
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("error while creating todo_task")
+		return model.TodoTask{}, err
 	}
 	logger.Info().Msg("TodoTask created")
 	// Return the created TodoTask with the generated ID
 	return todoTask, nil
 }
 
-func (r repository) DeleteTodoTask(ctx context.Context, id string) error {
+func (r repository) DeleteTodoTask(ctx context.Context, id string, versions []uint) error {
 	logger := contextLogger.ContextLog(ctx)
 
-	if err := r.db.Delete(&model.TodoTask{}, id).Error; err != nil {
+	if id == "" {
+		logger.Info().Str("todo_task_id", id).Msg("invalid todo_task_id")
+		return errors.New("Invalid id")
+	}
+
+	// The comments of the todo_task go to the trash with it
+	var result *gorm.DB
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result = withVersions(tx, versions).Scopes(todoTasksOfCaller(ctx)).Where("id = ?", id).Delete(&model.TodoTask{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return trashComments(tx, id)
+	})
+	if err != nil {
 		logger.Error().Err(err).Msg("error while deleting todo_task")
 		return errors.New("Invalid id")
 	}
+	if result.RowsAffected == 0 && versions != nil {
+		return r.conditionalWriteError(ctx, id)
+	}
 
 	logger.Info().Msg("TodoTask deleted")
 	return nil
@@ -66,7 +130,7 @@ func (r repository) GetTodoTask(ctx context.Context, id string) (model.TodoTask,
 	}
 
 	var todoTask model.TodoTask
-	result := r.db.Where("id = ?", id).First(&todoTask)
+	result := preloadTags(r.db.WithContext(ctx)).Scopes(todoTasksOfCaller(ctx)).Where("id = ?", id).First(&todoTask)
 	if result.Error != nil {
 		logger.Error().Err(result.Error).Msg("error while getting todo_task")
 		return model.TodoTask{}, result.Error
@@ -77,21 +141,67 @@ func (r repository) GetTodoTask(ctx context.Context, id string) (model.TodoTask,
 	return todoTask, nil
 }
 
-func (r repository) GetTodoTasks(ctx context.Context) ([]model.TodoTask, error) {
+// GetTodoTasks returns one page of todo_tasks matching params.Filter in
+// params.Sort order. The next page starts after the sort key values encoded
+// in params.Cursor, so deep pages stay as cheap as the first one. The
+// todo_tasks of archived projects are left out unless params.Filter asks
+// for one project.
+func (r repository) GetTodoTasks(ctx context.Context, params model.TodoTaskListParams) (model.TodoTaskPage, error) {
+	db := r.db.WithContext(ctx)
+	if params.Filter.ProjectID == nil {
+		db = db.Where(outsideArchivedProjects)
+	}
+	return r.listTodoTasks(ctx, db, params)
+}
+
+// GetTrashedTodoTasks pages through the soft-deleted todo_tasks the same
+// way GetTodoTasks pages through the live ones.
+func (r repository) GetTrashedTodoTasks(ctx context.Context, params model.TodoTaskListParams) (model.TodoTaskPage, error) {
+	return r.listTodoTasks(ctx, r.db.WithContext(ctx).Unscoped().Where("deleted_at IS NOT NULL"), params)
+}
+
+func (r repository) listTodoTasks(ctx context.Context, db *gorm.DB, params model.TodoTaskListParams) (model.TodoTaskPage, error) {
 	logger := contextLogger.ContextLog(ctx)
 
-	var todoTask []model.TodoTask
-	if err := r.db.Find(&todoTask).Error; err != nil {
+	order, err := todoTaskOrder(params.Sort)
+	if err != nil {
+		logger.Info().Err(err).Msg("invalid todo_tasks sort")
+		return model.TodoTaskPage{}, err
+	}
+
+	limit := params.PageSize()
+	query := applyTodoTaskFilter(preloadTags(db).Scopes(todoTasksOfCaller(ctx)), params.Filter)
+	if params.Cursor != "" {
+		c, err := decodeCursor(params.Cursor)
+		if err == nil {
+			query, err = applyCursor(query, order, c)
+		}
+		if err != nil {
+			logger.Info().Str("cursor", params.Cursor).Msg("invalid todo_tasks cursor")
+			return model.TodoTaskPage{}, err
+		}
+	}
+	query = applyTodoTaskOrder(query, order).Limit(limit + 1)
+
+	todoTasks := []model.TodoTask{}
+	if err := query.Find(&todoTasks).Error; err != nil {
 		logger.Error().Err(err).Msg("error while fetching todo_tasks")
-		return []model.TodoTask{}, err
+		return model.TodoTaskPage{}, err
 	}
 
-	logger.Info().Msg("Get all TodoTasks")
-	// Return array of TodoTasks
-	return todoTask, nil
+	// One extra row was fetched to find out whether another page exists
+	page := model.TodoTaskPage{Items: todoTasks}
+	if len(todoTasks) > limit {
+		page.Items = todoTasks[:limit]
+		page.HasMore = true
+		page.NextCursor = encodeCursor(cursorFor(order, page.Items[limit-1]))
+	}
+
+	logger.Info().Int("count", len(page.Items)).Msg("Get page of TodoTasks")
+	return page, nil
 }
 
-func (r repository) UpdateTodoTask(ctx context.Context, id string, todoTaskPayload *model.TodoTaskPayload) (model.TodoTask, error) {
+func (r repository) UpdateTodoTask(ctx context.Context, id string, versions []uint, todoTaskPayload *model.TodoTaskPayload) (model.TodoTask, error) {
 	logger := contextLogger.ContextLog(ctx)
 
 	if id == "" {
@@ -99,30 +209,123 @@ func (r repository) UpdateTodoTask(ctx context.Context, id string, todoTaskPaylo
 		return model.TodoTask{}, errors.New("Invalid id")
 	}
 
-	var todoTask model.TodoTask
-	res := r.db.Where("id = ?", id).First(&todoTask)
-	if res.Error != nil {
-		logger.Error().Err(res.Error).Msg("error while getting todo_task")
-		return model.TodoTask{}, res.Error
-	}
-
-	logger.Info().Msg("mapping found todo_task to update fields")
-	todoTask.Title = todoTaskPayload.Title
-	todoTask.Description = todoTaskPayload.Description
-	todoTask.State = todoTaskPayload.State
+	todoTaskPayload.Tags = model.NormalizeTagNames(todoTaskPayload.Tags)
+	todoTaskPayload.Recurrence = model.NormalizeRecurrence(todoTaskPayload.Recurrence)
 
 	// Validate the updated todoTask
-	if err := model.ValidateTodoTask(todoTask); err != nil {
+	if err := todoTaskPayload.ValidateTodoTaskPayload(); err != nil {
 		logger.Error().Err(err).Msg("validation failed for todo_task")
 		return model.TodoTask{}, err
 	}
+
 	// update todoTask
-	result := r.db.Model(&todoTask).Updates(todoTask)
-	if result.Error != nil {
-		logger.Error().Err(result.Error).Str("todo_task_id", id).Msgf("Error while updating todo_task")
-		return model.TodoTask{}, result.Error
+	changes := map[string]interface{}{
+		"title":       todoTaskPayload.Title,
+		"description": todoTaskPayload.Description,
+		"status":      todoTaskPayload.Status,
+		"start_at":    todoTaskPayload.StartAt,
+		"due_at":      todoTaskPayload.DueAt,
+		"recurrence":  todoTaskPayload.Recurrence,
+		"timezone":    todoTaskPayload.Timezone,
 	}
-	logger.Info().Msg("Get updated TodoTask")
-	// Return the created TodoTask with the generated ID
-	return todoTask, nil
+	// Tags left out of the payload stay as they are
+	if todoTaskPayload.Tags != nil {
+		changes["tags"] = todoTaskPayload.Tags
+	}
+	return r.PatchTodoTask(ctx, id, versions, changes)
+}
+
+// PatchTodoTask writes only the given columns of the todo_task and returns
+// the stored row after the update. The version check and the write are a
+// single conditional UPDATE, so two concurrent writers can not both win.
+// The "tags" change holds the normalized tag names replacing the current ones.
+// A "project_id" change, a *uint, must name an active project and takes the
+// subtasks of the todo_task along.
+func (r repository) PatchTodoTask(ctx context.Context, id string, versions []uint, changes map[string]interface{}) (model.TodoTask, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	if id == "" {
+		logger.Info().Str("todo_task_id", id).Msg("invalid todo_task_id")
+		return model.TodoTask{}, errors.New("Invalid id")
+	}
+
+	columns := map[string]interface{}{"version": gorm.Expr("version + 1")}
+	var tags []string
+	for column, value := range changes {
+		switch v := value.(type) {
+		case *time.Time:
+			value = utc(v)
+		case []string:
+			if column == "tags" {
+				tags = v
+				continue
+			}
+		}
+		columns[column] = value
+	}
+
+	projectID, moving := columns["project_id"].(*uint)
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if moving {
+			if err := (repository{tx}).activeProject(ctx, projectID); err != nil {
+				return err
+			}
+		}
+		result := withVersions(tx.Model(&model.TodoTask{}).Scopes(todoTasksOfCaller(ctx)).Where("id = ?", id), versions).Updates(columns)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return repository{tx}.conditionalWriteError(ctx, id)
+		}
+		if moving {
+			if err := moveSubtasksToProject(tx, id, projectID); err != nil {
+				return err
+			}
+		}
+		if tags == nil {
+			return nil
+		}
+		var todoTask model.TodoTask
+		if err := tx.Where("id = ?", id).First(&todoTask).Error; err != nil {
+			return err
+		}
+		return replaceTodoTaskTags(tx, &todoTask, tags)
+	})
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) && !errors.Is(err, ErrVersionMismatch) &&
+			!errors.Is(err, ErrUnknownProject) && !errors.Is(err, ErrProjectArchived) {
+			logger.Error().Err(err).Str("todo_task_id", id).Msg("Error while updating todo_task")
+		}
+		return model.TodoTask{}, err
+	}
+
+	logger.Info().Int("columns", len(changes)).Msg("TodoTask updated")
+	return r.GetTodoTask(ctx, id)
+}
+
+// withVersions restricts a write to the accepted row versions.
+func withVersions(db *gorm.DB, versions []uint) *gorm.DB {
+	if versions == nil {
+		return db
+	}
+	return db.Where("version IN ?", versions)
+}
+
+// conditionalWriteError explains why a conditional write matched no row:
+// either the todo_task does not exist or it is at another version.
+func (r repository) conditionalWriteError(ctx context.Context, id string) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.TodoTask{}).Scopes(todoTasksOfCaller(ctx)).Where("id = ?", id).Count(&count).Error; err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("error while checking todo_task")
+		return err
+	}
+	if count == 0 {
+		logger.Info().Str("todo_task_id", id).Msg("todo_task not found")
+		return gorm.ErrRecordNotFound
+	}
+	logger.Info().Str("todo_task_id", id).Msg("todo_task version mismatch")
+	return ErrVersionMismatch
 }
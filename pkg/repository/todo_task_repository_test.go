@@ -54,7 +54,6 @@ func TestCreateTodoTask(t *testing.T) {
 			ctx:           context.Background(),
 			expectedError: nil,
 			setup: func(t *testing.T, d *world) {
-				d.todoTaskPayload.State = true
 			},
 		},
 		{
@@ -63,7 +62,6 @@ func TestCreateTodoTask(t *testing.T) {
 			expectedError: errors.New("validation fails: Key: 'TodoTaskPayload.Title' Error:Field validation for 'Title' failed on the 'required' tag"),
 			setup: func(t *testing.T, d *world) {
 				d.todoTaskPayload.Title = ""
-				d.todoTaskPayload.State = true
 			},
 		},
 		{
@@ -72,13 +70,15 @@ func TestCreateTodoTask(t *testing.T) {
 			expectedError: errors.New("validation fails: Key: 'TodoTaskPayload.Description' Error:Field validation for 'Description' failed on the 'required' tag"),
 			setup: func(t *testing.T, d *world) {
 				d.todoTaskPayload.Description = ""
-				d.todoTaskPayload.State = true
 			},
 		},
 		{
-			name:          "invalid Payload state",
+			name:          "invalid Payload status",
 			ctx:           context.Background(),
-			expectedError: errors.New("validation fails: Key: 'TodoTaskPayload.State' Error:Field validation for 'State' failed on the 'required' tag"),
+			expectedError: errors.New("validation fails: Key: 'TodoTaskPayload.Status' Error:Field validation for 'Status' failed on the 'oneof' tag"),
+			setup: func(t *testing.T, d *world) {
+				d.todoTaskPayload.Status = "finished"
+			},
 		},
 	}
 
@@ -101,7 +101,7 @@ func TestCreateTodoTask(t *testing.T) {
 			_, err := repo.CreateTodoTask(tt.ctx, &d.todoTaskPayload)
 
 			//Check the error returned
-			assert.Equal(t, tt.expectedError, err)
+			assertErrorMessage(t, tt.expectedError, err)
 
 			t.Cleanup(func() {
 				AfterEach()
@@ -117,7 +117,7 @@ func TestCreateTodoTaskError(t *testing.T) {
 	todoTaskPayload := model.TodoTaskPayload{
 		Title:       "Test Task",
 		Description: "Test Description",
-		State:       true,
+		Status:      model.StatusTodo,
 	}
 	var todoTaskRepository = NewTodoTaskRepository(testDB)
 	mock.ExpectExec(`INSERT INTO "todo_tasks" `).WithArgs(&todoTaskPayload).WillReturnError(errors.New("error"))
@@ -156,7 +156,7 @@ func TestDeleteTodoTask(t *testing.T) {
 			todoTaskPayload := model.TodoTaskPayload{
 				Title:       "Test Task",
 				Description: "Test Description",
-				State:       true,
+				Status:      model.StatusTodo,
 			}
 
 			// Create a repository instance with the mocked database
@@ -164,7 +164,7 @@ func TestDeleteTodoTask(t *testing.T) {
 			assert.NoError(t, err)
 
 			// Call the function with the test context and ID
-			err = repo.DeleteTodoTask(tt.ctx, tt.id)
+			err = repo.DeleteTodoTask(tt.ctx, tt.id, nil)
 
 			// Check for any errors
 			assert.Equal(t, tt.expectedError, err)
@@ -175,6 +175,20 @@ func TestDeleteTodoTask(t *testing.T) {
 	}
 }
 
+func TestDeleteTodoTaskBindsID(t *testing.T) {
+	repo := repository{db: testDB}
+	CreateTodoTasksList(t, repo)
+	t.Cleanup(func() {
+		AfterEach()
+	})
+
+	// The id is a value of the query, never a part of its SQL
+	assert.Error(t, repo.DeleteTodoTask(context.Background(), "1 OR 1=1", nil))
+	page, err := repo.GetTodoTasks(context.Background(), model.TodoTaskListParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(page.Items))
+}
+
 func TestGetTodoTask(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -191,7 +205,7 @@ func TestGetTodoTask(t *testing.T) {
 			expectedResult: model.TodoTask{
 				Title:       "Test Task",
 				Description: "Test Description",
-				State:       true,
+				Status:      model.StatusTodo,
 			},
 		},
 		{
@@ -217,7 +231,7 @@ func TestGetTodoTask(t *testing.T) {
 			todoTaskPayload := model.TodoTaskPayload{
 				Title:       "Test Task",
 				Description: "Test Description",
-				State:       true,
+				Status:      model.StatusTodo,
 			}
 
 			// Create a repository instance with the mocked database
@@ -231,7 +245,7 @@ func TestGetTodoTask(t *testing.T) {
 			assert.Equal(t, tt.expectedError, resultErr)
 			assert.Equal(t, tt.expectedResult.Title, result.Title)
 			assert.Equal(t, tt.expectedResult.Description, result.Description)
-			assert.Equal(t, tt.expectedResult.State, result.State)
+			assert.Equal(t, tt.expectedResult.Status, result.Status)
 		})
 		t.Cleanup(func() {
 			AfterEach()
@@ -257,7 +271,7 @@ func TestGetListTodoTasks(t *testing.T) {
 				{
 					Title:       "Test Task 1",
 					Description: "Test Description 1",
-					State:       true,
+					Status:      model.StatusTodo,
 				},
 			},
 		},
@@ -267,14 +281,17 @@ func TestGetListTodoTasks(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 
 			// Call the function with the test context
-			result, resultErr := repo.GetTodoTasks(tt.ctx)
+			page, resultErr := repo.GetTodoTasks(tt.ctx, model.TodoTaskListParams{})
+			result := page.Items
 
 			// Check for any errors
 			assert.Equal(t, tt.expectedError, resultErr)
 			assert.Equal(t, tt.expectedResult[0].Title, result[0].Title)
 			assert.Equal(t, tt.expectedResult[0].Description, result[0].Description)
-			assert.Equal(t, tt.expectedResult[0].State, result[0].State)
+			assert.Equal(t, tt.expectedResult[0].Status, result[0].Status)
 			assert.Equal(t, 3, len(result))
+			assert.False(t, page.HasMore)
+			assert.Empty(t, page.NextCursor)
 		})
 		t.Cleanup(func() {
 			AfterEach()
@@ -288,13 +305,109 @@ func TestGetAllTodoTasksError(t *testing.T) {
 	testDB = mockedDB
 	var mockTodoTaskRepository = NewTodoTaskRepository(testDB)
 
-	mock.ExpectQuery(`SELECT * FROM "todo_tasks"`).WillReturnError(fmt.Errorf("error while fetching todo_tasks"))
+	mock.ExpectQuery(`SELECT * FROM "todo_tasks" WHERE "todo_tasks"."deleted_at" IS NULL ORDER BY id ASC LIMIT $1`).WillReturnError(fmt.Errorf("error while fetching todo_tasks"))
 
-	_, err := mockTodoTaskRepository.GetTodoTasks(context.Background())
+	_, err := mockTodoTaskRepository.GetTodoTasks(context.Background(), model.TodoTaskListParams{})
 	testDB = mainDB
 	assert.NotNil(t, err)
 }
 
+func TestGetTodoTasksPagination(t *testing.T) {
+	repo := repository{db: testDB}
+	CreateTodoTasksList(t, repo)
+	t.Cleanup(func() {
+		AfterEach()
+	})
+
+	first, err := repo.GetTodoTasks(context.Background(), model.TodoTaskListParams{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(first.Items))
+	assert.True(t, first.HasMore)
+	assert.NotEmpty(t, first.NextCursor)
+
+	second, err := repo.GetTodoTasks(context.Background(), model.TodoTaskListParams{Limit: 2, Cursor: first.NextCursor})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(second.Items))
+	assert.Equal(t, "Test Task 3", second.Items[0].Title)
+	assert.False(t, second.HasMore)
+
+	empty, err := repo.GetTodoTasks(context.Background(), model.TodoTaskListParams{Filter: model.TodoTaskFilter{Title: "no such task"}})
+	assert.NoError(t, err)
+	assert.NotNil(t, empty.Items, "an empty page is rendered as an empty array")
+	assert.Empty(t, empty.Items)
+
+	_, err = repo.GetTodoTasks(context.Background(), model.TodoTaskListParams{Cursor: "not-a-cursor"})
+	assert.Equal(t, ErrInvalidCursor, err)
+}
+
+func TestGetTodoTasksFilterAndSort(t *testing.T) {
+	repo := repository{db: testDB}
+	CreateTodoTasksList(t, repo)
+	t.Cleanup(func() {
+		AfterEach()
+	})
+
+	page, err := repo.GetTodoTasks(context.Background(), model.TodoTaskListParams{
+		Sort: []model.SortField{{Field: "title", Desc: true}},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Test Task 3", page.Items[0].Title)
+	assert.Equal(t, "Test Task 1", page.Items[2].Title)
+
+	page, err = repo.GetTodoTasks(context.Background(), model.TodoTaskListParams{
+		Filter: model.TodoTaskFilter{Title: "task 2"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(page.Items))
+	assert.Equal(t, "Test Task 2", page.Items[0].Title)
+
+	_, err = repo.GetTodoTasks(context.Background(), model.TodoTaskListParams{
+		Sort: []model.SortField{{Field: "description"}},
+	})
+	assert.Error(t, err)
+}
+
+func TestSearchTodoTasks(t *testing.T) {
+	repo := repository{db: testDB}
+	CreateTodoTasksList(t, repo)
+	_, err := CreateTodoTask(t, repo, model.TodoTaskPayload{
+		Title:       "Buy groceries",
+		Description: "Milk, bread and eggs",
+		Status:      model.StatusTodo,
+	})
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		AfterEach()
+	})
+
+	results, err := repo.SearchTodoTasks(context.Background(), "groceries", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(results))
+	assert.Equal(t, "Buy <mark>groceries</mark>", results[0].TitleSnippet)
+	assert.Greater(t, results[0].Rank, 0.0)
+
+	results, err = repo.SearchTodoTasks(context.Background(), "description", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(results))
+
+	// The snippets are HTML escaped, only the <mark> tags are markup
+	_, err = CreateTodoTask(t, repo, model.TodoTaskPayload{
+		Title:       "<script>alert(1)</script> payload",
+		Description: "<b>bold</b>",
+		Status:      model.StatusTodo,
+	})
+	assert.NoError(t, err)
+	results, err = repo.SearchTodoTasks(context.Background(), "payload", 10)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(results)) {
+		assert.Equal(t, "&lt;script&gt;alert(1)&lt;/script&gt; <mark>payload</mark>", results[0].TitleSnippet)
+		assert.Equal(t, "&lt;b&gt;bold&lt;/b&gt;", results[0].DescriptionSnippet)
+	}
+
+	_, err = repo.SearchTodoTasks(context.Background(), " ", 10)
+	assert.Error(t, err)
+}
+
 func TestUpdateTodoTask(t *testing.T) {
 	type world struct {
 		todoTaskPayload model.TodoTaskPayload
@@ -313,7 +426,6 @@ func TestUpdateTodoTask(t *testing.T) {
 			expectedError: nil,
 			id:            "1",
 			setup: func(t *testing.T, d *world) {
-				d.todoTaskPayload.State = true
 			},
 		},
 		{
@@ -322,34 +434,34 @@ func TestUpdateTodoTask(t *testing.T) {
 			expectedError: errors.New("Invalid id"),
 			id:            "",
 			setup: func(t *testing.T, d *world) {
-				d.todoTaskPayload.State = true
 			},
 		},
 		{
 			name:          "invalid Payload title",
 			ctx:           context.Background(),
 			id:            "1",
-			expectedError: errors.New("validation fails: Key: 'TodoTask.Title' Error:Field validation for 'Title' failed on the 'required' tag"),
+			expectedError: errors.New("validation fails: Key: 'TodoTaskPayload.Title' Error:Field validation for 'Title' failed on the 'required' tag"),
 			setup: func(t *testing.T, d *world) {
 				d.todoTaskPayload.Title = ""
-				d.todoTaskPayload.State = true
 			},
 		},
 		{
 			name:          "invalid Payload description",
 			ctx:           context.Background(),
 			id:            "1",
-			expectedError: errors.New("validation fails: Key: 'TodoTask.Description' Error:Field validation for 'Description' failed on the 'required' tag"),
+			expectedError: errors.New("validation fails: Key: 'TodoTaskPayload.Description' Error:Field validation for 'Description' failed on the 'required' tag"),
 			setup: func(t *testing.T, d *world) {
 				d.todoTaskPayload.Description = ""
-				d.todoTaskPayload.State = true
 			},
 		},
 		{
-			name:          "invalid Payload state",
+			name:          "invalid Payload status",
 			ctx:           context.Background(),
 			id:            "1",
-			expectedError: errors.New("validation fails: Key: 'TodoTask.State' Error:Field validation for 'State' failed on the 'required' tag"),
+			expectedError: errors.New("validation fails: Key: 'TodoTaskPayload.Status' Error:Field validation for 'Status' failed on the 'required' tag"),
+			setup: func(t *testing.T, d *world) {
+				d.todoTaskPayload.Status = ""
+			},
 		},
 	}
 
@@ -359,6 +471,7 @@ func TestUpdateTodoTask(t *testing.T) {
 				todoTaskPayload: model.TodoTaskPayload{
 					Title:       "New Task",
 					Description: "New Description",
+					Status:      model.StatusDone,
 				},
 			}
 
@@ -369,14 +482,14 @@ func TestUpdateTodoTask(t *testing.T) {
 			repo := repository{db: testDB}
 			CreateTodoTasksList(t, repo)
 			// Call the function with the test payload and context
-			result, err := repo.UpdateTodoTask(tt.ctx, tt.id, &d.todoTaskPayload)
+			result, err := repo.UpdateTodoTask(tt.ctx, tt.id, nil, &d.todoTaskPayload)
 
 			//Check the error returned
-			assert.Equal(t, tt.expectedError, err)
+			assertErrorMessage(t, tt.expectedError, err)
 			if tt.expectedError == nil {
 				assert.Equal(t, d.todoTaskPayload.Title, result.Title)
 				assert.Equal(t, d.todoTaskPayload.Description, result.Description)
-				assert.Equal(t, d.todoTaskPayload.State, result.State)
+				assert.Equal(t, d.todoTaskPayload.Status, result.Status)
 			}
 
 			t.Cleanup(func() {
@@ -386,13 +499,96 @@ func TestUpdateTodoTask(t *testing.T) {
 	}
 }
 
+func TestPatchTodoTask(t *testing.T) {
+	repo := repository{db: testDB}
+	CreateTodoTasksList(t, repo)
+	t.Cleanup(func() {
+		AfterEach()
+	})
+
+	result, err := repo.PatchTodoTask(context.Background(), "2", nil, map[string]interface{}{"title": "Patched Task"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Patched Task", result.Title)
+	assert.Equal(t, "Test Description 2", result.Description)
+	assert.Equal(t, model.StatusTodo, result.Status)
+	assert.Equal(t, uint(2), result.Version)
+
+	_, err = repo.PatchTodoTask(context.Background(), "40", nil, map[string]interface{}{"title": "Patched Task"})
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestConditionalWrites(t *testing.T) {
+	repo := repository{db: testDB}
+	CreateTodoTasksList(t, repo)
+	t.Cleanup(func() {
+		AfterEach()
+	})
+
+	payload := model.TodoTaskPayload{Title: "New Task", Description: "New Description", Status: model.StatusTodo}
+
+	result, err := repo.UpdateTodoTask(context.Background(), "1", []uint{1}, &payload)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), result.Version)
+
+	// A second writer still holding version 1 must lose
+	_, err = repo.UpdateTodoTask(context.Background(), "1", []uint{1}, &payload)
+	assert.Equal(t, ErrVersionMismatch, err)
+
+	err = repo.DeleteTodoTask(context.Background(), "1", []uint{1})
+	assert.Equal(t, ErrVersionMismatch, err)
+
+	err = repo.DeleteTodoTask(context.Background(), "1", []uint{2})
+	assert.NoError(t, err)
+
+	err = repo.DeleteTodoTask(context.Background(), "1", []uint{2})
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+func TestTrash(t *testing.T) {
+	repo := repository{db: testDB}
+	CreateTodoTasksList(t, repo)
+	t.Cleanup(func() {
+		AfterEach()
+	})
+
+	assert.NoError(t, repo.DeleteTodoTask(context.Background(), "1", nil))
+
+	trash, err := repo.GetTrashedTodoTasks(context.Background(), model.TodoTaskListParams{})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(trash.Items))
+	assert.Equal(t, "Test Task 1", trash.Items[0].Title)
+
+	_, err = repo.RestoreTodoTask(context.Background(), "2", nil)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+
+	_, err = repo.RestoreTodoTask(context.Background(), "1", []uint{trash.Items[0].Version + 1})
+	assert.Equal(t, ErrVersionMismatch, err)
+	restored, err := repo.RestoreTodoTask(context.Background(), "1", []uint{trash.Items[0].Version})
+	assert.NoError(t, err)
+	assert.False(t, restored.DeletedAt.Valid)
+
+	assert.NoError(t, repo.PurgeTodoTask(context.Background(), "1", nil))
+	_, err = repo.RestoreTodoTask(context.Background(), "1", nil)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+}
+
+// assertErrorMessage compares errors by message, validation errors are
+// typed and carry the failed fields on top of the message.
+func assertErrorMessage(t *testing.T, expected error, actual error) {
+	if expected == nil {
+		assert.NoError(t, actual)
+		return
+	}
+	assert.EqualError(t, actual, expected.Error())
+}
+
 func CreateTodoTasksList(t *testing.T, repo repository) {
 	// Create a repository instance with the mocked database
 	for i := 1; i <= 3; i++ {
 		todoTaskPayload := model.TodoTaskPayload{
 			Title:       "Test Task " + strconv.Itoa(i),
 			Description: "Test Description " + strconv.Itoa(i),
-			State:       true,
+			Status:      model.StatusTodo,
 		}
 		_, err := CreateTodoTask(t, repo, todoTaskPayload)
 		assert.NoError(t, err)
@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"errors"
+	"html"
+	"strings"
+
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"golang.org/x/net/context"
+)
+
+const (
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+)
+
+// postgresSearchQuery ranks todo_tasks by the generated search_vector column
+// added in migration 000002 and highlights the matches with ts_headline.
+// The text is HTML escaped before ts_headline adds the <mark> tags, so the
+// snippets are safe to render. Like the todo_tasks list, it leaves out the
+// todo_tasks of archived projects and of other owners.
+var postgresSearchQuery = `
+SELECT todo_tasks.*,
+       ts_rank(search_vector, query) AS rank,
+       ts_headline('english', ` + escapeHTML("title") + `, query, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true') AS title_snippet,
+       ts_headline('english', ` + escapeHTML("coalesce(description, '')") + `, query, 'StartSel=<mark>, StopSel=</mark>, MaxFragments=2') AS description_snippet
+FROM (?) AS todo_tasks, websearch_to_tsquery('english', ?) AS query
+WHERE todo_tasks.deleted_at IS NULL AND search_vector @@ query
+  AND ` + outsideArchivedProjects + `
+ORDER BY rank DESC, id ASC
+LIMIT ?`
+
+// likeSearchQuery is used on dialects without full-text search, such as
+// SQLite. A title match ranks above a description match.
+const likeSearchQuery = `
+SELECT todo_tasks.*,
+       (CASE WHEN LOWER(title) LIKE @pattern ESCAPE '\' THEN 2 ELSE 0 END +
+        CASE WHEN LOWER(description) LIKE @pattern ESCAPE '\' THEN 1 ELSE 0 END) AS rank
+FROM (@todo_tasks) AS todo_tasks
+WHERE todo_tasks.deleted_at IS NULL
+  AND (LOWER(title) LIKE @pattern ESCAPE '\' OR LOWER(description) LIKE @pattern ESCAPE '\')
+  AND ` + outsideArchivedProjects + `
+ORDER BY rank DESC, id ASC
+LIMIT @limit`
+
+// escapeHTML is the SQL expression escaping the text of expr like
+// html.EscapeString.
+func escapeHTML(expr string) string {
+	for _, r := range []struct{ from, to string }{{"&", "&amp;"}, {"<", "&lt;"}, {">", "&gt;"}, {`"`, "&#34;"}, {"''", "&#39;"}} {
+		expr = "replace(" + expr + ", '" + r.from + "', '" + r.to + "')"
+	}
+	return expr
+}
+
+// SearchTodoTasks returns up to limit todo_tasks matching text, best match first.
+func (r repository) SearchTodoTasks(ctx context.Context, text string, limit int) ([]model.TodoTaskSearchResult, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	text = strings.TrimSpace(text)
+	if text == "" {
+		logger.Info().Msg("empty todo_tasks search query")
+		return nil, errors.New("empty search query")
+	}
+	limit = model.PageSize(limit)
+
+	results := []model.TodoTaskSearchResult{}
+	if r.db.WithContext(ctx).Dialector.Name() == "postgres" {
+		if err := r.db.WithContext(ctx).Raw(postgresSearchQuery, r.callerTodoTasks(ctx), text, limit).Scan(&results).Error; err != nil {
+			logger.Error().Err(err).Msg("error while searching todo_tasks")
+			return nil, err
+		}
+	} else {
+		pattern := "%" + escapeLike(strings.ToLower(text)) + "%"
+		err := r.db.WithContext(ctx).Raw(likeSearchQuery, map[string]interface{}{"todo_tasks": r.callerTodoTasks(ctx), "pattern": pattern, "limit": limit}).Scan(&results).Error
+		if err != nil {
+			logger.Error().Err(err).Msg("error while searching todo_tasks")
+			return nil, err
+		}
+		for i := range results {
+			results[i].TitleSnippet = highlight(results[i].Title, text)
+			results[i].DescriptionSnippet = highlight(results[i].Description, text)
+		}
+	}
+
+	if err := r.loadSearchResultTags(ctx, results); err != nil {
+		logger.Error().Err(err).Msg("error while getting tags of todo_tasks")
+		return nil, err
+	}
+
+	logger.Info().Int("count", len(results)).Msg("Search TodoTasks")
+	return results, nil
+}
+
+// highlight HTML escapes s and wraps every case-insensitive occurrence of
+// term in <mark> tags, mirroring what ts_headline returns on Postgres.
+func highlight(s, term string) string {
+	var b strings.Builder
+	lower, lowerTerm := strings.ToLower(s), strings.ToLower(term)
+	for {
+		i := strings.Index(lower, lowerTerm)
+		if i < 0 || len(lowerTerm) == 0 || len(lower) != len(s) {
+			b.WriteString(html.EscapeString(s))
+			return b.String()
+		}
+		b.WriteString(html.EscapeString(s[:i]))
+		b.WriteString(highlightStart + html.EscapeString(s[i:i+len(term)]) + highlightStop)
+		s, lower = s[i+len(term):], lower[i+len(term):]
+	}
+}
+
+// loadSearchResultTags fills in the tags of the results, which the raw
+// search queries can not preload.
+func (r repository) loadSearchResultTags(ctx context.Context, results []model.TodoTaskSearchResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(results))
+	for i := range results {
+		ids[i] = results[i].ID
+	}
+	var todoTasks []model.TodoTask
+	if err := preloadTags(r.db.WithContext(ctx)).Select("id").Where("id IN ?", ids).Find(&todoTasks).Error; err != nil {
+		return err
+	}
+	tags := make(map[uint][]model.Tag, len(todoTasks))
+	for _, t := range todoTasks {
+		tags[t.ID] = t.Tags
+	}
+	for i := range results {
+		results[i].Tags = tags[results[i].ID]
+		if results[i].Tags == nil {
+			results[i].Tags = []model.Tag{}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+// RestoreTodoTask takes a soft-deleted todo_task out of the trash together
+// with the comments trashed along with it. It returns
+// gorm.ErrRecordNotFound when the todo_task is not in the trash and
+// ErrVersionMismatch when versions is non-nil and does not contain its
+// current version.
+func (r repository) RestoreTodoTask(ctx context.Context, id string, versions []uint) (model.TodoTask, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var trashed model.TodoTask
+		if err := tx.Unscoped().Scopes(todoTasksOfCaller(ctx)).Select("id", "deleted_at").Where("id = ? AND deleted_at IS NOT NULL", id).First(&trashed).Error; err != nil {
+			return err
+		}
+		result := withVersions(tx.Unscoped().Model(&model.TodoTask{}).Where("id = ? AND deleted_at IS NOT NULL", id), versions).
+			Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionMismatch
+		}
+		return restoreComments(tx, id, trashed.DeletedAt.Time)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.Info().Str("todo_task_id", id).Msg("todo_task is not in the trash")
+		return model.TodoTask{}, err
+	}
+	if errors.Is(err, ErrVersionMismatch) {
+		logger.Info().Str("todo_task_id", id).Msg("todo_task version mismatch")
+		return model.TodoTask{}, err
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("error while restoring todo_task")
+		return model.TodoTask{}, err
+	}
+
+	logger.Info().Str("todo_task_id", id).Msg("TodoTask restored")
+	return r.GetTodoTask(ctx, id)
+}
+
+// PurgeTodoTask permanently deletes a todo_task, whether it is live or
+// already in the trash. Its attachments are detached, their content is
+// deleted by the trash sweeper.
+func (r repository) PurgeTodoTask(ctx context.Context, id string, versions []uint) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	var result *gorm.DB
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result = withVersions(tx.Unscoped().Scopes(todoTasksOfCaller(ctx)).Where("id = ?", id), versions).Delete(&model.TodoTask{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		if err := tx.Exec("DELETE FROM todo_task_tags WHERE todo_task_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM todo_task_dependencies WHERE blocker_id = ? OR blocked_id = ?", id, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM shares WHERE todo_task_id = ?", id).Error; err != nil {
+			return err
+		}
+		if err := purgeComments(tx, id); err != nil {
+			return err
+		}
+		if err := detachAttachments(tx, id); err != nil {
+			return err
+		}
+		return repository{tx}.orphanSubtasks(ctx, id).Error
+	})
+	if err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("error while purging todo_task")
+		return err
+	}
+	if result.Error != nil {
+		logger.Error().Err(result.Error).Str("todo_task_id", id).Msg("error while purging todo_task")
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var count int64
+		if err := r.db.WithContext(ctx).Unscoped().Model(&model.TodoTask{}).Scopes(todoTasksOfCaller(ctx)).Where("id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return ErrVersionMismatch
+	}
+
+	logger.Info().Str("todo_task_id", id).Msg("TodoTask purged")
+	return nil
+}
+
+// PurgeTrashedTodoTasks permanently deletes the todo_tasks that were moved
+// to the trash before deletedBefore and returns how many were removed.
+func (r repository) PurgeTrashedTodoTasks(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var result *gorm.DB
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		trashed := tx.Unscoped().Model(&model.TodoTask{}).Select("id").Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore)
+		if err := tx.Exec("DELETE FROM todo_task_tags WHERE todo_task_id IN (?)", trashed).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM todo_task_dependencies WHERE blocker_id IN (?) OR blocked_id IN (?)", trashed, trashed).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM shares WHERE todo_task_id IN (?)", trashed).Error; err != nil {
+			return err
+		}
+		if err := purgeComments(tx, trashed); err != nil {
+			return err
+		}
+		if err := detachAttachments(tx, trashed); err != nil {
+			return err
+		}
+		if err := (repository{tx}).orphanSubtasks(ctx, trashed).Error; err != nil {
+			return err
+		}
+		result = tx.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).Delete(&model.TodoTask{})
+		return result.Error
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("error while purging trashed todo_tasks")
+		return 0, err
+	}
+
+	logger.Info().Int64("count", result.RowsAffected).Msg("Trashed TodoTasks purged")
+	return result.RowsAffected, nil
+}
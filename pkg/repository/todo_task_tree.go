@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+// todoTaskSubtreeQuery walks down from a live todo_task to all of its live
+// subtasks. UNION drops the rows already visited, so the walk ends even if
+// the parents form a cycle.
+const todoTaskSubtreeQuery = `
+WITH RECURSIVE subtree (id) AS (
+    SELECT id FROM todo_tasks WHERE id = ? AND deleted_at IS NULL
+    UNION
+    SELECT todo_tasks.id FROM todo_tasks JOIN subtree ON todo_tasks.parent_id = subtree.id
+    WHERE todo_tasks.deleted_at IS NULL
+)
+SELECT id FROM subtree`
+
+// GetTodoTaskTree returns the todo_task with the given id followed by all
+// of its subtasks at any depth, ordered by id. It returns
+// gorm.ErrRecordNotFound when the todo_task does not exist or is trashed.
+func (r repository) GetTodoTaskTree(ctx context.Context, id string) ([]model.TodoTask, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var ids []uint
+	if err := r.db.WithContext(ctx).Raw(todoTaskSubtreeQuery, id).Scan(&ids).Error; err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("error while walking todo_task tree")
+		return nil, err
+	}
+	if len(ids) == 0 {
+		logger.Info().Str("todo_task_id", id).Msg("todo_task not found")
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var todoTasks []model.TodoTask
+	if err := preloadTags(r.db.WithContext(ctx)).Scopes(todoTasksOfCaller(ctx)).Where("id IN ?", ids).Order("id ASC").Find(&todoTasks).Error; err != nil {
+		logger.Error().Err(err).Str("todo_task_id", id).Msg("error while getting todo_task tree")
+		return nil, err
+	}
+
+	// The root goes first whatever its id
+	root := slices.IndexFunc(todoTasks, func(t model.TodoTask) bool { return strconv.FormatUint(uint64(t.ID), 10) == id })
+	if root < 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	rootTask := todoTasks[root]
+	todoTasks = slices.Insert(slices.Delete(todoTasks, root, root+1), 0, rootTask)
+
+	logger.Info().Int("count", len(todoTasks)).Msg("Get TodoTask tree")
+	return todoTasks, nil
+}
+
+// OrphanSubtasks makes the direct subtasks of the todo_task, trashed ones
+// included, top-level todo_tasks and returns how many there were.
+func (r repository) OrphanSubtasks(ctx context.Context, id string) (int64, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	result := repository{r.db.WithContext(ctx).Scopes(todoTasksOfCaller(ctx))}.orphanSubtasks(ctx, id)
+	if result.Error != nil {
+		logger.Error().Err(result.Error).Str("todo_task_id", id).Msg("error while orphaning subtasks")
+		return 0, result.Error
+	}
+
+	logger.Info().Int64("count", result.RowsAffected).Msg("Subtasks orphaned")
+	return result.RowsAffected, nil
+}
+
+// orphanSubtasks clears the parent of the subtasks of parents, an id or a
+// subquery selecting ids.
+func (r repository) orphanSubtasks(ctx context.Context, parents interface{}) *gorm.DB {
+	return r.db.WithContext(ctx).Unscoped().Model(&model.TodoTask{}).Where("parent_id IN (?)", parents).
+		Updates(map[string]interface{}{"parent_id": nil, "version": gorm.Expr("version + 1")})
+}
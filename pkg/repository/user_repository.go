@@ -0,0 +1,280 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+)
+
+// ErrEmailTaken is returned when a user registers with the email address
+// of another user.
+var ErrEmailTaken = errors.New("email already registered")
+
+// ErrLegacyOwnerTaken is returned when the legacy rows are assigned to a
+// user while the default workspace already belongs to another one.
+var ErrLegacyOwnerTaken = errors.New("default workspace owned by another user")
+
+// UserRepository stores the user accounts, their login sessions and
+// their personal access tokens. Emails are expected in the form returned
+// by model.NormalizeEmail.
+type UserRepository interface {
+	CreateUser(ctx context.Context, user *model.User) error
+	GetUserByEmail(ctx context.Context, email string) (model.User, error)
+	RecordFailedLogin(ctx context.Context, id uint, maxFailures int, lockout time.Duration) error
+	RecordSuccessfulLogin(ctx context.Context, id uint) error
+	CreateSession(ctx context.Context, session *model.Session) error
+	GetSessionUser(ctx context.Context, tokenHash string) (model.User, error)
+	DeleteSession(ctx context.Context, tokenHash string) error
+	CreateAccessToken(ctx context.Context, accessToken *model.AccessToken) error
+	GetAccessTokens(ctx context.Context, userID uint) ([]model.AccessToken, error)
+	RevokeAccessToken(ctx context.Context, userID uint, id string) (model.AccessToken, error)
+	UseAccessToken(ctx context.Context, tokenHash string) (model.AccessToken, error)
+	AssignLegacyOwner(ctx context.Context, userID uint) error
+}
+
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return repository{db}
+}
+
+func (r repository) CreateUser(ctx context.Context, user *model.User) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	// The unique index is the last word, the lookup gives a clean error
+	// without relying on the driver translating the violation.
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&model.User{}).Where("email = ?", user.Email).Count(&count).Error; err != nil {
+		logger.Error().Err(err).Msg("error while checking user email")
+		return err
+	}
+	if count > 0 {
+		logger.Info().Msg("user email already registered")
+		return ErrEmailTaken
+	}
+	// Every user starts with a workspace of their own
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		workspace := model.Workspace{Name: "Personal", OwnerID: &user.ID}
+		if err := tx.Create(&workspace).Error; err != nil {
+			return err
+		}
+		return tx.Create(&model.WorkspaceMember{WorkspaceID: workspace.ID, UserID: user.ID}).Error
+	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return ErrEmailTaken
+		}
+		logger.Error().Err(err).Msg("error while creating user")
+		return err
+	}
+	logger.Info().Uint("user_id", user.ID).Msg("User created")
+	return nil
+}
+
+func (r repository) GetUserByEmail(ctx context.Context, email string) (model.User, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var user model.User
+	result := r.db.WithContext(ctx).Where("email = ?", email).Limit(1).Find(&user)
+	if result.Error != nil {
+		logger.Error().Err(result.Error).Msg("error while getting user")
+		return model.User{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.User{}, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+
+// RecordFailedLogin counts a failed login of the user. The maxFailures-th
+// failure in a row locks the account for lockout and starts counting again.
+func (r repository) RecordFailedLogin(ctx context.Context, id uint, maxFailures int, lockout time.Duration) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	lockedUntil := time.Now().UTC().Add(lockout)
+	err := r.db.WithContext(ctx).Model(&model.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"locked_until":  gorm.Expr("CASE WHEN failed_logins + 1 >= ? THEN ? ELSE locked_until END", maxFailures, lockedUntil),
+		"failed_logins": gorm.Expr("CASE WHEN failed_logins + 1 >= ? THEN 0 ELSE failed_logins + 1 END", maxFailures),
+	}).Error
+	if err != nil {
+		logger.Error().Err(err).Uint("user_id", id).Msg("error while recording failed login")
+		return err
+	}
+	logger.Info().Uint("user_id", id).Msg("Failed login recorded")
+	return nil
+}
+
+// RecordSuccessfulLogin resets the failed logins of the user.
+func (r repository) RecordSuccessfulLogin(ctx context.Context, id uint) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	err := r.db.WithContext(ctx).Model(&model.User{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"failed_logins": 0, "locked_until": nil}).Error
+	if err != nil {
+		logger.Error().Err(err).Uint("user_id", id).Msg("error while recording login")
+		return err
+	}
+	return nil
+}
+
+func (r repository) CreateSession(ctx context.Context, session *model.Session) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	if err := r.db.WithContext(ctx).Create(session).Error; err != nil {
+		logger.Error().Err(err).Uint("user_id", session.UserID).Msg("error while creating session")
+		return err
+	}
+	logger.Info().Uint("user_id", session.UserID).Msg("Session created")
+	return nil
+}
+
+// GetSessionUser returns the user of the unexpired session with the given
+// token hash, gorm.ErrRecordNotFound when there is none.
+func (r repository) GetSessionUser(ctx context.Context, tokenHash string) (model.User, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var user model.User
+	result := r.db.WithContext(ctx).Where("id = (?)", r.db.WithContext(ctx).Model(&model.Session{}).Select("user_id").
+		Where("token_hash = ? AND expires_at > ?", tokenHash, time.Now().UTC())).Limit(1).Find(&user)
+	if result.Error != nil {
+		logger.Error().Err(result.Error).Msg("error while getting session")
+		return model.User{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.User{}, gorm.ErrRecordNotFound
+	}
+	return user, nil
+}
+
+// DeleteSession ends a session, ending an unknown session does nothing.
+func (r repository) DeleteSession(ctx context.Context, tokenHash string) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).Delete(&model.Session{}).Error; err != nil {
+		logger.Error().Err(err).Msg("error while deleting session")
+		return err
+	}
+	logger.Info().Msg("Session deleted")
+	return nil
+}
+
+func (r repository) CreateAccessToken(ctx context.Context, accessToken *model.AccessToken) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	if err := r.db.WithContext(ctx).Create(accessToken).Error; err != nil {
+		logger.Error().Err(err).Uint("user_id", accessToken.UserID).Msg("error while creating access_token")
+		return err
+	}
+	logger.Info().Uint("access_token_id", accessToken.ID).Msg("AccessToken created")
+	return nil
+}
+
+// GetAccessTokens returns every access token of the user, revoked and
+// expired ones included, oldest first.
+func (r repository) GetAccessTokens(ctx context.Context, userID uint) ([]model.AccessToken, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	accessTokens := []model.AccessToken{}
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id ASC").Find(&accessTokens).Error; err != nil {
+		logger.Error().Err(err).Msg("error while fetching access_tokens")
+		return nil, err
+	}
+	return accessTokens, nil
+}
+
+// RevokeAccessToken revokes an access token of the user. Revoking it again
+// keeps the time of the first revocation.
+func (r repository) RevokeAccessToken(ctx context.Context, userID uint, id string) (model.AccessToken, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var accessToken model.AccessToken
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND id = ?", userID, id).First(&accessToken).Error; err != nil {
+			return err
+		}
+		if accessToken.RevokedAt != nil {
+			return nil
+		}
+		now := time.Now().UTC()
+		accessToken.RevokedAt = &now
+		return tx.Model(&accessToken).Update("revoked_at", now).Error
+	})
+	if err != nil {
+		logger.Error().Err(err).Str("access_token_id", id).Msg("error while revoking access_token")
+		return model.AccessToken{}, err
+	}
+	logger.Info().Str("access_token_id", id).Msg("AccessToken revoked")
+	return accessToken, nil
+}
+
+// UseAccessToken returns the unrevoked, unexpired access token with the
+// given token hash and records that it was used, gorm.ErrRecordNotFound
+// when there is none. The last use is only written once a minute, so that
+// a busy script does not write on every request.
+func (r repository) UseAccessToken(ctx context.Context, tokenHash string) (model.AccessToken, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	now := time.Now().UTC()
+	var accessToken model.AccessToken
+	result := r.db.WithContext(ctx).Where("token_hash = ? AND revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)", tokenHash, now).
+		Limit(1).Find(&accessToken)
+	if result.Error != nil {
+		logger.Error().Err(result.Error).Msg("error while getting access_token")
+		return model.AccessToken{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return model.AccessToken{}, gorm.ErrRecordNotFound
+	}
+
+	if accessToken.LastUsedAt == nil || now.Sub(*accessToken.LastUsedAt) >= time.Minute {
+		err := r.db.WithContext(ctx).Model(&model.AccessToken{}).Where("id = ?", accessToken.ID).Update("last_used_at", now).Error
+		if err != nil {
+			logger.Error().Err(err).Uint("access_token_id", accessToken.ID).Msg("error while recording access_token use")
+			return model.AccessToken{}, err
+		}
+		accessToken.LastUsedAt = &now
+	}
+	return accessToken, nil
+}
+
+// AssignLegacyOwner gives the default workspace to the user, with the
+// todo_tasks and projects of it that are older than accounts and have no
+// owner. Nobody owns them until the operator names the user, assigning
+// them again to the same user is a no-op. Raw SQL keeps the statements
+// clear of the workspace of the request.
+func (r repository) AssignLegacyOwner(ctx context.Context, userID uint) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var workspace model.Workspace
+		if err := tx.First(&workspace, model.DefaultWorkspaceID).Error; err != nil {
+			return err
+		}
+		if workspace.OwnerID != nil && *workspace.OwnerID != userID {
+			return ErrLegacyOwnerTaken
+		}
+		params := map[string]interface{}{"workspace": model.DefaultWorkspaceID, "owner": userID, "now": time.Now()}
+		for _, query := range []string{
+			"UPDATE workspaces SET owner_id = @owner WHERE id = @workspace",
+			"INSERT INTO workspace_members (workspace_id, user_id, created_at) VALUES (@workspace, @owner, @now) ON CONFLICT DO NOTHING",
+			"UPDATE todo_tasks SET owner_id = @owner WHERE owner_id IS NULL AND workspace_id = @workspace",
+			"UPDATE projects SET owner_id = @owner WHERE owner_id IS NULL AND workspace_id = @workspace",
+		} {
+			if err := tx.Exec(query, params).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.Error().Err(err).Uint("user_id", userID).Msg("error while assigning legacy rows")
+		return err
+	}
+	logger.Info().Uint("user_id", userID).Msg("Legacy rows assigned")
+	return nil
+}
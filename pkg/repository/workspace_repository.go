@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"golang.org/x/net/context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// WorkspaceRepository stores the workspaces and their members. The
+// workspaces are not scoped by the workspace of the request: a user sees
+// every workspace they are a member of, and only those.
+type WorkspaceRepository interface {
+	CreateWorkspace(ctx context.Context, workspacePayload *model.WorkspacePayload) (model.Workspace, error)
+	GetWorkspaces(ctx context.Context) ([]model.Workspace, error)
+	GetWorkspace(ctx context.Context, id string) (model.Workspace, error)
+	GetWorkspaceMembers(ctx context.Context, id string) ([]model.WorkspaceMember, error)
+	PutWorkspaceMember(ctx context.Context, id string, userID uint) (model.WorkspaceMember, error)
+	DeleteWorkspaceMember(ctx context.Context, id string, userID uint) error
+	// GetMemberWorkspaceID returns the oldest workspace of the user, the
+	// one their requests are made in unless they pick another.
+	GetMemberWorkspaceID(ctx context.Context, userID uint) (uint, error)
+	IsWorkspaceMember(ctx context.Context, workspaceID uint, userID uint) (bool, error)
+}
+
+func NewWorkspaceRepository(db *gorm.DB) WorkspaceRepository {
+	return repository{db}
+}
+
+// workspacesOfCaller restricts a query on workspaces to the ones the
+// caller is a member of. Calls made outside of a request see every row.
+func workspacesOfCaller(ctx context.Context) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if principal, ok := auth.PrincipalFromContext(ctx); ok {
+			return db.Where("workspaces.id IN (SELECT workspace_id FROM workspace_members WHERE user_id = ?)", principal.UserID)
+		}
+		return db
+	}
+}
+
+// CreateWorkspace creates a workspace owned by the caller, who becomes its
+// first member.
+func (r repository) CreateWorkspace(ctx context.Context, workspacePayload *model.WorkspacePayload) (model.Workspace, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	if err := workspacePayload.ValidateWorkspacePayload(); err != nil {
+		return model.Workspace{}, err
+	}
+
+	workspace := model.Workspace{Name: workspacePayload.Name, OwnerID: callerID(ctx)}
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&workspace).Error; err != nil {
+			return err
+		}
+		if workspace.OwnerID == nil {
+			return nil
+		}
+		return tx.Create(&model.WorkspaceMember{WorkspaceID: workspace.ID, UserID: *workspace.OwnerID}).Error
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("error while creating workspace")
+		return model.Workspace{}, err
+	}
+	logger.Info().Uint("workspace_id", workspace.ID).Msg("Workspace created")
+	return workspace, nil
+}
+
+// GetWorkspaces returns the workspaces of the caller, oldest first.
+func (r repository) GetWorkspaces(ctx context.Context) ([]model.Workspace, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	workspaces := []model.Workspace{}
+	if err := r.db.WithContext(ctx).Scopes(workspacesOfCaller(ctx)).Order("id ASC").Find(&workspaces).Error; err != nil {
+		logger.Error().Err(err).Msg("error while fetching workspaces")
+		return nil, err
+	}
+	logger.Info().Int("count", len(workspaces)).Msg("Get Workspaces")
+	return workspaces, nil
+}
+
+func (r repository) GetWorkspace(ctx context.Context, id string) (model.Workspace, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var workspace model.Workspace
+	if err := r.db.WithContext(ctx).Scopes(workspacesOfCaller(ctx)).Where("id = ?", id).First(&workspace).Error; err != nil {
+		logger.Error().Err(err).Str("workspace_id", id).Msg("error while getting workspace")
+		return model.Workspace{}, err
+	}
+	logger.Info().Msg("Workspace was found")
+	return workspace, nil
+}
+
+// GetWorkspaceMembers returns the members of a workspace of the caller
+// with their email, oldest first.
+func (r repository) GetWorkspaceMembers(ctx context.Context, id string) ([]model.WorkspaceMember, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	workspace, err := r.GetWorkspace(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	members := []model.WorkspaceMember{}
+	err = membersWithEmail(r.db.WithContext(ctx)).Where("workspace_members.workspace_id = ?", workspace.ID).
+		Order("workspace_members.created_at ASC, workspace_members.user_id ASC").Find(&members).Error
+	if err != nil {
+		logger.Error().Err(err).Str("workspace_id", id).Msg("error while fetching workspace members")
+		return nil, err
+	}
+	logger.Info().Int("count", len(members)).Msg("Get Workspace members")
+	return members, nil
+}
+
+// PutWorkspaceMember adds the user to a workspace of the caller. Adding a
+// member again succeeds.
+func (r repository) PutWorkspaceMember(ctx context.Context, id string, userID uint) (model.WorkspaceMember, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	workspace, err := r.GetWorkspace(ctx, id)
+	if err != nil {
+		return model.WorkspaceMember{}, err
+	}
+	member := model.WorkspaceMember{WorkspaceID: workspace.ID, UserID: userID}
+	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&member).Error; err != nil {
+		logger.Error().Err(err).Uint("user_id", userID).Msg("error while adding workspace member")
+		return model.WorkspaceMember{}, err
+	}
+	err = membersWithEmail(r.db.WithContext(ctx)).
+		Where("workspace_members.workspace_id = ? AND workspace_members.user_id = ?", workspace.ID, userID).First(&member).Error
+	if err != nil {
+		logger.Error().Err(err).Uint("user_id", userID).Msg("error while getting workspace member")
+		return model.WorkspaceMember{}, err
+	}
+	logger.Info().Uint("workspace_id", workspace.ID).Uint("user_id", userID).Msg("Workspace member added")
+	return member, nil
+}
+
+// DeleteWorkspaceMember removes the user from a workspace of the caller,
+// along with the shares they were given in it. Removing a user who is not
+// a member succeeds.
+func (r repository) DeleteWorkspaceMember(ctx context.Context, id string, userID uint) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	workspace, err := r.GetWorkspace(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ? AND (project_id IN (SELECT id FROM projects WHERE workspace_id = ?) OR todo_task_id IN (SELECT id FROM todo_tasks WHERE workspace_id = ?))",
+			userID, workspace.ID, workspace.ID).Delete(&model.Share{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("workspace_id = ? AND user_id = ?", workspace.ID, userID).Delete(&model.WorkspaceMember{}).Error
+	})
+	if err != nil {
+		logger.Error().Err(err).Uint("user_id", userID).Msg("error while deleting workspace member")
+		return err
+	}
+	logger.Info().Uint("workspace_id", workspace.ID).Uint("user_id", userID).Msg("Workspace member deleted")
+	return nil
+}
+
+func (r repository) GetMemberWorkspaceID(ctx context.Context, userID uint) (uint, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var members []model.WorkspaceMember
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("workspace_id ASC").Limit(1).Find(&members).Error; err != nil {
+		logger.Error().Err(err).Uint("user_id", userID).Msg("error while getting workspace of user")
+		return 0, err
+	}
+	if len(members) == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return members[0].WorkspaceID, nil
+}
+
+func (r repository) IsWorkspaceMember(ctx context.Context, workspaceID uint, userID uint) (bool, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.WorkspaceMember{}).
+		Where("workspace_id = ? AND user_id = ?", workspaceID, userID).Count(&count).Error
+	if err != nil {
+		logger.Error().Err(err).Uint("user_id", userID).Msg("error while checking workspace member")
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// membersWithEmail queries the workspace members joined with the email of
+// their user.
+func membersWithEmail(db *gorm.DB) *gorm.DB {
+	return db.Model(&model.WorkspaceMember{}).Select("workspace_members.*, users.email").
+		Joins("JOIN users ON users.id = workspace_members.user_id")
+}
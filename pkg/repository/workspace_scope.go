@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNoWorkspace is returned when a request made outside of any workspace
+// creates a row that belongs to one.
+var ErrNoWorkspace = errors.New("the request is not made in a workspace")
+
+// workspaceTables are the tables whose rows belong to a workspace, in the
+// workspace_id column. The rows of the other tables hang off theirs.
+var workspaceTables = map[string]bool{"todo_tasks": true, "projects": true, "tags": true}
+
+// ScopeByWorkspace registers the callbacks isolating the workspaces: every
+// statement on the workspaceTables made on behalf of a principal, as found
+// in the context of the statement, only sees the rows of its workspace and
+// the rows it creates are put in it. A query can not forget the condition,
+// the repositories only have to hand the context down with WithContext.
+//
+// Statements made outside of a request, such as by the trash sweeper, span
+// every workspace, like todoTasksOfCaller. Raw SQL is not rewritten, it
+// must select from a scoped query such as the one of callerTodoTasks.
+func ScopeByWorkspace(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().Before("gorm:create").Register("workspace:create", setWorkspace); err != nil {
+		return err
+	}
+	if err := callbacks.Query().Before("gorm:query").Register("workspace:query", whereWorkspace); err != nil {
+		return err
+	}
+	if err := callbacks.Row().Before("gorm:row").Register("workspace:row", whereWorkspace); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("workspace:update", whereWorkspace); err != nil {
+		return err
+	}
+	return callbacks.Delete().Before("gorm:delete").Register("workspace:delete", whereWorkspace)
+}
+
+// statementWorkspace returns the workspace the statement is restricted to,
+// ok is false when it is not.
+func statementWorkspace(db *gorm.DB) (workspaceID uint, ok bool) {
+	if db.Error != nil || !workspaceTables[db.Statement.Table] || db.Statement.Context == nil {
+		return 0, false
+	}
+	principal, ok := auth.PrincipalFromContext(db.Statement.Context)
+	return principal.WorkspaceID, ok
+}
+
+func whereWorkspace(db *gorm.DB) {
+	workspaceID, ok := statementWorkspace(db)
+	if !ok {
+		return
+	}
+	// A principal without a workspace matches no row, as workspace ids
+	// start at 1
+	db.Statement.AddClause(clause.Where{Exprs: []clause.Expression{
+		clause.Eq{Column: clause.Column{Table: db.Statement.Table, Name: "workspace_id"}, Value: workspaceID},
+	}})
+}
+
+func setWorkspace(db *gorm.DB) {
+	workspaceID, ok := statementWorkspace(db)
+	if !ok || db.Statement.Schema == nil {
+		return
+	}
+	if workspaceID == 0 {
+		_ = db.AddError(ErrNoWorkspace)
+		return
+	}
+	field := db.Statement.Schema.LookUpField("WorkspaceID")
+	if field == nil {
+		return
+	}
+
+	set := func(row reflect.Value) {
+		if err := field.Set(db.Statement.Context, row, workspaceID); err != nil {
+			_ = db.AddError(err)
+		}
+	}
+	switch value := reflect.Indirect(db.Statement.ReflectValue); value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			set(reflect.Indirect(value.Index(i)))
+		}
+	case reflect.Struct:
+		set(value)
+	}
+}
@@ -0,0 +1,87 @@
+//go:build UnitTest
+// +build UnitTest
+
+package repository
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"gorm.io/gorm"
+)
+
+// TestWorkspaceIsolation runs the repositories of one user in two
+// workspaces against Postgres, no row of one may be seen or changed from
+// the other.
+func TestWorkspaceIsolation(t *testing.T) {
+	repo := repository{db: testDB}
+	t.Cleanup(func() {
+		AfterEach()
+	})
+
+	user := model.User{Email: "ada@example.com", PasswordHash: "-"}
+	require.NoError(t, testDB.Create(&user).Error)
+	other := model.Workspace{Name: "Other"}
+	require.NoError(t, testDB.Create(&other).Error)
+	in := func(workspaceID uint) context.Context {
+		return auth.WithPrincipal(context.Background(), auth.Principal{UserID: user.ID, WorkspaceID: workspaceID})
+	}
+	inDefault, inOther := in(model.DefaultWorkspaceID), in(other.ID)
+
+	payload := func(title string) *model.TodoTaskPayload {
+		return &model.TodoTaskPayload{Title: title, Description: "d", Status: model.StatusTodo, Tags: []string{"urgent"}}
+	}
+	mine, err := repo.CreateTodoTask(inDefault, payload("mine"))
+	require.NoError(t, err)
+	assert.Equal(t, model.DefaultWorkspaceID, mine.WorkspaceID)
+	theirs, err := repo.CreateTodoTask(inOther, payload("theirs"))
+	require.NoError(t, err)
+	assert.Equal(t, other.ID, theirs.WorkspaceID)
+	assert.NotEqual(t, mine.Tags[0].ID, theirs.Tags[0].ID, "each workspace has its own tags")
+	id := strconv.Itoa(int(mine.ID))
+
+	_, err = repo.GetTodoTask(inOther, id)
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	page, err := repo.GetTodoTasks(inOther, model.TodoTaskListParams{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "theirs", page.Items[0].Title)
+	results, err := repo.SearchTodoTasks(inOther, "mine", 10)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	_, err = repo.UpdateTodoTask(inOther, id, nil, payload("taken"))
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	_, err = repo.PatchTodoTask(inOther, id, nil, map[string]interface{}{"title": "taken"})
+	assert.Equal(t, gorm.ErrRecordNotFound, err)
+	assert.NoError(t, repo.DeleteTodoTask(inOther, id, nil))
+	_, err = repo.GetTodoTask(inDefault, id)
+	assert.NoError(t, err, "deleting from another workspace does nothing")
+	tags, err := repo.GetTags(inOther)
+	require.NoError(t, err)
+	assert.Len(t, tags, 1)
+
+	// A query written without any condition still stays in its workspace
+	var all []model.TodoTask
+	require.NoError(t, testDB.WithContext(inOther).Find(&all).Error)
+	assert.Len(t, all, 1)
+	var count int64
+	require.NoError(t, testDB.WithContext(inOther).Model(&model.TodoTask{}).Where("1 = 1").Update("title", "taken").Error)
+	require.NoError(t, testDB.Model(&model.TodoTask{}).Where("title = ?", "taken").Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	// Background jobs span every workspace, principals without one see
+	// and create nothing
+	page, err = repo.GetTodoTasks(context.Background(), model.TodoTaskListParams{})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 2)
+	page, err = repo.GetTodoTasks(in(0), model.TodoTaskListParams{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+	_, err = repo.CreateTodoTask(in(0), payload("nowhere"))
+	assert.ErrorIs(t, err, ErrNoWorkspace)
+}
@@ -0,0 +1,254 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+	"github.com/vkuzmich/gin-project/pkg/storage"
+)
+
+// AttachmentService manages the files attached to the todo_tasks. The
+// metadata is kept by the repository, the content by a storage.BlobStore.
+type AttachmentService interface {
+	AddAttachment(ctx *gin.Context, todoTaskID string, upload model.AttachmentUpload) (model.Attachment, error)
+	GetAttachment(ctx *gin.Context, todoTaskID string, id string) (model.Attachment, error)
+	GetAttachments(ctx *gin.Context, todoTaskID string, params model.AttachmentListParams) (model.AttachmentPage, error)
+	// OpenAttachment returns an attachment with its content, which the
+	// caller must close.
+	OpenAttachment(ctx *gin.Context, todoTaskID string, id string) (model.Attachment, io.ReadCloser, error)
+	DeleteAttachment(ctx *gin.Context, todoTaskID string, id string) error
+	// PurgeDetachedAttachments deletes the content of the attachments
+	// detached from their todo_task and returns how many were removed.
+	PurgeDetachedAttachments(ctx context.Context) (int64, error)
+}
+
+// NewAttachmentService builds the service. Uploads larger than maxSize
+// bytes are rejected, see model.AttachmentMaxSize for the default.
+func NewAttachmentService(attachmentRepository repository.AttachmentRepository, blobs storage.BlobStore, maxSize int64) AttachmentService {
+	return attachmentService{
+		attachmentRepository,
+		blobs,
+		model.AttachmentMaxSize(maxSize),
+	}
+}
+
+type attachmentService struct {
+	attachmentRepository repository.AttachmentRepository
+	blobs                storage.BlobStore
+	maxSize              int64
+}
+
+// detachedBatchSize is how many detached attachments are purged per query.
+const detachedBatchSize = 100
+
+// AddAttachment and DeleteAttachment take the edit action on the todo_task.
+func (s attachmentService) AddAttachment(ctx *gin.Context, todoTaskID string, upload model.AttachmentUpload) (model.Attachment, error) {
+	logger := contextLogger.ContextLog(ctx)
+	if err := authorizeTodoTask(ctx, s.attachmentRepository, todoTaskID, auth.ActionEdit); err != nil {
+		logger.Info().Err(err).Msg("attachment not allowed")
+		return model.Attachment{}, translateError(err)
+	}
+	attachment, err := s.addAttachment(ctx, todoTaskID, upload)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to create attachment")
+		return model.Attachment{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully created attachment")
+	return attachment, nil
+}
+
+// addAttachment reads the upload once to checksum it and sniff its
+// content type, then streams it to the blob store and records it.
+func (s attachmentService) addAttachment(ctx *gin.Context, todoTaskID string, upload model.AttachmentUpload) (model.Attachment, error) {
+	upload.Filename = attachmentFilename(upload.Filename)
+	if err := upload.ValidateAttachmentUpload(); err != nil {
+		return model.Attachment{}, err
+	}
+	if upload.Size > s.maxSize {
+		return model.Attachment{}, s.tooLarge()
+	}
+
+	digest := &contentDigest{hash: sha256.New()}
+	content := io.TeeReader(io.LimitReader(upload.Content, s.maxSize+1), digest)
+	contentType, err := mimetype.DetectReader(content)
+	if err != nil {
+		return model.Attachment{}, err
+	}
+	if _, err := io.Copy(io.Discard, content); err != nil {
+		return model.Attachment{}, err
+	}
+	if digest.size > s.maxSize {
+		return model.Attachment{}, s.tooLarge()
+	}
+	if _, err := upload.Content.Seek(0, io.SeekStart); err != nil {
+		return model.Attachment{}, err
+	}
+
+	key, err := newStorageKey()
+	if err != nil {
+		return model.Attachment{}, err
+	}
+	attachment := model.Attachment{
+		Filename:    upload.Filename,
+		ContentType: contentType.String(),
+		Size:        digest.size,
+		SHA256:      hex.EncodeToString(digest.hash.Sum(nil)),
+		StorageKey:  key,
+	}
+	if err := s.blobs.Put(ctx, key, upload.Content, attachment.Size, attachment.SHA256); err != nil {
+		return model.Attachment{}, err
+	}
+	if err := s.attachmentRepository.CreateAttachment(ctx, todoTaskID, &attachment); err != nil {
+		if deleteErr := s.blobs.Delete(ctx, key); deleteErr != nil {
+			contextLogger.ContextLog(ctx).Error().Err(deleteErr).Str("key", key).Msg("Fail to delete content of unrecorded attachment")
+		}
+		return model.Attachment{}, err
+	}
+	return attachment, nil
+}
+
+func (s attachmentService) tooLarge() error {
+	return &Error{Kind: ErrTooLarge, Detail: "the file is too large",
+		Fields: []model.FieldError{{Field: "file", Message: fmt.Sprintf("must be at most %d bytes", s.maxSize)}}}
+}
+
+func (s attachmentService) GetAttachment(ctx *gin.Context, todoTaskID string, id string) (model.Attachment, error) {
+	logger := contextLogger.ContextLog(ctx)
+	attachment, err := s.attachmentRepository.GetAttachment(ctx, todoTaskID, id)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get attachment")
+		return model.Attachment{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully get attachment")
+	return attachment, nil
+}
+
+func (s attachmentService) GetAttachments(ctx *gin.Context, todoTaskID string, params model.AttachmentListParams) (model.AttachmentPage, error) {
+	logger := contextLogger.ContextLog(ctx)
+	page, err := s.attachmentRepository.GetAttachments(ctx, todoTaskID, params)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get attachments")
+		return model.AttachmentPage{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully get attachments")
+	return page, nil
+}
+
+func (s attachmentService) OpenAttachment(ctx *gin.Context, todoTaskID string, id string) (model.Attachment, io.ReadCloser, error) {
+	logger := contextLogger.ContextLog(ctx)
+	attachment, err := s.attachmentRepository.GetAttachment(ctx, todoTaskID, id)
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get attachment")
+		return model.Attachment{}, nil, translateError(err)
+	}
+
+	content, err := s.blobs.Get(ctx, attachment.StorageKey)
+	if err != nil {
+		logger.Error().Err(err).Str("key", attachment.StorageKey).Msg("Fail to open content of attachment")
+		return model.Attachment{}, nil, translateError(err)
+	}
+	logger.Info().Msg("Successfully open attachment")
+	return attachment, content, nil
+}
+
+// DeleteAttachment detaches the attachment and then deletes its content.
+// When the blob store fails, the content is left to the trash sweeper.
+func (s attachmentService) DeleteAttachment(ctx *gin.Context, todoTaskID string, id string) error {
+	logger := contextLogger.ContextLog(ctx)
+	if err := authorizeTodoTask(ctx, s.attachmentRepository, todoTaskID, auth.ActionEdit); err != nil {
+		logger.Info().Err(err).Msg("attachment delete not allowed")
+		return translateError(err)
+	}
+
+	attachment, err := s.attachmentRepository.DetachAttachment(ctx, todoTaskID, id)
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to delete attachment")
+		return translateError(err)
+	}
+	if err := s.purge(ctx, attachment); err != nil {
+		logger.Error().Err(err).Msg("Fail to purge attachment, the trash sweeper retries")
+	}
+	logger.Info().Msg("Successfully delete attachment")
+	return nil
+}
+
+// PurgeDetachedAttachments stops at the first attachment that can not be
+// purged, the next run starts over with it.
+func (s attachmentService) PurgeDetachedAttachments(ctx context.Context) (int64, error) {
+	var purged int64
+	for {
+		attachments, err := s.attachmentRepository.GetDetachedAttachments(ctx, detachedBatchSize)
+		if err != nil {
+			return purged, err
+		}
+		for _, attachment := range attachments {
+			if err := s.purge(ctx, attachment); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+		if len(attachments) < detachedBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// purge deletes the content of a detached attachment, then its row.
+func (s attachmentService) purge(ctx context.Context, attachment model.Attachment) error {
+	if err := s.blobs.Delete(ctx, attachment.StorageKey); err != nil {
+		return err
+	}
+	return s.attachmentRepository.PurgeAttachment(ctx, attachment.ID)
+}
+
+// contentDigest counts and hashes the bytes written to it.
+type contentDigest struct {
+	hash hash.Hash
+	size int64
+}
+
+func (d *contentDigest) Write(p []byte) (int, error) {
+	d.size += int64(len(p))
+	return d.hash.Write(p)
+}
+
+// attachmentFilename keeps the last element of a client supplied path
+// without control characters, so that a download can not be saved
+// outside of the folder it is downloaded to.
+func attachmentFilename(name string) string {
+	name = name[strings.LastIndexAny(name, `/\`)+1:]
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	return strings.TrimSpace(name)
+}
+
+// newStorageKey returns a random key, prefixed with its first two hex
+// digits to spread the blobs of a local store over directories.
+func newStorageKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(b)
+	return key[:2] + "/" + key, nil
+}
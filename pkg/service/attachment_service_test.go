@@ -0,0 +1,122 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+	"github.com/vkuzmich/gin-project/pkg/storage"
+)
+
+func TestTodoTaskAttachments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/todo_tasks/", nil)
+
+	db := GetSQLiteDBInstance(t)
+	dir := t.TempDir()
+	todoTasks := NewTodoTaskService(repository.NewTodoTaskRepository(db), repository.NewStorage(db), nil, "")
+	s := NewAttachmentService(repository.NewAttachmentRepository(db), storage.NewLocalBlobStore(dir), 64)
+
+	todoTask, err := todoTasks.AddTodoTask(ctx, &model.TodoTaskPayload{Title: "t", Description: "d"})
+	require.NoError(t, err)
+	id := strconv.Itoa(int(todoTask.ID))
+
+	upload := func(filename string, content []byte) model.AttachmentUpload {
+		return model.AttachmentUpload{Filename: filename, Size: int64(len(content)), Content: bytes.NewReader(content)}
+	}
+	blobs := func() int {
+		count := 0
+		require.NoError(t, filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				count++
+			}
+			return err
+		}))
+		return count
+	}
+
+	// The content type is sniffed and the client's path is dropped
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	image, err := s.AddAttachment(ctx, id, upload(`..\..\screens/shot.png`, png))
+	require.NoError(t, err)
+	sum := sha256.Sum256(png)
+	assert.Equal(t, "shot.png", image.Filename)
+	assert.Equal(t, "image/png", image.ContentType)
+	assert.Equal(t, int64(len(png)), image.Size)
+	assert.Equal(t, hex.EncodeToString(sum[:]), image.SHA256)
+
+	notes, err := s.AddAttachment(ctx, id, upload("notes.png", []byte("just text")))
+	require.NoError(t, err)
+	assert.Equal(t, "text/plain; charset=utf-8", notes.ContentType)
+
+	// Uploads over the limit are rejected, whatever size they claim
+	large := bytes.Repeat([]byte("a"), 65)
+	_, err = s.AddAttachment(ctx, id, upload("large.txt", large))
+	assert.True(t, errors.Is(err, ErrTooLarge))
+	_, err = s.AddAttachment(ctx, id, model.AttachmentUpload{Filename: "large.txt", Size: 1, Content: bytes.NewReader(large)})
+	assert.True(t, errors.Is(err, ErrTooLarge))
+	_, err = s.AddAttachment(ctx, id, upload("/", png))
+	assert.True(t, errors.Is(err, ErrValidation))
+	_, err = s.AddAttachment(ctx, "999", upload("lost.png", png))
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.Equal(t, 2, blobs())
+
+	attachment, content, err := s.OpenAttachment(ctx, id, strconv.Itoa(int(image.ID)))
+	require.NoError(t, err)
+	got, err := io.ReadAll(content)
+	require.NoError(t, err)
+	require.NoError(t, content.Close())
+	assert.Equal(t, png, got)
+	assert.Equal(t, image.SHA256, attachment.SHA256)
+
+	page, err := s.GetAttachments(ctx, id, model.AttachmentListParams{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.True(t, page.HasMore)
+	page, err = s.GetAttachments(ctx, id, model.AttachmentListParams{Limit: 1, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, notes.ID, page.Items[0].ID)
+	assert.False(t, page.HasMore)
+
+	// Deleting an attachment deletes its content
+	require.NoError(t, s.DeleteAttachment(ctx, id, strconv.Itoa(int(notes.ID))))
+	assert.True(t, errors.Is(s.DeleteAttachment(ctx, id, strconv.Itoa(int(notes.ID))), ErrNotFound))
+	assert.Equal(t, 1, blobs())
+
+	// Attachments are hidden in the trash, and their content outlives the
+	// purge of the todo_task until the sweeper deletes it
+	require.NoError(t, todoTasks.DeleteTodoTask(ctx, id, nil))
+	_, err = s.GetAttachments(ctx, id, model.AttachmentListParams{})
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = todoTasks.RestoreTodoTask(ctx, id, nil)
+	require.NoError(t, err)
+	page, err = s.GetAttachments(ctx, id, model.AttachmentListParams{})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+
+	require.NoError(t, todoTasks.PurgeTodoTask(ctx, id, nil))
+	assert.Equal(t, 1, blobs())
+	purged, err := s.PurgeDetachedAttachments(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+	assert.Equal(t, 0, blobs())
+	var left int64
+	require.NoError(t, db.Model(&model.Attachment{}).Count(&left).Error)
+	assert.Zero(t, left)
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+// CommentService manages the discussion thread of every todo_task. The
+// comments of a deleted todo_task are trashed, restored and purged with it
+// by TodoTaskService.
+type CommentService interface {
+	AddComment(ctx *gin.Context, todoTaskID string, commentPayload *model.CommentPayload) (model.Comment, error)
+	GetComment(ctx *gin.Context, todoTaskID string, id string) (model.Comment, error)
+	GetComments(ctx *gin.Context, todoTaskID string, params model.CommentListParams) (model.CommentPage, error)
+	UpdateComment(ctx *gin.Context, todoTaskID string, id string, commentPayload *model.CommentEditPayload) (model.Comment, error)
+	DeleteComment(ctx *gin.Context, todoTaskID string, id string) error
+}
+
+func NewCommentService(commentRepository repository.CommentRepository) CommentService {
+	return commentService{
+		commentRepository,
+	}
+}
+
+type commentService struct {
+	commentRepository repository.CommentRepository
+}
+
+// AddComment takes the comment action on the todo_task. Editing and
+// deleting a comment is left to its author and to the moderators of the
+// todo_task, see auth.Role.CanChangeComment.
+func (s commentService) AddComment(ctx *gin.Context, todoTaskID string, commentPayload *model.CommentPayload) (model.Comment, error) {
+	logger := contextLogger.ContextLog(ctx)
+	if err := authorizeTodoTask(ctx, s.commentRepository, todoTaskID, auth.ActionComment); err != nil {
+		logger.Info().Err(err).Msg("comment not allowed")
+		return model.Comment{}, translateError(err)
+	}
+	comment, err := s.commentRepository.CreateComment(ctx, todoTaskID, commentPayload)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to create comment")
+		return model.Comment{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully created comment")
+	return comment, nil
+}
+
+func (s commentService) GetComment(ctx *gin.Context, todoTaskID string, id string) (model.Comment, error) {
+	logger := contextLogger.ContextLog(ctx)
+	comment, err := s.commentRepository.GetComment(ctx, todoTaskID, id)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get comment")
+		return model.Comment{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully get comment")
+	return comment, nil
+}
+
+func (s commentService) GetComments(ctx *gin.Context, todoTaskID string, params model.CommentListParams) (model.CommentPage, error) {
+	logger := contextLogger.ContextLog(ctx)
+	page, err := s.commentRepository.GetComments(ctx, todoTaskID, params)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get comments")
+		return model.CommentPage{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully get comments")
+	return page, nil
+}
+
+func (s commentService) UpdateComment(ctx *gin.Context, todoTaskID string, id string, commentPayload *model.CommentEditPayload) (model.Comment, error) {
+	logger := contextLogger.ContextLog(ctx)
+	if err := s.authorizeComment(ctx, todoTaskID, id); err != nil {
+		logger.Info().Err(err).Msg("comment edit not allowed")
+		return model.Comment{}, translateError(err)
+	}
+	comment, err := s.commentRepository.UpdateComment(ctx, todoTaskID, id, commentPayload)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to update comment")
+		return model.Comment{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully update comment")
+	return comment, nil
+}
+
+func (s commentService) DeleteComment(ctx *gin.Context, todoTaskID string, id string) error {
+	logger := contextLogger.ContextLog(ctx)
+	if err := s.authorizeComment(ctx, todoTaskID, id); err != nil {
+		logger.Info().Err(err).Msg("comment delete not allowed")
+		return translateError(err)
+	}
+
+	if err := s.commentRepository.DeleteComment(ctx, todoTaskID, id); err != nil {
+		logger.Error().Err(err).Msg("Fail to delete comment")
+		return translateError(err)
+	}
+	logger.Info().Msg("Successfully delete comment")
+	return nil
+}
+
+// authorizeComment fails with ErrForbidden unless the role of the caller
+// on the todo_task allows changing the comment id, which depends on
+// whether they wrote it.
+func (s commentService) authorizeComment(ctx *gin.Context, todoTaskID string, id string) error {
+	role, err := s.commentRepository.GetTodoTaskRole(ctx, todoTaskID)
+	if err != nil {
+		return err
+	}
+	comment, err := s.commentRepository.GetComment(ctx, todoTaskID, id)
+	if err != nil {
+		return err
+	}
+	principal, ok := auth.PrincipalFromContext(ctx)
+	author := ok && comment.AuthorID != nil && *comment.AuthorID == principal.UserID
+	if role.CanChangeComment(author) {
+		return nil
+	}
+	return NewError(ErrForbidden, fmt.Sprintf("the %s role does not allow changing this comment", role), nil)
+}
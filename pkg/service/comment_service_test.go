@@ -0,0 +1,87 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+func TestTodoTaskComments(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/todo_tasks/", nil)
+
+	db := GetSQLiteDBInstance(t)
+	todoTasks := NewTodoTaskService(repository.NewTodoTaskRepository(db), repository.NewStorage(db), nil, "")
+	s := NewCommentService(repository.NewCommentRepository(db))
+
+	todoTask, err := todoTasks.AddTodoTask(ctx, &model.TodoTaskPayload{Title: "t", Description: "d"})
+	require.NoError(t, err)
+	id := strconv.Itoa(int(todoTask.ID))
+
+	var comments []model.Comment
+	for _, body := range []string{"first", "second", "third"} {
+		comment, err := s.AddComment(ctx, id, &model.CommentPayload{Body: " " + body + " "})
+		require.NoError(t, err)
+		assert.Equal(t, body, comment.Body)
+		comments = append(comments, comment)
+	}
+	_, err = s.AddComment(ctx, id, &model.CommentPayload{Body: " "})
+	assert.True(t, errors.Is(err, ErrValidation))
+	_, err = s.AddComment(ctx, "999", &model.CommentPayload{Body: "lost"})
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// Pages follow the thread oldest first
+	page, err := s.GetComments(ctx, id, model.CommentListParams{Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.True(t, page.HasMore)
+	page, err = s.GetComments(ctx, id, model.CommentListParams{Limit: 2, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "third", page.Items[0].Body)
+	assert.False(t, page.HasMore)
+	_, err = s.GetComments(ctx, id, model.CommentListParams{Cursor: "bogus"})
+	assert.True(t, errors.Is(err, ErrValidation))
+
+	// Only a changed body counts as an edit
+	first := strconv.Itoa(int(comments[0].ID))
+	unchanged, err := s.UpdateComment(ctx, id, first, &model.CommentEditPayload{Body: "first"})
+	require.NoError(t, err)
+	assert.Nil(t, unchanged.EditedAt)
+	edited, err := s.UpdateComment(ctx, id, first, &model.CommentEditPayload{Body: "first, edited"})
+	require.NoError(t, err)
+	assert.Equal(t, "first, edited", edited.Body)
+	assert.NotNil(t, edited.EditedAt)
+	_, err = s.UpdateComment(ctx, "999", first, &model.CommentEditPayload{Body: "moved"})
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	second := strconv.Itoa(int(comments[1].ID))
+	require.NoError(t, s.DeleteComment(ctx, id, second))
+	assert.True(t, errors.Is(s.DeleteComment(ctx, id, second), ErrNotFound))
+
+	// The thread is trashed and restored with its todo_task, without the
+	// comment deleted on its own
+	require.NoError(t, todoTasks.DeleteTodoTask(ctx, id, nil))
+	_, err = s.GetComments(ctx, id, model.CommentListParams{})
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = todoTasks.RestoreTodoTask(ctx, id, nil)
+	require.NoError(t, err)
+	page, err = s.GetComments(ctx, id, model.CommentListParams{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	assert.Equal(t, comments[2].ID, page.Items[1].ID)
+
+	require.NoError(t, todoTasks.PurgeTodoTask(ctx, id, nil))
+	var left int64
+	require.NoError(t, db.Unscoped().Model(&model.Comment{}).Count(&left).Error)
+	assert.Zero(t, left)
+}
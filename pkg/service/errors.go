@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/patch"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+	"gorm.io/gorm"
+)
+
+// Kinds of domain errors returned by the services. Use errors.Is to test
+// an error for a kind; the HTTP layer maps each kind to a status code.
+var (
+	ErrNotFound           = errors.New("resource not found")
+	ErrValidation         = errors.New("validation failed")
+	ErrConflict           = errors.New("conflict")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrUnprocessable      = errors.New("unprocessable request")
+	ErrFailedDependency   = errors.New("failed dependency")
+	ErrTooLarge           = errors.New("content too large")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrForbidden          = errors.New("forbidden")
+	ErrTooManyRequests    = errors.New("too many requests")
+	ErrUnavailable        = errors.New("service unavailable")
+)
+
+// Error is a domain error. Detail and Fields are safe to show to clients,
+// Err is the underlying cause and is only meant for logs.
+type Error struct {
+	Kind   error
+	Detail string
+	Fields []model.FieldError
+	Err    error
+}
+
+func (e *Error) Error() string {
+	msg := e.Kind.Error()
+	if e.Detail != "" {
+		msg = e.Detail
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Is reports whether the error is of the given kind.
+func (e *Error) Is(target error) bool {
+	return e.Kind == target
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// NewError builds a domain error of kind with a client facing detail.
+func NewError(kind error, detail string, err error) *Error {
+	return &Error{Kind: kind, Detail: detail, Err: err}
+}
+
+// NewValidationError builds a validation error for the given fields.
+func NewValidationError(detail string, fields ...model.FieldError) *Error {
+	return &Error{Kind: ErrValidation, Detail: detail, Fields: fields}
+}
+
+// translateError turns errors of the lower layers into domain errors.
+// Errors that already are domain errors are returned unchanged.
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var domainErr *Error
+	if errors.As(err, &domainErr) {
+		return err
+	}
+
+	var validationErr *model.ValidationError
+	switch {
+	case errors.As(err, &validationErr):
+		return &Error{Kind: ErrValidation, Detail: "one or more fields are invalid", Fields: validationErr.Fields, Err: err}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return NewError(ErrNotFound, "the requested resource does not exist", err)
+	case errors.Is(err, repository.ErrInvalidCursor):
+		return &Error{Kind: ErrValidation, Detail: "the cursor is invalid", Err: err,
+			Fields: []model.FieldError{{Field: "cursor", Message: "is not a cursor issued for this query"}}}
+	case errors.Is(err, repository.ErrUnknownProject):
+		return &Error{Kind: ErrValidation, Detail: "invalid project", Err: err,
+			Fields: []model.FieldError{{Field: "project_id", Message: "is not an existing project"}}}
+	case errors.Is(err, repository.ErrProjectArchived):
+		return NewError(ErrConflict, "the project is archived", err)
+	case errors.Is(err, repository.ErrEmailTaken):
+		return &Error{Kind: ErrConflict, Detail: "the email is already registered", Err: err,
+			Fields: []model.FieldError{{Field: "email", Message: "is already registered"}}}
+	case errors.Is(err, repository.ErrLegacyOwnerTaken):
+		return NewError(ErrConflict, "the default workspace already belongs to another user", err)
+	case errors.Is(err, repository.ErrNoWorkspace):
+		return NewError(ErrForbidden, "you are not a member of any workspace", err)
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return NewError(ErrPreconditionFailed, "the resource was modified since it was read", err)
+	case errors.Is(err, patch.ErrTestFailed):
+		return NewError(ErrConflict, "a test operation of the patch failed", err)
+	case errors.Is(err, patch.ErrInvalidPatch):
+		return NewError(ErrValidation, "the patch document is invalid", err)
+	case errors.Is(err, gorm.ErrDuplicatedKey), errors.Is(err, gorm.ErrForeignKeyViolated):
+		return NewError(ErrConflict, "the change conflicts with existing data", err)
+	case isUnavailable(err):
+		return NewError(ErrUnavailable, "the database is unavailable", err)
+	}
+	return err
+}
+
+// isUnavailable reports whether err means the database could not be reached.
+func isUnavailable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, sql.ErrConnDone) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
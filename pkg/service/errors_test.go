@@ -0,0 +1,69 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+	"gorm.io/gorm"
+)
+
+func TestTranslateError(t *testing.T) {
+	tests := []struct {
+		name         string
+		err          error
+		expectedKind error
+	}{
+		{
+			name:         "record not found",
+			err:          fmt.Errorf("getting todo_task: %w", gorm.ErrRecordNotFound),
+			expectedKind: ErrNotFound,
+		},
+		{
+			name:         "invalid payload",
+			err:          (&model.TodoTaskPayload{Description: "d", Status: model.StatusTodo}).ValidateTodoTaskPayload(),
+			expectedKind: ErrValidation,
+		},
+		{
+			name:         "version mismatch",
+			err:          repository.ErrVersionMismatch,
+			expectedKind: ErrPreconditionFailed,
+		},
+		{
+			name:         "duplicated key",
+			err:          gorm.ErrDuplicatedKey,
+			expectedKind: ErrConflict,
+		},
+		{
+			name:         "database down",
+			err:          &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")},
+			expectedKind: ErrUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := translateError(tt.err)
+			assert.ErrorIs(t, err, tt.expectedKind)
+			assert.ErrorIs(t, err, tt.err)
+		})
+	}
+}
+
+func TestTranslateErrorValidationFields(t *testing.T) {
+	err := translateError((&model.TodoTaskPayload{Description: "d", Status: model.StatusTodo}).ValidateTodoTaskPayload())
+
+	var domainErr *Error
+	assert.True(t, errors.As(err, &domainErr))
+	assert.Equal(t, []model.FieldError{{Field: "title", Message: "is required"}}, domainErr.Fields)
+}
+
+func TestTranslateErrorUnknown(t *testing.T) {
+	err := errors.New("boom")
+	assert.Equal(t, err, translateError(err))
+	assert.Nil(t, translateError(nil))
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+// DefaultIdempotencyTTL is used when no positive TTL is configured.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// maxIdempotencyKeyLength is the size of the key column.
+const maxIdempotencyKeyLength = 255
+
+// IdempotencyService deduplicates retried requests. A request first
+// begins its key; when the key is new the request runs and then completes
+// or abandons the key, otherwise the stored response is replayed.
+type IdempotencyService interface {
+	Begin(ctx *gin.Context, key, fingerprint string) (*model.IdempotencyKey, error)
+	Complete(ctx *gin.Context, key string, statusCode int, header map[string]string, body []byte) error
+	Abandon(ctx *gin.Context, key string) error
+	// PurgeExpiredIdempotencyKeys deletes the keys past their expiry and
+	// returns how many were removed.
+	PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error)
+}
+
+func NewIdempotencyService(idempotencyKeyRepository repository.IdempotencyKeyRepository, ttl time.Duration) IdempotencyService {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	return idempotencyService{
+		idempotencyKeyRepository: idempotencyKeyRepository,
+		ttl:                      ttl,
+		now:                      time.Now,
+	}
+}
+
+type idempotencyService struct {
+	idempotencyKeyRepository repository.IdempotencyKeyRepository
+	ttl                      time.Duration
+	now                      func() time.Time
+}
+
+// Begin reserves key for the request identified by fingerprint. It
+// returns nil when the request should run, and the completed key when its
+// response should be replayed instead. A key reused for another request
+// is unprocessable, a key whose first request is still running conflicts.
+func (s idempotencyService) Begin(ctx *gin.Context, key, fingerprint string) (*model.IdempotencyKey, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	if len(key) > maxIdempotencyKeyLength {
+		return nil, NewValidationError("invalid Idempotency-Key header",
+			model.FieldError{Field: "Idempotency-Key", Message: "must be at most 255 characters"})
+	}
+
+	now := s.now()
+	stored, reserved, err := s.idempotencyKeyRepository.ReserveIdempotencyKey(ctx, model.IdempotencyKey{
+		Key:         key,
+		Fingerprint: fingerprint,
+		ExpiresAt:   now.Add(s.ttl),
+	}, now)
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to reserve idempotency_key")
+		return nil, translateError(err)
+	}
+
+	switch {
+	case reserved:
+		return nil, nil
+	case stored.Fingerprint != fingerprint:
+		logger.Info().Msg("idempotency_key reused with a different request")
+		return nil, NewError(ErrUnprocessable, "the Idempotency-Key was already used for a different request", nil)
+	case !stored.Completed():
+		logger.Info().Msg("idempotency_key still in flight")
+		return nil, NewError(ErrConflict, "a request with this Idempotency-Key is still being processed", nil)
+	}
+	logger.Info().Msg("Replaying idempotent response")
+	return &stored, nil
+}
+
+// Complete stores the response to replay for key until it expires.
+func (s idempotencyService) Complete(ctx *gin.Context, key string, statusCode int, header map[string]string, body []byte) error {
+	if err := s.idempotencyKeyRepository.CompleteIdempotencyKey(ctx, key, statusCode, header, body); err != nil {
+		contextLogger.ContextLog(ctx).Error().Err(err).Msg("Fail to complete idempotency_key")
+		return translateError(err)
+	}
+	return nil
+}
+
+// Abandon releases key after a failed request so that it can be retried.
+func (s idempotencyService) Abandon(ctx *gin.Context, key string) error {
+	if err := s.idempotencyKeyRepository.DeleteIdempotencyKey(ctx, key); err != nil {
+		contextLogger.ContextLog(ctx).Error().Err(err).Msg("Fail to abandon idempotency_key")
+		return translateError(err)
+	}
+	return nil
+}
+
+// PurgeExpiredIdempotencyKeys is run by the TrashSweeper, Begin only
+// replaces the expired key it is given.
+func (s idempotencyService) PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	purged, err := s.idempotencyKeyRepository.DeleteExpiredIdempotencyKeys(ctx, s.now())
+	if err != nil {
+		contextLogger.ContextLog(ctx).Error().Err(err).Msg("Fail to purge expired idempotency_keys")
+		return 0, translateError(err)
+	}
+	return purged, nil
+}
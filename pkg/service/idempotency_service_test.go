@@ -0,0 +1,92 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+func TestIdempotencyService(t *testing.T) {
+	db := GetSQLiteDBInstance(t)
+
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/todo_tasks/", nil)
+	now := time.Now()
+	s := NewIdempotencyService(repository.NewIdempotencyKeyRepository(db), time.Hour).(idempotencyService)
+	s.now = func() time.Time { return now }
+
+	stored, err := s.Begin(ctx, "k1", "fp")
+	require.NoError(t, err)
+	assert.Nil(t, stored, "a new key runs the request")
+
+	_, err = s.Begin(ctx, "k1", "fp")
+	assert.True(t, errors.Is(err, ErrConflict), "the first request is still in flight: %v", err)
+
+	require.NoError(t, s.Complete(ctx, "k1", http.StatusOK, map[string]string{"ETag": `"1"`}, []byte(`{"ID":1}`)))
+
+	stored, err = s.Begin(ctx, "k1", "fp")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, http.StatusOK, stored.StatusCode)
+	assert.Equal(t, `"1"`, stored.Header["ETag"])
+	assert.Equal(t, `{"ID":1}`, string(stored.Body))
+
+	_, err = s.Begin(ctx, "k1", "other")
+	assert.True(t, errors.Is(err, ErrUnprocessable), "the key was used for another request: %v", err)
+
+	// Once expired the key is free again, also for another request, while
+	// the other expired keys are left to the sweeper.
+	_, err = s.Begin(ctx, "k2", "fp")
+	require.NoError(t, err)
+	s.now = func() time.Time { return now.Add(2 * time.Hour) }
+	stored, err = s.Begin(ctx, "k1", "other")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+	var count int64
+	require.NoError(t, db.Model(&model.IdempotencyKey{}).Where("key = ?", "k2").Count(&count).Error)
+	assert.Equal(t, int64(1), count)
+	purged, err := s.PurgeExpiredIdempotencyKeys(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	// An abandoned key can be retried right away.
+	require.NoError(t, s.Abandon(ctx, "k1"))
+	stored, err = s.Begin(ctx, "k1", "fp")
+	require.NoError(t, err)
+	assert.Nil(t, stored)
+
+	_, err = s.Begin(ctx, string(make([]byte, 256)), "fp")
+	assert.True(t, errors.Is(err, ErrValidation))
+
+	// Keys only collide within the requests of one user in one workspace
+	in := func(principal auth.Principal) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/todo_tasks/", nil)
+		ctx.Request = ctx.Request.WithContext(auth.WithPrincipal(ctx.Request.Context(), principal))
+		return ctx
+	}
+	ada := in(auth.Principal{UserID: 1, WorkspaceID: 1})
+	_, err = s.Begin(ada, "shared", "ada")
+	require.NoError(t, err)
+	require.NoError(t, s.Complete(ada, "shared", http.StatusOK, nil, []byte(`{"ID":2}`)))
+	for _, other := range []*gin.Context{in(auth.Principal{UserID: 2, WorkspaceID: 1}), in(auth.Principal{UserID: 1, WorkspaceID: 2})} {
+		stored, err = s.Begin(other, "shared", "other")
+		require.NoError(t, err)
+		assert.Nil(t, stored, "the key of another user or workspace is another key")
+		require.NoError(t, s.Abandon(other, "shared"))
+	}
+	stored, err = s.Begin(ada, "shared", "ada")
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.Equal(t, `{"ID":2}`, string(stored.Body))
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+// authorizeTodoTask fails with ErrForbidden unless the role of the caller
+// on the todo_task id allows action. A todo_task the caller has no role on
+// is not found, as it is for every read.
+func authorizeTodoTask(ctx *gin.Context, repo repository.RoleRepository, id string, action auth.Action) error {
+	role, err := repo.GetTodoTaskRole(ctx, id)
+	if err != nil {
+		return err
+	}
+	return authorize(role, action, "todo_task")
+}
+
+// authorizeProject is authorizeTodoTask for the project id.
+func authorizeProject(ctx *gin.Context, repo repository.RoleRepository, id string, action auth.Action) error {
+	role, err := repo.GetProjectRole(ctx, id)
+	if err != nil {
+		return err
+	}
+	return authorize(role, action, "project")
+}
+
+// authorize fails with ErrForbidden unless role allows action on a
+// resource, such as a todo_task.
+func authorize(role auth.Role, action auth.Action, resource string) error {
+	if role.Can(action) {
+		return nil
+	}
+	return NewError(ErrForbidden, fmt.Sprintf("the %s role does not allow the %s action on this %s", role, action, resource), nil)
+}
+
+// formatID formats the id of a todo_task or a project like the ids of the
+// routes.
+func formatID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+// ProjectService manages the projects grouping the todo_tasks. Todo_tasks
+// are put in a project on create or moved to one by TodoTaskService.
+type ProjectService interface {
+	AddProject(ctx *gin.Context, projectPayload *model.ProjectPayload) (model.Project, error)
+	GetProject(ctx *gin.Context, id string) (model.Project, error)
+	GetProjects(ctx *gin.Context, params model.ProjectListParams) (model.ProjectPage, error)
+	UpdateProject(ctx *gin.Context, id string, projectPayload *model.ProjectPayload) (model.Project, error)
+	DeleteProject(ctx *gin.Context, id string) error
+	ArchiveProject(ctx *gin.Context, id string, archived bool) (model.Project, error)
+	GetProjectTodoTasks(ctx *gin.Context, id string, params model.TodoTaskListParams) (model.TodoTaskPage, error)
+}
+
+func NewProjectService(projectRepository repository.ProjectRepository, todoTaskRepository repository.TodoTaskRepository) ProjectService {
+	return projectService{
+		projectRepository,
+		todoTaskRepository,
+	}
+}
+
+type projectService struct {
+	projectRepository  repository.ProjectRepository
+	todoTaskRepository repository.TodoTaskRepository
+}
+
+func (s projectService) AddProject(ctx *gin.Context, projectPayload *model.ProjectPayload) (model.Project, error) {
+	logger := contextLogger.ContextLog(ctx)
+	project, err := s.projectRepository.CreateProject(ctx, projectPayload)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to create project")
+		return model.Project{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully created project")
+	return project, nil
+}
+
+func (s projectService) GetProject(ctx *gin.Context, id string) (model.Project, error) {
+	logger := contextLogger.ContextLog(ctx)
+	project, err := s.projectRepository.GetProject(ctx, id)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get project")
+		return model.Project{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully get project")
+	return project, nil
+}
+
+func (s projectService) GetProjects(ctx *gin.Context, params model.ProjectListParams) (model.ProjectPage, error) {
+	logger := contextLogger.ContextLog(ctx)
+	page, err := s.projectRepository.GetProjects(ctx, params)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get projects")
+		return model.ProjectPage{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully get projects")
+	return page, nil
+}
+
+// UpdateProject, DeleteProject and ArchiveProject take the manage action.
+func (s projectService) UpdateProject(ctx *gin.Context, id string, projectPayload *model.ProjectPayload) (model.Project, error) {
+	logger := contextLogger.ContextLog(ctx)
+	if err := authorizeProject(ctx, s.projectRepository, id, auth.ActionManage); err != nil {
+		logger.Info().Err(err).Msg("project management not allowed")
+		return model.Project{}, translateError(err)
+	}
+	project, err := s.projectRepository.UpdateProject(ctx, id, projectPayload)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to update project")
+		return model.Project{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully update project")
+	return project, nil
+}
+
+// DeleteProject deletes the project and keeps its todo_tasks outside of
+// any project.
+func (s projectService) DeleteProject(ctx *gin.Context, id string) error {
+	logger := contextLogger.ContextLog(ctx)
+	if err := authorizeProject(ctx, s.projectRepository, id, auth.ActionManage); err != nil {
+		logger.Info().Err(err).Msg("project management not allowed")
+		return translateError(err)
+	}
+	err := s.projectRepository.DeleteProject(ctx, id)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to delete project")
+		return translateError(err)
+	}
+	logger.Info().Msg("Successfully delete project")
+	return nil
+}
+
+// ArchiveProject archives the project, and with it all of its todo_tasks,
+// or brings it back when archived is false.
+func (s projectService) ArchiveProject(ctx *gin.Context, id string, archived bool) (model.Project, error) {
+	logger := contextLogger.ContextLog(ctx)
+	if err := authorizeProject(ctx, s.projectRepository, id, auth.ActionManage); err != nil {
+		logger.Info().Err(err).Msg("project management not allowed")
+		return model.Project{}, translateError(err)
+	}
+	project, err := s.projectRepository.ArchiveProject(ctx, id, archived)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to archive project")
+		return model.Project{}, translateError(err)
+	}
+	logger.Info().Bool("archived", archived).Msg("Successfully archive project")
+	return project, nil
+}
+
+// GetProjectTodoTasks lists the todo_tasks of the project, archived or not,
+// with the filters and sort of the todo_tasks list.
+func (s projectService) GetProjectTodoTasks(ctx *gin.Context, id string, params model.TodoTaskListParams) (model.TodoTaskPage, error) {
+	logger := contextLogger.ContextLog(ctx)
+	project, err := s.projectRepository.GetProject(ctx, id)
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get project")
+		return model.TodoTaskPage{}, translateError(err)
+	}
+
+	params.Filter.ProjectID = &project.ID
+	page, err := s.todoTaskRepository.GetTodoTasks(ctx, params)
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get todo_tasks of project")
+		return model.TodoTaskPage{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully get todo_tasks of project")
+	return page, nil
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+func TestProjects(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/projects", nil)
+
+	db := GetSQLiteDBInstance(t)
+	todoTaskRepository := repository.NewTodoTaskRepository(db)
+	todoTasks := NewTodoTaskService(todoTaskRepository, repository.NewStorage(db), nil, "")
+	s := NewProjectService(repository.NewProjectRepository(db), todoTaskRepository)
+
+	home, err := s.AddProject(ctx, &model.ProjectPayload{Name: " Home "})
+	require.NoError(t, err)
+	assert.Equal(t, "Home", home.Name)
+	work, err := s.AddProject(ctx, &model.ProjectPayload{Name: "Work", Description: "d"})
+	require.NoError(t, err)
+	_, err = s.AddProject(ctx, &model.ProjectPayload{Name: " "})
+	assert.True(t, errors.Is(err, ErrValidation))
+
+	add := func(title string, projectID, parentID *uint, status model.TodoTaskStatus) (model.TodoTask, error) {
+		return todoTasks.AddTodoTask(ctx, &model.TodoTaskPayload{
+			Title: title, Description: "d", Status: status, ProjectID: projectID, ParentID: parentID})
+	}
+	mustAdd := func(title string, projectID, parentID *uint, status model.TodoTaskStatus) model.TodoTask {
+		todoTask, err := add(title, projectID, parentID, status)
+		require.NoError(t, err)
+		return todoTask
+	}
+	id := func(todoTask model.TodoTask) string { return strconv.Itoa(int(todoTask.ID)) }
+	titles := func(page model.TodoTaskPage) []string {
+		names := []string{}
+		for _, todoTask := range page.Items {
+			names = append(names, todoTask.Title)
+		}
+		return names
+	}
+
+	// Subtasks are in the project of their parent
+	plan := mustAdd("plan", &work.ID, nil, model.StatusTodo)
+	step := mustAdd("step", nil, &plan.ID, model.StatusDone)
+	assert.Equal(t, &work.ID, step.ProjectID)
+	_, err = add("stray", &home.ID, &plan.ID, model.StatusTodo)
+	assert.True(t, errors.Is(err, ErrValidation))
+	mustAdd("dishes", &home.ID, nil, model.StatusTodo)
+	mustAdd("inbox", nil, nil, model.StatusTodo)
+
+	missing := uint(999)
+	_, err = add("lost", &missing, nil, model.StatusTodo)
+	var domainErr *Error
+	require.True(t, errors.As(err, &domainErr))
+	assert.Equal(t, ErrValidation, domainErr.Kind)
+	assert.Equal(t, "project_id", domainErr.Fields[0].Field)
+
+	project, err := s.GetProject(ctx, strconv.Itoa(int(work.ID)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), project.Counts.Total)
+	assert.Equal(t, map[model.TodoTaskStatus]int64{model.StatusTodo: 1, model.StatusDone: 1}, project.Counts.ByStatus)
+
+	page, err := s.GetProjectTodoTasks(ctx, strconv.Itoa(int(work.ID)), model.TodoTaskListParams{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"plan", "step"}, titles(page))
+	_, err = s.GetProjectTodoTasks(ctx, "999", model.TodoTaskListParams{})
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// The project only changes by moving a top-level todo_task, which takes
+	// its subtasks along
+	_, err = todoTasks.UpdateTodoTask(ctx, id(plan), nil, &model.TodoTaskPayload{
+		Title: "plan", Description: "d", Status: model.StatusTodo, ProjectID: &home.ID})
+	assert.True(t, errors.Is(err, ErrValidation))
+	_, err = todoTasks.MoveTodoTaskToProject(ctx, id(step), nil, &home.ID)
+	assert.True(t, errors.Is(err, ErrValidation))
+	_, err = todoTasks.MoveTodoTaskToProject(ctx, id(plan), []uint{plan.Version + 1}, &home.ID)
+	assert.True(t, errors.Is(err, ErrPreconditionFailed))
+	moved, err := todoTasks.MoveTodoTaskToProject(ctx, id(plan), []uint{plan.Version}, &home.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &home.ID, moved.ProjectID)
+	step, err = todoTasks.GetTodoTask(ctx, id(step))
+	require.NoError(t, err)
+	assert.Equal(t, &home.ID, step.ProjectID)
+
+	// Moving under another parent joins the project of the parent
+	inbox := mustAdd("later", nil, nil, model.StatusTodo)
+	moved, err = todoTasks.MoveTodoTask(ctx, id(inbox), nil, &plan.ID)
+	require.NoError(t, err)
+	assert.Equal(t, &home.ID, moved.ProjectID)
+	moved, err = todoTasks.MoveTodoTask(ctx, id(inbox), nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, &home.ID, moved.ProjectID)
+	moved, err = todoTasks.MoveTodoTaskToProject(ctx, id(inbox), nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, moved.ProjectID)
+
+	// Archiving a project hides its todo_tasks and closes it to new ones
+	archived, err := s.ArchiveProject(ctx, strconv.Itoa(int(home.ID)), true)
+	require.NoError(t, err)
+	require.NotNil(t, archived.ArchivedAt)
+	again, err := s.ArchiveProject(ctx, strconv.Itoa(int(home.ID)), true)
+	require.NoError(t, err)
+	assert.Equal(t, archived.ArchivedAt.Unix(), again.ArchivedAt.Unix())
+
+	page, err = todoTasks.GetTodoTasks(ctx, model.TodoTaskListParams{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"inbox", "later"}, titles(page))
+	page, err = s.GetProjectTodoTasks(ctx, strconv.Itoa(int(home.ID)), model.TodoTaskListParams{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"plan", "step", "dishes"}, titles(page))
+	results, err := todoTasks.SearchTodoTasks(ctx, "plan", 0)
+	require.NoError(t, err)
+	assert.Empty(t, results.Items)
+
+	_, err = add("more", &home.ID, nil, model.StatusTodo)
+	assert.True(t, errors.Is(err, ErrConflict))
+	_, err = todoTasks.MoveTodoTaskToProject(ctx, id(inbox), nil, &home.ID)
+	assert.True(t, errors.Is(err, ErrConflict))
+
+	projects, err := s.GetProjects(ctx, model.ProjectListParams{})
+	require.NoError(t, err)
+	require.Len(t, projects.Items, 1)
+	assert.Equal(t, "Work", projects.Items[0].Name)
+	assert.Zero(t, projects.Items[0].Counts.Total)
+	projects, err = s.GetProjects(ctx, model.ProjectListParams{Archived: true})
+	require.NoError(t, err)
+	require.Len(t, projects.Items, 1)
+	assert.Equal(t, int64(3), projects.Items[0].Counts.Total)
+
+	_, err = s.ArchiveProject(ctx, strconv.Itoa(int(home.ID)), false)
+	require.NoError(t, err)
+	page, err = todoTasks.GetTodoTasks(ctx, model.TodoTaskListParams{})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 5)
+
+	// Deleting a project keeps its todo_tasks outside of any project
+	require.NoError(t, s.DeleteProject(ctx, strconv.Itoa(int(home.ID))))
+	assert.True(t, errors.Is(s.DeleteProject(ctx, strconv.Itoa(int(home.ID))), ErrNotFound))
+	step, err = todoTasks.GetTodoTask(ctx, id(step))
+	require.NoError(t, err)
+	assert.Nil(t, step.ProjectID)
+}
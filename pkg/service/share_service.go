@@ -0,0 +1,210 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+	"gorm.io/gorm"
+)
+
+// ShareService shares projects and todo_tasks with other users and tells
+// the caller what their role on one allows. Anyone with a role sees the
+// shares, changing them takes the share action.
+type ShareService interface {
+	GetTodoTaskPermissions(ctx *gin.Context, id string) (model.Permissions, error)
+	GetTodoTaskShares(ctx *gin.Context, id string) (model.ShareList, error)
+	ShareTodoTask(ctx *gin.Context, id string, sharePayload *model.SharePayload) (model.Share, error)
+	UnshareTodoTask(ctx *gin.Context, id string, userID string) error
+	GetProjectPermissions(ctx *gin.Context, id string) (model.Permissions, error)
+	GetProjectShares(ctx *gin.Context, id string) (model.ShareList, error)
+	ShareProject(ctx *gin.Context, id string, sharePayload *model.SharePayload) (model.Share, error)
+	UnshareProject(ctx *gin.Context, id string, userID string) error
+}
+
+func NewShareService(shareRepository repository.ShareRepository, userRepository repository.UserRepository, workspaceRepository repository.WorkspaceRepository) ShareService {
+	return shareService{
+		shareRepository,
+		userRepository,
+		workspaceRepository,
+	}
+}
+
+type shareService struct {
+	shareRepository     repository.ShareRepository
+	userRepository      repository.UserRepository
+	workspaceRepository repository.WorkspaceRepository
+}
+
+// shareResource is what shares are on.
+type shareResource string
+
+const (
+	sharedTodoTask shareResource = "todo_task"
+	sharedProject  shareResource = "project"
+)
+
+func (s shareService) GetTodoTaskPermissions(ctx *gin.Context, id string) (model.Permissions, error) {
+	return s.getPermissions(ctx, sharedTodoTask, id)
+}
+
+func (s shareService) GetTodoTaskShares(ctx *gin.Context, id string) (model.ShareList, error) {
+	return s.getShares(ctx, sharedTodoTask, id)
+}
+
+func (s shareService) ShareTodoTask(ctx *gin.Context, id string, sharePayload *model.SharePayload) (model.Share, error) {
+	return s.share(ctx, sharedTodoTask, id, sharePayload)
+}
+
+func (s shareService) UnshareTodoTask(ctx *gin.Context, id string, userID string) error {
+	return s.unshare(ctx, sharedTodoTask, id, userID)
+}
+
+func (s shareService) GetProjectPermissions(ctx *gin.Context, id string) (model.Permissions, error) {
+	return s.getPermissions(ctx, sharedProject, id)
+}
+
+func (s shareService) GetProjectShares(ctx *gin.Context, id string) (model.ShareList, error) {
+	return s.getShares(ctx, sharedProject, id)
+}
+
+func (s shareService) ShareProject(ctx *gin.Context, id string, sharePayload *model.SharePayload) (model.Share, error) {
+	return s.share(ctx, sharedProject, id, sharePayload)
+}
+
+func (s shareService) UnshareProject(ctx *gin.Context, id string, userID string) error {
+	return s.unshare(ctx, sharedProject, id, userID)
+}
+
+// getPermissions lists what the role of the caller on the resource allows.
+func (s shareService) getPermissions(ctx *gin.Context, resource shareResource, id string) (model.Permissions, error) {
+	logger := contextLogger.ContextLog(ctx)
+	role, err := s.role(ctx, resource, id)
+
+	if err != nil {
+		logger.Error().Err(err).Str(string(resource)+"_id", id).Msg("Fail to get permissions")
+		return model.Permissions{}, translateError(err)
+	}
+	logger.Info().Str("role", string(role)).Msg("Successfully get permissions")
+	return model.NewPermissions(role), nil
+}
+
+func (s shareService) getShares(ctx *gin.Context, resource shareResource, id string) (model.ShareList, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	target, err := s.authorize(ctx, resource, id, auth.ActionRead)
+	if err != nil {
+		logger.Info().Err(err).Str(string(resource)+"_id", id).Msg("Fail to get shares")
+		return model.ShareList{}, translateError(err)
+	}
+	shares, err := s.shareRepository.GetShares(ctx, target)
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get shares")
+		return model.ShareList{}, translateError(err)
+	}
+	logger.Info().Int("count", len(shares)).Msg("Successfully get shares")
+	return model.ShareList{Items: shares}, nil
+}
+
+// share gives the user registered with the email of sharePayload its role
+// on the resource. The user must be a member of the workspace of the
+// request, no other could see it.
+func (s shareService) share(ctx *gin.Context, resource shareResource, id string, sharePayload *model.SharePayload) (model.Share, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	target, err := s.authorize(ctx, resource, id, auth.ActionShare)
+	if err != nil {
+		logger.Info().Err(err).Str(string(resource)+"_id", id).Msg("Fail to share")
+		return model.Share{}, translateError(err)
+	}
+	if err := sharePayload.ValidateSharePayload(); err != nil {
+		logger.Info().Err(err).Msg("Fail to validate share")
+		return model.Share{}, translateError(err)
+	}
+	user, err := s.userRepository.GetUserByEmail(ctx, sharePayload.Email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.Share{}, NewValidationError("unknown user", model.FieldError{Field: "email", Message: "is not a registered user"})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get user")
+		return model.Share{}, translateError(err)
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		member, err := s.workspaceRepository.IsWorkspaceMember(ctx, principal.WorkspaceID, user.ID)
+		if err != nil {
+			logger.Error().Err(err).Msg("Fail to check workspace member")
+			return model.Share{}, translateError(err)
+		}
+		if !member {
+			return model.Share{}, NewValidationError("unknown user", model.FieldError{Field: "email", Message: "is not a member of the workspace"})
+		}
+	}
+
+	share, err := s.shareRepository.PutShare(ctx, target, user.ID, sharePayload.Role)
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to share")
+		return model.Share{}, translateError(err)
+	}
+	logger.Info().Str(string(resource)+"_id", id).Uint("user_id", user.ID).Msg("Successfully share")
+	return share, nil
+}
+
+// unshare takes the role of the user away. Users may always give up their
+// own role.
+func (s shareService) unshare(ctx *gin.Context, resource shareResource, id string, userID string) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	user, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return translateError(gorm.ErrRecordNotFound)
+	}
+	action := auth.ActionShare
+	if principal, ok := auth.PrincipalFromContext(ctx); ok && uint64(principal.UserID) == user {
+		action = auth.ActionRead
+	}
+	target, err := s.authorize(ctx, resource, id, action)
+	if err != nil {
+		logger.Info().Err(err).Str(string(resource)+"_id", id).Msg("Fail to unshare")
+		return translateError(err)
+	}
+
+	if err := s.shareRepository.DeleteShare(ctx, target, uint(user)); err != nil {
+		logger.Error().Err(err).Msg("Fail to unshare")
+		return translateError(err)
+	}
+	logger.Info().Str(string(resource)+"_id", id).Str("user_id", userID).Msg("Successfully unshare")
+	return nil
+}
+
+// authorize checks that the role of the caller on the resource allows
+// action and returns the target of its shares.
+func (s shareService) authorize(ctx *gin.Context, resource shareResource, id string, action auth.Action) (model.ShareTarget, error) {
+	role, err := s.role(ctx, resource, id)
+	if err != nil {
+		return model.ShareTarget{}, err
+	}
+	if err := authorize(role, action, string(resource)); err != nil {
+		return model.ShareTarget{}, err
+	}
+	// The role was found, so id is the id of an existing resource
+	parsed, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return model.ShareTarget{}, gorm.ErrRecordNotFound
+	}
+	targetID := uint(parsed)
+	if resource == sharedProject {
+		return model.ShareTarget{ProjectID: &targetID}, nil
+	}
+	return model.ShareTarget{TodoTaskID: &targetID}, nil
+}
+
+func (s shareService) role(ctx *gin.Context, resource shareResource, id string) (auth.Role, error) {
+	if resource == sharedProject {
+		return s.shareRepository.GetProjectRole(ctx, id)
+	}
+	return s.shareRepository.GetTodoTaskRole(ctx, id)
+}
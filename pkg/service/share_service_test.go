@@ -0,0 +1,176 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+func TestShares(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := GetSQLiteDBInstance(t)
+	todoTasks := NewTodoTaskService(repository.NewTodoTaskRepository(db), repository.NewStorage(db), nil, "")
+	projects := NewProjectService(repository.NewProjectRepository(db), repository.NewTodoTaskRepository(db))
+	comments := NewCommentService(repository.NewCommentRepository(db))
+	shares := NewShareService(repository.NewShareRepository(db), repository.NewUserRepository(db), repository.NewWorkspaceRepository(db))
+
+	as := func(userID uint) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/todo_tasks", nil)
+		ctx.Request = ctx.Request.WithContext(auth.WithPrincipal(ctx.Request.Context(), auth.Principal{UserID: userID, WorkspaceID: 1}))
+		return ctx
+	}
+	require.NoError(t, db.Create(&model.Workspace{Name: "Team"}).Error)
+	for i, email := range []string{"ada@example.com", "vic@example.com", "cat@example.com", "ed@example.com", "sam@example.com"} {
+		user := model.User{Email: email, PasswordHash: "-"}
+		require.NoError(t, db.Create(&user).Error)
+		if i < 4 {
+			require.NoError(t, db.Create(&model.WorkspaceMember{WorkspaceID: 1, UserID: user.ID}).Error)
+		}
+	}
+	ada, vic, cat, ed, sam := as(1), as(2), as(3), as(4), as(5)
+	payload := func(title string) *model.TodoTaskPayload {
+		return &model.TodoTaskPayload{Title: title, Description: "d", Status: model.StatusTodo}
+	}
+
+	project, err := projects.AddProject(ada, &model.ProjectPayload{Name: "Home"})
+	require.NoError(t, err)
+	projectID := strconv.Itoa(int(project.ID))
+	inProject := payload("dishes")
+	inProject.ProjectID = &project.ID
+	dishes, err := todoTasks.AddTodoTask(ada, inProject)
+	require.NoError(t, err)
+	id := strconv.Itoa(int(dishes.ID))
+	laundry, err := todoTasks.AddTodoTask(ada, payload("laundry"))
+	require.NoError(t, err)
+	laundryID := strconv.Itoa(int(laundry.ID))
+
+	// The owner shares the project, every todo_task of it goes along
+	for _, share := range []model.SharePayload{
+		{Email: " VIC@example.com", Role: auth.RoleViewer},
+		{Email: "cat@example.com", Role: auth.RoleViewer},
+		{Email: "cat@example.com", Role: auth.RoleCommenter},
+		{Email: "ed@example.com", Role: auth.RoleEditor},
+	} {
+		created, err := shares.ShareProject(ada, projectID, &share)
+		require.NoError(t, err, share.Email)
+		assert.Equal(t, share.Role, created.Role)
+	}
+	_, err = shares.ShareProject(ada, projectID, &model.SharePayload{Email: "nobody@example.com", Role: auth.RoleViewer})
+	assert.True(t, errors.Is(err, ErrValidation))
+	_, err = shares.ShareProject(ada, projectID, &model.SharePayload{Email: "sam@example.com", Role: "admin"})
+	assert.True(t, errors.Is(err, ErrValidation))
+	_, err = shares.ShareProject(ada, projectID, &model.SharePayload{Email: "sam@example.com", Role: auth.RoleViewer})
+	assert.True(t, errors.Is(err, ErrValidation), "sam is not a member of the workspace")
+	list, err := shares.GetProjectShares(vic, projectID)
+	require.NoError(t, err)
+	require.Len(t, list.Items, 3)
+	assert.Equal(t, "vic@example.com", list.Items[0].Email)
+	assert.Equal(t, auth.RoleCommenter, list.Items[1].Role, "sharing again changes the role")
+
+	for ctx, want := range map[*gin.Context]auth.Role{ada: auth.RoleOwner, vic: auth.RoleViewer, cat: auth.RoleCommenter, ed: auth.RoleEditor} {
+		permissions, err := shares.GetTodoTaskPermissions(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, want, permissions.Role)
+		assert.Equal(t, want.Actions(), permissions.Actions)
+		_, err = todoTasks.GetTodoTask(ctx, id)
+		assert.NoError(t, err)
+	}
+	_, err = shares.GetTodoTaskPermissions(sam, id)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = todoTasks.GetTodoTask(sam, id)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// Every mutation is checked against the role
+	forbidden := func(err error) bool { return errors.Is(err, ErrForbidden) }
+	_, err = comments.AddComment(vic, id, &model.CommentPayload{Body: "b"})
+	assert.True(t, forbidden(err))
+	comment, err := comments.AddComment(cat, id, &model.CommentPayload{Body: "b"})
+	require.NoError(t, err)
+	require.NotNil(t, comment.AuthorID)
+	assert.Equal(t, uint(3), *comment.AuthorID)
+
+	// Authors change their own comments, editors and owners every comment
+	catsID := strconv.Itoa(int(comment.ID))
+	comment, err = comments.AddComment(ada, id, &model.CommentPayload{Body: "a"})
+	require.NoError(t, err)
+	adasID := strconv.Itoa(int(comment.ID))
+	edit := &model.CommentEditPayload{Body: "edited"}
+	_, err = comments.UpdateComment(cat, id, catsID, edit)
+	assert.NoError(t, err)
+	_, err = comments.UpdateComment(cat, id, adasID, edit)
+	assert.True(t, forbidden(err))
+	assert.True(t, forbidden(comments.DeleteComment(cat, id, adasID)))
+	_, err = comments.UpdateComment(vic, id, catsID, edit)
+	assert.True(t, forbidden(err))
+	assert.True(t, forbidden(comments.DeleteComment(vic, id, catsID)))
+	_, err = comments.UpdateComment(ed, id, catsID, &model.CommentEditPayload{Body: "moderated"})
+	assert.NoError(t, err)
+	assert.NoError(t, comments.DeleteComment(ed, id, adasID))
+	assert.NoError(t, comments.DeleteComment(ada, id, catsID))
+	for _, ctx := range []*gin.Context{vic, cat} {
+		_, err = todoTasks.UpdateTodoTask(ctx, id, nil, payload("mine"))
+		assert.True(t, forbidden(err))
+		_, err = todoTasks.TransitionTodoTask(ctx, id, nil, model.StatusInProgress)
+		assert.True(t, forbidden(err))
+		_, err = todoTasks.AddTodoTask(ctx, inProject)
+		assert.True(t, forbidden(err))
+		assert.True(t, forbidden(todoTasks.DeleteTodoTask(ctx, id, nil)))
+	}
+	_, err = todoTasks.UpdateTodoTask(ed, id, nil, payload("clean dishes"))
+	assert.NoError(t, err)
+	added, err := todoTasks.AddTodoTask(ed, inProject)
+	require.NoError(t, err)
+	_, err = todoTasks.MoveTodoTaskToProject(ed, laundryID, nil, &project.ID)
+	assert.True(t, errors.Is(err, ErrNotFound), "the todo_task is not shared with the editor")
+	_, err = shares.ShareProject(ed, projectID, &model.SharePayload{Email: "sam@example.com", Role: auth.RoleViewer})
+	assert.True(t, forbidden(err))
+	_, err = projects.UpdateProject(ed, projectID, &model.ProjectPayload{Name: "Mine"})
+	assert.True(t, forbidden(err))
+	assert.True(t, forbidden(projects.DeleteProject(ed, projectID)))
+	assert.True(t, forbidden(shares.UnshareProject(ed, projectID, "3")))
+	require.NoError(t, todoTasks.DeleteTodoTask(ed, strconv.Itoa(int(added.ID)), nil))
+
+	// A todo_task shared on its own, the highest role counts
+	_, err = shares.ShareTodoTask(ada, laundryID, &model.SharePayload{Email: "vic@example.com", Role: auth.RoleEditor})
+	require.NoError(t, err)
+	_, err = shares.ShareTodoTask(ada, id, &model.SharePayload{Email: "vic@example.com", Role: auth.RoleEditor})
+	require.NoError(t, err)
+	permissions, err := shares.GetTodoTaskPermissions(vic, id)
+	require.NoError(t, err)
+	assert.Equal(t, auth.RoleEditor, permissions.Role)
+	permissions, err = shares.GetProjectPermissions(vic, projectID)
+	require.NoError(t, err)
+	assert.Equal(t, auth.RoleViewer, permissions.Role)
+	_, err = todoTasks.UpdateTodoTask(vic, laundryID, nil, payload("wash"))
+	assert.NoError(t, err)
+	page, err := todoTasks.GetTodoTasks(vic, model.TodoTaskListParams{})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 2)
+
+	// Users may leave, the owner takes roles away
+	require.NoError(t, shares.UnshareProject(vic, projectID, "2"))
+	_, err = projects.GetProject(vic, projectID)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = todoTasks.GetTodoTask(vic, id)
+	assert.NoError(t, err, "still shared on its own")
+	require.NoError(t, shares.UnshareTodoTask(ada, id, "2"))
+	require.NoError(t, shares.UnshareTodoTask(ada, id, "2"))
+	_, err = todoTasks.GetTodoTask(vic, id)
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	// Deleting the project deletes its shares
+	require.NoError(t, projects.DeleteProject(ada, projectID))
+	var count int64
+	require.NoError(t, db.Model(&model.Share{}).Where("project_id IS NOT NULL").Count(&count).Error)
+	assert.Zero(t, count)
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+// TagService manages the tags. Todo_tasks get their tags through
+// TodoTaskService, which creates missing tags on the fly.
+type TagService interface {
+	AddTag(ctx *gin.Context, tagPayload *model.TagPayload) (model.Tag, error)
+	GetTag(ctx *gin.Context, id string) (model.Tag, error)
+	GetTags(ctx *gin.Context) (model.TagList, error)
+	UpdateTag(ctx *gin.Context, id string, tagPayload *model.TagPayload) (model.Tag, error)
+	DeleteTag(ctx *gin.Context, id string) error
+}
+
+func NewTagService(tagRepository repository.TagRepository) TagService {
+	return tagService{
+		tagRepository,
+	}
+}
+
+type tagService struct {
+	tagRepository repository.TagRepository
+}
+
+func (s tagService) AddTag(ctx *gin.Context, tagPayload *model.TagPayload) (model.Tag, error) {
+	logger := contextLogger.ContextLog(ctx)
+	tag, err := s.tagRepository.CreateTag(ctx, tagPayload)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to create tag")
+		return model.Tag{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully created tag")
+	return tag, nil
+}
+
+func (s tagService) GetTag(ctx *gin.Context, id string) (model.Tag, error) {
+	logger := contextLogger.ContextLog(ctx)
+	tag, err := s.tagRepository.GetTag(ctx, id)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get tag")
+		return model.Tag{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully get tag")
+	return tag, nil
+}
+
+func (s tagService) GetTags(ctx *gin.Context) (model.TagList, error) {
+	logger := contextLogger.ContextLog(ctx)
+	tags, err := s.tagRepository.GetTags(ctx)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get tags")
+		return model.TagList{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully get tags")
+	return model.TagList{Items: tags}, nil
+}
+
+func (s tagService) UpdateTag(ctx *gin.Context, id string, tagPayload *model.TagPayload) (model.Tag, error) {
+	logger := contextLogger.ContextLog(ctx)
+	tag, err := s.tagRepository.UpdateTag(ctx, id, tagPayload)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to update tag")
+		return model.Tag{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully update tag")
+	return tag, nil
+}
+
+func (s tagService) DeleteTag(ctx *gin.Context, id string) error {
+	logger := contextLogger.ContextLog(ctx)
+	err := s.tagRepository.DeleteTag(ctx, id)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to delete tag")
+		return translateError(err)
+	}
+	logger.Info().Msg("Successfully delete tag")
+	return nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+func TestTodoTaskTags(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/todo_tasks/", nil)
+
+	db := GetSQLiteDBInstance(t)
+	s := NewTodoTaskService(repository.NewTodoTaskRepository(db), repository.NewStorage(db), nil, "")
+	tags := NewTagService(repository.NewTagRepository(db))
+
+	add := func(title string, tags ...string) model.TodoTask {
+		todoTask, err := s.AddTodoTask(ctx, &model.TodoTaskPayload{Title: title, Description: "d", Tags: tags})
+		require.NoError(t, err)
+		return todoTask
+	}
+	both := add("both", "Urgent", " home", "urgent")
+	assert.Equal(t, []string{"home", "urgent"}, model.TagNames(both.Tags))
+	add("home", "home")
+	add("none")
+
+	tests := []struct {
+		name   string
+		filter model.TodoTaskFilter
+		titles []string
+	}{
+		{"any", model.TodoTaskFilter{TagsAny: []string{"urgent", "home"}}, []string{"both", "home"}},
+		{"all", model.TodoTaskFilter{TagsAll: []string{"HOME", "urgent"}}, []string{"both"}},
+		{"all of one", model.TodoTaskFilter{TagsAll: []string{"home"}}, []string{"both", "home"}},
+		{"unknown", model.TodoTaskFilter{TagsAny: []string{"work"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := s.GetTodoTasks(ctx, model.TodoTaskListParams{Filter: tt.filter})
+			require.NoError(t, err)
+			var titles []string
+			for _, item := range page.Items {
+				titles = append(titles, item.Title)
+			}
+			assert.Equal(t, tt.titles, titles)
+		})
+	}
+
+	// Tags left out of an update stay, an empty list removes them all
+	id := strconv.Itoa(int(both.ID))
+	updated, err := s.UpdateTodoTask(ctx, id, nil, &model.TodoTaskPayload{Title: "both", Description: "d", Status: model.StatusTodo})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"home", "urgent"}, model.TagNames(updated.Tags))
+	updated, err = s.PatchTodoTask(ctx, id, nil, "application/merge-patch+json", []byte(`{"tags":["work","home"]}`))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"home", "work"}, model.TagNames(updated.Tags))
+	updated, err = s.UpdateTodoTask(ctx, id, nil, &model.TodoTaskPayload{Title: "both", Description: "d", Status: model.StatusTodo, Tags: []string{}})
+	require.NoError(t, err)
+	assert.Empty(t, updated.Tags)
+
+	list, err := tags.GetTags(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"home", "urgent", "work"}, model.TagNames(list.Items))
+
+	_, err = tags.AddTag(ctx, &model.TagPayload{Name: " Home "})
+	assert.True(t, errors.Is(err, ErrConflict))
+	_, err = tags.AddTag(ctx, &model.TagPayload{Name: " "})
+	assert.True(t, errors.Is(err, ErrValidation))
+
+	// Renaming a tag changes the version of the todo_tasks carrying it
+	home, err := s.GetTodoTask(ctx, "2")
+	require.NoError(t, err)
+	renamed, err := tags.UpdateTag(ctx, strconv.Itoa(int(home.Tags[0].ID)), &model.TagPayload{Name: "House"})
+	require.NoError(t, err)
+	assert.Equal(t, "house", renamed.Name)
+	after, err := s.GetTodoTask(ctx, "2")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"house"}, model.TagNames(after.Tags))
+	assert.Equal(t, home.Version+1, after.Version)
+
+	require.NoError(t, tags.DeleteTag(ctx, strconv.Itoa(int(renamed.ID))))
+	after, err = s.GetTodoTask(ctx, "2")
+	require.NoError(t, err)
+	assert.Empty(t, after.Tags)
+	assert.True(t, errors.Is(tags.DeleteTag(ctx, strconv.Itoa(int(renamed.ID))), ErrNotFound))
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+	"gorm.io/gorm"
+)
+
+// errBulkFailed aborts the transaction of an all-or-nothing bulk request.
+var errBulkFailed = errors.New("bulk operation failed")
+
+// BulkTodoTasks runs the operations in order inside one transaction and
+// returns a result per operation and whether the transaction committed.
+// In BulkBestEffort mode every operation runs in its own savepoint, so a
+// failing one is undone alone. In BulkAllOrNothing mode the first failure
+// rolls back the transaction and the remaining operations are not run.
+func (s todoTaskService) BulkTodoTasks(ctx *gin.Context, mode model.BulkMode, operations []model.TodoTaskBulkOperation) ([]model.TodoTaskBulkResult, bool, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	switch {
+	case mode != model.BulkAllOrNothing && mode != model.BulkBestEffort:
+		return nil, false, NewValidationError("invalid bulk mode", model.FieldError{
+			Field: "mode", Message: fmt.Sprintf("must be %s or %s", model.BulkAllOrNothing, model.BulkBestEffort)})
+	case len(operations) == 0:
+		return nil, false, NewValidationError("no bulk operations", model.FieldError{Field: "operations", Message: "must not be empty"})
+	case len(operations) > model.MaxBulkOperations:
+		return nil, false, NewValidationError("too many bulk operations", model.FieldError{
+			Field: "operations", Message: fmt.Sprintf("must contain at most %d operations", model.MaxBulkOperations)})
+	}
+
+	results := make([]model.TodoTaskBulkResult, len(operations))
+	failed := -1
+	err := s.storage.Transaction(func(tx *gorm.DB) error {
+		for i, op := range operations {
+			if mode == model.BulkAllOrNothing {
+				results[i] = s.applyBulkOperation(ctx, repository.NewTodoTaskRepository(tx), op)
+				if results[i].Err != nil {
+					failed = i
+					return errBulkFailed
+				}
+				continue
+			}
+
+			// A nested transaction is a savepoint, rolled back when the operation fails
+			_ = tx.Transaction(func(savepoint *gorm.DB) error {
+				results[i] = s.applyBulkOperation(ctx, repository.NewTodoTaskRepository(savepoint), op)
+				return results[i].Err
+			})
+		}
+		return nil
+	})
+
+	if failed >= 0 {
+		logger.Info().Int("index", failed).Msg("bulk todo_tasks rolled back")
+		for i := range results {
+			if i == failed {
+				continue
+			}
+			results[i] = model.TodoTaskBulkResult{Err: NewError(ErrFailedDependency,
+				fmt.Sprintf("not applied because operation %d failed", failed), nil)}
+		}
+		return results, false, nil
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to run bulk todo_tasks")
+		return nil, false, translateError(err)
+	}
+
+	logger.Info().Int("count", len(operations)).Msg("Successfully run bulk todo_tasks")
+	return results, true, nil
+}
+
+// applyBulkOperation runs a single operation against repo.
+func (s todoTaskService) applyBulkOperation(ctx *gin.Context, repo repository.TodoTaskRepository, op model.TodoTaskBulkOperation) model.TodoTaskBulkResult {
+	var versions []uint
+	if op.Version != nil {
+		versions = []uint{*op.Version}
+	}
+	if op.Op != model.BulkCreate && op.Op != model.BulkUpdate && op.Op != model.BulkDelete {
+		return model.TodoTaskBulkResult{Err: NewValidationError("invalid bulk operation", model.FieldError{
+			Field: "op", Message: fmt.Sprintf("must be %s, %s or %s", model.BulkCreate, model.BulkUpdate, model.BulkDelete)})}
+	}
+	if op.Op != model.BulkCreate && op.ID == "" {
+		return model.TodoTaskBulkResult{Err: NewValidationError("invalid bulk operation",
+			model.FieldError{Field: "id", Message: "is required for " + op.Op})}
+	}
+	if id, err := strconv.ParseUint(op.ID, 10, 0); op.Op != model.BulkCreate && (err != nil || id == 0) {
+		return model.TodoTaskBulkResult{Err: NewValidationError("invalid bulk operation",
+			model.FieldError{Field: "id", Message: "must be a positive integer id"})}
+	}
+	if op.Op != model.BulkDelete && op.Payload == nil {
+		return model.TodoTaskBulkResult{Err: NewValidationError("invalid bulk operation",
+			model.FieldError{Field: "todo_task", Message: "is required for " + op.Op})}
+	}
+
+	var (
+		todoTask model.TodoTask
+		err      error
+	)
+	switch op.Op {
+	case model.BulkCreate:
+		todoTask, err = s.createTodoTask(ctx, repo, op.Payload)
+	case model.BulkUpdate:
+		todoTask, err = s.updateTodoTask(ctx, repo, op.ID, versions, op.Payload)
+	case model.BulkDelete:
+		err = s.deleteTodoTask(ctx, repo, op.ID, versions)
+		return model.TodoTaskBulkResult{Err: translateError(err)}
+	}
+	if err != nil {
+		return model.TodoTaskBulkResult{Err: translateError(err)}
+	}
+	return model.TodoTaskBulkResult{TodoTask: &todoTask}
+}
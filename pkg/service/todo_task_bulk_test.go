@@ -0,0 +1,75 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+func TestBulkTodoTasks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/todo_tasks/bulk", nil)
+
+	stale := uint(7)
+	payload := func(title string) *model.TodoTaskPayload {
+		return &model.TodoTaskPayload{Title: title, Description: "d", Status: model.StatusTodo}
+	}
+	operations := []model.TodoTaskBulkOperation{
+		{Op: model.BulkCreate, Payload: payload("new")},
+		{Op: model.BulkUpdate, ID: "1", Payload: payload("renamed")},
+		{Op: model.BulkDelete, ID: "1", Version: &stale},
+		{Op: model.BulkDelete, ID: "1 OR 1=1"},
+	}
+
+	tests := []struct {
+		mode      model.BulkMode
+		committed bool
+		errs      []error
+		titles    []string
+	}{
+		{model.BulkAllOrNothing, false, []error{ErrFailedDependency, ErrFailedDependency, ErrPreconditionFailed, ErrFailedDependency}, []string{"first"}},
+		{model.BulkBestEffort, true, []error{nil, nil, ErrPreconditionFailed, ErrValidation}, []string{"renamed", "new"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			db := GetSQLiteDBInstance(t)
+			require.NoError(t, db.Create(&model.TodoTask{Title: "first", Description: "d", Status: model.StatusTodo, Version: 1}).Error)
+
+			s := NewTodoTaskService(repository.NewTodoTaskRepository(db), repository.NewStorage(db), nil, "")
+			results, committed, err := s.BulkTodoTasks(ctx, tt.mode, operations)
+			require.NoError(t, err)
+			assert.Equal(t, tt.committed, committed)
+			for i, want := range tt.errs {
+				if want == nil {
+					assert.NoError(t, results[i].Err, "operation %d", i)
+				} else {
+					assert.True(t, errors.Is(results[i].Err, want), "operation %d: %v", i, results[i].Err)
+				}
+			}
+
+			var titles []string
+			require.NoError(t, db.Model(&model.TodoTask{}).Order("id").Pluck("title", &titles).Error)
+			assert.Equal(t, tt.titles, titles)
+		})
+	}
+}
+
+func TestBulkTodoTasksValidation(t *testing.T) {
+	s := NewTodoTaskService(nil, nil, nil, "")
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/todo_tasks/bulk", nil)
+
+	_, _, err := s.BulkTodoTasks(ctx, "sometimes", []model.TodoTaskBulkOperation{{Op: model.BulkDelete, ID: "1"}})
+	assert.True(t, errors.Is(err, ErrValidation))
+
+	_, _, err = s.BulkTodoTasks(ctx, model.BulkBestEffort, nil)
+	assert.True(t, errors.Is(err, ErrValidation))
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+	"gorm.io/gorm"
+)
+
+// AddTodoTaskDependency makes the todo_task blockerID block the todo_task
+// id. The edge is refused when blockerID already depends on id, directly
+// or indirectly, since the two could then never be done. Adding or
+// removing a blocker takes the edit action on the blocked todo_task.
+func (s todoTaskService) AddTodoTaskDependency(ctx *gin.Context, id string, blockerID *uint) (model.TodoTaskDependency, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	if blockerID == nil {
+		return model.TodoTaskDependency{}, NewValidationError("invalid dependency",
+			model.FieldError{Field: "blocker_id", Message: "is required"})
+	}
+
+	var dependency model.TodoTaskDependency
+	err := s.storage.Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewTodoTaskRepository(tx)
+		if err := authorizeTodoTask(ctx, repo, id, auth.ActionEdit); err != nil {
+			return err
+		}
+		blocked, err := repo.GetTodoTask(ctx, id)
+		if err != nil {
+			return err
+		}
+		if _, err := repo.GetTodoTask(ctx, strconv.FormatUint(uint64(*blockerID), 10)); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return NewValidationError("invalid dependency",
+					model.FieldError{Field: "blocker_id", Message: "is not an existing todo_task"})
+			}
+			return err
+		}
+
+		cycle := *blockerID == blocked.ID
+		if !cycle {
+			if cycle, err = repo.IsTodoTaskBlockedBy(ctx, *blockerID, blocked.ID); err != nil {
+				return err
+			}
+		}
+		if cycle {
+			return &Error{
+				Kind:   ErrConflict,
+				Detail: fmt.Sprintf("todo_task %d blocking todo_task %d would create a cycle", *blockerID, blocked.ID),
+				Fields: []model.FieldError{{Field: "blocker_id", Message: "must not be the todo_task itself or depend on it"}},
+			}
+		}
+
+		dependency, err = repo.AddTodoTaskDependency(ctx, *blockerID, blocked.ID)
+		return err
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to add todo_task dependency")
+		return model.TodoTaskDependency{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully add todo_task dependency")
+	return dependency, nil
+}
+
+// DeleteTodoTaskDependency removes the edge "blockerID blocks id".
+func (s todoTaskService) DeleteTodoTaskDependency(ctx *gin.Context, id string, blockerID string) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	blocked, errBlocked := strconv.ParseUint(id, 10, 64)
+	blocker, errBlocker := strconv.ParseUint(blockerID, 10, 64)
+	if errBlocked != nil || errBlocker != nil {
+		return translateError(gorm.ErrRecordNotFound)
+	}
+
+	if err := authorizeTodoTask(ctx, s.todoTaskRepository, id, auth.ActionEdit); err != nil {
+		logger.Info().Err(err).Msg("todo_task edit not allowed")
+		return translateError(err)
+	}
+	if err := s.todoTaskRepository.DeleteTodoTaskDependency(ctx, uint(blocker), uint(blocked)); err != nil {
+		logger.Error().Err(err).Msg("Fail to delete todo_task dependency")
+		return translateError(err)
+	}
+	logger.Info().Msg("Successfully delete todo_task dependency")
+	return nil
+}
+
+// GetTodoTaskDependencyGraph returns the todo_tasks the todo_task depends
+// on and that depend on it, with the edges between them.
+func (s todoTaskService) GetTodoTaskDependencyGraph(ctx *gin.Context, id string) (model.TodoTaskGraph, error) {
+	logger := contextLogger.ContextLog(ctx)
+	graph, err := s.todoTaskRepository.GetTodoTaskDependencyGraph(ctx, id)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get todo_task dependency graph")
+		return model.TodoTaskGraph{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully get todo_task dependency graph")
+	return graph, nil
+}
+
+// checkBlockers fails when the todo_task is about to be done while one of
+// the todo_tasks blocking it is still open.
+func checkBlockers(ctx *gin.Context, repo repository.TodoTaskRepository, id string, from, to model.TodoTaskStatus) error {
+	if to != model.StatusDone || from == model.StatusDone {
+		return nil
+	}
+	blockers, err := repo.GetTodoTaskBlockers(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	var open []string
+	for _, blocker := range blockers {
+		if blocker.Status.Open() {
+			open = append(open, strconv.FormatUint(uint64(blocker.ID), 10))
+		}
+	}
+	if len(open) == 0 {
+		return nil
+	}
+	return &Error{
+		Kind:   ErrConflict,
+		Detail: "a todo_task can not be done while it is blocked by open todo_tasks " + strings.Join(open, ", "),
+		Fields: []model.FieldError{{Field: "status", Message: "blocked by " + strings.Join(open, ", ")}},
+	}
+}
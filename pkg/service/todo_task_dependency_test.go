@@ -0,0 +1,82 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+func TestTodoTaskDependencies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/todo_tasks/", nil)
+
+	db := GetSQLiteDBInstance(t)
+	s := NewTodoTaskService(repository.NewTodoTaskRepository(db), repository.NewStorage(db), nil, "")
+
+	ids := map[string]uint{}
+	for _, title := range []string{"design", "build", "ship", "unrelated"} {
+		todoTask, err := s.AddTodoTask(ctx, &model.TodoTaskPayload{Title: title, Description: "d", Status: model.StatusInProgress})
+		require.NoError(t, err)
+		ids[title] = todoTask.ID
+	}
+	id := func(title string) string { return strconv.Itoa(int(ids[title])) }
+	block := func(blocker, blocked string) error {
+		blockerID := ids[blocker]
+		_, err := s.AddTodoTaskDependency(ctx, id(blocked), &blockerID)
+		return err
+	}
+
+	require.NoError(t, block("design", "build"))
+	require.NoError(t, block("build", "ship"))
+	require.NoError(t, block("build", "ship"), "adding an edge again is a no-op")
+
+	// Edges closing a cycle are refused, whatever its length
+	assert.True(t, errors.Is(block("ship", "design"), ErrConflict))
+	assert.True(t, errors.Is(block("build", "build"), ErrConflict))
+	_, err := s.AddTodoTaskDependency(ctx, id("build"), nil)
+	assert.True(t, errors.Is(err, ErrValidation))
+
+	graph, err := s.GetTodoTaskDependencyGraph(ctx, id("build"))
+	require.NoError(t, err)
+	assert.Equal(t, ids["build"], graph.Root)
+	assert.Len(t, graph.Nodes, 3)
+	assert.Equal(t, []model.TodoTaskDependency{
+		{BlockerID: ids["design"], BlockedID: ids["build"]},
+		{BlockerID: ids["build"], BlockedID: ids["ship"]},
+	}, withoutCreatedAt(graph.Edges))
+	assert.Contains(t, graph.DOT(), strconv.Itoa(int(ids["design"]))+" -> "+id("build")+";")
+
+	// A todo_task is done only once every blocker is closed
+	_, err = s.TransitionTodoTask(ctx, id("build"), nil, model.StatusDone)
+	assert.True(t, errors.Is(err, ErrConflict))
+	_, err = s.PatchTodoTask(ctx, id("build"), nil, "application/merge-patch+json", []byte(`{"status":"done"}`))
+	assert.True(t, errors.Is(err, ErrConflict))
+	_, err = s.TransitionTodoTask(ctx, id("design"), nil, model.StatusCancelled)
+	require.NoError(t, err)
+	_, err = s.TransitionTodoTask(ctx, id("build"), nil, model.StatusDone)
+	require.NoError(t, err)
+
+	require.NoError(t, s.DeleteTodoTaskDependency(ctx, id("ship"), id("build")))
+	assert.True(t, errors.Is(s.DeleteTodoTaskDependency(ctx, id("ship"), id("build")), ErrNotFound))
+	graph, err = s.GetTodoTaskDependencyGraph(ctx, id("ship"))
+	require.NoError(t, err)
+	assert.Len(t, graph.Nodes, 1)
+	assert.Empty(t, graph.Edges)
+}
+
+func withoutCreatedAt(edges []model.TodoTaskDependency) []model.TodoTaskDependency {
+	stripped := make([]model.TodoTaskDependency, len(edges))
+	for i, e := range edges {
+		stripped[i] = model.TodoTaskDependency{BlockerID: e.BlockerID, BlockedID: e.BlockedID}
+	}
+	return stripped
+}
@@ -0,0 +1,54 @@
+package service
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+// GetTodoTaskOccurrences previews up to count occurrences following the
+// todo_task, none when it does not recur or its series has ended.
+func (s todoTaskService) GetTodoTaskOccurrences(ctx *gin.Context, id string, count int) (model.TodoTaskOccurrences, error) {
+	logger := contextLogger.ContextLog(ctx)
+	todoTask, err := s.todoTaskRepository.GetTodoTask(ctx, id)
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get todo_task")
+		return model.TodoTaskOccurrences{}, translateError(err)
+	}
+
+	occurrences, err := todoTask.NextOccurrences(model.PageSize(count))
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get todo_task occurrences")
+		return model.TodoTaskOccurrences{}, translateError(err)
+	}
+	logger.Info().Int("count", len(occurrences)).Msg("Successfully get todo_task occurrences")
+	return model.TodoTaskOccurrences{Items: occurrences}, nil
+}
+
+// completeOccurrence is called with a todo_task just written by repo. When
+// the write completed an occurrence of a recurring todo_task, the next
+// occurrence is created and the series moves over to it: the recurrence of
+// the completed todo_task is cleared, so reopening and completing it again
+// does not repeat the series. Both writes belong in the transaction of repo.
+func (s todoTaskService) completeOccurrence(ctx *gin.Context, repo repository.TodoTaskRepository, from model.TodoTaskStatus, todoTask model.TodoTask) (model.TodoTask, error) {
+	if todoTask.Recurrence == "" || from == model.StatusDone || todoTask.Status != model.StatusDone {
+		return todoTask, nil
+	}
+	logger := contextLogger.ContextLog(ctx)
+
+	next, err := todoTask.NextOccurrence()
+	if err != nil {
+		return model.TodoTask{}, err
+	}
+	if next != nil {
+		created, err := s.createTodoTask(ctx, repo, next)
+		if err != nil {
+			return model.TodoTask{}, err
+		}
+		logger.Info().Uint("todo_task_id", created.ID).Msg("Created next todo_task occurrence")
+	}
+	return repo.PatchTodoTask(ctx, strconv.FormatUint(uint64(todoTask.ID), 10), []uint{todoTask.Version}, map[string]interface{}{"recurrence": ""})
+}
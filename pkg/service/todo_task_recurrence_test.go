@@ -0,0 +1,135 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+func TestTodoTaskNextOccurrences(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	at := func(value string) *time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		require.NoError(t, err)
+		return &parsed
+	}
+
+	tests := []struct {
+		name     string
+		todoTask model.TodoTask
+		due      []string
+	}{
+		{
+			"weekly on monday across daylight saving time",
+			model.TodoTask{Recurrence: "RRULE:FREQ=WEEKLY;BYDAY=MO", Timezone: "Europe/Berlin", DueAt: at("2026-03-23T08:00:00Z")},
+			[]string{"2026-03-30T09:00:00+02:00", "2026-04-06T09:00:00+02:00", "2026-04-13T09:00:00+02:00"},
+		},
+		{
+			"monthly on the last weekday",
+			model.TodoTask{Recurrence: "FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", DueAt: at("2026-01-30T17:00:00Z")},
+			[]string{"2026-02-27T17:00:00Z", "2026-03-31T17:00:00Z", "2026-04-30T17:00:00Z"},
+		},
+		{
+			"count includes the todo_task itself",
+			model.TodoTask{Recurrence: "FREQ=DAILY;COUNT=3", DueAt: at("2026-05-01T10:00:00Z")},
+			[]string{"2026-05-02T10:00:00Z", "2026-05-03T10:00:00Z"},
+		},
+		{
+			"until date includes the whole day",
+			model.TodoTask{Recurrence: "FREQ=DAILY;UNTIL=20260503", Timezone: "Europe/Berlin", DueAt: at("2026-05-01T16:00:00Z")},
+			[]string{"2026-05-02T18:00:00+02:00", "2026-05-03T18:00:00+02:00"},
+		},
+		{
+			"last occurrence of the series",
+			model.TodoTask{Recurrence: "FREQ=DAILY;COUNT=1", DueAt: at("2026-05-01T10:00:00Z")},
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			occurrences, err := tt.todoTask.NextOccurrences(3)
+			require.NoError(t, err)
+			var due []string
+			for _, occurrence := range occurrences {
+				due = append(due, occurrence.DueAt.Format(time.RFC3339))
+			}
+			assert.Equal(t, tt.due, due)
+		})
+	}
+
+	// A start keeps its wall clock distance to the due date
+	todoTask := model.TodoTask{Recurrence: "FREQ=WEEKLY", Timezone: "Europe/Berlin",
+		StartAt: at("2026-03-20T18:00:00+01:00"), DueAt: at("2026-03-23T09:00:00+01:00")}
+	occurrences, err := todoTask.NextOccurrences(1)
+	require.NoError(t, err)
+	require.Len(t, occurrences, 1)
+	assert.Equal(t, time.Date(2026, 3, 27, 18, 0, 0, 0, berlin), *occurrences[0].StartAt)
+	assert.Equal(t, time.Date(2026, 3, 30, 9, 0, 0, 0, berlin), *occurrences[0].DueAt)
+}
+
+func TestRecurringTodoTask(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/todo_tasks/", nil)
+
+	db := GetSQLiteDBInstance(t)
+	s := NewTodoTaskService(repository.NewTodoTaskRepository(db), repository.NewStorage(db), nil, "")
+
+	dueAt := time.Date(2026, 10, 19, 7, 0, 0, 0, time.UTC)
+	for _, invalid := range []model.TodoTaskPayload{
+		{Title: "t", Description: "d", Recurrence: "FREQ=FORTNIGHTLY", DueAt: &dueAt},
+		{Title: "t", Description: "d", Recurrence: "FREQ=MINUTELY", DueAt: &dueAt},
+		{Title: "t", Description: "d", Recurrence: "FREQ=WEEKLY"},
+		{Title: "t", Description: "d", Recurrence: "FREQ=WEEKLY", Timezone: "Mars/Olympus", DueAt: &dueAt},
+	} {
+		_, err := s.AddTodoTask(ctx, &invalid)
+		assert.True(t, errors.Is(err, ErrValidation), invalid.Recurrence)
+	}
+
+	first, err := s.AddTodoTask(ctx, &model.TodoTaskPayload{Title: "bins", Description: "d", Status: model.StatusInProgress,
+		Recurrence: "rrule:freq=weekly;byday=mo;count=2", Timezone: "Europe/Berlin", DueAt: &dueAt})
+	require.NoError(t, err)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO;COUNT=2", first.Recurrence)
+	id := strconv.Itoa(int(first.ID))
+
+	preview, err := s.GetTodoTaskOccurrences(ctx, id, 5)
+	require.NoError(t, err)
+	require.Len(t, preview.Items, 1)
+
+	// Completing an occurrence creates the next one and hands the series over
+	done, err := s.TransitionTodoTask(ctx, id, nil, model.StatusDone)
+	require.NoError(t, err)
+	assert.Empty(t, done.Recurrence)
+	page, err := s.GetTodoTasks(ctx, model.TodoTaskListParams{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 2)
+	second := page.Items[1]
+	assert.Equal(t, model.StatusTodo, second.Status)
+	assert.Equal(t, "FREQ=WEEKLY;BYDAY=MO;COUNT=1", second.Recurrence)
+	assert.True(t, preview.Items[0].DueAt.Equal(*second.DueAt))
+
+	// Reopening and completing again does not repeat the series
+	for _, status := range []model.TodoTaskStatus{model.StatusTodo, model.StatusInProgress, model.StatusDone} {
+		_, err = s.TransitionTodoTask(ctx, id, nil, status)
+		require.NoError(t, err)
+	}
+
+	// The last occurrence of the series creates none
+	for _, status := range []string{"in_progress", "done"} {
+		_, err = s.PatchTodoTask(ctx, strconv.Itoa(int(second.ID)), nil, "application/merge-patch+json", []byte(`{"status":"`+status+`"}`))
+		require.NoError(t, err)
+	}
+	page, err = s.GetTodoTasks(ctx, model.TodoTaskListParams{})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 2)
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+func TestTodoTaskSchedule(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/todo_tasks/", nil)
+
+	db := GetSQLiteDBInstance(t)
+	s := NewTodoTaskService(repository.NewTodoTaskRepository(db), repository.NewStorage(db), nil, "")
+
+	now := time.Now()
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+	add := func(title string, status model.TodoTaskStatus, startAt, dueAt *time.Time) {
+		_, err := s.AddTodoTask(ctx, &model.TodoTaskPayload{Title: title, Description: "d", Status: status, StartAt: startAt, DueAt: dueAt})
+		require.NoError(t, err)
+	}
+	add("late", model.StatusInProgress, nil, at(-72*time.Hour))
+	add("late but done", model.StatusDone, nil, at(-72*time.Hour))
+	add("soon", model.StatusTodo, at(-time.Hour), at(72*time.Hour))
+	add("someday", model.StatusBacklog, nil, nil)
+	add("just done", model.StatusDone, nil, at(0))
+
+	_, err := s.AddTodoTask(ctx, &model.TodoTaskPayload{Title: "t", Description: "d", Status: model.StatusTodo, StartAt: at(time.Hour), DueAt: at(0)})
+	var domainErr *Error
+	require.True(t, errors.As(err, &domainErr))
+	assert.Equal(t, []model.FieldError{{Field: "start_at", Message: "must not be after due_at"}}, domainErr.Fields)
+
+	overdue, notOverdue := true, false
+	tests := []struct {
+		name   string
+		filter model.TodoTaskFilter
+		titles []string
+	}{
+		{"overdue", model.TodoTaskFilter{Overdue: &overdue}, []string{"late"}},
+		{"not overdue", model.TodoTaskFilter{Overdue: &notOverdue}, []string{"late but done", "soon", "someday", "just done"}},
+		{"due before", model.TodoTaskFilter{DueBefore: at(0)}, []string{"late", "late but done"}},
+		{"due after", model.TodoTaskFilter{DueAfter: at(0)}, []string{"soon", "just done"}},
+		{"due today", model.TodoTaskFilter{DueToday: true, Location: time.FixedZone("UTC+14", 14*3600)}, []string{"just done"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := s.GetTodoTasks(ctx, model.TodoTaskListParams{Filter: tt.filter})
+			require.NoError(t, err)
+			var titles []string
+			for _, todoTask := range page.Items {
+				titles = append(titles, todoTask.Title)
+			}
+			assert.Equal(t, tt.titles, titles)
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+// TestSearchTodoTasksWithoutFullText covers the LIKE search used on
+// dialects without full-text search, the Postgres one is covered by the
+// repository tests.
+func TestSearchTodoTasksWithoutFullText(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/todo_tasks/search", nil)
+
+	db := GetSQLiteDBInstance(t)
+	s := NewTodoTaskService(repository.NewTodoTaskRepository(db), repository.NewStorage(db), nil, "")
+
+	add := func(title, description string, tags ...string) model.TodoTask {
+		todoTask, err := s.AddTodoTask(ctx, &model.TodoTaskPayload{Title: title, Description: description, Tags: tags})
+		require.NoError(t, err)
+		return todoTask
+	}
+	add("Pack", "buy Groceries on the way", "errand")
+	add("Buy groceries", "milk and bread", "home")
+	add("Save 100% of it", "no match here")
+	deleted := add("Groceries list", "old")
+	require.NoError(t, s.DeleteTodoTask(ctx, strconv.Itoa(int(deleted.ID)), nil))
+
+	// A title match ranks above a description match, deleted ones are left out
+	results, err := s.SearchTodoTasks(ctx, " GROCERIES ", 10)
+	require.NoError(t, err)
+	require.Len(t, results.Items, 2)
+	assert.Equal(t, "Buy groceries", results.Items[0].Title)
+	assert.Equal(t, "Buy <mark>groceries</mark>", results.Items[0].TitleSnippet)
+	assert.Equal(t, []string{"home"}, model.TagNames(results.Items[0].Tags))
+	assert.Greater(t, results.Items[0].Rank, results.Items[1].Rank)
+	assert.Equal(t, "Pack", results.Items[1].TitleSnippet)
+	assert.Equal(t, "buy <mark>Groceries</mark> on the way", results.Items[1].DescriptionSnippet)
+
+	// LIKE wildcards in the text are matched literally
+	results, err = s.SearchTodoTasks(ctx, "0%", 10)
+	require.NoError(t, err)
+	require.Len(t, results.Items, 1)
+	assert.Equal(t, "Save 100% of it", results.Items[0].Title)
+	results, err = s.SearchTodoTasks(ctx, "o_m", 10)
+	require.NoError(t, err)
+	assert.Empty(t, results.Items)
+
+	// The snippets are HTML escaped, only the <mark> tags are markup
+	add(`<script>alert("x")</script> & more`, "<img src=x onerror=alert(1)>")
+	results, err = s.SearchTodoTasks(ctx, "script", 10)
+	require.NoError(t, err)
+	require.Len(t, results.Items, 1)
+	assert.Equal(t, "&lt;<mark>script</mark>&gt;alert(&#34;x&#34;)&lt;/<mark>script</mark>&gt; &amp; more", results.Items[0].TitleSnippet)
+	assert.Equal(t, "&lt;img src=x onerror=alert(1)&gt;", results.Items[0].DescriptionSnippet)
+
+	results, err = s.SearchTodoTasks(ctx, "groceries", 1)
+	require.NoError(t, err)
+	assert.Len(t, results.Items, 1)
+
+	_, err = s.SearchTodoTasks(ctx, " ", 10)
+	assert.Error(t, err)
+}
@@ -1,52 +1,115 @@
 package service
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
 	"github.com/gin-gonic/gin"
 	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
 	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/patch"
 	"github.com/vkuzmich/gin-project/pkg/repository"
+	"gorm.io/gorm"
 )
 
-// TodoTaskService service represents process of data.
+// TodoTaskService service represents process of data. Mutations take the
+// row versions the client accepts (from If-Match), nil means unconditional.
 type TodoTaskService interface {
 	AddTodoTask(c *gin.Context, todoTaskPayload *model.TodoTaskPayload) (model.TodoTask, error)
-	DeleteTodoTask(c *gin.Context, id string) error
+	DeleteTodoTask(c *gin.Context, id string, versions []uint) error
 	GetTodoTask(ctx *gin.Context, id string) (model.TodoTask, error)
-	GetTodoTasks(ctx *gin.Context) ([]model.TodoTask, error)
-	UpdateTodoTask(ctx *gin.Context, id string, todoTaskPayload *model.TodoTaskPayload) (model.TodoTask, error)
+	GetTodoTasks(ctx *gin.Context, params model.TodoTaskListParams) (model.TodoTaskPage, error)
+	SearchTodoTasks(ctx *gin.Context, text string, limit int) (model.TodoTaskSearchResults, error)
+	UpdateTodoTask(ctx *gin.Context, id string, versions []uint, todoTaskPayload *model.TodoTaskPayload) (model.TodoTask, error)
+	PatchTodoTask(ctx *gin.Context, id string, versions []uint, contentType string, patchDocument []byte) (model.TodoTask, error)
+	GetTrashedTodoTasks(ctx *gin.Context, params model.TodoTaskListParams) (model.TodoTaskPage, error)
+	RestoreTodoTask(ctx *gin.Context, id string, versions []uint) (model.TodoTask, error)
+	PurgeTodoTask(ctx *gin.Context, id string, versions []uint) error
+	BulkTodoTasks(ctx *gin.Context, mode model.BulkMode, operations []model.TodoTaskBulkOperation) ([]model.TodoTaskBulkResult, bool, error)
+	TransitionTodoTask(ctx *gin.Context, id string, versions []uint, status model.TodoTaskStatus) (model.TodoTask, error)
+	GetTodoTaskTree(ctx *gin.Context, id string) (model.TodoTaskNode, error)
+	MoveTodoTask(ctx *gin.Context, id string, versions []uint, parentID *uint) (model.TodoTask, error)
+	MoveTodoTaskToProject(ctx *gin.Context, id string, versions []uint, projectID *uint) (model.TodoTask, error)
+	AddTodoTaskDependency(ctx *gin.Context, id string, blockerID *uint) (model.TodoTaskDependency, error)
+	DeleteTodoTaskDependency(ctx *gin.Context, id string, blockerID string) error
+	GetTodoTaskDependencyGraph(ctx *gin.Context, id string) (model.TodoTaskGraph, error)
+	GetTodoTaskOccurrences(ctx *gin.Context, id string, count int) (model.TodoTaskOccurrences, error)
 }
 
-func NewTodoTaskService(todoTaskRepository repository.TodoTaskRepository) TodoTaskService {
+// NewTodoTaskService builds the service. Status changes must follow
+// workflow, nil means model.DefaultTodoTaskWorkflow. Deleting a todo_task
+// handles its subtasks according to deletePolicy, empty means
+// model.DefaultSubtaskDeletePolicy.
+func NewTodoTaskService(todoTaskRepository repository.TodoTaskRepository, storage repository.Storage, workflow model.TodoTaskWorkflow, deletePolicy model.SubtaskDeletePolicy) TodoTaskService {
+	if workflow == nil {
+		workflow = model.DefaultTodoTaskWorkflow
+	}
+	if deletePolicy == "" {
+		deletePolicy = model.DefaultSubtaskDeletePolicy
+	}
 	return todoTaskService{
 		todoTaskRepository,
+		storage,
+		workflow,
+		deletePolicy,
 	}
 }
 
 type todoTaskService struct {
 	todoTaskRepository repository.TodoTaskRepository
+	storage            repository.Storage // runs the transactions spanning several writes
+	workflow           model.TodoTaskWorkflow
+	deletePolicy       model.SubtaskDeletePolicy
 }
 
 // AddTodoTask is a handler function for adding a new todoTask.
 func (s todoTaskService) AddTodoTask(ctx *gin.Context, todoTaskPayload *model.TodoTaskPayload) (model.TodoTask, error) {
 	logger := contextLogger.ContextLog(ctx)
-	todoTask, err := s.todoTaskRepository.CreateTodoTask(ctx, todoTaskPayload)
+	todoTask, err := s.createTodoTask(ctx, s.todoTaskRepository, todoTaskPayload)
 
 	if err != nil {
 		logger.Error().Err(err).Msg("Fail to create todo_task")
-		return model.TodoTask{}, err
+		return model.TodoTask{}, translateError(err)
 	}
 	logger.Info().Msg("Successfully created todo_task")
 	return todoTask, nil
 }
 
-func (s todoTaskService) DeleteTodoTask(ctx *gin.Context, id string) error {
+// createTodoTask adds a todo_task through repo, under its parent if one is
+// given. A subtask is always in the project of its parent. Adding a
+// todo_task under a parent or to a project takes the edit action on it.
+func (s todoTaskService) createTodoTask(ctx *gin.Context, repo repository.TodoTaskRepository, todoTaskPayload *model.TodoTaskPayload) (model.TodoTask, error) {
+	parent, err := checkParent(ctx, repo, "", todoTaskPayload.ParentID)
+	if err != nil {
+		return model.TodoTask{}, err
+	}
+	if err := authorizeDestination(ctx, repo, parent, todoTaskPayload.ProjectID); err != nil {
+		return model.TodoTask{}, err
+	}
+	if parent != nil {
+		if todoTaskPayload.ProjectID != nil && !sameID(todoTaskPayload.ProjectID, parent.ProjectID) {
+			return model.TodoTask{}, NewValidationError("invalid project", model.FieldError{
+				Field: "project_id", Message: "must be the project of the parent"})
+		}
+		todoTaskPayload.ProjectID = parent.ProjectID
+	}
+	return repo.CreateTodoTask(ctx, todoTaskPayload)
+}
+
+func (s todoTaskService) DeleteTodoTask(ctx *gin.Context, id string, versions []uint) error {
 	logger := contextLogger.ContextLog(ctx)
 
-	err := s.todoTaskRepository.DeleteTodoTask(ctx, id)
+	err := s.storage.Transaction(func(tx *gorm.DB) error {
+		return s.deleteTodoTask(ctx, repository.NewTodoTaskRepository(tx), id, versions)
+	})
 
 	if err != nil {
 		logger.Error().Err(err).Msg("Fail to delete todo_task")
-		return err
+		return translateError(err)
 	}
 	logger.Info().Msg("Successfully delete todo_task")
 	return nil
@@ -58,33 +121,292 @@ func (s todoTaskService) GetTodoTask(ctx *gin.Context, id string) (model.TodoTas
 
 	if err != nil {
 		logger.Error().Err(err).Msg("Fail to get todo_task")
-		return model.TodoTask{}, err
+		return model.TodoTask{}, translateError(err)
 	}
 	logger.Info().Msg("Successfully get todo_task")
 	return todoTask, nil
 }
 
-func (s todoTaskService) GetTodoTasks(ctx *gin.Context) ([]model.TodoTask, error) {
+func (s todoTaskService) GetTodoTasks(ctx *gin.Context, params model.TodoTaskListParams) (model.TodoTaskPage, error) {
 	logger := contextLogger.ContextLog(ctx)
-	todoTask, err := s.todoTaskRepository.GetTodoTasks(ctx)
+	page, err := s.todoTaskRepository.GetTodoTasks(ctx, params)
 
 	if err != nil {
 		logger.Error().Err(err).Msg("Fail to get todo_tasks")
-		return []model.TodoTask{}, err
+		return model.TodoTaskPage{}, translateError(err)
 	}
 	logger.Info().Msg("Successfully get todo_tasks")
+	return page, nil
+}
+
+func (s todoTaskService) SearchTodoTasks(ctx *gin.Context, text string, limit int) (model.TodoTaskSearchResults, error) {
+	logger := contextLogger.ContextLog(ctx)
+	results, err := s.todoTaskRepository.SearchTodoTasks(ctx, text, limit)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to search todo_tasks")
+		return model.TodoTaskSearchResults{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully search todo_tasks")
+	return model.TodoTaskSearchResults{Items: results}, nil
+}
+
+func (s todoTaskService) UpdateTodoTask(ctx *gin.Context, id string, versions []uint, todoTaskPayload *model.TodoTaskPayload) (model.TodoTask, error) {
+	logger := contextLogger.ContextLog(ctx)
+	var todoTask model.TodoTask
+	err := s.storage.Transaction(func(tx *gorm.DB) (err error) {
+		todoTask, err = s.updateTodoTask(ctx, repository.NewTodoTaskRepository(tx), id, versions, todoTaskPayload)
+		return err
+	})
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get todo_task")
+		return model.TodoTask{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully get todo_task")
+
 	return todoTask, nil
 }
 
-func (s todoTaskService) UpdateTodoTask(ctx *gin.Context, id string, todoTaskPayload *model.TodoTaskPayload) (model.TodoTask, error) {
+// updateTodoTask replaces the fields of a todo_task through repo. A status
+// change must be allowed by the workflow, so the current todo_task is read
+// first and the write is conditional on the version that was checked.
+// Completing a recurring todo_task creates its next occurrence.
+func (s todoTaskService) updateTodoTask(ctx *gin.Context, repo repository.TodoTaskRepository, id string, versions []uint, todoTaskPayload *model.TodoTaskPayload) (model.TodoTask, error) {
+	if err := authorizeTodoTask(ctx, repo, id, auth.ActionEdit); err != nil {
+		return model.TodoTask{}, err
+	}
+	current, err := repo.GetTodoTask(ctx, id)
+	if err != nil {
+		return model.TodoTask{}, err
+	}
+	if versions != nil && !slices.Contains(versions, current.Version) {
+		return model.TodoTask{}, repository.ErrVersionMismatch
+	}
+	if err := s.checkTransition(current.Status, todoTaskPayload.Status); err != nil {
+		return model.TodoTask{}, err
+	}
+	if err := checkBlockers(ctx, repo, id, current.Status, todoTaskPayload.Status); err != nil {
+		return model.TodoTask{}, err
+	}
+	if err := checkParentUnchanged(current, todoTaskPayload.ParentID); err != nil {
+		return model.TodoTask{}, err
+	}
+	if err := checkProjectUnchanged(current, todoTaskPayload.ProjectID); err != nil {
+		return model.TodoTask{}, err
+	}
+	todoTask, err := repo.UpdateTodoTask(ctx, id, []uint{current.Version}, todoTaskPayload)
+	if err != nil {
+		return model.TodoTask{}, err
+	}
+	return s.completeOccurrence(ctx, repo, current.Status, todoTask)
+}
+
+// TransitionTodoTask moves a todo_task to another status of the workflow.
+// Completing a recurring todo_task creates its next occurrence.
+func (s todoTaskService) TransitionTodoTask(ctx *gin.Context, id string, versions []uint, status model.TodoTaskStatus) (model.TodoTask, error) {
 	logger := contextLogger.ContextLog(ctx)
-	todoTask, err := s.todoTaskRepository.UpdateTodoTask(ctx, id, todoTaskPayload)
 
+	if !status.Valid() {
+		return model.TodoTask{}, NewValidationError("invalid status", model.FieldError{
+			Field: "status", Message: "must be one of: " + joinStatuses(model.TodoTaskStatuses)})
+	}
+
+	if err := authorizeTodoTask(ctx, s.todoTaskRepository, id, auth.ActionEdit); err != nil {
+		logger.Info().Err(err).Msg("todo_task edit not allowed")
+		return model.TodoTask{}, translateError(err)
+	}
+	todoTask, err := s.todoTaskRepository.GetTodoTask(ctx, id)
 	if err != nil {
 		logger.Error().Err(err).Msg("Fail to get todo_task")
+		return model.TodoTask{}, translateError(err)
+	}
+	if versions != nil && !slices.Contains(versions, todoTask.Version) {
+		logger.Info().Uint("version", todoTask.Version).Msg("todo_task version mismatch")
+		return model.TodoTask{}, translateError(repository.ErrVersionMismatch)
+	}
+	if err := s.checkTransition(todoTask.Status, status); err != nil {
+		logger.Info().Err(err).Msg("todo_task transition not allowed")
 		return model.TodoTask{}, err
 	}
-	logger.Info().Msg("Successfully get todo_task")
+	if err := checkBlockers(ctx, s.todoTaskRepository, id, todoTask.Status, status); err != nil {
+		logger.Info().Err(err).Msg("todo_task is blocked")
+		return model.TodoTask{}, translateError(err)
+	}
+	if todoTask.Status == status {
+		return todoTask, nil
+	}
 
+	err = s.storage.Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewTodoTaskRepository(tx)
+		from := todoTask.Status
+		todoTask, err = repo.PatchTodoTask(ctx, id, []uint{todoTask.Version}, map[string]interface{}{"status": status})
+		if err != nil {
+			return err
+		}
+		todoTask, err = s.completeOccurrence(ctx, repo, from, todoTask)
+		return err
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to transition todo_task")
+		return model.TodoTask{}, translateError(err)
+	}
+	logger.Info().Str("status", string(status)).Msg("Successfully transition todo_task")
 	return todoTask, nil
 }
+
+// checkTransition fails when the workflow does not allow moving from one
+// status to the other. Invalid target statuses are left to validation.
+func (s todoTaskService) checkTransition(from, to model.TodoTaskStatus) error {
+	if !to.Valid() || s.workflow.CanTransition(from, to) {
+		return nil
+	}
+	allowed := "none"
+	if len(s.workflow[from]) > 0 {
+		allowed = joinStatuses(s.workflow[from])
+	}
+	return &Error{
+		Kind:   ErrConflict,
+		Detail: fmt.Sprintf("a todo_task can not move from %s to %s", from, to),
+		Fields: []model.FieldError{{Field: "status", Message: fmt.Sprintf("allowed from %s: %s", from, allowed)}},
+	}
+}
+
+func joinStatuses(statuses []model.TodoTaskStatus) string {
+	names := make([]string, len(statuses))
+	for i, s := range statuses {
+		names[i] = string(s)
+	}
+	return strings.Join(names, ", ")
+}
+
+// PatchTodoTask applies a JSON Merge Patch or JSON Patch document to the
+// stored todo_task, validates the result and persists the changed columns.
+// The write is conditional on the version the patch was applied to, so a
+// concurrent update makes it fail instead of being overwritten.
+func (s todoTaskService) PatchTodoTask(ctx *gin.Context, id string, versions []uint, contentType string, patchDocument []byte) (model.TodoTask, error) {
+	logger := contextLogger.ContextLog(ctx)
+	if err := authorizeTodoTask(ctx, s.todoTaskRepository, id, auth.ActionEdit); err != nil {
+		logger.Info().Err(err).Msg("todo_task edit not allowed")
+		return model.TodoTask{}, translateError(err)
+	}
+	todoTask, err := s.todoTaskRepository.GetTodoTask(ctx, id)
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get todo_task")
+		return model.TodoTask{}, translateError(err)
+	}
+	if versions != nil && !slices.Contains(versions, todoTask.Version) {
+		logger.Info().Uint("version", todoTask.Version).Msg("todo_task version mismatch")
+		return model.TodoTask{}, translateError(repository.ErrVersionMismatch)
+	}
+
+	current := todoTask.Payload()
+	document, err := json.Marshal(current)
+	if err != nil {
+		return model.TodoTask{}, translateError(err)
+	}
+	patched, err := patch.Apply(contentType, document, patchDocument)
+	if err != nil {
+		logger.Info().Err(err).Msg("Fail to apply patch to todo_task")
+		return model.TodoTask{}, translateError(err)
+	}
+
+	// Only the fields of the payload may be patched, anything else is an error
+	var payload model.TodoTaskPayload
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&payload); err != nil {
+		logger.Info().Err(err).Msg("Patched todo_task is not a valid payload")
+		return model.TodoTask{}, translateError(fmt.Errorf("%w: %v", patch.ErrInvalidPatch, err))
+	}
+	if err := payload.ValidateTodoTaskPayload(); err != nil {
+		logger.Info().Err(err).Msg("Patched todo_task fails validation")
+		return model.TodoTask{}, translateError(err)
+	}
+	if err := s.checkTransition(current.Status, payload.Status); err != nil {
+		logger.Info().Err(err).Msg("todo_task transition not allowed")
+		return model.TodoTask{}, err
+	}
+	if err := checkBlockers(ctx, s.todoTaskRepository, id, current.Status, payload.Status); err != nil {
+		logger.Info().Err(err).Msg("todo_task is blocked")
+		return model.TodoTask{}, translateError(err)
+	}
+	if err := checkParentUnchanged(todoTask, payload.ParentID); err != nil {
+		logger.Info().Err(err).Msg("Patch moves todo_task")
+		return model.TodoTask{}, err
+	}
+	if err := checkProjectUnchanged(todoTask, payload.ProjectID); err != nil {
+		logger.Info().Err(err).Msg("Patch moves todo_task to another project")
+		return model.TodoTask{}, err
+	}
+
+	changes := payload.Changes(current)
+	if len(changes) == 0 {
+		logger.Info().Msg("Patch does not change todo_task")
+		return todoTask, nil
+	}
+
+	err = s.storage.Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewTodoTaskRepository(tx)
+		todoTask, err = repo.PatchTodoTask(ctx, id, []uint{todoTask.Version}, changes)
+		if err != nil {
+			return err
+		}
+		todoTask, err = s.completeOccurrence(ctx, repo, current.Status, todoTask)
+		return err
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to patch todo_task")
+		return model.TodoTask{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully patch todo_task")
+	return todoTask, nil
+}
+
+func (s todoTaskService) GetTrashedTodoTasks(ctx *gin.Context, params model.TodoTaskListParams) (model.TodoTaskPage, error) {
+	logger := contextLogger.ContextLog(ctx)
+	page, err := s.todoTaskRepository.GetTrashedTodoTasks(ctx, params)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get trashed todo_tasks")
+		return model.TodoTaskPage{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully get trashed todo_tasks")
+	return page, nil
+}
+
+// RestoreTodoTask takes a todo_task out of the trash, which like moving it
+// there takes the delete action.
+func (s todoTaskService) RestoreTodoTask(ctx *gin.Context, id string, versions []uint) (model.TodoTask, error) {
+	logger := contextLogger.ContextLog(ctx)
+	if err := authorizeTodoTask(ctx, s.todoTaskRepository, id, auth.ActionDelete); err != nil {
+		logger.Info().Err(err).Msg("todo_task restore not allowed")
+		return model.TodoTask{}, translateError(err)
+	}
+	todoTask, err := s.todoTaskRepository.RestoreTodoTask(ctx, id, versions)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to restore todo_task")
+		return model.TodoTask{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully restore todo_task")
+	return todoTask, nil
+}
+
+// PurgeTodoTask permanently deletes a todo_task, bypassing the trash.
+func (s todoTaskService) PurgeTodoTask(ctx *gin.Context, id string, versions []uint) error {
+	logger := contextLogger.ContextLog(ctx)
+	err := s.storage.Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewTodoTaskRepository(tx)
+		return s.removeTodoTask(ctx, repo, id, versions, func(id string, versions []uint) error {
+			return repo.PurgeTodoTask(ctx, id, versions)
+		})
+	})
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to purge todo_task")
+		return translateError(err)
+	}
+	logger.Info().Msg("Successfully purge todo_task")
+	return nil
+}
@@ -2,8 +2,14 @@ package service
 
 import (
 	"fmt"
+	"net/url"
+	"testing"
+
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/db"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -28,3 +34,17 @@ func GetMockedDBInstance() (*gorm.DB, sqlmock.Sqlmock) {
 	}
 	return mockedDB, mock
 }
+
+// GetSQLiteDBInstance returns an in-memory SQLite database of the test,
+// migrated like the application database. The connections of the pool all
+// see the same database, it is dropped when the test ends.
+func GetSQLiteDBInstance(t *testing.T) *gorm.DB {
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", url.PathEscape(t.Name()))
+	sqliteDB, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{TranslateError: true})
+	require.NoError(t, err)
+	conn, err := sqliteDB.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	require.NoError(t, db.AutoMigration(sqliteDB))
+	return sqliteDB
+}
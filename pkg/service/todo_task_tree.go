@@ -0,0 +1,242 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+	"gorm.io/gorm"
+)
+
+// GetTodoTaskTree returns the todo_task with all of its subtasks and how
+// many of them are done.
+func (s todoTaskService) GetTodoTaskTree(ctx *gin.Context, id string) (model.TodoTaskNode, error) {
+	logger := contextLogger.ContextLog(ctx)
+	todoTasks, err := s.todoTaskRepository.GetTodoTaskTree(ctx, id)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get todo_task tree")
+		return model.TodoTaskNode{}, translateError(err)
+	}
+	logger.Info().Int("count", len(todoTasks)).Msg("Successfully get todo_task tree")
+	return model.NewTodoTaskTree(todoTasks), nil
+}
+
+// MoveTodoTask puts the todo_task under another parent, nil makes it a
+// top-level todo_task. A todo_task can not be moved below itself. Under its
+// new parent the todo_task and its subtasks join the project of the parent.
+// Moving takes the edit action on the todo_task and on its new parent.
+func (s todoTaskService) MoveTodoTask(ctx *gin.Context, id string, versions []uint, parentID *uint) (model.TodoTask, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var todoTask model.TodoTask
+	err := s.storage.Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewTodoTaskRepository(tx)
+		if err := authorizeTodoTask(ctx, repo, id, auth.ActionEdit); err != nil {
+			return err
+		}
+		current, err := repo.GetTodoTask(ctx, id)
+		if err != nil {
+			return err
+		}
+		if versions != nil && !slices.Contains(versions, current.Version) {
+			return repository.ErrVersionMismatch
+		}
+		if sameID(current.ParentID, parentID) {
+			todoTask = current
+			return nil
+		}
+		parent, err := checkParent(ctx, repo, id, parentID)
+		if err != nil {
+			return err
+		}
+		if err := authorizeDestination(ctx, repo, parent, nil); err != nil {
+			return err
+		}
+		changes := map[string]interface{}{"parent_id": parentID}
+		if parent != nil && !sameID(current.ProjectID, parent.ProjectID) {
+			changes["project_id"] = parent.ProjectID
+		}
+		todoTask, err = repo.PatchTodoTask(ctx, id, []uint{current.Version}, changes)
+		return err
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to move todo_task")
+		return model.TodoTask{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully move todo_task")
+	return todoTask, nil
+}
+
+// MoveTodoTaskToProject puts a top-level todo_task and all of its subtasks
+// in another project, nil takes them out of any project. Subtasks follow
+// their parent, so they can only change project by being moved. Moving
+// takes the edit action on the todo_task and on its new project.
+func (s todoTaskService) MoveTodoTaskToProject(ctx *gin.Context, id string, versions []uint, projectID *uint) (model.TodoTask, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var todoTask model.TodoTask
+	err := s.storage.Transaction(func(tx *gorm.DB) error {
+		repo := repository.NewTodoTaskRepository(tx)
+		if err := authorizeTodoTask(ctx, repo, id, auth.ActionEdit); err != nil {
+			return err
+		}
+		current, err := repo.GetTodoTask(ctx, id)
+		if err != nil {
+			return err
+		}
+		if versions != nil && !slices.Contains(versions, current.Version) {
+			return repository.ErrVersionMismatch
+		}
+		if sameID(current.ProjectID, projectID) {
+			todoTask = current
+			return nil
+		}
+		if current.ParentID != nil {
+			return NewValidationError("a subtask is in the project of its parent", model.FieldError{
+				Field: "project_id", Message: "can only be changed by moving the subtask under another parent"})
+		}
+		if err := authorizeDestination(ctx, repo, nil, projectID); err != nil {
+			return err
+		}
+		todoTask, err = repo.PatchTodoTask(ctx, id, []uint{current.Version}, map[string]interface{}{"project_id": projectID})
+		return err
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to move todo_task to project")
+		return model.TodoTask{}, translateError(err)
+	}
+	logger.Info().Msg("Successfully move todo_task to project")
+	return todoTask, nil
+}
+
+// checkParent returns the parent, nil when parentID is nil. It fails when
+// parentID is not a live todo_task or, when id is given, when the parent is
+// the todo_task itself or one of its subtasks.
+func checkParent(ctx *gin.Context, repo repository.TodoTaskRepository, id string, parentID *uint) (*model.TodoTask, error) {
+	if parentID == nil {
+		return nil, nil
+	}
+	parent := strconv.FormatUint(uint64(*parentID), 10)
+	parentTask, err := repo.GetTodoTask(ctx, parent)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, NewValidationError("invalid parent", model.FieldError{Field: "parent_id", Message: "is not an existing todo_task"})
+		}
+		return nil, err
+	}
+	if id == "" {
+		return &parentTask, nil
+	}
+
+	subtree, err := repo.GetTodoTaskTree(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if slices.ContainsFunc(subtree, func(t model.TodoTask) bool { return t.ID == *parentID }) {
+		return nil, &Error{
+			Kind:   ErrConflict,
+			Detail: fmt.Sprintf("moving todo_task %s under %s would create a cycle", id, parent),
+			Fields: []model.FieldError{{Field: "parent_id", Message: "must not be the todo_task itself or one of its subtasks"}},
+		}
+	}
+	return &parentTask, nil
+}
+
+// authorizeDestination checks the edit action on the parent a todo_task is
+// put under or, without a parent, on the project it is put in. A project
+// the caller has no role on is unknown.
+func authorizeDestination(ctx *gin.Context, repo repository.RoleRepository, parent *model.TodoTask, projectID *uint) error {
+	if parent != nil {
+		return authorizeTodoTask(ctx, repo, formatID(parent.ID), auth.ActionEdit)
+	}
+	if projectID == nil {
+		return nil
+	}
+	err := authorizeProject(ctx, repo, formatID(*projectID), auth.ActionEdit)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return repository.ErrUnknownProject
+	}
+	return err
+}
+
+// checkParentUnchanged rejects updates that try to move the todo_task, a
+// nil parentID leaves the parent as it is.
+func checkParentUnchanged(current model.TodoTask, parentID *uint) error {
+	if parentID == nil || sameID(current.ParentID, parentID) {
+		return nil
+	}
+	return NewValidationError("the parent can not be updated", model.FieldError{
+		Field: "parent_id", Message: "can only be changed by moving the todo_task"})
+}
+
+// checkProjectUnchanged rejects updates that try to move the todo_task to
+// another project, a nil projectID leaves the project as it is.
+func checkProjectUnchanged(current model.TodoTask, projectID *uint) error {
+	if projectID == nil || sameID(current.ProjectID, projectID) {
+		return nil
+	}
+	return NewValidationError("the project can not be updated", model.FieldError{
+		Field: "project_id", Message: "can only be changed by moving the todo_task to another project"})
+}
+
+// sameID compares two optional ids, such as parent or project ids.
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// deleteTodoTask moves the todo_task to the trash through repo and deals
+// with its subtasks according to the delete policy.
+func (s todoTaskService) deleteTodoTask(ctx *gin.Context, repo repository.TodoTaskRepository, id string, versions []uint) error {
+	return s.removeTodoTask(ctx, repo, id, versions, func(id string, versions []uint) error {
+		return repo.DeleteTodoTask(ctx, id, versions)
+	})
+}
+
+// removeTodoTask removes the todo_task with remove, which either trashes
+// or purges it. With SubtaskCascade all of its live subtasks are removed
+// the same way, otherwise its subtasks become top-level todo_tasks. Every
+// todo_task removed takes the delete action.
+func (s todoTaskService) removeTodoTask(ctx *gin.Context, repo repository.TodoTaskRepository, id string, versions []uint, remove func(id string, versions []uint) error) error {
+	// A todo_task the caller has no role on is left to remove, which does
+	// not find it either
+	if err := authorizeTodoTask(ctx, repo, id, auth.ActionDelete); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if s.deletePolicy != model.SubtaskCascade {
+		if err := remove(id, versions); err != nil {
+			return err
+		}
+		_, err := repo.OrphanSubtasks(ctx, id)
+		return err
+	}
+
+	// A trashed todo_task has no live subtree, only the todo_task is removed
+	subtree, err := repo.GetTodoTaskTree(ctx, id)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	for i := 1; i < len(subtree); i++ {
+		if err := authorizeTodoTask(ctx, repo, formatID(subtree[i].ID), auth.ActionDelete); err != nil {
+			return err
+		}
+	}
+	if err := remove(id, versions); err != nil {
+		return err
+	}
+	for i := 1; i < len(subtree); i++ {
+		if err := remove(strconv.FormatUint(uint64(subtree[i].ID), 10), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+// newTodoTaskTree stores root > a > a1 and root > b and returns the
+// service with their ids.
+func newTodoTaskTree(t *testing.T, policy model.SubtaskDeletePolicy) (*gin.Context, TodoTaskService, map[string]string) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodGet, "/todo_tasks/", nil)
+
+	db := GetSQLiteDBInstance(t)
+	s := NewTodoTaskService(repository.NewTodoTaskRepository(db), repository.NewStorage(db), nil, policy)
+
+	ids := map[string]string{}
+	add := func(title string, parent string, status model.TodoTaskStatus) {
+		payload := &model.TodoTaskPayload{Title: title, Description: "d", Status: status}
+		if parent != "" {
+			id, _ := strconv.ParseUint(ids[parent], 10, 64)
+			parentID := uint(id)
+			payload.ParentID = &parentID
+		}
+		todoTask, err := s.AddTodoTask(ctx, payload)
+		require.NoError(t, err)
+		ids[title] = strconv.Itoa(int(todoTask.ID))
+	}
+	add("root", "", model.StatusTodo)
+	add("a", "root", model.StatusInProgress)
+	add("a1", "a", model.StatusDone)
+	add("b", "root", model.StatusDone)
+	return ctx, s, ids
+}
+
+func TestTodoTaskTree(t *testing.T) {
+	ctx, s, ids := newTodoTaskTree(t, "")
+
+	tree, err := s.GetTodoTaskTree(ctx, ids["root"])
+	require.NoError(t, err)
+	assert.Equal(t, 3, tree.Descendants)
+	assert.Equal(t, 2, tree.DoneDescendants)
+	require.Len(t, tree.Children, 2)
+	assert.Equal(t, "a", tree.Children[0].Title)
+	assert.Equal(t, 1, tree.Children[0].Descendants)
+	assert.Equal(t, 1, tree.Children[0].DoneDescendants)
+	assert.Equal(t, "a1", tree.Children[0].Children[0].Title)
+	assert.Empty(t, tree.Children[1].Children)
+
+	// A todo_task can not be moved under itself or one of its subtasks
+	for _, parent := range []string{"root", "a1"} {
+		id, _ := strconv.ParseUint(ids[parent], 10, 64)
+		parentID := uint(id)
+		_, err = s.MoveTodoTask(ctx, ids["root"], nil, &parentID)
+		assert.True(t, errors.Is(err, ErrConflict), parent)
+	}
+	missing := uint(999)
+	_, err = s.MoveTodoTask(ctx, ids["a"], nil, &missing)
+	assert.True(t, errors.Is(err, ErrValidation))
+
+	id, _ := strconv.ParseUint(ids["b"], 10, 64)
+	parentID := uint(id)
+	moved, err := s.MoveTodoTask(ctx, ids["a1"], []uint{1}, &parentID)
+	require.NoError(t, err)
+	assert.Equal(t, &parentID, moved.ParentID)
+	assert.Equal(t, uint(2), moved.Version)
+	_, err = s.MoveTodoTask(ctx, ids["a1"], []uint{1}, nil)
+	assert.True(t, errors.Is(err, ErrPreconditionFailed))
+
+	tree, err = s.GetTodoTaskTree(ctx, ids["b"])
+	require.NoError(t, err)
+	assert.Equal(t, 1, tree.Descendants)
+
+	// Updates keep the parent, only a move changes it
+	_, err = s.PatchTodoTask(ctx, ids["a1"], nil, "application/merge-patch+json", []byte(`{"parent_id":null}`))
+	require.NoError(t, err)
+	_, err = s.PatchTodoTask(ctx, ids["a1"], nil, "application/merge-patch+json", []byte(`{"parent_id":`+ids["a"]+`}`))
+	assert.True(t, errors.Is(err, ErrValidation))
+}
+
+func TestDeleteTodoTaskWithSubtasks(t *testing.T) {
+	t.Run("orphan", func(t *testing.T) {
+		ctx, s, ids := newTodoTaskTree(t, model.SubtaskOrphan)
+
+		require.NoError(t, s.DeleteTodoTask(ctx, ids["root"], nil))
+		a, err := s.GetTodoTask(ctx, ids["a"])
+		require.NoError(t, err)
+		assert.Nil(t, a.ParentID)
+		tree, err := s.GetTodoTaskTree(ctx, ids["a"])
+		require.NoError(t, err)
+		assert.Equal(t, 1, tree.Descendants)
+	})
+
+	t.Run("cascade", func(t *testing.T) {
+		ctx, s, ids := newTodoTaskTree(t, model.SubtaskCascade)
+
+		require.NoError(t, s.DeleteTodoTask(ctx, ids["a"], nil))
+		for _, title := range []string{"a", "a1"} {
+			_, err := s.GetTodoTask(ctx, ids[title])
+			assert.True(t, errors.Is(err, ErrNotFound), title)
+		}
+		tree, err := s.GetTodoTaskTree(ctx, ids["root"])
+		require.NoError(t, err)
+		assert.Equal(t, 1, tree.Descendants)
+
+		require.NoError(t, s.PurgeTodoTask(ctx, ids["root"], nil))
+		_, err = s.GetTodoTask(ctx, ids["b"])
+		assert.True(t, errors.Is(err, ErrNotFound))
+		trash, err := s.GetTrashedTodoTasks(ctx, model.TodoTaskListParams{})
+		require.NoError(t, err)
+		require.Len(t, trash.Items, 2)
+		for _, item := range trash.Items {
+			assert.NotEqual(t, ids["root"], strconv.Itoa(int(item.ID)))
+		}
+	})
+}
+
+func TestRestoreTodoTaskIfMatch(t *testing.T) {
+	ctx, s, ids := newTodoTaskTree(t, model.SubtaskOrphan)
+
+	b, err := s.GetTodoTask(ctx, ids["b"])
+	require.NoError(t, err)
+	require.NoError(t, s.DeleteTodoTask(ctx, ids["b"], []uint{b.Version}))
+	trash, err := s.GetTrashedTodoTasks(ctx, model.TodoTaskListParams{})
+	require.NoError(t, err)
+	require.Len(t, trash.Items, 1)
+
+	_, err = s.RestoreTodoTask(ctx, ids["b"], []uint{trash.Items[0].Version + 1})
+	assert.True(t, errors.Is(err, ErrPreconditionFailed))
+	restored, err := s.RestoreTodoTask(ctx, ids["b"], []uint{trash.Items[0].Version})
+	require.NoError(t, err)
+	assert.Equal(t, trash.Items[0].Version+1, restored.Version)
+}
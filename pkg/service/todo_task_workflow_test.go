@@ -0,0 +1,72 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/patch"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+func TestTodoTaskWorkflow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/todo_tasks/1/transitions", nil)
+
+	db := GetSQLiteDBInstance(t)
+	s := NewTodoTaskService(repository.NewTodoTaskRepository(db), repository.NewStorage(db), nil, "")
+
+	created, err := s.AddTodoTask(ctx, &model.TodoTaskPayload{Title: "t", Description: "d"})
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusTodo, created.Status, "a status of false used to fail validation")
+
+	todoTask, err := s.TransitionTodoTask(ctx, "1", nil, model.StatusInProgress)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusInProgress, todoTask.Status)
+	assert.Equal(t, uint(2), todoTask.Version)
+
+	todoTask, err = s.TransitionTodoTask(ctx, "1", []uint{2}, model.StatusDone)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusDone, todoTask.Status)
+
+	_, err = s.TransitionTodoTask(ctx, "1", nil, model.StatusBlocked)
+	assert.True(t, errors.Is(err, ErrConflict), "done can not become blocked: %v", err)
+
+	_, err = s.TransitionTodoTask(ctx, "1", nil, "finished")
+	assert.True(t, errors.Is(err, ErrValidation))
+
+	// PUT and PATCH follow the same workflow
+	_, err = s.UpdateTodoTask(ctx, "1", nil, &model.TodoTaskPayload{Title: "t", Description: "d", Status: model.StatusBacklog})
+	assert.True(t, errors.Is(err, ErrConflict), "done can not become backlog: %v", err)
+
+	_, err = s.PatchTodoTask(ctx, "1", nil, patch.MergePatchContentType, []byte(`{"status":"cancelled"}`))
+	assert.True(t, errors.Is(err, ErrConflict), "done can not become cancelled: %v", err)
+
+	todoTask, err = s.PatchTodoTask(ctx, "1", nil, patch.MergePatchContentType, []byte(`{"status":"todo"}`))
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusTodo, todoTask.Status)
+}
+
+func TestParseTodoTaskWorkflow(t *testing.T) {
+	workflow, err := model.ParseTodoTaskWorkflow("")
+	require.NoError(t, err)
+	assert.Equal(t, model.DefaultTodoTaskWorkflow, workflow)
+
+	workflow, err = model.ParseTodoTaskWorkflow("todo:done|cancelled; done:todo")
+	require.NoError(t, err)
+	assert.True(t, workflow.CanTransition(model.StatusTodo, model.StatusDone))
+	assert.True(t, workflow.CanTransition(model.StatusDone, model.StatusDone))
+	assert.False(t, workflow.CanTransition(model.StatusTodo, model.StatusInProgress))
+	assert.False(t, workflow.CanTransition(model.StatusCancelled, model.StatusTodo))
+
+	for _, invalid := range []string{"todo", "todo:finished", "todo:done;todo:cancelled", "someday:todo"} {
+		_, err := model.ParseTodoTaskWorkflow(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+// TrashSweeper permanently deletes todo_tasks that have been in the trash
+// for longer than the retention period, and then the content of the
+// attachments detached from purged or deleted todo_tasks and the expired
+// idempotency keys.
+type TrashSweeper struct {
+	todoTaskRepository repository.TodoTaskRepository
+	attachmentService  AttachmentService
+	idempotencyService IdempotencyService
+	retention          time.Duration
+	interval           time.Duration
+	now                func() time.Time
+}
+
+// NewTrashSweeper builds a sweeper, attachmentService and
+// idempotencyService may be nil when there is nothing of theirs to clean up.
+func NewTrashSweeper(todoTaskRepository repository.TodoTaskRepository, attachmentService AttachmentService, idempotencyService IdempotencyService, retention, interval time.Duration) *TrashSweeper {
+	return &TrashSweeper{
+		todoTaskRepository: todoTaskRepository,
+		attachmentService:  attachmentService,
+		idempotencyService: idempotencyService,
+		retention:          retention,
+		interval:           interval,
+		now:                time.Now,
+	}
+}
+
+// Run sweeps once immediately and then every interval until ctx is done.
+// It returns right away when the interval is not positive, or when there
+// is neither a retention nor attachments or idempotency keys to sweep.
+func (s *TrashSweeper) Run(ctx context.Context) {
+	logger := contextLogger.ContextLog(ctx)
+	if s.interval <= 0 || (s.retention <= 0 && s.attachmentService == nil && s.idempotencyService == nil) {
+		logger.Info().Msg("trash sweeper disabled")
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Sweep(ctx); err != nil {
+			logger.Error().Err(err).Msg("Fail to sweep trash")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep purges the todo_tasks trashed before now minus the retention and
+// returns how many were removed. Trashed todo_tasks are kept when the
+// retention is not positive.
+func (s *TrashSweeper) Sweep(ctx context.Context) (int64, error) {
+	var purged int64
+	if s.retention > 0 {
+		var err error
+		if purged, err = s.todoTaskRepository.PurgeTrashedTodoTasks(ctx, s.now().Add(-s.retention)); err != nil {
+			return 0, err
+		}
+	}
+	if s.attachmentService != nil {
+		attachments, err := s.attachmentService.PurgeDetachedAttachments(ctx)
+		if err != nil {
+			return purged, err
+		}
+		contextLogger.ContextLog(ctx).Info().Int64("count", attachments).Msg("Detached attachments purged")
+	}
+	if s.idempotencyService != nil {
+		keys, err := s.idempotencyService.PurgeExpiredIdempotencyKeys(ctx)
+		if err != nil {
+			return purged, err
+		}
+		contextLogger.ContextLog(ctx).Info().Int64("count", keys).Msg("Expired idempotency_keys purged")
+	}
+	return purged, nil
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+	"gorm.io/gorm"
+)
+
+func TestTrashSweeperSweep(t *testing.T) {
+	db := GetSQLiteDBInstance(t)
+
+	now := time.Now()
+	tasks := []model.TodoTask{
+		{Title: "live", Description: "d", Status: model.StatusTodo},
+		{Title: "old", Description: "d", Status: model.StatusTodo, Model: gorm.Model{DeletedAt: gorm.DeletedAt{Time: now.Add(-48 * time.Hour), Valid: true}}},
+		{Title: "recent", Description: "d", Status: model.StatusTodo, Model: gorm.Model{DeletedAt: gorm.DeletedAt{Time: now.Add(-time.Hour), Valid: true}}},
+	}
+	assert.NoError(t, db.Create(&tasks).Error)
+	keys := []model.IdempotencyKey{
+		{UserID: 1, WorkspaceID: 1, Key: "expired", Fingerprint: "fp", ExpiresAt: now.Add(-time.Minute)},
+		{UserID: 2, WorkspaceID: 1, Key: "live", Fingerprint: "fp", ExpiresAt: now.Add(time.Minute)},
+	}
+	assert.NoError(t, db.Create(&keys).Error)
+
+	idempotency := NewIdempotencyService(repository.NewIdempotencyKeyRepository(db), time.Hour).(idempotencyService)
+	idempotency.now = func() time.Time { return now }
+	sweeper := NewTrashSweeper(repository.NewTodoTaskRepository(db), nil, idempotency, 24*time.Hour, time.Hour)
+	sweeper.now = func() time.Time { return now }
+
+	purged, err := sweeper.Sweep(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	var titles []string
+	assert.NoError(t, db.Unscoped().Model(&model.TodoTask{}).Order("id").Pluck("title", &titles).Error)
+	assert.Equal(t, []string{"live", "recent"}, titles)
+
+	var names []string
+	assert.NoError(t, db.Model(&model.IdempotencyKey{}).Pluck("key", &names).Error)
+	assert.Equal(t, []string{"live"}, names)
+}
@@ -0,0 +1,319 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+	"gorm.io/gorm"
+)
+
+// LoginPolicy is how long sessions last and when failed logins lock an
+// account. Zero values use the defaults below.
+type LoginPolicy struct {
+	SessionTTL  time.Duration // 24 hours by default
+	MaxFailures int           // Failed logins in a row that lock the account, 5 by default
+	Lockout     time.Duration // How long a locked account refuses logins, 15 minutes by default
+}
+
+// UserService registers users and logs them in. A login returns a bearer
+// token that Authenticate resolves to the user on later requests, and so
+// do the personal access tokens the user creates for scripts.
+type UserService interface {
+	Register(ctx *gin.Context, credentials *model.Credentials) (model.User, error)
+	Login(ctx *gin.Context, credentials *model.Credentials) (model.LoginResult, error)
+	Logout(ctx *gin.Context, token string) error
+	Authenticate(ctx *gin.Context, token string) (auth.Principal, error)
+	CreateAccessToken(ctx *gin.Context, accessTokenPayload *model.AccessTokenPayload) (model.CreatedAccessToken, error)
+	GetAccessTokens(ctx *gin.Context) ([]model.AccessToken, error)
+	RevokeAccessToken(ctx *gin.Context, id string) (model.AccessToken, error)
+	// AssignLegacyOwner gives the default workspace and its rows older
+	// than accounts to the user registered with email. It is run at
+	// startup when LEGACY_OWNER_EMAIL is set.
+	AssignLegacyOwner(ctx context.Context, email string) error
+}
+
+// NewUserService builds the service. Passwords are hashed with
+// passwordParams, logins follow policy.
+func NewUserService(userRepository repository.UserRepository, passwordParams auth.PasswordParams, policy LoginPolicy) UserService {
+	if policy.SessionTTL <= 0 {
+		policy.SessionTTL = 24 * time.Hour
+	}
+	if policy.MaxFailures <= 0 {
+		policy.MaxFailures = 5
+	}
+	if policy.Lockout <= 0 {
+		policy.Lockout = 15 * time.Minute
+	}
+	// Logins of unknown users check their password against this hash, so
+	// they take as long as the logins of known users.
+	dummyHash, err := auth.HashPassword("", passwordParams)
+	if err != nil {
+		panic(err)
+	}
+	return userService{
+		userRepository: userRepository,
+		passwordParams: passwordParams,
+		policy:         policy,
+		dummyHash:      dummyHash,
+	}
+}
+
+type userService struct {
+	userRepository repository.UserRepository
+	passwordParams auth.PasswordParams
+	policy         LoginPolicy
+	dummyHash      string
+}
+
+// errInvalidCredentials does not tell whether the email or the password
+// was wrong.
+var errInvalidCredentials = NewError(ErrUnauthorized, "the email or the password is wrong", nil)
+
+func (s userService) Register(ctx *gin.Context, credentials *model.Credentials) (model.User, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	credentials.Email = model.NormalizeEmail(credentials.Email)
+	if err := credentials.ValidateCredentials(); err != nil {
+		logger.Info().Err(err).Msg("Registration fails validation")
+		return model.User{}, translateError(err)
+	}
+	hash, err := auth.HashPassword(credentials.Password, s.passwordParams)
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to hash password")
+		return model.User{}, translateError(err)
+	}
+
+	user := model.User{Email: credentials.Email, PasswordHash: hash}
+	if err := s.userRepository.CreateUser(ctx, &user); err != nil {
+		logger.Error().Err(err).Msg("Fail to create user")
+		return model.User{}, translateError(err)
+	}
+	logger.Info().Uint("user_id", user.ID).Msg("Successfully registered user")
+	return user, nil
+}
+
+// Login checks the credentials and opens a session. Every failed login of
+// a known user counts towards locking the account.
+func (s userService) Login(ctx *gin.Context, credentials *model.Credentials) (model.LoginResult, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	var fields []model.FieldError
+	if credentials.Email == "" {
+		fields = append(fields, model.FieldError{Field: "email", Message: "is required"})
+	}
+	if credentials.Password == "" {
+		fields = append(fields, model.FieldError{Field: "password", Message: "is required"})
+	}
+	if fields != nil {
+		return model.LoginResult{}, NewValidationError("one or more fields are invalid", fields...)
+	}
+
+	user, err := s.userRepository.GetUserByEmail(ctx, model.NormalizeEmail(credentials.Email))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		_, _ = auth.VerifyPassword(s.dummyHash, credentials.Password)
+		logger.Info().Msg("Login of unknown user")
+		return model.LoginResult{}, errInvalidCredentials
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get user")
+		return model.LoginResult{}, translateError(err)
+	}
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		logger.Info().Uint("user_id", user.ID).Msg("Login of locked user")
+		return model.LoginResult{}, NewError(ErrTooManyRequests, "the account is locked after too many failed logins, try again later", nil)
+	}
+
+	ok, err := auth.VerifyPassword(user.PasswordHash, credentials.Password)
+	if err != nil {
+		logger.Error().Err(err).Uint("user_id", user.ID).Msg("Fail to verify password")
+		return model.LoginResult{}, translateError(err)
+	}
+	if !ok {
+		if err := s.userRepository.RecordFailedLogin(ctx, user.ID, s.policy.MaxFailures, s.policy.Lockout); err != nil {
+			return model.LoginResult{}, translateError(err)
+		}
+		logger.Info().Uint("user_id", user.ID).Msg("Login with wrong password")
+		return model.LoginResult{}, errInvalidCredentials
+	}
+	if err := s.userRepository.RecordSuccessfulLogin(ctx, user.ID); err != nil {
+		return model.LoginResult{}, translateError(err)
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return model.LoginResult{}, translateError(err)
+	}
+	session := model.Session{
+		UserID:    user.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().UTC().Add(s.policy.SessionTTL),
+	}
+	if err := s.userRepository.CreateSession(ctx, &session); err != nil {
+		logger.Error().Err(err).Msg("Fail to create session")
+		return model.LoginResult{}, translateError(err)
+	}
+	logger.Info().Uint("user_id", user.ID).Msg("Successfully logged in user")
+	return model.LoginResult{AccessToken: token, TokenType: "Bearer", ExpiresAt: session.ExpiresAt, User: user}, nil
+}
+
+func (s userService) Logout(ctx *gin.Context, token string) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	if err := s.userRepository.DeleteSession(ctx, hashToken(token)); err != nil {
+		logger.Error().Err(err).Msg("Fail to log out")
+		return translateError(err)
+	}
+	logger.Info().Msg("Successfully logged out")
+	return nil
+}
+
+// Authenticate returns the principal of the session or the personal
+// access token the token belongs to.
+func (s userService) Authenticate(ctx *gin.Context, token string) (auth.Principal, error) {
+	if strings.HasPrefix(token, accessTokenPrefix) {
+		accessToken, err := s.userRepository.UseAccessToken(ctx, hashToken(token))
+		if err == nil {
+			return auth.Principal{UserID: accessToken.UserID, Scopes: append([]string{}, accessToken.Scopes...)}, nil
+		}
+		// A session token may start with the prefix by chance
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return auth.Principal{}, translateError(err)
+		}
+	}
+
+	user, err := s.userRepository.GetSessionUser(ctx, hashToken(token))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return auth.Principal{}, NewError(ErrUnauthorized, "the access token is invalid or expired", err)
+	}
+	if err != nil {
+		return auth.Principal{}, translateError(err)
+	}
+	return auth.Principal{UserID: user.ID}, nil
+}
+
+// accessTokenPrefix starts every personal access token, so that secret
+// scanners can recognize leaked tokens.
+const accessTokenPrefix = "pat_"
+
+// CreateAccessToken creates a personal access token of the caller. The
+// token is only returned here, it can not be read again.
+func (s userService) CreateAccessToken(ctx *gin.Context, accessTokenPayload *model.AccessTokenPayload) (model.CreatedAccessToken, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	principal, err := accountOwner(ctx)
+	if err != nil {
+		return model.CreatedAccessToken{}, err
+	}
+	if err := accessTokenPayload.ValidateAccessTokenPayload(time.Now()); err != nil {
+		logger.Info().Err(err).Msg("AccessToken fails validation")
+		return model.CreatedAccessToken{}, translateError(err)
+	}
+
+	secret, err := newToken()
+	if err != nil {
+		return model.CreatedAccessToken{}, translateError(err)
+	}
+	token := accessTokenPrefix + secret
+	accessToken := model.AccessToken{
+		UserID:    principal.UserID,
+		Name:      accessTokenPayload.Name,
+		Prefix:    token[:len(accessTokenPrefix)+8],
+		TokenHash: hashToken(token),
+		Scopes:    accessTokenPayload.Scopes,
+		ExpiresAt: accessTokenPayload.ExpiresAt,
+	}
+	if err := s.userRepository.CreateAccessToken(ctx, &accessToken); err != nil {
+		logger.Error().Err(err).Msg("Fail to create access_token")
+		return model.CreatedAccessToken{}, translateError(err)
+	}
+	logger.Info().Uint("access_token_id", accessToken.ID).Msg("Successfully created access_token")
+	return model.CreatedAccessToken{AccessToken: accessToken, Token: token}, nil
+}
+
+func (s userService) GetAccessTokens(ctx *gin.Context) ([]model.AccessToken, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	principal, err := accountOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+	accessTokens, err := s.userRepository.GetAccessTokens(ctx, principal.UserID)
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get access_tokens")
+		return nil, translateError(err)
+	}
+	return accessTokens, nil
+}
+
+func (s userService) RevokeAccessToken(ctx *gin.Context, id string) (model.AccessToken, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	principal, err := accountOwner(ctx)
+	if err != nil {
+		return model.AccessToken{}, err
+	}
+	accessToken, err := s.userRepository.RevokeAccessToken(ctx, principal.UserID, id)
+	if err != nil {
+		logger.Error().Err(err).Str("access_token_id", id).Msg("Fail to revoke access_token")
+		return model.AccessToken{}, translateError(err)
+	}
+	logger.Info().Str("access_token_id", id).Msg("Successfully revoked access_token")
+	return accessToken, nil
+}
+
+func (s userService) AssignLegacyOwner(ctx context.Context, email string) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	user, err := s.userRepository.GetUserByEmail(ctx, model.NormalizeEmail(email))
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get legacy owner")
+		return translateError(err)
+	}
+	if err := s.userRepository.AssignLegacyOwner(ctx, user.ID); err != nil {
+		logger.Error().Err(err).Uint("user_id", user.ID).Msg("Fail to assign legacy rows")
+		return translateError(err)
+	}
+	logger.Info().Uint("user_id", user.ID).Msg("Successfully assigned legacy rows")
+	return nil
+}
+
+// accountOwner returns the caller if it may manage the account. Personal
+// access tokens may not, or a read-only token could create a token that
+// writes.
+func accountOwner(ctx *gin.Context) (auth.Principal, error) {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return auth.Principal{}, NewError(ErrUnauthorized, "an access token is required", nil)
+	}
+	if principal.Restricted() {
+		return auth.Principal{}, NewError(ErrForbidden, "personal access tokens can not manage access tokens", nil)
+	}
+	return principal, nil
+}
+
+// newToken returns 256 random bits, URL safe.
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how session and access tokens are stored. They are random,
+// so a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+// testPasswordParams keep the tests fast, they are far too weak for real
+// passwords.
+var testPasswordParams = auth.PasswordParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestUsers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/auth/login", nil)
+
+	db := GetSQLiteDBInstance(t)
+	s := NewUserService(repository.NewUserRepository(db), testPasswordParams, LoginPolicy{MaxFailures: 3, Lockout: time.Hour})
+
+	fieldOf := func(err error) string {
+		var domainErr *Error
+		require.True(t, errors.As(err, &domainErr), err)
+		require.Equal(t, ErrValidation, domainErr.Kind)
+		return domainErr.Fields[0].Field
+	}
+
+	// The password policy
+	for password, field := range map[string]string{
+		"short":                   "password",
+		"aaaaaaaaaaaaaaaa":        "password",
+		"Password1234":            "password",
+		"my-name-is-ada-lovelace": "password",
+	} {
+		_, err := s.Register(ctx, &model.Credentials{Email: "ada@example.com", Password: password})
+		assert.Equal(t, field, fieldOf(err), password)
+	}
+	_, err := s.Register(ctx, &model.Credentials{Email: "not an email", Password: "violet tulip harbor"})
+	assert.Equal(t, "email", fieldOf(err))
+
+	user, err := s.Register(ctx, &model.Credentials{Email: " Ada@Example.com ", Password: "violet tulip harbor"})
+	require.NoError(t, err)
+	assert.Equal(t, "ada@example.com", user.Email)
+	assert.NotContains(t, user.PasswordHash, "violet")
+	_, err = s.Register(ctx, &model.Credentials{Email: "ADA@example.com", Password: "another good one"})
+	assert.True(t, errors.Is(err, ErrConflict))
+
+	// Logging in
+	result, err := s.Login(ctx, &model.Credentials{Email: "ADA@example.com", Password: "violet tulip harbor"})
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", result.TokenType)
+	assert.Equal(t, user.ID, result.User.ID)
+	principal, err := s.Authenticate(ctx, result.AccessToken)
+	require.NoError(t, err)
+	assert.Equal(t, user.ID, principal.UserID)
+	_, err = s.Authenticate(ctx, result.AccessToken+"x")
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+
+	_, err = s.Login(ctx, &model.Credentials{Email: "nobody@example.com", Password: "violet tulip harbor"})
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	_, err = s.Login(ctx, &model.Credentials{Email: "ada@example.com"})
+	assert.Equal(t, "password", fieldOf(err))
+
+	require.NoError(t, s.Logout(ctx, result.AccessToken))
+	_, err = s.Authenticate(ctx, result.AccessToken)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+
+	// A success resets the failures, the third failure in a row locks the
+	// account even for the right password
+	wrong := &model.Credentials{Email: "ada@example.com", Password: "wrong password!"}
+	_, err = s.Login(ctx, wrong)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+	_, err = s.Login(ctx, &model.Credentials{Email: "ada@example.com", Password: "violet tulip harbor"})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = s.Login(ctx, wrong)
+		assert.True(t, errors.Is(err, ErrUnauthorized))
+	}
+	_, err = s.Login(ctx, &model.Credentials{Email: "ada@example.com", Password: "violet tulip harbor"})
+	assert.True(t, errors.Is(err, ErrTooManyRequests))
+
+	// The lockout ends
+	require.NoError(t, db.Model(&model.User{}).Where("id = ?", user.ID).Update("locked_until", time.Now().Add(-time.Minute)).Error)
+	_, err = s.Login(ctx, &model.Credentials{Email: "ada@example.com", Password: "violet tulip harbor"})
+	require.NoError(t, err)
+}
+
+func TestTodoTasksOfOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := GetSQLiteDBInstance(t)
+	todoTasks := NewTodoTaskService(repository.NewTodoTaskRepository(db), repository.NewStorage(db), nil, "")
+	projects := NewProjectService(repository.NewProjectRepository(db), repository.NewTodoTaskRepository(db))
+
+	as := func(userID uint) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/todo_tasks", nil)
+		ctx.Request = ctx.Request.WithContext(auth.WithPrincipal(ctx.Request.Context(), auth.Principal{UserID: userID}))
+		return ctx
+	}
+	ada, bob := as(1), as(2)
+
+	project, err := projects.AddProject(ada, &model.ProjectPayload{Name: "Home"})
+	require.NoError(t, err)
+	todoTask, err := todoTasks.AddTodoTask(ada, &model.TodoTaskPayload{Title: "dishes", Description: "d", Status: model.StatusTodo, ProjectID: &project.ID})
+	require.NoError(t, err)
+	require.NotNil(t, todoTask.OwnerID)
+	assert.Equal(t, uint(1), *todoTask.OwnerID)
+	id := strconv.Itoa(int(todoTask.ID))
+
+	// Other users can not tell the todo_task exists
+	_, err = todoTasks.GetTodoTask(bob, id)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = todoTasks.UpdateTodoTask(bob, id, nil, &model.TodoTaskPayload{Title: "mine", Description: "d", Status: model.StatusTodo})
+	assert.True(t, errors.Is(err, ErrNotFound))
+	require.NoError(t, todoTasks.DeleteTodoTask(bob, id, nil))
+	_, err = todoTasks.GetTodoTask(ada, id)
+	require.NoError(t, err)
+	_, err = todoTasks.AddTodoTask(bob, &model.TodoTaskPayload{Title: "sub", Description: "d", Status: model.StatusTodo, ParentID: &todoTask.ID})
+	assert.True(t, errors.Is(err, ErrValidation))
+	_, err = todoTasks.AddTodoTask(bob, &model.TodoTaskPayload{Title: "in", Description: "d", Status: model.StatusTodo, ProjectID: &project.ID})
+	assert.True(t, errors.Is(err, ErrValidation))
+	_, err = projects.GetProject(bob, strconv.Itoa(int(project.ID)))
+	assert.True(t, errors.Is(err, ErrNotFound))
+
+	page, err := todoTasks.GetTodoTasks(bob, model.TodoTaskListParams{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+	results, err := todoTasks.SearchTodoTasks(bob, "dishes", 0)
+	require.NoError(t, err)
+	assert.Empty(t, results.Items)
+	projectPage, err := projects.GetProjects(bob, model.ProjectListParams{})
+	require.NoError(t, err)
+	assert.Empty(t, projectPage.Items)
+
+	page, err = todoTasks.GetTodoTasks(ada, model.TodoTaskListParams{})
+	require.NoError(t, err)
+	assert.Len(t, page.Items, 1)
+	results, err = todoTasks.SearchTodoTasks(ada, "dishes", 0)
+	require.NoError(t, err)
+	assert.Len(t, results.Items, 1)
+	require.NoError(t, todoTasks.DeleteTodoTask(ada, id, nil))
+}
+
+func TestLegacyTodoTasksOwner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := GetSQLiteDBInstance(t)
+	require.NoError(t, repository.ScopeByWorkspace(db))
+	// A database upgraded before anyone registered: the default workspace
+	// and a todo_task older than accounts, both without an owner
+	require.NoError(t, db.Create(&model.Workspace{ID: model.DefaultWorkspaceID, Name: "Default"}).Error)
+	require.NoError(t, db.Create(&model.TodoTask{Title: "legacy", Description: "d", Status: model.StatusTodo, WorkspaceID: model.DefaultWorkspaceID}).Error)
+	users := NewUserService(repository.NewUserRepository(db), testPasswordParams, LoginPolicy{})
+	todoTasks := NewTodoTaskService(repository.NewTodoTaskRepository(db), repository.NewStorage(db), nil, "")
+
+	in := func(userID, workspaceID uint) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/todo_tasks", nil)
+		ctx.Request = ctx.Request.WithContext(auth.WithPrincipal(ctx.Request.Context(), auth.Principal{UserID: userID, WorkspaceID: workspaceID}))
+		return ctx
+	}
+	ada, err := users.Register(in(0, 0), &model.Credentials{Email: "ada@example.com", Password: "violet tulip harbor"})
+	require.NoError(t, err)
+	bob, err := users.Register(in(0, 0), &model.Credentials{Email: "bob@example.com", Password: "amber falcon meadow"})
+	require.NoError(t, err)
+
+	// Registering does not give them to anyone
+	page, err := todoTasks.GetTodoTasks(in(ada.ID, model.DefaultWorkspaceID), model.TodoTaskListParams{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+	var workspace model.Workspace
+	require.NoError(t, db.First(&workspace, model.DefaultWorkspaceID).Error)
+	assert.Nil(t, workspace.OwnerID)
+
+	// The operator does
+	assert.True(t, errors.Is(users.AssignLegacyOwner(context.Background(), "nobody@example.com"), ErrNotFound))
+	for i := 0; i < 2; i++ {
+		require.NoError(t, users.AssignLegacyOwner(context.Background(), " Ada@example.com"))
+	}
+	assert.True(t, errors.Is(users.AssignLegacyOwner(context.Background(), "bob@example.com"), ErrConflict))
+
+	page, err = todoTasks.GetTodoTasks(in(ada.ID, model.DefaultWorkspaceID), model.TodoTaskListParams{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	require.NotNil(t, page.Items[0].OwnerID)
+	assert.Equal(t, ada.ID, *page.Items[0].OwnerID)
+	id := strconv.Itoa(int(page.Items[0].ID))
+	_, err = todoTasks.UpdateTodoTask(in(ada.ID, model.DefaultWorkspaceID), id, nil, &model.TodoTaskPayload{Title: "mine", Description: "d", Status: model.StatusInProgress})
+	require.NoError(t, err)
+
+	_, err = todoTasks.GetTodoTask(in(bob.ID, model.DefaultWorkspaceID), id)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	require.NoError(t, db.First(&workspace, model.DefaultWorkspaceID).Error)
+	require.NotNil(t, workspace.OwnerID)
+	assert.Equal(t, ada.ID, *workspace.OwnerID)
+	var members int64
+	require.NoError(t, db.Model(&model.WorkspaceMember{}).Where("workspace_id = ?", model.DefaultWorkspaceID).Count(&members).Error)
+	assert.Equal(t, int64(1), members)
+}
+
+func TestAccessTokens(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := GetSQLiteDBInstance(t)
+	s := NewUserService(repository.NewUserRepository(db), testPasswordParams, LoginPolicy{})
+
+	as := func(principal auth.Principal) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodPost, "/auth/tokens", nil)
+		ctx.Request = ctx.Request.WithContext(auth.WithPrincipal(ctx.Request.Context(), principal))
+		return ctx
+	}
+	ada, bob := as(auth.Principal{UserID: 1}), as(auth.Principal{UserID: 2})
+
+	past, future := time.Now().Add(-time.Hour), time.Now().Add(time.Hour)
+	for _, invalid := range []model.AccessTokenPayload{
+		{Name: " ", Scopes: []string{auth.ScopeTasksRead}},
+		{Name: "ci", Scopes: []string{}},
+		{Name: "ci", Scopes: []string{"tasks:admin"}},
+		{Name: "ci", Scopes: []string{auth.ScopeTasksRead}, ExpiresAt: &past},
+	} {
+		_, err := s.CreateAccessToken(ada, &invalid)
+		assert.True(t, errors.Is(err, ErrValidation), invalid)
+	}
+
+	created, err := s.CreateAccessToken(ada, &model.AccessTokenPayload{
+		Name: "ci", Scopes: []string{auth.ScopeTasksWrite, auth.ScopeTasksRead, auth.ScopeTasksRead}, ExpiresAt: &future})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(created.Token, "pat_"))
+	assert.True(t, strings.HasPrefix(created.Token, created.Prefix))
+	assert.Equal(t, model.Scopes{auth.ScopeTasksRead, auth.ScopeTasksWrite}, created.Scopes)
+
+	// The token authenticates with its scopes, its use is recorded
+	principal, err := s.Authenticate(ada, created.Token)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), principal.UserID)
+	assert.True(t, principal.HasScope(auth.ScopeTasksWrite))
+	assert.False(t, principal.HasScope(auth.ScopeTasksDelete))
+	tokens, err := s.GetAccessTokens(ada)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	require.NotNil(t, tokens[0].LastUsedAt)
+	listed, err := json.Marshal(tokens)
+	require.NoError(t, err)
+	assert.NotContains(t, string(listed), tokens[0].TokenHash)
+
+	// A token can not manage tokens, or it could create one with more scopes
+	_, err = s.CreateAccessToken(as(principal), &model.AccessTokenPayload{Name: "more", Scopes: auth.Scopes})
+	assert.True(t, errors.Is(err, ErrForbidden))
+
+	_, err = s.RevokeAccessToken(bob, strconv.Itoa(int(created.ID)))
+	assert.True(t, errors.Is(err, ErrNotFound))
+	revoked, err := s.RevokeAccessToken(ada, strconv.Itoa(int(created.ID)))
+	require.NoError(t, err)
+	require.NotNil(t, revoked.RevokedAt)
+	_, err = s.Authenticate(ada, created.Token)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+
+	// Expired tokens stop working
+	soon, err := s.CreateAccessToken(ada, &model.AccessTokenPayload{Name: "soon", Scopes: []string{auth.ScopeTasksRead}, ExpiresAt: &future})
+	require.NoError(t, err)
+	require.NoError(t, db.Model(&model.AccessToken{}).Where("id = ?", soon.ID).Update("expires_at", past).Error)
+	_, err = s.Authenticate(ada, soon.Token)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+}
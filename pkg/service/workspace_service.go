@@ -0,0 +1,201 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/vkuzmich/gin-project/internal/contextLogger"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+	"gorm.io/gorm"
+)
+
+// WorkspaceService manages the workspaces of the caller and tells which
+// workspace a request is made in. Only the owner of a workspace adds and
+// removes its members, members may leave on their own.
+type WorkspaceService interface {
+	// ResolveWorkspace returns the workspace of the request: the one the
+	// access token is bound to, else the requested one, else the oldest
+	// workspace of the caller. The caller must be a member of it.
+	ResolveWorkspace(ctx *gin.Context, requested string) (uint, error)
+	GetWorkspaces(ctx *gin.Context) (model.WorkspaceList, error)
+	AddWorkspace(ctx *gin.Context, workspacePayload *model.WorkspacePayload) (model.Workspace, error)
+	GetWorkspaceMembers(ctx *gin.Context, id string) (model.WorkspaceMemberList, error)
+	AddWorkspaceMember(ctx *gin.Context, id string, memberPayload *model.WorkspaceMemberPayload) (model.WorkspaceMember, error)
+	RemoveWorkspaceMember(ctx *gin.Context, id string, userID string) error
+}
+
+func NewWorkspaceService(workspaceRepository repository.WorkspaceRepository, userRepository repository.UserRepository) WorkspaceService {
+	return workspaceService{
+		workspaceRepository,
+		userRepository,
+	}
+}
+
+type workspaceService struct {
+	workspaceRepository repository.WorkspaceRepository
+	userRepository      repository.UserRepository
+}
+
+func (s workspaceService) ResolveWorkspace(ctx *gin.Context, requested string) (uint, error) {
+	logger := contextLogger.ContextLog(ctx)
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return 0, NewError(ErrUnauthorized, "an access token is required", nil)
+	}
+
+	workspaceID := principal.WorkspaceID
+	if requested != "" {
+		parsed, err := strconv.ParseUint(requested, 10, 64)
+		if err != nil || parsed == 0 {
+			return 0, NewError(ErrValidation, "the workspace must be given by its id", err)
+		}
+		if workspaceID != 0 && uint(parsed) != workspaceID {
+			return 0, NewError(ErrForbidden, fmt.Sprintf("the access token is bound to workspace %d", workspaceID), nil)
+		}
+		workspaceID = uint(parsed)
+	}
+	if workspaceID == 0 {
+		memberOf, err := s.workspaceRepository.GetMemberWorkspaceID(ctx, principal.UserID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Requests outside of any workspace see no todo_task
+			return 0, nil
+		}
+		if err != nil {
+			logger.Error().Err(err).Msg("Fail to resolve workspace")
+			return 0, translateError(err)
+		}
+		return memberOf, nil
+	}
+
+	member, err := s.workspaceRepository.IsWorkspaceMember(ctx, workspaceID, principal.UserID)
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to resolve workspace")
+		return 0, translateError(err)
+	}
+	if !member {
+		logger.Info().Uint("workspace_id", workspaceID).Msg("Fail to resolve workspace, not a member")
+		return 0, NewError(ErrForbidden, fmt.Sprintf("you are not a member of workspace %d", workspaceID), nil)
+	}
+	return workspaceID, nil
+}
+
+func (s workspaceService) GetWorkspaces(ctx *gin.Context) (model.WorkspaceList, error) {
+	logger := contextLogger.ContextLog(ctx)
+	workspaces, err := s.workspaceRepository.GetWorkspaces(ctx)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get workspaces")
+		return model.WorkspaceList{}, translateError(err)
+	}
+	logger.Info().Int("count", len(workspaces)).Msg("Successfully get workspaces")
+	return model.WorkspaceList{Items: workspaces}, nil
+}
+
+func (s workspaceService) AddWorkspace(ctx *gin.Context, workspacePayload *model.WorkspacePayload) (model.Workspace, error) {
+	logger := contextLogger.ContextLog(ctx)
+	workspace, err := s.workspaceRepository.CreateWorkspace(ctx, workspacePayload)
+
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to create workspace")
+		return model.Workspace{}, translateError(err)
+	}
+	logger.Info().Uint("workspace_id", workspace.ID).Msg("Successfully create workspace")
+	return workspace, nil
+}
+
+func (s workspaceService) GetWorkspaceMembers(ctx *gin.Context, id string) (model.WorkspaceMemberList, error) {
+	logger := contextLogger.ContextLog(ctx)
+	members, err := s.workspaceRepository.GetWorkspaceMembers(ctx, id)
+
+	if err != nil {
+		logger.Error().Err(err).Str("workspace_id", id).Msg("Fail to get workspace members")
+		return model.WorkspaceMemberList{}, translateError(err)
+	}
+	logger.Info().Int("count", len(members)).Msg("Successfully get workspace members")
+	return model.WorkspaceMemberList{Items: members}, nil
+}
+
+// AddWorkspaceMember adds the user registered with the email of
+// memberPayload to the workspace.
+func (s workspaceService) AddWorkspaceMember(ctx *gin.Context, id string, memberPayload *model.WorkspaceMemberPayload) (model.WorkspaceMember, error) {
+	logger := contextLogger.ContextLog(ctx)
+
+	if _, err := s.ownedWorkspace(ctx, id); err != nil {
+		logger.Info().Err(err).Str("workspace_id", id).Msg("Fail to add workspace member")
+		return model.WorkspaceMember{}, translateError(err)
+	}
+	if err := memberPayload.ValidateWorkspaceMemberPayload(); err != nil {
+		logger.Info().Err(err).Msg("Fail to validate workspace member")
+		return model.WorkspaceMember{}, translateError(err)
+	}
+	user, err := s.userRepository.GetUserByEmail(ctx, memberPayload.Email)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.WorkspaceMember{}, NewValidationError("unknown user", model.FieldError{Field: "email", Message: "is not a registered user"})
+	}
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to get user")
+		return model.WorkspaceMember{}, translateError(err)
+	}
+
+	member, err := s.workspaceRepository.PutWorkspaceMember(ctx, id, user.ID)
+	if err != nil {
+		logger.Error().Err(err).Msg("Fail to add workspace member")
+		return model.WorkspaceMember{}, translateError(err)
+	}
+	logger.Info().Str("workspace_id", id).Uint("user_id", user.ID).Msg("Successfully add workspace member")
+	return member, nil
+}
+
+// RemoveWorkspaceMember removes a member from the workspace. Members may
+// always leave, except for the owner.
+func (s workspaceService) RemoveWorkspaceMember(ctx *gin.Context, id string, userID string) error {
+	logger := contextLogger.ContextLog(ctx)
+
+	user, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return translateError(gorm.ErrRecordNotFound)
+	}
+	principal, _ := auth.PrincipalFromContext(ctx)
+	var workspace model.Workspace
+	if uint64(principal.UserID) == user {
+		workspace, err = s.workspaceRepository.GetWorkspace(ctx, id)
+	} else {
+		workspace, err = s.ownedWorkspace(ctx, id)
+	}
+	if err != nil {
+		logger.Info().Err(err).Str("workspace_id", id).Msg("Fail to remove workspace member")
+		return translateError(err)
+	}
+	if ownedBy(workspace.OwnerID, uint(user)) {
+		return NewError(ErrConflict, "the owner of the workspace can not leave it", nil)
+	}
+
+	if err := s.workspaceRepository.DeleteWorkspaceMember(ctx, id, uint(user)); err != nil {
+		logger.Error().Err(err).Msg("Fail to remove workspace member")
+		return translateError(err)
+	}
+	logger.Info().Str("workspace_id", id).Str("user_id", userID).Msg("Successfully remove workspace member")
+	return nil
+}
+
+// ownedWorkspace returns the workspace id of the caller, failing with
+// ErrForbidden unless they own it.
+func (s workspaceService) ownedWorkspace(ctx *gin.Context, id string) (model.Workspace, error) {
+	workspace, err := s.workspaceRepository.GetWorkspace(ctx, id)
+	if err != nil {
+		return model.Workspace{}, err
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok && !ownedBy(workspace.OwnerID, principal.UserID) {
+		return model.Workspace{}, NewError(ErrForbidden, "only the owner of the workspace manages its members", nil)
+	}
+	return workspace, nil
+}
+
+// ownedBy tells whether ownerID is the user userID.
+func ownedBy(ownerID *uint, userID uint) bool {
+	return ownerID != nil && *ownerID == userID
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vkuzmich/gin-project/pkg/auth"
+	"github.com/vkuzmich/gin-project/pkg/model"
+	"github.com/vkuzmich/gin-project/pkg/repository"
+)
+
+func TestWorkspaces(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := GetSQLiteDBInstance(t)
+	require.NoError(t, repository.ScopeByWorkspace(db))
+	users := NewUserService(repository.NewUserRepository(db), testPasswordParams, LoginPolicy{})
+	workspaces := NewWorkspaceService(repository.NewWorkspaceRepository(db), repository.NewUserRepository(db))
+	todoTasks := NewTodoTaskService(repository.NewTodoTaskRepository(db), repository.NewStorage(db), nil, "")
+	projects := NewProjectService(repository.NewProjectRepository(db), repository.NewTodoTaskRepository(db))
+	tags := NewTagService(repository.NewTagRepository(db))
+
+	in := func(principal auth.Principal) *gin.Context {
+		ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+		ctx.Request = httptest.NewRequest(http.MethodGet, "/todo_tasks", nil)
+		ctx.Request = ctx.Request.WithContext(auth.WithPrincipal(ctx.Request.Context(), principal))
+		return ctx
+	}
+	ada, err := users.Register(in(auth.Principal{}), &model.Credentials{Email: "ada@example.com", Password: "violet tulip harbor"})
+	require.NoError(t, err)
+	bob, err := users.Register(in(auth.Principal{}), &model.Credentials{Email: "bob@example.com", Password: "amber falcon meadow"})
+	require.NoError(t, err)
+
+	// Every user starts in a personal workspace
+	personal, err := workspaces.ResolveWorkspace(in(auth.Principal{UserID: ada.ID}), "")
+	require.NoError(t, err)
+	bobs, err := workspaces.ResolveWorkspace(in(auth.Principal{UserID: bob.ID}), "")
+	require.NoError(t, err)
+	assert.NotEqual(t, personal, bobs)
+	_, err = workspaces.ResolveWorkspace(in(auth.Principal{UserID: ada.ID}), strconv.Itoa(int(bobs)))
+	assert.True(t, errors.Is(err, ErrForbidden))
+	_, err = workspaces.ResolveWorkspace(in(auth.Principal{UserID: ada.ID}), "home")
+	assert.True(t, errors.Is(err, ErrValidation))
+
+	team, err := workspaces.AddWorkspace(in(auth.Principal{UserID: ada.ID, WorkspaceID: personal}), &model.WorkspacePayload{Name: " Team "})
+	require.NoError(t, err)
+	assert.Equal(t, "Team", team.Name)
+	teamID := strconv.Itoa(int(team.ID))
+	list, err := workspaces.GetWorkspaces(in(auth.Principal{UserID: ada.ID}))
+	require.NoError(t, err)
+	assert.Len(t, list.Items, 2)
+
+	// A token bound to a workspace can not be used in another
+	_, err = workspaces.ResolveWorkspace(in(auth.Principal{UserID: ada.ID, WorkspaceID: personal}), teamID)
+	assert.True(t, errors.Is(err, ErrForbidden))
+	resolved, err := workspaces.ResolveWorkspace(in(auth.Principal{UserID: ada.ID, WorkspaceID: team.ID}), "")
+	require.NoError(t, err)
+	assert.Equal(t, team.ID, resolved)
+
+	adaAtHome := in(auth.Principal{UserID: ada.ID, WorkspaceID: personal})
+	adaInTeam := in(auth.Principal{UserID: ada.ID, WorkspaceID: team.ID})
+	bobInTeam := in(auth.Principal{UserID: bob.ID, WorkspaceID: team.ID})
+
+	// Only the owner manages the members
+	_, err = workspaces.AddWorkspaceMember(adaInTeam, teamID, &model.WorkspaceMemberPayload{Email: "BOB@example.com"})
+	require.NoError(t, err)
+	_, err = workspaces.AddWorkspaceMember(adaInTeam, teamID, &model.WorkspaceMemberPayload{Email: "nobody@example.com"})
+	assert.True(t, errors.Is(err, ErrValidation))
+	_, err = workspaces.AddWorkspaceMember(bobInTeam, teamID, &model.WorkspaceMemberPayload{Email: "ada@example.com"})
+	assert.True(t, errors.Is(err, ErrForbidden))
+	members, err := workspaces.GetWorkspaceMembers(bobInTeam, teamID)
+	require.NoError(t, err)
+	require.Len(t, members.Items, 2)
+	assert.Equal(t, "bob@example.com", members.Items[1].Email)
+	_, err = workspaces.GetWorkspaceMembers(bobInTeam, strconv.Itoa(int(personal)))
+	assert.True(t, errors.Is(err, ErrNotFound))
+	assert.True(t, errors.Is(workspaces.RemoveWorkspaceMember(bobInTeam, teamID, strconv.Itoa(int(ada.ID))), ErrForbidden))
+	assert.True(t, errors.Is(workspaces.RemoveWorkspaceMember(adaInTeam, teamID, strconv.Itoa(int(ada.ID))), ErrConflict))
+
+	// The same user sees nothing of one workspace from another
+	secretPayload := &model.TodoTaskPayload{Title: "secret", Description: "d", Status: model.StatusTodo, Tags: []string{"urgent", "private"}}
+	secret, err := todoTasks.AddTodoTask(adaAtHome, secretPayload)
+	require.NoError(t, err)
+	secretID := strconv.Itoa(int(secret.ID))
+	home, err := projects.AddProject(adaAtHome, &model.ProjectPayload{Name: "Home"})
+	require.NoError(t, err)
+	plan, err := todoTasks.AddTodoTask(adaInTeam, &model.TodoTaskPayload{Title: "plan", Description: "d", Status: model.StatusTodo, Tags: []string{"urgent"}})
+	require.NoError(t, err)
+	assert.Equal(t, team.ID, plan.WorkspaceID)
+
+	page, err := todoTasks.GetTodoTasks(adaInTeam, model.TodoTaskListParams{})
+	require.NoError(t, err)
+	require.Len(t, page.Items, 1)
+	assert.Equal(t, "plan", page.Items[0].Title)
+	_, err = todoTasks.GetTodoTask(adaInTeam, secretID)
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = todoTasks.UpdateTodoTask(adaInTeam, secretID, nil, &model.TodoTaskPayload{Title: "leak", Description: "d", Status: model.StatusTodo})
+	assert.True(t, errors.Is(err, ErrNotFound))
+	_, err = todoTasks.MoveTodoTaskToProject(adaInTeam, strconv.Itoa(int(plan.ID)), nil, &home.ID)
+	assert.True(t, errors.Is(err, ErrValidation), "the project of another workspace is unknown")
+	inHome := &model.TodoTaskPayload{Title: "plan", Description: "d", Status: model.StatusTodo, ProjectID: &home.ID}
+	_, err = todoTasks.AddTodoTask(adaInTeam, inHome)
+	assert.True(t, errors.Is(err, ErrValidation))
+	results, err := todoTasks.SearchTodoTasks(adaInTeam, "secret", 10)
+	require.NoError(t, err)
+	assert.Empty(t, results.Items)
+	teamTags, err := tags.GetTags(adaInTeam)
+	require.NoError(t, err)
+	require.Len(t, teamTags.Items, 1, "tag names are unique per workspace")
+	assert.NotEqual(t, secret.Tags[1].ID, teamTags.Items[0].ID)
+	_ = todoTasks.DeleteTodoTask(adaInTeam, secretID, nil)
+	_, err = todoTasks.GetTodoTask(adaAtHome, secretID)
+	assert.NoError(t, err, "deleting from another workspace does nothing")
+
+	// Members who leave lose access, requests outside of any workspace see
+	// nothing and create nothing
+	require.NoError(t, workspaces.RemoveWorkspaceMember(bobInTeam, teamID, strconv.Itoa(int(bob.ID))))
+	_, err = workspaces.ResolveWorkspace(in(auth.Principal{UserID: bob.ID}), teamID)
+	assert.True(t, errors.Is(err, ErrForbidden))
+	nowhere := in(auth.Principal{UserID: ada.ID})
+	page, err = todoTasks.GetTodoTasks(nowhere, model.TodoTaskListParams{})
+	require.NoError(t, err)
+	assert.Empty(t, page.Items)
+	_, err = todoTasks.AddTodoTask(nowhere, &model.TodoTaskPayload{Title: "t", Description: "d", Status: model.StatusTodo})
+	assert.True(t, errors.Is(err, ErrForbidden))
+}
@@ -0,0 +1,40 @@
+// Package storage keeps the content of attachments outside of the
+// database, behind the BlobStore interface.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrBlobNotFound is returned by BlobStore.Get when no content is stored
+// under the key.
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps opaque content under slash separated keys chosen by the
+// caller, such as "3f/3f2c...". Content is streamed in and out, it is
+// never held in memory as a whole.
+type BlobStore interface {
+	// Put stores the size bytes read from content under key, replacing
+	// what was stored there. checksum is the hex encoded SHA-256 of the
+	// content, the store rejects content that does not match it.
+	Put(ctx context.Context, key string, content io.Reader, size int64, checksum string) error
+	// Get opens the content stored under key. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the content stored under key. Deleting a missing
+	// key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// checkKey rejects keys that could escape the root of a store.
+func checkKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key ||
+		key == ".." || strings.HasPrefix(key, "../") {
+		return fmt.Errorf("storage: invalid key %q", key)
+	}
+	return nil
+}